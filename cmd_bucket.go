@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/urfave/cli"
+	"gorm.io/gorm"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func (cmd *Commands) bucketCommand() cli.Command {
+	return cli.Command{
+		Name:  "bucket",
+		Usage: "manage buckets on the storage nodes",
+		Subcommands: []cli.Command{
+			{
+				Name:  "ls",
+				Usage: "list buckets",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "prefix", Usage: "only list buckets with this prefix"},
+				},
+				Action: cmd.withConfig(runBucketList),
+			},
+			{
+				Name:      "mb",
+				Usage:     "make a bucket",
+				ArgsUsage: "<bucket>",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "owner", Usage: "user name of the owner, the bucket counts towards the owner's quota"},
+				},
+				Action: cmd.withConfig(runBucketMake),
+			},
+			{
+				Name:      "rb",
+				Usage:     "remove an empty bucket",
+				ArgsUsage: "<bucket>",
+				Flags: []cli.Flag{
+					cli.BoolFlag{Name: "force", Usage: "queue a job that deletes all objects in the bucket and then the bucket"},
+				},
+				Action: cmd.withConfig(runBucketRemove),
+			},
+			{
+				Name:      "resync-replication",
+				Usage:     "queue existing objects of a bucket for replication",
+				ArgsUsage: "<bucket>",
+				Action:    cmd.withConfig(runBucketResyncReplication),
+			},
+		},
+	}
+}
+
+func bucketArg(c *cli.Context) (string, error) {
+	if c.NArg() != 1 {
+		return "", fmt.Errorf("usage: %s %s", c.Command.FullName(), c.Command.ArgsUsage)
+	}
+	return c.Args().First(), nil
+}
+
+func runBucketList(c *cli.Context) error {
+	buckets, err := svc.NewMetadataSvc(dao.Init()).ListBuckets(context.Background(), c.String("prefix"), 0)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tREGION")
+	for _, bucket := range buckets {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", bucket.Name, bucket.CreationDate.Format(time.RFC3339), bucket.Region)
+	}
+	return w.Flush()
+}
+
+func runBucketMake(c *cli.Context) error {
+	name, err := bucketArg(c)
+	if err != nil {
+		return err
+	}
+	daoS := dao.Init()
+	bucket := types.BucketConfig{Name: name}
+	if owner := c.String("owner"); owner != "" {
+		user, err := svc.NewUserSvc(daoS).GetUserInfoByName(owner)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("user %s does not exist", owner)
+		}
+		if err != nil {
+			return fmt.Errorf("get user %s: %w", owner, err)
+		}
+		bucket.OwnerID, bucket.Owner = user.Id, user.UserName
+	}
+	if err = svc.NewMetadataSvc(daoS).CreateBucket(context.Background(), bucket); err != nil {
+		return err
+	}
+	fmt.Printf("bucket %s created\n", name)
+	return nil
+}
+
+func runBucketRemove(c *cli.Context) error {
+	name, err := bucketArg(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("force") {
+		job, err := svc.NewDeleteJobSvc(dao.Init()).CreateBucketJob(context.Background(), name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("delete job %d created, bucket %s will be removed after it is emptied\n", job.Id, name)
+		return nil
+	}
+	if err = svc.NewMetadataSvc(dao.Init()).DeleteBucket(context.Background(), name); err != nil {
+		return err
+	}
+	fmt.Printf("bucket %s removed\n", name)
+	return nil
+}
+
+func runBucketResyncReplication(c *cli.Context) error {
+	name, err := bucketArg(c)
+	if err != nil {
+		return err
+	}
+	report, err := svc.NewReplicationSvc(dao.Init()).Resync(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("bucket %s: %d objects scanned, %d queued for replication\n", name, report.Objects, report.Queued)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/svc"
+	"encoding/json"
+	"fmt"
+	"github.com/urfave/cli"
+)
+
+func (cmd *Commands) fsckCommand() cli.Command {
+	return cli.Command{
+		Name:  "fsck",
+		Usage: "compare metadata with the data on storage nodes",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "bucket", Usage: "only check this bucket"},
+			cli.BoolFlag{Name: "fix", Usage: "fix the inconsistencies found"},
+		},
+		Action: cmd.withConfig(withRedis(runFsck)),
+	}
+}
+
+// runFsck 执行一次对账并输出报告
+func runFsck(c *cli.Context) error {
+	report, err := svc.NewReconcileSvc(dao.Init()).Reconcile(context.Background(), svc.ReconcileOptions{
+		BucketName: c.String("bucket"),
+		Fix:        c.Bool("fix"),
+	})
+	if err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+	return nil
+}
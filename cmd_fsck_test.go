@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newS3Server 启动一个内存中的 S3 兼容服务作为存储节点
+func newS3Server(t *testing.T) (*s3mem.Backend, string) {
+	t.Helper()
+	backend := s3mem.New()
+	handler := gofakes3.New(backend).Server()
+	// minio-go 递归列出对象时带空的 delimiter 参数，gofakes3 会把它当作分隔符，列不出任何对象
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query(); query.Has("delimiter") && query.Get("delimiter") == "" {
+			query.Del("delimiter")
+			r.URL.RawQuery = query.Encode()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return backend, server.URL
+}
+
+func TestFsckFix(t *testing.T) {
+	rds := miniredis.RunT(t)
+	backend, url := newS3Server(t)
+	t.Cleanup(minIo.SetStorageNodes([]types.StorageNodeInfo{{ID: "node-1", Endpoint: url, Weight: 1}}))
+	if err := backend.CreateBucket("photos"); err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+	meta := map[string]string{"Last-Modified": time.Now().UTC().Format(http.TimeFormat)}
+	if _, err := backend.PutObject("photos", "orphan.txt", meta, strings.NewReader("orphan"), 6); err != nil {
+		t.Fatalf("put object: %v", err)
+	}
+
+	conn, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := conn.DB()
+	// 内存数据库只在同一个连接内可见
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	t.Cleanup(db.Set(conn))
+	if err = dao.Init().AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	prev := config.Get()
+	t.Cleanup(func() { config.Set(prev) })
+
+	err = NewCommands().app.Run([]string{"dos",
+		"--config", "./config/config-dev.yaml",
+		"--redis-addr", rds.Addr(),
+		"--mysql-dsn", "dos:password@tcp(127.0.0.1:3306)/dos",
+		"--minio-access-key", "test-access-key",
+		"--minio-secret-key", "test-secret-key",
+		"--jwt-secret", "a-long-enough-jwt-secret",
+		"fsck", "--bucket", "photos", "--fix",
+	})
+	if err != nil {
+		t.Fatalf("fsck --fix: %v", err)
+	}
+	adopted, err := dao.Init().MetadataNode.GetObjectMetadata(context.Background(), "photos", "orphan.txt")
+	if err != nil {
+		t.Fatalf("orphan not adopted: %v", err)
+	}
+	if adopted.Size != 6 {
+		t.Fatalf("adopted size = %d, want 6", adopted.Size)
+	}
+}
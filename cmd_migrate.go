@@ -0,0 +1,16 @@
+package main
+
+import (
+	"distributed-object-storage/pkg/db/dao"
+	"fmt"
+	"github.com/urfave/cli"
+)
+
+// runMigrate 创建或更新元数据表结构
+func runMigrate(c *cli.Context) error {
+	if err := dao.Init().AutoMigrate(); err != nil {
+		return fmt.Errorf("migrate failed: %w", err)
+	}
+	fmt.Println("migrate done")
+	return nil
+}
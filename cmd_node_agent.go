@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"github.com/urfave/cli"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func (cmd *Commands) nodeAgentCommand() cli.Command {
+	return cli.Command{
+		Name:  "node-agent",
+		Usage: "register a storage node in etcd and report its health and disk usage",
+		Flags: []cli.Flag{
+			cli.StringFlag{Name: "id", Usage: "storage node id, default hostname"},
+			cli.StringFlag{Name: "endpoint", Usage: "minio endpoint, e.g. http://127.0.0.1:9000"},
+			cli.StringFlag{Name: "zone", Usage: "availability zone label"},
+			cli.StringFlag{Name: "rack", Usage: "rack label"},
+			cli.Float64Flag{Name: "weight", Usage: "placement weight", Value: 1},
+			cli.StringFlag{Name: "data-dir", Usage: "minio data directory for disk usage reports"},
+			cli.IntFlag{Name: "interval", Usage: "report interval in seconds", Value: 10},
+			cli.IntFlag{Name: "ttl", Usage: "registration ttl in seconds, must be greater than interval", Value: 30},
+		},
+		Action: cmd.withConfig(runNodeAgent),
+	}
+}
+
+func runNodeAgent(c *cli.Context) error {
+	id := c.String("id")
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		id = hostname
+	}
+	agent, err := svc.NewNodeAgent(types.StorageNodeInfo{
+		ID:       id,
+		Endpoint: c.String("endpoint"),
+		Zone:     c.String("zone"),
+		Rack:     c.String("rack"),
+		Weight:   c.Float64("weight"),
+	}, c.String("data-dir"), time.Duration(c.Int("interval"))*time.Second, time.Duration(c.Int("ttl"))*time.Second)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Infof("node agent %s started", id)
+	return agent.Run(ctx)
+}
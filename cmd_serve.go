@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/controller"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/etcd"
+	"distributed-object-storage/pkg/db"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/metrics"
+	"distributed-object-storage/pkg/middleware"
+	"distributed-object-storage/pkg/tracing"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/service"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/syncer"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/swaggo/files"
+	"github.com/swaggo/gin-swagger"
+	"github.com/urfave/cli"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serve 启动 api 服务和后台同步任务，收到 SIGINT/SIGTERM 后优雅退出
+func (cmd *Commands) serve(c *cli.Context) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 后台任务使用独立的 context，在停止接收请求之后再取消
+	bgCtx, cancelBg := context.WithCancel(context.Background())
+	defer cancelBg()
+
+	shutdownTracing, err := tracing.Init(ctx, config.GetTracing())
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+
+	engine := gin.New()
+	// 通过 *gin.Context 读取 c.Request 的 context，svc 才能拿到请求的 span
+	engine.ContextWithFallback = true
+	engine.Use(middleware.RequestID(), middleware.AccessLog(), middleware.Recovery(),
+		middleware.CORS(), middleware.Tracing(), middleware.Metrics())
+	engine.NoRoute(func(c *gin.Context) {
+		service.AbortWithError(c, fmt.Errorf("%w: route %s %s", errors.ErrNotFound, c.Request.Method, c.Request.URL.Path))
+	})
+	//engine.Use(middwares.AuthMiddleware())
+	syncersDone, auditRecorder := initApp(bgCtx, engine)
+
+	addr := config.GetServer()
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", addr.Host, addr.Port),
+		Handler: engine,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Infof("Server starting on port %v", addr.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		// 监听失败等情况，同样需要停止后台任务并释放资源
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case <-ctx.Done():
+		log.Infof("received shutdown signal")
+	}
+	// 再次收到信号时直接退出
+	stop()
+	shutdown(srv, cancelBg, syncersDone, auditRecorder, shutdownTracing)
+	return err
+}
+
+// shutdown 停止接收新请求，等待进行中的请求和后台上传完成，停止同步任务后关闭各个客户端。
+// 整个过程不超过 server.shutdown_timeout_seconds。
+func shutdown(srv *http.Server, cancelBg context.CancelFunc, syncersDone <-chan struct{}, auditRecorder *svc.AuditRecorder,
+	shutdownTracing func(ctx context.Context) error) {
+	timeout := time.Duration(config.GetServer().ShutdownTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	log.Infof("shutting down, waiting up to %s", timeout)
+
+	// 同步任务退出时释放 redis 锁、撤销 etcd 租约，与等待请求同时进行
+	cancelBg()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warnf("wait for in-flight requests: %v", err)
+	}
+	if err := svc.Uploads.Wait(ctx); err != nil {
+		log.Warnf("%d uploads are still running: %v", svc.Uploads.Running(), err)
+	}
+	// 所有请求结束后不会再有新的审计记录
+	if err := auditRecorder.Close(ctx); err != nil {
+		log.Warnf("flush audit log failed: %v", err)
+	}
+	select {
+	case <-syncersDone:
+		log.Infof("all syncers stopped")
+	case <-ctx.Done():
+		log.Warnf("syncers did not stop in %s", timeout)
+	}
+
+	if err := db.Close(); err != nil {
+		log.Warnf("close db failed: %v", err)
+	}
+	if err := redis.Close(); err != nil {
+		log.Warnf("close redis failed: %v", err)
+	}
+	if err := etcd.Close(); err != nil {
+		log.Warnf("close etcd failed: %v", err)
+	}
+	// 导出剩余的 span
+	if err := shutdownTracing(ctx); err != nil {
+		log.Warnf("flush traces failed: %v", err)
+	}
+	log.Infof("server exited")
+	_ = log.Close()
+}
+
+// initApp 注册路由并启动后台任务，返回的 channel 在所有同步任务退出后关闭
+func initApp(ctx context.Context, server *gin.Engine) (<-chan struct{}, *svc.AuditRecorder) {
+	applyLogConfig(config.GetLog())
+	config.Subscribe(func(old, new *config.Config) {
+		log.Infof("config reloaded, version %d", config.GetStatus().Version)
+		applyLogConfig(config.GetLog())
+	})
+	go watchConfig(ctx)
+
+	dos := dao.Init()
+	if err := dos.AutoMigrate(); err != nil {
+		log.Errorf("auto migrate failed: %v", err)
+	}
+	if err := redis.Init(); err != nil {
+		log.Errorf("Redis can not init %v", err)
+	}
+	// 审计日志只记录之后注册的路由
+	auditRecorder := svc.NewAuditRecorder(dos)
+	server.Use(middleware.Audit(auditRecorder.Record))
+	metaDataController := controller.NewMetadataNodeController(dos)
+	storageController := controller.NewStorageNodeController(dos)
+	authController := controller.NewAuthController(dos)
+	adminController := controller.NewAdminController(dos)
+	healthController := controller.NewHealthController()
+	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	server.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	go metrics.RunSampler(ctx)
+	metaDataController.RegisterRouter(server)
+	storageController.RegisterRouter(server)
+	authController.RegisterRouter(server)
+	adminController.RegisterRouter(server)
+	healthController.RegisterRouter(server)
+
+	syncersDone := make(chan struct{})
+	go func() {
+		defer close(syncersDone)
+		syncer.Init(ctx, dos)
+	}()
+	return syncersDone, auditRecorder
+}
+
+func applyLogConfig(c config.LogConfig) {
+	if err := log.SetLevel(c.Level); err != nil {
+		log.Warnf("set log level failed: %v", err)
+	}
+	log.SetLogFormat(c.Format)
+	file := log.FileOptions{
+		Path:       c.File,
+		MaxSizeMB:  c.MaxSizeMB,
+		MaxBackups: c.MaxBackups,
+		MaxAgeDays: c.MaxAgeDays,
+		Compress:   c.Compress,
+	}
+	if c.File == config.LogFileStderr {
+		file = log.FileOptions{}
+	}
+	log.SetOutput(file)
+}
+
+// watchConfig 监听配置文件和 etcd 中的配置，变化后热加载
+func watchConfig(ctx context.Context) {
+	onError := func(err error) {
+		log.Errorf("reject config update: %v", err)
+	}
+	if key := config.GetReload().EtcdKey; key != "" {
+		go func() {
+			err := etcd.WatchKey(ctx, key, func(value []byte) {
+				if err := config.SetOverlay(value); err != nil {
+					onError(err)
+				}
+			})
+			if err != nil && ctx.Err() == nil {
+				log.Errorf("watch config key %s failed: %v", key, err)
+			}
+		}()
+	}
+	config.Watch(ctx, onError)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/urfave/cli"
+	"golang.org/x/term"
+	"gorm.io/gorm"
+	"io"
+	"os"
+	"strings"
+)
+
+// userPasswordEnv 非交互执行时传入密码的环境变量
+const userPasswordEnv = config.EnvPrefix + "USER_PASSWORD"
+
+func (cmd *Commands) userCommand() cli.Command {
+	// 密码不通过命令行参数传入，避免出现在 shell 历史和进程列表中
+	flags := []cli.Flag{
+		cli.StringFlag{Name: "username, u", Usage: "user name"},
+		cli.BoolFlag{Name: "password-stdin", Usage: "read the password from the first line of stdin"},
+	}
+	return cli.Command{
+		Name:  "user",
+		Usage: "manage login users",
+		Subcommands: []cli.Command{
+			{
+				Name:   "create",
+				Usage:  "create a user, e.g. the first admin",
+				Flags:  flags,
+				Action: cmd.withConfig(runUserCreate),
+			},
+			{
+				Name:   "reset-password",
+				Usage:  "reset the password of a user",
+				Flags:  flags,
+				Action: cmd.withConfig(runUserResetPassword),
+			},
+		},
+	}
+}
+
+func userFlags(c *cli.Context) (string, string, error) {
+	username := c.String("username")
+	if username == "" {
+		return "", "", fmt.Errorf("--username is required")
+	}
+	password, err := readPassword(c)
+	if err != nil {
+		return "", "", err
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("password must not be empty")
+	}
+	return username, password, nil
+}
+
+// readPassword 依次从标准输入（--password-stdin）、环境变量和终端提示读取密码
+func readPassword(c *cli.Context) (string, error) {
+	if c.Bool("password-stdin") {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("read password from stdin: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	if password, ok := os.LookupEnv(userPasswordEnv); ok {
+		return password, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal, pass the password with --password-stdin or %s", userPasswordEnv)
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	if string(password) != string(confirm) {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return string(password), nil
+}
+
+func runUserCreate(c *cli.Context) error {
+	username, password, err := userFlags(c)
+	if err != nil {
+		return err
+	}
+	userSvc := svc.NewUserSvc(dao.Init())
+	_, err = userSvc.GetUserInfoByName(username)
+	if err == nil {
+		return fmt.Errorf("user %s already exists", username)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get user %s: %w", username, err)
+	}
+	err = userSvc.CreateUser(context.Background(), &types.UserMetaData{UserName: username, Password: password})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %s created\n", username)
+	return nil
+}
+
+func runUserResetPassword(c *cli.Context) error {
+	username, password, err := userFlags(c)
+	if err != nil {
+		return err
+	}
+	if err = svc.NewUserSvc(dao.Init()).ResetPassword(context.Background(), username, password); err != nil {
+		return err
+	}
+	fmt.Printf("password of %s reset\n", username)
+	return nil
+}
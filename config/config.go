@@ -3,17 +3,363 @@ package config
 import (
 	"fmt"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
-	"github.com/urfave/cli"
-	"gopkg.in/yaml.v3"
-	"os"
+	"sync/atomic"
 )
 
-var (
-	ConfigDetail *Config
-)
+// current 当前生效的配置，热加载时整体替换
+var current atomic.Pointer[Config]
+
+// Get 返回当前生效的配置，尚未加载时返回 nil。返回值只读，不要修改。
+func Get() *Config {
+	return current.Load()
+}
+
+// Set 直接替换当前生效的配置，不经过校验，也不通知订阅者，供测试使用
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
 
 type Config struct {
-	OssConfig OssConfig `yaml:"oss_config" json:"oss_config"`
+	Server      ServerConfig      `yaml:"server" json:"server"`
+	Redis       RedisConfig       `yaml:"redis" json:"redis"`
+	Etcd        EtcdConfig        `yaml:"etcd" json:"etcd"`
+	MySQL       MySQLConfig       `yaml:"mysql" json:"mysql"`
+	Minio       MinioConfig       `yaml:"minio" json:"minio"`
+	JWT         JWTConfig         `yaml:"jwt" json:"jwt"`
+	Log         LogConfig         `yaml:"log" json:"log"`
+	Tracing     TracingConfig     `yaml:"tracing" json:"tracing"`
+	CORS        CORSConfig        `yaml:"cors" json:"cors"`
+	Reload      ReloadConfig      `yaml:"reload" json:"reload"`
+	OssConfig   OssConfig         `yaml:"oss_config" json:"oss_config"`
+	Placement   PlacementConfig   `yaml:"placement" json:"placement"`
+	Rebalance   RebalanceConfig   `yaml:"rebalance" json:"rebalance"`
+	Scrub       ScrubConfig       `yaml:"scrub" json:"scrub"`
+	Reconcile   ReconcileConfig   `yaml:"reconcile" json:"reconcile"`
+	Quota       QuotaConfig       `yaml:"quota" json:"quota"`
+	Lifecycle   LifecycleConfig   `yaml:"lifecycle" json:"lifecycle"`
+	Replication ReplicationConfig `yaml:"replication" json:"replication"`
+	Syncer      SyncerConfig      `yaml:"syncer" json:"syncer"`
+	Audit       AuditConfig       `yaml:"audit" json:"audit"`
+}
+
+// ServerConfig 网关 HTTP 服务配置
+type ServerConfig struct {
+	Host string `yaml:"host,omitempty" json:"host"`
+	Port int    `yaml:"port,omitempty" json:"port"`
+	// ShutdownTimeoutSeconds 收到退出信号后等待进行中的请求和上传完成的最长时间
+	ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds,omitempty" json:"shutdown_timeout_seconds"`
+}
+
+const (
+	DefaultServerHost             = "0.0.0.0"
+	DefaultServerPort             = 3002
+	DefaultShutdownTimeoutSeconds = 30
+)
+
+// GetServer 返回补齐默认值后的 HTTP 服务配置
+func GetServer() ServerConfig {
+	var c ServerConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Server
+	}
+	if c.Host == "" {
+		c.Host = DefaultServerHost
+	}
+	if c.Port == 0 {
+		c.Port = DefaultServerPort
+	}
+	if c.ShutdownTimeoutSeconds == 0 {
+		c.ShutdownTimeoutSeconds = DefaultShutdownTimeoutSeconds
+	}
+	return c
+}
+
+// RedisConfig redis 连接配置
+type RedisConfig struct {
+	Addr     string `yaml:"addr,omitempty" json:"addr"`
+	Password string `yaml:"password,omitempty" json:"-"`
+	DB       int    `yaml:"db,omitempty" json:"db"`
+}
+
+const DefaultRedisAddr = "0.0.0.0:6379"
+
+// GetRedis 返回补齐默认值后的 redis 配置
+func GetRedis() RedisConfig {
+	var c RedisConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Redis
+	}
+	if c.Addr == "" {
+		c.Addr = DefaultRedisAddr
+	}
+	return c
+}
+
+// EtcdConfig etcd 连接配置，存储节点注册和选主使用
+type EtcdConfig struct {
+	Endpoints          []string `yaml:"endpoints,omitempty" json:"endpoints"`
+	DialTimeoutSeconds int      `yaml:"dial_timeout_seconds,omitempty" json:"dial_timeout_seconds"`
+}
+
+const (
+	DefaultEtcdEndpoint           = "http://0.0.0.0:2379"
+	DefaultEtcdDialTimeoutSeconds = 5
+)
+
+// GetEtcd 返回补齐默认值后的 etcd 配置
+func GetEtcd() EtcdConfig {
+	var c EtcdConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Etcd
+	}
+	if len(c.Endpoints) == 0 {
+		c.Endpoints = []string{DefaultEtcdEndpoint}
+	}
+	if c.DialTimeoutSeconds <= 0 {
+		c.DialTimeoutSeconds = DefaultEtcdDialTimeoutSeconds
+	}
+	return c
+}
+
+// MySQLConfig 元数据库配置
+type MySQLConfig struct {
+	DSN                    string `yaml:"dsn,omitempty" json:"-"`
+	MaxOpenConns           int    `yaml:"max_open_conns,omitempty" json:"max_open_conns"`
+	MaxIdleConns           int    `yaml:"max_idle_conns,omitempty" json:"max_idle_conns"`
+	ConnMaxLifetimeMinutes int    `yaml:"conn_max_lifetime_minutes,omitempty" json:"conn_max_lifetime_minutes"`
+}
+
+const (
+	DefaultMySQLMaxOpenConns           = 100
+	DefaultMySQLMaxIdleConns           = 50
+	DefaultMySQLConnMaxLifetimeMinutes = 60
+)
+
+// GetMySQL 返回补齐默认值后的元数据库配置，DSN 没有默认值，由 Validate 保证已配置
+func GetMySQL() MySQLConfig {
+	var c MySQLConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.MySQL
+	}
+	if c.MaxOpenConns <= 0 {
+		c.MaxOpenConns = DefaultMySQLMaxOpenConns
+	}
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = DefaultMySQLMaxIdleConns
+	}
+	if c.ConnMaxLifetimeMinutes <= 0 {
+		c.ConnMaxLifetimeMinutes = DefaultMySQLConnMaxLifetimeMinutes
+	}
+	return c
+}
+
+// MinioConfig 访问存储节点使用的凭证，所有节点相同
+type MinioConfig struct {
+	AccessKey string `yaml:"access_key,omitempty" json:"access_key"`
+	SecretKey string `yaml:"secret_key,omitempty" json:"-"`
+	Secure    bool   `yaml:"secure,omitempty" json:"secure"`
+}
+
+// GetMinio 返回存储节点凭证，凭证没有默认值，由 Validate 保证已配置
+func GetMinio() MinioConfig {
+	var c MinioConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Minio
+	}
+	return c
+}
+
+// JWTConfig 登录 token 配置
+type JWTConfig struct {
+	Secret      string `yaml:"secret,omitempty" json:"-"`
+	ExpireHours int    `yaml:"expire_hours,omitempty" json:"expire_hours"`
+}
+
+const (
+	DefaultJWTExpireHours = 24
+	// MinJWTSecretLength HS256 密钥的最小长度
+	MinJWTSecretLength = 16
+)
+
+// GetJWT 返回补齐默认值后的 token 配置，Secret 没有默认值，由 Validate 保证已配置
+func GetJWT() JWTConfig {
+	var c JWTConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.JWT
+	}
+	if c.ExpireHours <= 0 {
+		c.ExpireHours = DefaultJWTExpireHours
+	}
+	return c
+}
+
+// LogConfig 日志配置，修改后立即生效
+type LogConfig struct {
+	// Level 日志级别，如 debug、info、warn、error
+	Level string `yaml:"level,omitempty" json:"level"`
+	// Format 日志格式，json 或 text
+	Format string `yaml:"format,omitempty" json:"format"`
+	// File 日志文件路径，为 - 时输出到标准错误
+	File string `yaml:"file,omitempty" json:"file"`
+	// MaxSizeMB 单个日志文件的最大大小，超过后切割
+	MaxSizeMB int `yaml:"max_size_mb,omitempty" json:"max_size_mb"`
+	// MaxBackups 保留的旧日志文件个数
+	MaxBackups int `yaml:"max_backups,omitempty" json:"max_backups"`
+	// MaxAgeDays 旧日志文件保留天数
+	MaxAgeDays int `yaml:"max_age_days,omitempty" json:"max_age_days"`
+	// Compress 是否压缩切割后的旧日志文件
+	Compress bool `yaml:"compress,omitempty" json:"compress"`
+}
+
+const (
+	DefaultLogLevel      = "info"
+	DefaultLogFile       = "server.log"
+	DefaultLogMaxSizeMB  = 100
+	DefaultLogMaxBackups = 10
+	DefaultLogMaxAgeDays = 30
+	// LogFileStderr 日志输出到标准错误
+	LogFileStderr = "-"
+)
+
+// GetLog 返回补齐默认值后的日志配置
+func GetLog() LogConfig {
+	var c LogConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Log
+	}
+	if c.Level == "" {
+		c.Level = DefaultLogLevel
+	}
+	if c.File == "" {
+		c.File = DefaultLogFile
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = DefaultLogMaxSizeMB
+	}
+	if c.MaxBackups == 0 {
+		c.MaxBackups = DefaultLogMaxBackups
+	}
+	if c.MaxAgeDays == 0 {
+		c.MaxAgeDays = DefaultLogMaxAgeDays
+	}
+	return c
+}
+
+// TracingConfig 链路追踪配置，修改后需要重启才能生效
+type TracingConfig struct {
+	// Enabled 为 false 时只透传上游的 trace context，不导出 span
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled"`
+	// Endpoint OTLP/HTTP 接收端地址，如 localhost:4318
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint"`
+	// Insecure 使用 http 而不是 https 连接接收端
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure"`
+	// SampleRatio 上游没有采样决定时的采样比例，取值 0-1
+	SampleRatio float64 `yaml:"sample_ratio,omitempty" json:"sample_ratio"`
+	// ServiceName 上报的服务名
+	ServiceName string `yaml:"service_name,omitempty" json:"service_name"`
+}
+
+const (
+	DefaultTracingEndpoint    = "localhost:4318"
+	DefaultTracingSampleRatio = 1
+	DefaultTracingServiceName = "distributed-object-storage"
+)
+
+// GetTracing 返回补齐默认值后的链路追踪配置
+func GetTracing() TracingConfig {
+	var c TracingConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Tracing
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = DefaultTracingEndpoint
+	}
+	if c.SampleRatio == 0 {
+		c.SampleRatio = DefaultTracingSampleRatio
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = DefaultTracingServiceName
+	}
+	return c
+}
+
+// CORSConfig 跨域配置，修改后立即生效
+type CORSConfig struct {
+	// AllowOrigins 允许跨域访问的来源，为空或包含 * 时允许所有来源
+	AllowOrigins []string `yaml:"allow_origins,omitempty" json:"allow_origins"`
+}
+
+// GetCORS 返回跨域配置
+func GetCORS() CORSConfig {
+	if cfg := Get(); cfg != nil {
+		return cfg.CORS
+	}
+	return CORSConfig{}
+}
+
+// AllowOrigin 是否允许来自 origin 的跨域请求
+func (c CORSConfig) AllowOrigin(origin string) bool {
+	if len(c.AllowOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig 配置热加载，修改后需要重启才能生效
+type ReloadConfig struct {
+	// IntervalSeconds 检查配置文件是否变化的间隔
+	IntervalSeconds int `yaml:"interval_seconds,omitempty" json:"interval_seconds"`
+	// EtcdKey 不为空时监听该 key，其值为 yaml 格式的配置，覆盖在配置文件之上
+	EtcdKey string `yaml:"etcd_key,omitempty" json:"etcd_key"`
+}
+
+const DefaultReloadIntervalSeconds = 5
+
+// GetReload 返回补齐默认值后的热加载配置
+func GetReload() ReloadConfig {
+	var c ReloadConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Reload
+	}
+	if c.IntervalSeconds <= 0 {
+		c.IntervalSeconds = DefaultReloadIntervalSeconds
+	}
+	return c
+}
+
+// PlacementConfig 对象副本放置策略
+type PlacementConfig struct {
+	// Replicas 每个对象的副本数
+	Replicas int `yaml:"replicas,omitempty" json:"replicas"`
+	// HighWaterMark 磁盘使用率（百分比）达到该值的节点不再接收写入
+	HighWaterMark float64 `yaml:"high_water_mark,omitempty" json:"high_water_mark"`
+	// NodeWeights 按节点 ID 覆盖注册时上报的权重
+	NodeWeights map[string]float64 `yaml:"node_weights,omitempty" json:"node_weights"`
+}
+
+const (
+	DefaultReplicas      = 2
+	DefaultHighWaterMark = 90
+)
+
+// GetPlacement 返回补齐默认值后的放置策略配置
+func GetPlacement() PlacementConfig {
+	var c PlacementConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Placement
+	}
+	if c.Replicas <= 0 {
+		c.Replicas = DefaultReplicas
+	}
+	if c.HighWaterMark <= 0 {
+		c.HighWaterMark = DefaultHighWaterMark
+	}
+	return c
 }
 
 func (c *OssConfig) NewOssClient() (*oss.Client, error) {
@@ -24,35 +370,330 @@ func (c *OssConfig) NewOssClient() (*oss.Client, error) {
 	return client, nil
 }
 
+// GetOss 返回补齐默认值后的 OSS 配置
+func GetOss() OssConfig {
+	var c OssConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.OssConfig
+	}
+	if c.PartSizeMB <= 0 {
+		c.PartSizeMB = DefaultOssPartSizeMB
+	}
+	return c
+}
+
+// OssConfig OSS 配置，设置 Bucket 后作为冷存储使用
 type OssConfig struct {
-	AK       string `yaml:"ak,omitempty"`
-	SK       string `yaml:"sk,omitempty"`
-	Endpoint string `yaml:"endpoint,omitempty"`
+	AK       string `yaml:"ak,omitempty" json:"ak"`
+	SK       string `yaml:"sk,omitempty" json:"-"`
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint"`
+	// Bucket 冷存储使用的桶，为空时不启用冷存储
+	Bucket string `yaml:"bucket,omitempty" json:"bucket"`
+	// Prefix 冷存储中对象 key 的前缀
+	Prefix string `yaml:"prefix,omitempty" json:"prefix"`
+	// S3Compatible 为 true 时以 S3 协议访问 Endpoint，用于本地以 MinIO 等代替 OSS 测试，Endpoint 以 https:// 开头时使用 TLS
+	S3Compatible bool `yaml:"s3_compatible,omitempty" json:"s3_compatible"`
+	// PartSizeMB 写入冷存储时超过该大小的对象分片上传
+	PartSizeMB int `yaml:"part_size_mb,omitempty" json:"part_size_mb"`
 }
 
-func InitConfig(cli *cli.Context) *Config {
-	//configPath := cli.String("configPath")
-	//profile := cli.String("profile")
+const DefaultOssPartSizeMB = 64
 
-	p := fmt.Sprintf("./config/config-dev.yaml")
-	c, err := NewByFile(p)
-	if err != nil {
-		panic(err)
+// ColdTierEnabled 是否配置了冷存储
+func (c OssConfig) ColdTierEnabled() bool {
+	return c.Bucket != ""
+}
+
+// RebalanceConfig 节点变化后迁移对象的后台任务配置
+type RebalanceConfig struct {
+	// Concurrency 同时迁移的对象数
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency"`
+	// BandwidthBytes 迁移总带宽（字节/秒），<=0 表示不限速
+	BandwidthBytes int64 `yaml:"bandwidth_bytes,omitempty" json:"bandwidth_bytes"`
+	// BatchSize 每批从元数据中读取的对象数
+	BatchSize int `yaml:"batch_size,omitempty" json:"batch_size"`
+}
+
+const (
+	DefaultRebalanceConcurrency = 4
+	DefaultRebalanceBatchSize   = 500
+)
+
+// GetRebalance 返回补齐默认值后的迁移配置
+func GetRebalance() RebalanceConfig {
+	var c RebalanceConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Rebalance
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultRebalanceConcurrency
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultRebalanceBatchSize
 	}
-	ConfigDetail = c
 	return c
 }
 
-func NewByFile(filepath string) (*Config, error) {
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("read config file failed,%v", err)
+// ScrubConfig 后台校验副本完整性的任务配置
+type ScrubConfig struct {
+	// IntervalMinutes 两轮校验之间的间隔（分钟）
+	IntervalMinutes int `yaml:"interval_minutes,omitempty" json:"interval_minutes"`
+	// ObjectsPerSecond 每秒校验的对象数上限
+	ObjectsPerSecond float64 `yaml:"objects_per_second,omitempty" json:"objects_per_second"`
+	// BandwidthBytes 读取数据和修复时的带宽（字节/秒），<=0 表示不限速
+	BandwidthBytes int64 `yaml:"bandwidth_bytes,omitempty" json:"bandwidth_bytes"`
+	// VerifyData 为 true 时读取全部数据重新计算 ETag，否则只比较 HEAD 返回的大小和 ETag
+	VerifyData bool `yaml:"verify_data,omitempty" json:"verify_data"`
+	// DisableRepair 为 true 时只记录问题，不从健康副本修复
+	DisableRepair bool `yaml:"disable_repair,omitempty" json:"disable_repair"`
+	// BatchSize 每批从元数据中读取的对象数，每批结束后保存一次进度
+	BatchSize int `yaml:"batch_size,omitempty" json:"batch_size"`
+}
+
+const (
+	DefaultScrubIntervalMinutes  = 24 * 60
+	DefaultScrubObjectsPerSecond = 20
+	DefaultScrubBatchSize        = 100
+)
+
+// GetScrub 返回补齐默认值后的校验配置
+func GetScrub() ScrubConfig {
+	var c ScrubConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Scrub
 	}
-	ret := new(Config)
-	err = yaml.Unmarshal(data, ret)
-	if err != nil {
-		return nil, fmt.Errorf("yaml.Unmarshal,%v", err)
+	if c.IntervalMinutes <= 0 {
+		c.IntervalMinutes = DefaultScrubIntervalMinutes
+	}
+	if c.ObjectsPerSecond <= 0 {
+		c.ObjectsPerSecond = DefaultScrubObjectsPerSecond
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultScrubBatchSize
+	}
+	return c
+}
+
+// ReconcileConfig 元数据与存储节点定时对账的配置
+type ReconcileConfig struct {
+	// IntervalMinutes 两次对账之间的间隔（分钟）
+	IntervalMinutes int `yaml:"interval_minutes,omitempty" json:"interval_minutes"`
+	// Fix 为 true 时定时对账会修复发现的不一致，否则只生成报告
+	Fix bool `yaml:"fix,omitempty" json:"fix"`
+}
+
+const DefaultReconcileIntervalMinutes = 6 * 60
+
+// GetReconcile 返回补齐默认值后的对账配置
+func GetReconcile() ReconcileConfig {
+	var c ReconcileConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Reconcile
+	}
+	if c.IntervalMinutes <= 0 {
+		c.IntervalMinutes = DefaultReconcileIntervalMinutes
+	}
+	return c
+}
+
+// QuotaConfig 配额用量统计配置
+type QuotaConfig struct {
+	// ReconcileIntervalMinutes 按元数据重新统计桶和用户用量的间隔（分钟），用于修正增量计数的误差
+	ReconcileIntervalMinutes int `yaml:"reconcile_interval_minutes,omitempty" json:"reconcile_interval_minutes"`
+}
+
+const DefaultQuotaReconcileIntervalMinutes = 60
+
+// GetQuota 返回补齐默认值后的配额配置
+func GetQuota() QuotaConfig {
+	var c QuotaConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Quota
+	}
+	if c.ReconcileIntervalMinutes <= 0 {
+		c.ReconcileIntervalMinutes = DefaultQuotaReconcileIntervalMinutes
+	}
+	return c
+}
+
+// LifecycleConfig 生命周期规则的执行配置
+type LifecycleConfig struct {
+	// IntervalMinutes 两次执行生命周期规则之间的间隔（分钟）
+	IntervalMinutes int `yaml:"interval_minutes,omitempty" json:"interval_minutes"`
+	// BatchSize 每批删除的对象数，每批删除后记录一次删除记录
+	BatchSize int `yaml:"batch_size,omitempty" json:"batch_size"`
+}
+
+const (
+	DefaultLifecycleIntervalMinutes = 60
+	DefaultLifecycleBatchSize       = 1000
+)
+
+// GetLifecycle 返回补齐默认值后的生命周期配置
+func GetLifecycle() LifecycleConfig {
+	var c LifecycleConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Lifecycle
+	}
+	if c.IntervalMinutes <= 0 {
+		c.IntervalMinutes = DefaultLifecycleIntervalMinutes
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultLifecycleBatchSize
+	}
+	return c
+}
+
+// ReplicationConfig 跨集群复制队列的处理配置
+type ReplicationConfig struct {
+	// IntervalSeconds 两次处理复制队列之间的间隔（秒）
+	IntervalSeconds int `yaml:"interval_seconds,omitempty" json:"interval_seconds"`
+	// BatchSize 每批取出的任务数
+	BatchSize int `yaml:"batch_size,omitempty" json:"batch_size"`
+	// Concurrency 同时复制的对象数
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency"`
+	// MaxAttempts 任务最多尝试的次数，用完后标记为 FAILED，需要重新同步
+	MaxAttempts int `yaml:"max_attempts,omitempty" json:"max_attempts"`
+	// RetryBaseSeconds 第一次重试前等待的秒数，之后每次翻倍
+	RetryBaseSeconds int `yaml:"retry_base_seconds,omitempty" json:"retry_base_seconds"`
+	// RetryMaxSeconds 两次重试之间最长等待的秒数
+	RetryMaxSeconds int `yaml:"retry_max_seconds,omitempty" json:"retry_max_seconds"`
+	// ReplicaSecret 校验其他集群 gateway 复制请求签名的共享密钥，与源集群复制规则中目标的 secret_key 相同；
+	// 为空时拒绝所有复制写入
+	ReplicaSecret string `yaml:"replica_secret,omitempty" json:"-"`
+}
+
+const (
+	DefaultReplicationIntervalSeconds  = 10
+	DefaultReplicationBatchSize        = 100
+	DefaultReplicationConcurrency      = 4
+	DefaultReplicationMaxAttempts      = 10
+	DefaultReplicationRetryBaseSeconds = 5
+	DefaultReplicationRetryMaxSeconds  = 3600
+)
+
+// GetReplication 返回补齐默认值后的复制配置
+func GetReplication() ReplicationConfig {
+	var c ReplicationConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Replication
+	}
+	if c.IntervalSeconds <= 0 {
+		c.IntervalSeconds = DefaultReplicationIntervalSeconds
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultReplicationBatchSize
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = DefaultReplicationConcurrency
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultReplicationMaxAttempts
+	}
+	if c.RetryBaseSeconds <= 0 {
+		c.RetryBaseSeconds = DefaultReplicationRetryBaseSeconds
+	}
+	if c.RetryMaxSeconds <= 0 {
+		c.RetryMaxSeconds = DefaultReplicationRetryMaxSeconds
 	}
-	//logs.Info("config: %s", string(data))
-	return ret, nil
+	return c
+}
+
+// SyncerConfig 后台任务配置
+type SyncerConfig struct {
+	// EnvGroup 环境分组，EnvIsolation 的任务在不同分组间互不抢锁
+	EnvGroup string `yaml:"env_group,omitempty" json:"env_group"`
+	// Jobs 按任务名覆盖默认配置
+	Jobs map[string]SyncerJobConfig `yaml:"jobs,omitempty" json:"jobs"`
+}
+
+// SyncerJobConfig 单个后台任务的配置
+type SyncerJobConfig struct {
+	// Enabled 为 false 时不启动该任务，默认启动
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled"`
+	// IntervalSeconds 覆盖任务默认的执行间隔
+	IntervalSeconds int `yaml:"interval_seconds,omitempty" json:"interval_seconds"`
+	// Election 选主方式，redis（默认）或 etcd
+	Election string `yaml:"election,omitempty" json:"election"`
+}
+
+const (
+	ElectionRedis = "redis" // 各网关轮流抢占 redis 锁
+	ElectionEtcd  = "etcd"  // etcd concurrency.Election，领导者持续持有直到会话失效
+)
+
+// GetSyncerEnvGroup 返回后台任务的环境分组
+func GetSyncerEnvGroup() string {
+	cfg := Get()
+	if cfg == nil {
+		return ""
+	}
+	return cfg.Syncer.EnvGroup
+}
+
+// GetSyncerJob 返回指定后台任务的配置
+func GetSyncerJob(name string) SyncerJobConfig {
+	cfg := Get()
+	if cfg == nil {
+		return SyncerJobConfig{}
+	}
+	return cfg.Syncer.Jobs[name]
+}
+
+// IsEnabled 任务是否启用
+func (c SyncerJobConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// ElectionMode 任务的选主方式，未配置时使用 redis
+func (c SyncerJobConfig) ElectionMode() string {
+	if c.Election == ElectionEtcd {
+		return ElectionEtcd
+	}
+	return ElectionRedis
+}
+
+// AuditConfig 审计日志配置，修改后需要重启才能生效
+type AuditConfig struct {
+	// Disabled 为 true 时不记录审计日志
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled"`
+	// Sink 审计日志写入位置，mysql（默认，可通过管理接口查询）或 file
+	Sink string `yaml:"sink,omitempty" json:"sink"`
+	// File sink 为 file 时写入的文件，按大小切割
+	File       string `yaml:"file,omitempty" json:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty" json:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups,omitempty" json:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days,omitempty" json:"max_age_days"`
+	// BufferSize 等待写入的审计记录数上限，写满后请求等待写入
+	BufferSize int `yaml:"buffer_size,omitempty" json:"buffer_size"`
+}
+
+const (
+	AuditSinkMySQL = "mysql"
+	AuditSinkFile  = "file"
+
+	DefaultAuditFile       = "audit.log"
+	DefaultAuditMaxSizeMB  = 100
+	DefaultAuditBufferSize = 1024
+)
+
+// GetAudit 返回补齐默认值后的审计日志配置
+func GetAudit() AuditConfig {
+	var c AuditConfig
+	if cfg := Get(); cfg != nil {
+		c = cfg.Audit
+	}
+	if c.Sink == "" {
+		c.Sink = AuditSinkMySQL
+	}
+	if c.File == "" {
+		c.File = DefaultAuditFile
+	}
+	if c.MaxSizeMB == 0 {
+		c.MaxSizeMB = DefaultAuditMaxSizeMB
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = DefaultAuditBufferSize
+	}
+	return c
 }
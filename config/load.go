@@ -0,0 +1,299 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v3"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultProfile = "dev"
+	// EnvPrefix 覆盖配置项的环境变量前缀
+	EnvPrefix = "DOS_"
+)
+
+// Flags 全局命令行参数。配置按 默认值 < 配置文件 < etcd 中的配置 < 环境变量 < 命令行参数 的顺序逐层覆盖。
+var Flags = []cli.Flag{
+	cli.StringFlag{Name: "config, c", Usage: "config file path, default ./config/config-<profile>.yaml", EnvVar: EnvPrefix + "CONFIG"},
+	cli.StringFlag{Name: "profile, p", Usage: "config profile", Value: DefaultProfile, EnvVar: EnvPrefix + "PROFILE"},
+	cli.StringFlag{Name: "host", Usage: "listen host"},
+	cli.IntFlag{Name: "port", Usage: "listen port"},
+	cli.StringFlag{Name: "redis-addr", Usage: "redis address"},
+	cli.StringFlag{Name: "etcd-endpoints", Usage: "comma separated etcd endpoints"},
+	cli.StringFlag{Name: "mysql-dsn", Usage: "metadata database dsn"},
+	cli.StringFlag{Name: "minio-access-key", Usage: "storage node access key"},
+	cli.StringFlag{Name: "minio-secret-key", Usage: "storage node secret key"},
+	cli.StringFlag{Name: "jwt-secret", Usage: "secret for signing login tokens"},
+}
+
+// binding 配置项与环境变量、命令行参数的对应关系，flag 为空表示只能通过环境变量覆盖
+type binding struct {
+	env  string
+	flag string
+	set  func(c *Config, value string) error
+}
+
+var bindings = []binding{
+	{env: "SERVER_HOST", flag: "host", set: func(c *Config, v string) error { c.Server.Host = v; return nil }},
+	{env: "SERVER_PORT", flag: "port", set: func(c *Config, v string) error { return setInt(&c.Server.Port, v) }},
+	{env: "REDIS_ADDR", flag: "redis-addr", set: func(c *Config, v string) error { c.Redis.Addr = v; return nil }},
+	{env: "REDIS_PASSWORD", set: func(c *Config, v string) error { c.Redis.Password = v; return nil }},
+	{env: "REDIS_DB", set: func(c *Config, v string) error { return setInt(&c.Redis.DB, v) }},
+	{env: "ETCD_ENDPOINTS", flag: "etcd-endpoints", set: func(c *Config, v string) error { c.Etcd.Endpoints = splitList(v); return nil }},
+	{env: "MYSQL_DSN", flag: "mysql-dsn", set: func(c *Config, v string) error { c.MySQL.DSN = v; return nil }},
+	{env: "MINIO_ACCESS_KEY", flag: "minio-access-key", set: func(c *Config, v string) error { c.Minio.AccessKey = v; return nil }},
+	{env: "MINIO_SECRET_KEY", flag: "minio-secret-key", set: func(c *Config, v string) error { c.Minio.SecretKey = v; return nil }},
+	{env: "MINIO_SECURE", set: func(c *Config, v string) error { return setBool(&c.Minio.Secure, v) }},
+	{env: "JWT_SECRET", flag: "jwt-secret", set: func(c *Config, v string) error { c.JWT.Secret = v; return nil }},
+	{env: "TRACING_ENABLED", set: func(c *Config, v string) error { return setBool(&c.Tracing.Enabled, v) }},
+	{env: "TRACING_ENDPOINT", set: func(c *Config, v string) error { c.Tracing.Endpoint = v; return nil }},
+	{env: "LOG_LEVEL", set: func(c *Config, v string) error { c.Log.Level = v; return nil }},
+	{env: "LOG_FILE", set: func(c *Config, v string) error { c.Log.File = v; return nil }},
+	{env: "OSS_AK", set: func(c *Config, v string) error { c.OssConfig.AK = v; return nil }},
+	{env: "OSS_SK", set: func(c *Config, v string) error { c.OssConfig.SK = v; return nil }},
+	{env: "OSS_ENDPOINT", set: func(c *Config, v string) error { c.OssConfig.Endpoint = v; return nil }},
+	{env: "OSS_BUCKET", set: func(c *Config, v string) error { c.OssConfig.Bucket = v; return nil }},
+	{env: "OSS_S3_COMPATIBLE", set: func(c *Config, v string) error { return setBool(&c.OssConfig.S3Compatible, v) }},
+	{env: "SYNCER_ENV_GROUP", set: func(c *Config, v string) error { c.Syncer.EnvGroup = v; return nil }},
+}
+
+// LoadOptions 加载配置的参数
+type LoadOptions struct {
+	// Path 配置文件路径，为空时使用 ./config/config-<Profile>.yaml，该文件不存在时只使用默认值
+	Path    string
+	Profile string
+	// LookupEnv 查询环境变量，为空时使用 os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+	// LookupFlag 查询显式设置的命令行参数，为空时不使用命令行参数
+	LookupFlag func(name string) (string, bool)
+	// Overlay 覆盖在配置文件之上的 yaml，来自 etcd 中的配置
+	Overlay []byte
+}
+
+// file 实际读取的配置文件路径，以及该文件是否必须存在
+func (opts LoadOptions) file() (string, bool) {
+	if opts.Path != "" {
+		return opts.Path, true
+	}
+	profile := opts.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	return fmt.Sprintf("./config/config-%s.yaml", profile), false
+}
+
+// InitConfig 按命令行参数加载配置并设置为全局配置
+func InitConfig(c *cli.Context) (*Config, error) {
+	opts := LoadOptions{
+		Path:    c.GlobalString("config"),
+		Profile: c.GlobalString("profile"),
+		LookupFlag: func(name string) (string, bool) {
+			if !c.GlobalIsSet(name) {
+				return "", false
+			}
+			return c.GlobalString(name), true
+		},
+	}
+	cfg, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	initReloader(opts, cfg)
+	return cfg, nil
+}
+
+// Load 依次读取配置文件、etcd 中的配置、环境变量和命令行参数并校验
+func Load(opts LoadOptions) (*Config, error) {
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	cfg := new(Config)
+	p, required := opts.file()
+	c, err := NewByFile(p)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+	if c != nil {
+		cfg = c
+	}
+	if len(opts.Overlay) > 0 {
+		if err = yaml.Unmarshal(opts.Overlay, cfg); err != nil {
+			return nil, fmt.Errorf("parse config from etcd: %v", err)
+		}
+	}
+
+	for _, b := range bindings {
+		if v, ok := opts.LookupEnv(EnvPrefix + b.env); ok {
+			if err := b.set(cfg, v); err != nil {
+				return nil, fmt.Errorf("invalid env %s%s: %w", EnvPrefix, b.env, err)
+			}
+		}
+	}
+	if opts.LookupFlag != nil {
+		for _, b := range bindings {
+			if b.flag == "" {
+				continue
+			}
+			if v, ok := opts.LookupFlag(b.flag); ok {
+				if err := b.set(cfg, v); err != nil {
+					return nil, fmt.Errorf("invalid flag --%s: %w", b.flag, err)
+				}
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func NewByFile(filepath string) (*Config, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("read config file failed,%w", err)
+	}
+	ret := new(Config)
+	err = yaml.Unmarshal(data, ret)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %v", filepath, err)
+	}
+	//logs.Info("config: %s", string(data))
+	return ret, nil
+}
+
+// Validate 检查配置项取值，返回所有不合法的配置项。
+// 数据库 DSN、存储节点凭证和 JWT 密钥没有默认值，必须配置；其余未填写的配置项使用默认值，不视为错误。
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Port < 0 || c.Server.Port > 65535 {
+		invalid("server.port", "%d out of range 1-65535", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeoutSeconds < 0 {
+		invalid("server.shutdown_timeout_seconds", "must not be negative")
+	}
+	if c.Redis.DB < 0 {
+		invalid("redis.db", "must not be negative")
+	}
+	for _, endpoint := range c.Etcd.Endpoints {
+		if u, err := url.Parse(endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("etcd.endpoints", "%q is not a url like http://host:2379", endpoint)
+		}
+	}
+	if c.MySQL.DSN == "" {
+		invalid("mysql.dsn", "required, set it in the config file, %sMYSQL_DSN or --mysql-dsn", EnvPrefix)
+	} else if !strings.Contains(c.MySQL.DSN, "/") {
+		invalid("mysql.dsn", "missing database name, expected user:password@tcp(host:port)/dbname")
+	}
+	if c.Minio.AccessKey == "" || c.Minio.SecretKey == "" {
+		invalid("minio", "access_key and secret_key are required, set them in the config file, %sMINIO_ACCESS_KEY and %sMINIO_SECRET_KEY",
+			EnvPrefix, EnvPrefix)
+	}
+	if c.JWT.Secret == "" {
+		invalid("jwt.secret", "required, set it in the config file, %sJWT_SECRET or --jwt-secret", EnvPrefix)
+	} else if len(c.JWT.Secret) < MinJWTSecretLength {
+		invalid("jwt.secret", "must be at least %d characters", MinJWTSecretLength)
+	}
+	if c.Placement.Replicas < 0 {
+		invalid("placement.replicas", "must not be negative")
+	}
+	if c.Placement.HighWaterMark < 0 || c.Placement.HighWaterMark > 100 {
+		invalid("placement.high_water_mark", "%g out of range 0-100", c.Placement.HighWaterMark)
+	}
+	for id, weight := range c.Placement.NodeWeights {
+		if weight < 0 {
+			invalid("placement.node_weights."+id, "must not be negative")
+		}
+	}
+	if c.Rebalance.Concurrency < 0 {
+		invalid("rebalance.concurrency", "must not be negative")
+	}
+	if c.Log.Level != "" {
+		if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+			invalid("log.level", "%v", err)
+		}
+	}
+	if c.Log.Format != "" && c.Log.Format != "json" && c.Log.Format != "text" {
+		invalid("log.format", "%q is not one of json, text", c.Log.Format)
+	}
+	if c.Log.MaxSizeMB < 0 || c.Log.MaxBackups < 0 || c.Log.MaxAgeDays < 0 {
+		invalid("log", "max_size_mb, max_backups and max_age_days must not be negative")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "%g out of range 0-1", c.Tracing.SampleRatio)
+	}
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("cors.allow_origins", "%q is not an origin like https://example.com", origin)
+		}
+	}
+	if c.Reload.IntervalSeconds < 0 {
+		invalid("reload.interval_seconds", "must not be negative")
+	}
+	if c.OssConfig.PartSizeMB < 0 {
+		invalid("oss_config.part_size_mb", "must not be negative")
+	}
+	if c.Scrub.ObjectsPerSecond < 0 {
+		invalid("scrub.objects_per_second", "must not be negative")
+	}
+	for name, job := range c.Syncer.Jobs {
+		if job.Election != "" && job.Election != ElectionRedis && job.Election != ElectionEtcd {
+			invalid("syncer.jobs."+name+".election", "%q is not one of %s, %s", job.Election, ElectionRedis, ElectionEtcd)
+		}
+		if job.IntervalSeconds < 0 {
+			invalid("syncer.jobs."+name+".interval_seconds", "must not be negative")
+		}
+	}
+	if c.Audit.Sink != "" && c.Audit.Sink != AuditSinkMySQL && c.Audit.Sink != AuditSinkFile {
+		invalid("audit.sink", "%q is not one of %s, %s", c.Audit.Sink, AuditSinkMySQL, AuditSinkFile)
+	}
+	if c.Audit.MaxSizeMB < 0 || c.Audit.MaxBackups < 0 || c.Audit.MaxAgeDays < 0 || c.Audit.BufferSize < 0 {
+		invalid("audit", "max_size_mb, max_backups, max_age_days and buffer_size must not be negative")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func setInt(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	*dst = n
+	return nil
+}
+
+func setBool(dst *bool, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a bool", value)
+	}
+	*dst = b
+	return nil
+}
+
+func splitList(value string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRequiresSecrets(t *testing.T) {
+	err := (&Config{}).Validate()
+	if err == nil {
+		t.Fatal("empty config passed validation")
+	}
+	for _, key := range []string{"mysql.dsn", "minio", "jwt.secret"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+
+	cfg := &Config{
+		MySQL: MySQLConfig{DSN: "dos:password@tcp(127.0.0.1:3306)/dos"},
+		Minio: MinioConfig{AccessKey: "access-key", SecretKey: "secret-key"},
+		JWT:   JWTConfig{Secret: "a-long-enough-jwt-secret"},
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+}
+
+func TestLoadReadsSecretsFromEnv(t *testing.T) {
+	env := map[string]string{
+		EnvPrefix + "MYSQL_DSN":        "dos:password@tcp(127.0.0.1:3306)/dos",
+		EnvPrefix + "MINIO_ACCESS_KEY": "access-key",
+		EnvPrefix + "MINIO_SECRET_KEY": "secret-key",
+		EnvPrefix + "JWT_SECRET":       "a-long-enough-jwt-secret",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	cfg, err := Load(LoadOptions{Path: "./config-dev.yaml", LookupEnv: lookup})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.JWT.Secret != env[EnvPrefix+"JWT_SECRET"] || cfg.Minio.SecretKey != "secret-key" {
+		t.Fatalf("secrets not loaded from env: %+v", cfg.Minio)
+	}
+
+	delete(env, EnvPrefix+"JWT_SECRET")
+	_, err = Load(LoadOptions{Path: "./config-dev.yaml", LookupEnv: lookup})
+	if err == nil || !strings.Contains(err.Error(), "jwt.secret") {
+		t.Fatalf("load without jwt secret = %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	ReloadSourceStartup = "startup" // 启动时加载
+	ReloadSourceFile    = "file"    // 配置文件变化
+	ReloadSourceEtcd    = "etcd"    // etcd 中的配置变化
+	ReloadSourceManual  = "manual"  // 通过管理接口触发
+)
+
+// Status 当前生效配置的版本和最近一次加载结果
+type Status struct {
+	// Version 每次生效的配置发生变化时加一
+	Version  int64     `json:"version"`
+	Checksum string    `json:"checksum"`
+	Source   string    `json:"source"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// PendingRestart 已修改但需要重启才能生效的配置项
+	PendingRestart []string `json:"pending_restart,omitempty"`
+	// LastError 最近一次被拒绝的更新，成功加载后清空
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	Config      *Config    `json:"config"`
+}
+
+// Subscriber 配置变化时的回调，old 和 new 只读
+type Subscriber func(old, new *Config)
+
+var reloader = struct {
+	sync.Mutex
+	opts        LoadOptions
+	overlay     []byte
+	status      Status
+	subscribers []Subscriber
+}{}
+
+// restartRequired 运行时无法切换的配置项，热加载时保留启动时的值
+var restartRequired = []struct {
+	key  string
+	copy func(dst, src *Config)
+}{
+	{"server", func(dst, src *Config) { dst.Server = src.Server }},
+	{"redis", func(dst, src *Config) { dst.Redis = src.Redis }},
+	{"etcd", func(dst, src *Config) { dst.Etcd = src.Etcd }},
+	{"mysql", func(dst, src *Config) { dst.MySQL = src.MySQL }},
+	{"minio", func(dst, src *Config) { dst.Minio = src.Minio }},
+	{"jwt", func(dst, src *Config) { dst.JWT = src.JWT }},
+	{"oss_config", func(dst, src *Config) { dst.OssConfig = src.OssConfig }},
+	{"tracing", func(dst, src *Config) { dst.Tracing = src.Tracing }},
+	{"audit", func(dst, src *Config) { dst.Audit = src.Audit }},
+	{"reload", func(dst, src *Config) { dst.Reload = src.Reload }},
+	{"syncer.env_group", func(dst, src *Config) { dst.Syncer.EnvGroup = src.Syncer.EnvGroup }},
+}
+
+func initReloader(opts LoadOptions, cfg *Config) {
+	reloader.Lock()
+	defer reloader.Unlock()
+	reloader.opts = opts
+	reloader.status = Status{
+		Version:  1,
+		Checksum: checksum(cfg),
+		Source:   ReloadSourceStartup,
+		LoadedAt: time.Now(),
+	}
+	current.Store(cfg)
+}
+
+// Subscribe 注册配置变化回调，回调在配置替换后同步执行
+func Subscribe(fn Subscriber) {
+	reloader.Lock()
+	defer reloader.Unlock()
+	reloader.subscribers = append(reloader.subscribers, fn)
+}
+
+// GetStatus 返回当前生效配置的版本和内容，密钥类配置项不会输出
+func GetStatus() Status {
+	reloader.Lock()
+	defer reloader.Unlock()
+	status := reloader.status
+	status.Config = Get()
+	return status
+}
+
+// Reload 重新加载配置。新配置校验失败时保留当前配置并返回错误。
+func Reload(source string) error {
+	reloader.Lock()
+	change, err := reload(source)
+	reloader.Unlock()
+	change.notify()
+	return err
+}
+
+// SetOverlay 更新 etcd 中的配置并重新加载，校验失败时保留原来的值
+func SetOverlay(data []byte) error {
+	reloader.Lock()
+	previous := reloader.overlay
+	reloader.overlay = data
+	change, err := reload(ReloadSourceEtcd)
+	if err != nil {
+		reloader.overlay = previous
+	}
+	reloader.Unlock()
+	change.notify()
+	return err
+}
+
+// change 一次生效的配置变化，在 reloader 锁外通知订阅者
+type change struct {
+	old, new    *Config
+	subscribers []Subscriber
+}
+
+func (c *change) notify() {
+	if c == nil {
+		return
+	}
+	for _, fn := range c.subscribers {
+		fn(c.old, c.new)
+	}
+}
+
+// reload 重新加载并替换当前配置，调用方持有 reloader 锁。配置没有变化时返回 nil。
+func reload(source string) (*change, error) {
+	opts := reloader.opts
+	opts.Overlay = reloader.overlay
+	cfg, err := Load(opts)
+	if err != nil {
+		now := time.Now()
+		reloader.status.LastError = err.Error()
+		reloader.status.LastErrorAt = &now
+		return nil, err
+	}
+
+	old := Get()
+	pending := make([]string, 0)
+	if old != nil {
+		for _, item := range restartRequired {
+			merged := *cfg
+			item.copy(&merged, old)
+			if !reflect.DeepEqual(&merged, cfg) {
+				pending = append(pending, item.key)
+			}
+			item.copy(cfg, old)
+		}
+	}
+	reloader.status.PendingRestart = pending
+	reloader.status.LastError, reloader.status.LastErrorAt = "", nil
+	if reflect.DeepEqual(old, cfg) {
+		return nil, nil
+	}
+
+	current.Store(cfg)
+	reloader.status.Version++
+	reloader.status.Checksum = checksum(cfg)
+	reloader.status.Source = source
+	reloader.status.LoadedAt = time.Now()
+	return &change{
+		old:         old,
+		new:         cfg,
+		subscribers: append([]Subscriber{}, reloader.subscribers...),
+	}, nil
+}
+
+// Watch 定期检查配置文件，文件变化后重新加载，直到 ctx 取消。onError 接收被拒绝的更新。
+func Watch(ctx context.Context, onError func(err error)) {
+	reloader.Lock()
+	path, _ := reloader.opts.file()
+	reloader.Unlock()
+
+	last := fileVersion(path)
+	ticker := time.NewTicker(time.Duration(GetReload().IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		version := fileVersion(path)
+		if version == last {
+			continue
+		}
+		last = version
+		if err := Reload(ReloadSourceFile); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// fileVersion 以修改时间和大小判断文件是否变化，文件不存在时返回空
+func fileVersion(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
+
+func checksum(cfg *Config) string {
+	data, _ := yaml.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
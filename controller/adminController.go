@@ -0,0 +1,388 @@
+package controller
+
+import (
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/metrics"
+	"distributed-object-storage/pkg/middleware"
+	"distributed-object-storage/service"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/syncer"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type AdminController struct {
+	RebalanceSvc   *svc.RebalanceSvc
+	ScrubSvc       *svc.ScrubSvc
+	ReconcileSvc   *svc.ReconcileSvc
+	SyncerSvc      *svc.SyncerSvc
+	AuditSvc       *svc.AuditSvc
+	QuotaSvc       *svc.QuotaSvc
+	LifecycleSvc   *svc.LifecycleSvc
+	ReplicationSvc *svc.ReplicationSvc
+}
+
+func NewAdminController(daoS *dao.S) *AdminController {
+	return &AdminController{
+		RebalanceSvc:   svc.NewRebalanceSvc(),
+		ScrubSvc:       svc.NewScrubSvc(daoS),
+		ReconcileSvc:   svc.NewReconcileSvc(daoS),
+		SyncerSvc:      svc.NewSyncerSvc(),
+		AuditSvc:       svc.NewAuditSvc(daoS),
+		QuotaSvc:       svc.NewQuotaSvc(daoS),
+		LifecycleSvc:   svc.NewLifecycleSvc(daoS),
+		ReplicationSvc: svc.NewReplicationSvc(daoS),
+	}
+}
+
+func (ctrl *AdminController) RegisterRouter(r gin.IRouter) {
+	g := r.Group("/admin", middleware.AuthMiddleware())
+	g.GET("/rebalance", service.DataHandlerWrapper(ctrl.GetRebalanceProgress))
+	g.POST("/rebalance/pause", service.NoDataHandlerWrapper(ctrl.PauseRebalance))
+	g.POST("/rebalance/resume", service.NoDataHandlerWrapper(ctrl.ResumeRebalance))
+	g.GET("/scrub", service.DataHandlerWrapper(ctrl.GetScrubReport))
+	g.GET("/scrub/issues", service.DataHandlerWrapper(ctrl.ListScrubIssues))
+	g.GET("/reconcile", service.DataHandlerWrapper(ctrl.GetReconcileReport))
+	g.GET("/syncers", service.DataHandlerWrapper(ctrl.ListSyncers))
+	g.GET("/syncers/:name/runs", service.DataHandlerWrapper(ctrl.ListSyncerRuns))
+	g.POST("/syncers/:name/trigger", service.NoDataHandlerWrapper(ctrl.TriggerSyncer))
+	g.GET("/syncers/:name/leader", service.DataHandlerWrapper(ctrl.GetSyncerLeader))
+	g.GET("/metrics", service.DataHandlerWrapper(ctrl.GetMetrics))
+	g.GET("/config", service.DataHandlerWrapper(ctrl.GetConfig))
+	g.POST("/config/reload", service.DataHandlerWrapper(ctrl.ReloadConfig))
+	g.GET("/audit", service.DataHandlerWrapper(ctrl.ListAuditLogs))
+	g.GET("/quota", service.DataHandlerWrapper(ctrl.GetQuotaOverview))
+	g.GET("/quota/user/:id", service.DataHandlerWrapper(ctrl.GetUserQuota))
+	g.PUT("/quota/user/:id", service.DataHandlerWrapper(ctrl.PutUserQuota))
+	g.GET("/lifecycle", service.DataHandlerWrapper(ctrl.GetLifecycleReport))
+	g.GET("/lifecycle/logs", service.DataHandlerWrapper(ctrl.ListLifecycleLogs))
+	g.GET("/replication", service.DataHandlerWrapper(ctrl.GetReplicationStats))
+	g.GET("/replication/tasks", service.DataHandlerWrapper(ctrl.ListReplicationTasks))
+}
+
+// GetRebalanceProgress 获取对象迁移进度
+// @Summary 获取对象迁移进度
+// @Description 节点加入或离开后后台迁移对象的进度
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.RebalanceProgress
+// @Failure 400
+// @Router /admin/rebalance [GET]
+func (ctrl *AdminController) GetRebalanceProgress(ctx *gin.Context) (interface{}, error) {
+	return ctrl.RebalanceSvc.GetProgress(ctx)
+}
+
+// PauseRebalance 暂停对象迁移
+// @Summary 暂停对象迁移
+// @Description 正在迁移的对象完成后停止，进度保留
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200
+// @Failure 400
+// @Router /admin/rebalance/pause [POST]
+func (ctrl *AdminController) PauseRebalance(ctx *gin.Context) error {
+	return ctrl.RebalanceSvc.Pause(ctx)
+}
+
+// ResumeRebalance 恢复对象迁移
+// @Summary 恢复对象迁移
+// @Description 下一次调度时从暂停处继续迁移
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200
+// @Failure 400
+// @Router /admin/rebalance/resume [POST]
+func (ctrl *AdminController) ResumeRebalance(ctx *gin.Context) error {
+	return ctrl.RebalanceSvc.Resume(ctx)
+}
+
+// GetScrubReport 获取副本校验报告
+// @Summary 获取副本校验报告
+// @Description 每个桶最近一次完成副本校验的时间以及发现和修复的问题数
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {array} types.ScrubReport
+// @Failure 400
+// @Router /admin/scrub [GET]
+func (ctrl *AdminController) GetScrubReport(ctx *gin.Context) (interface{}, error) {
+	return ctrl.ScrubSvc.GetReports(ctx)
+}
+
+// ListScrubIssues 分页查询副本校验发现的问题
+// @Summary 分页查询副本校验发现的问题
+// @Description 根据 bucket_name 分页查询缺失或损坏的副本
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param  types.ListScrubIssueReq query  types.ListScrubIssueReq false "查询条件"
+// @Success 200 {object} dao.PagedData
+// @Failure 400
+// @Router /admin/scrub/issues [GET]
+func (ctrl *AdminController) ListScrubIssues(ctx *gin.Context) (interface{}, error) {
+	req := types.ListScrubIssueReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.ScrubSvc.ListIssues(ctx, req.BucketName, req.Current, req.PageSize)
+}
+
+// GetReconcileReport 获取最近一次对账报告
+// @Summary 获取最近一次对账报告
+// @Description 元数据与存储节点定时对账发现的孤立数据、悬空元数据和不一致副本
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.ReconcileReport
+// @Failure 400
+// @Router /admin/reconcile [GET]
+func (ctrl *AdminController) GetReconcileReport(ctx *gin.Context) (interface{}, error) {
+	return ctrl.ReconcileSvc.GetLastReport(ctx)
+}
+
+// ListSyncers 获取后台任务列表
+// @Summary 获取后台任务列表
+// @Description 所有已注册的后台任务、是否启用、执行间隔以及最近一次执行记录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {array} types.SyncerInfo
+// @Failure 400
+// @Router /admin/syncers [GET]
+func (ctrl *AdminController) ListSyncers(ctx *gin.Context) (interface{}, error) {
+	return syncer.List(ctx)
+}
+
+// ListSyncerRuns 获取后台任务的执行记录
+// @Summary 获取后台任务的执行记录
+// @Description 根据 name 按时间倒序返回最近的执行记录
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名"
+// @Param limit query int false "返回的记录数"
+// @Success 200 {array} types.SyncerRun
+// @Failure 400
+// @Router /admin/syncers/:name/runs [GET]
+func (ctrl *AdminController) ListSyncerRuns(ctx *gin.Context) (interface{}, error) {
+	name := ctx.Param("name")
+	if _, ok := syncer.Lookup(name); !ok {
+		return nil, fmt.Errorf("%w: syncer %s not found", errors.ErrNotFound, name)
+	}
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	return ctrl.SyncerSvc.ListRuns(ctx, name, limit)
+}
+
+// TriggerSyncer 立即执行后台任务
+// @Summary 立即执行后台任务
+// @Description 根据 name 触发后台任务，持有该任务锁的网关会立即执行一次
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名"
+// @Success 200
+// @Failure 400
+// @Router /admin/syncers/:name/trigger [POST]
+func (ctrl *AdminController) TriggerSyncer(ctx *gin.Context) error {
+	name := ctx.Param("name")
+	if _, ok := syncer.Lookup(name); !ok {
+		return fmt.Errorf("%w: syncer %s not found", errors.ErrNotFound, name)
+	}
+	return ctrl.SyncerSvc.Trigger(ctx, name)
+}
+
+// GetSyncerLeader 获取后台任务当前的领导者
+// @Summary 获取后台任务当前的领导者
+// @Description 根据 name 返回正在执行该任务的网关实例、选主方式和 fencing token，没有领导者时返回空
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "任务名"
+// @Success 200 {object} types.SyncerLeader
+// @Failure 400
+// @Router /admin/syncers/:name/leader [GET]
+func (ctrl *AdminController) GetSyncerLeader(ctx *gin.Context) (interface{}, error) {
+	name := ctx.Param("name")
+	s, ok := syncer.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: syncer %s not found", errors.ErrNotFound, name)
+	}
+	return syncer.Leader(ctx, name, s)
+}
+
+// GetMetrics 获取网关性能指标摘要
+// @Summary 获取网关性能指标摘要
+// @Description 按最近一分钟计算的请求速率、错误率、平均延迟等，完整指标见 /metrics
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.PerformanceMetrics
+// @Router /admin/metrics [GET]
+func (ctrl *AdminController) GetMetrics(ctx *gin.Context) (interface{}, error) {
+	return metrics.Summary(), nil
+}
+
+// GetConfig 获取当前生效的配置
+// @Summary 获取当前生效的配置
+// @Description 返回配置版本、来源、需要重启才能生效的配置项、最近一次被拒绝的更新以及配置内容（不含密钥）
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} config.Status
+// @Failure 400
+// @Router /admin/config [GET]
+func (ctrl *AdminController) GetConfig(ctx *gin.Context) (interface{}, error) {
+	return config.GetStatus(), nil
+}
+
+// ReloadConfig 重新加载配置
+// @Summary 重新加载配置
+// @Description 立即重新读取配置文件，校验失败时保留当前配置并返回错误
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} config.Status
+// @Failure 400
+// @Router /admin/config/reload [POST]
+func (ctrl *AdminController) ReloadConfig(ctx *gin.Context) (interface{}, error) {
+	if err := config.Reload(config.ReloadSourceManual); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrBadRequest, err)
+	}
+	return config.GetStatus(), nil
+}
+
+// ListAuditLogs 分页查询审计日志
+// @Summary 分页查询审计日志
+// @Description 按用户、操作类型、桶、对象、结果和时间范围分页查询数据和管理操作的审计记录，按操作时间倒序
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param  types.ListAuditLogReq query  types.ListAuditLogReq false "查询条件"
+// @Success 200 {object} dao.PagedData
+// @Failure 400
+// @Router /admin/audit [GET]
+func (ctrl *AdminController) ListAuditLogs(ctx *gin.Context) (interface{}, error) {
+	req := types.ListAuditLogReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.AuditSvc.List(ctx, req)
+}
+
+// GetQuotaOverview 获取所有桶和用户的用量
+// @Summary 获取所有桶和用户的用量
+// @Description 所有已登记的桶、拥有桶或设置了配额的用户的用量与配额，以及最近一次按元数据重新统计的结果
+// @Tags admin
+// @Produce json
+// @Success 200 {object} types.UsageOverview
+// @Failure 400
+// @Router /admin/quota [GET]
+func (ctrl *AdminController) GetQuotaOverview(ctx *gin.Context) (interface{}, error) {
+	return ctrl.QuotaSvc.Overview(ctx)
+}
+
+// GetUserQuota 获取用户配额
+// @Summary 获取用户配额
+// @Description 没有设置配额的用户返回全 0，表示不限制
+// @Tags admin
+// @Produce json
+// @Param id path int true "用户 ID"
+// @Success 200 {object} types.UserQuota
+// @Failure 400
+// @Router /admin/quota/user/{id} [GET]
+func (ctrl *AdminController) GetUserQuota(ctx *gin.Context) (interface{}, error) {
+	userID, err := userIDParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctrl.QuotaSvc.GetUserQuota(ctx, userID)
+}
+
+// PutUserQuota 设置用户配额
+// @Summary 设置用户配额
+// @Description 设置用户拥有的所有桶合计的硬配额和软配额，为 0 的项不限制
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "用户 ID"
+// @Param types.Quota body types.Quota true "配额"
+// @Success 200 {object} types.UserQuota
+// @Failure 400
+// @Failure 404
+// @Router /admin/quota/user/{id} [PUT]
+func (ctrl *AdminController) PutUserQuota(ctx *gin.Context) (interface{}, error) {
+	userID, err := userIDParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	quota := types.Quota{}
+	if err = ctx.ShouldBindJSON(&quota); err != nil {
+		return nil, fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.QuotaSvc.PutUserQuota(ctx, userID, quota)
+}
+
+// GetLifecycleReport 获取最近一次执行生命周期规则的结果
+// @Summary 获取最近一次执行生命周期规则的结果
+// @Description 删除的对象数、历史版本数、取消的分片上传数以及失败次数
+// @Tags admin
+// @Produce json
+// @Success 200 {object} types.LifecycleReport
+// @Failure 400
+// @Router /admin/lifecycle [GET]
+func (ctrl *AdminController) GetLifecycleReport(ctx *gin.Context) (interface{}, error) {
+	return ctrl.LifecycleSvc.GetLastReport(ctx)
+}
+
+// ListLifecycleLogs 分页查询生命周期规则删除的内容
+// @Summary 分页查询生命周期规则删除的内容
+// @Description 根据 bucket_name 和 action（expire/noncurrent/abort_upload）分页查询
+// @Tags admin
+// @Produce json
+// @Param  types.ListLifecycleLogReq query  types.ListLifecycleLogReq false "查询条件"
+// @Success 200 {object} dao.PagedData
+// @Failure 400
+// @Router /admin/lifecycle/logs [GET]
+func (ctrl *AdminController) ListLifecycleLogs(ctx *gin.Context) (interface{}, error) {
+	req := types.ListLifecycleLogReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.LifecycleSvc.ListLogs(ctx, req)
+}
+
+// GetReplicationStats 获取复制队列的积压情况
+// @Summary 获取复制队列的积压情况
+// @Description 待复制和复制失败的任务数，以及最早的待复制任务已等待的时间
+// @Tags admin
+// @Produce json
+// @Success 200 {object} types.ReplicationStats
+// @Failure 400
+// @Router /admin/replication [GET]
+func (ctrl *AdminController) GetReplicationStats(ctx *gin.Context) (interface{}, error) {
+	return ctrl.ReplicationSvc.Stats(ctx)
+}
+
+// ListReplicationTasks 分页查询复制任务
+// @Summary 分页查询复制任务
+// @Description 根据 bucket_name 和 state（PENDING/FAILED）分页查询复制队列中的任务
+// @Tags admin
+// @Produce json
+// @Param  types.ListReplicationTaskReq query  types.ListReplicationTaskReq false "查询条件"
+// @Success 200 {object} dao.PagedData
+// @Failure 400
+// @Router /admin/replication/tasks [GET]
+func (ctrl *AdminController) ListReplicationTasks(ctx *gin.Context) (interface{}, error) {
+	req := types.ListReplicationTaskReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.ReplicationSvc.ListTasks(ctx, req)
+}
@@ -1,13 +1,14 @@
 package controller
 
 import (
+	"distributed-object-storage/errors"
 	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/pkg/middleware"
+	"distributed-object-storage/service"
 	"distributed-object-storage/svc"
 	"distributed-object-storage/types"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"net/http"
 )
 
 type AuthController struct {
@@ -25,57 +26,50 @@ func NewAuthController(daoS *dao.S) *AuthController {
 
 func (ctrl *AuthController) RegisterRouter(r gin.IRouter) {
 	g := r.Group("")
-	g.POST("/login", ctrl.Login)
-	g.POST("/register", ctrl.Register)
+	g.POST("/login", service.DataHandlerWrapper(ctrl.Login))
+	g.POST("/register", service.DataHandlerWrapper(ctrl.Register))
 }
 
-func (ctrl *AuthController) Login(ctx *gin.Context) {
+func (ctrl *AuthController) Login(ctx *gin.Context) (interface{}, error) {
 	var loginInfo struct {
 		Username string `json:"username" form:"username"`
 		Password string `json:"password" form:"password"`
 	}
-	if err := ctx.Bind(&loginInfo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": err.Error()})
-		return
+	if err := ctx.ShouldBind(&loginInfo); err != nil {
+		return nil, fmt.Errorf("%w: %v", errors.ErrBadRequest, err)
 	}
 	userInfo, err := ctrl.userSvc.GetUserInfoByName(loginInfo.Username)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "error": "Invalid username or password"})
-		return
+		return nil, errors.WithCode(errors.CodeInvalidCredentials, "invalid username or password")
 	}
 
 	if !ctrl.authSvc.AuthenticateUser(loginInfo.Password, userInfo.PassWord) {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"status": http.StatusUnauthorized, "error": "Invalid username 1or password"})
-		return
+		return nil, errors.WithCode(errors.CodeInvalidCredentials, "invalid username or password")
 	}
 
 	// 账号密码验证成功，生成 JWT 令牌并返回给用户
-	tokenString, err := middleware.GenerateJWT(userInfo.Id, "rq")
+	tokenString, err := middleware.GenerateJWT(userInfo.Id, userInfo.UserName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "error": "Failed to generate token"})
-		return
+		return nil, fmt.Errorf("generate token: %w", err)
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"token": tokenString})
+	return gin.H{"token": tokenString}, nil
 }
 
-func (ctrl *AuthController) Register(ctx *gin.Context) {
+func (ctrl *AuthController) Register(ctx *gin.Context) (interface{}, error) {
 	var registerInfo struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindJSON(&registerInfo); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": http.StatusBadRequest, "error": err.Error()})
-		return
+		return nil, fmt.Errorf("%w: %v", errors.ErrBadRequest, err)
 	}
 
 	// 检查用户名是否已存在
 	existingUser, err := ctrl.userSvc.GetUserInfoByName(registerInfo.Username)
 	if existingUser != nil {
-		fmt.Println(err)
-		ctx.JSON(http.StatusConflict, gin.H{"status": http.StatusConflict, "error": "user already exists"})
-		return
+		return nil, errors.WithCode(errors.CodeUserAlreadyExists, "user %s already exists", registerInfo.Username)
 	}
 
 	// 创建新用户
@@ -86,16 +80,14 @@ func (ctrl *AuthController) Register(ctx *gin.Context) {
 
 	err = ctrl.userSvc.CreateUser(ctx.Request.Context(), userInfo)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "error": "Failed to create user"})
-		return
+		return nil, fmt.Errorf("create user: %w", err)
 	}
 
 	// 生成 JWT 令牌
 	tokenString, err := middleware.GenerateJWT(uint(int(userInfo.Id)), userInfo.UserName)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"status": http.StatusInternalServerError, "error": "Failed to generate token"})
-		return
+		return nil, fmt.Errorf("generate token: %w", err)
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"status": http.StatusCreated, "data": gin.H{"id": userInfo.Id, "username": userInfo.UserName, "token": tokenString}})
+	return gin.H{"id": userInfo.Id, "username": userInfo.UserName, "token": tokenString}, nil
 }
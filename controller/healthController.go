@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type HealthController struct {
+	HealthSvc *svc.HealthSvc
+}
+
+func NewHealthController() *HealthController {
+	return &HealthController{
+		HealthSvc: svc.NewHealthSvc(),
+	}
+}
+
+// RegisterRouter 探针接口不需要登录，供 Kubernetes 和负载均衡使用
+func (ctrl *HealthController) RegisterRouter(r gin.IRouter) {
+	r.GET("/livez", ctrl.Livez)
+	r.GET("/readyz", ctrl.Readyz)
+}
+
+// Livez 存活检查
+// @Summary 存活检查
+// @Description 进程能够处理请求即返回 200，不检查依赖
+// @Tags health
+// @Produce json
+// @Success 200
+// @Router /livez [GET]
+func (ctrl *HealthController) Livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": types.HealthStatusOK})
+}
+
+// Readyz 就绪检查
+// @Summary 就绪检查
+// @Description 检查 MySQL、Redis、etcd 以及是否至少有一个健康的存储节点，返回每个依赖的状态和耗时
+// @Tags health
+// @Produce json
+// @Success 200 {object} types.Readiness
+// @Failure 503 {object} types.Readiness "有依赖异常"
+// @Router /readyz [GET]
+func (ctrl *HealthController) Readyz(ctx *gin.Context) {
+	res := ctrl.HealthSvc.Ready(ctx)
+	code := http.StatusOK
+	if res.Status != types.HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, res)
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"distributed-object-storage/errors"
 	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/pkg/middleware"
 	"distributed-object-storage/service"
@@ -8,15 +9,24 @@ import (
 	"distributed-object-storage/types"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"strconv"
 )
 
 type MetadataNodeController struct {
 	MetadataNodeSvc *svc.MetadataSvc
+	QuotaSvc        *svc.QuotaSvc
+	LifecycleSvc    *svc.LifecycleSvc
+	ReplicationSvc  *svc.ReplicationSvc
+	DeleteJobSvc    *svc.DeleteJobSvc
 }
 
 func NewMetadataNodeController(daoS *dao.S) *MetadataNodeController {
 	return &MetadataNodeController{
 		MetadataNodeSvc: svc.NewMetadataSvc(daoS),
+		QuotaSvc:        svc.NewQuotaSvc(daoS),
+		LifecycleSvc:    svc.NewLifecycleSvc(daoS),
+		ReplicationSvc:  svc.NewReplicationSvc(daoS),
+		DeleteJobSvc:    svc.NewDeleteJobSvc(daoS),
 	}
 }
 
@@ -25,8 +35,18 @@ func (ctrl *MetadataNodeController) RegisterRouter(r gin.IRouter) {
 	g.GET("/object", service.DataHandlerWrapper(ctrl.GetObjectMetadata))
 	g.GET("/object/list", service.DataHandlerWrapper(ctrl.ListObjectMetadata))
 	g.GET("/bucket/list", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.ListBucket))
-	g.POST("/bucket/:name", service.NoDataHandlerWrapper(ctrl.CreateBucket))
-	g.DELETE("/bucket/:name", service.NoDataHandlerWrapper(ctrl.DeleteBucket))
+	g.POST("/bucket/:name", middleware.AuthMiddleware(), service.NoDataHandlerWrapper(ctrl.CreateBucket))
+	g.DELETE("/bucket/:name", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.DeleteBucket))
+	g.GET("/bucket/:name/config", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.GetBucketConfig))
+	g.PUT("/bucket/:name/config", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.PutBucketConfig))
+	g.GET("/bucket/:name/usage", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.GetBucketUsage))
+	g.GET("/bucket/:name/lifecycle", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.GetBucketLifecycle))
+	g.PUT("/bucket/:name/lifecycle", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.PutBucketLifecycle))
+	g.DELETE("/bucket/:name/lifecycle", middleware.AuthMiddleware(), service.NoDataHandlerWrapper(ctrl.DeleteBucketLifecycle))
+	g.GET("/bucket/:name/replication", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.GetBucketReplication))
+	g.PUT("/bucket/:name/replication", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.PutBucketReplication))
+	g.DELETE("/bucket/:name/replication", middleware.AuthMiddleware(), service.NoDataHandlerWrapper(ctrl.DeleteBucketReplication))
+	g.POST("/bucket/:name/replication/resync", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.ResyncBucketReplication))
 
 }
 
@@ -43,13 +63,13 @@ func (ctrl *MetadataNodeController) RegisterRouter(r gin.IRouter) {
 func (ctrl *MetadataNodeController) GetObjectMetadata(ctx *gin.Context) (interface{}, error) {
 	options := types.GetObjectMetadataReq{}
 	if err := ctx.ShouldBindQuery(&options); err != nil {
-		return nil, fmt.Errorf("invaild query parameter: %v", err)
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
 	}
 	if options.ObjectName == "" {
-		return nil, fmt.Errorf("empty object name")
+		return nil, fmt.Errorf("%w: empty object name", errors.ErrBadRequest)
 	}
 	if options.BucketName == "" {
-		return nil, fmt.Errorf("empty bucket name")
+		return nil, fmt.Errorf("%w: empty bucket name", errors.ErrBadRequest)
 	}
 	return ctrl.MetadataNodeSvc.GetObjectMetadata(ctx, options.BucketName, options.ObjectName)
 }
@@ -69,10 +89,10 @@ func (ctrl *MetadataNodeController) GetObjectMetadata(ctx *gin.Context) (interfa
 func (ctrl *MetadataNodeController) ListObjectMetadata(ctx *gin.Context) (interface{}, error) {
 	options := types.ListObjectMetadataReq{}
 	if err := ctx.ShouldBindQuery(&options); err != nil {
-		return nil, fmt.Errorf("invaild query parameter: %v", err)
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
 	}
 	if options.BucketName == "" {
-		return nil, fmt.Errorf("empty bucket name")
+		return nil, fmt.Errorf("%w: empty bucket name", errors.ErrBadRequest)
 	}
 	return ctrl.MetadataNodeSvc.ListObjects(ctx, options.BucketName, options.Prefix, options.MaxKeys)
 }
@@ -91,43 +111,216 @@ func (ctrl *MetadataNodeController) ListObjectMetadata(ctx *gin.Context) (interf
 func (ctrl *MetadataNodeController) ListBucket(ctx *gin.Context) (interface{}, error) {
 	options := types.ListBucketReq{}
 	if err := ctx.ShouldBindQuery(&options); err != nil {
-		return nil, fmt.Errorf("invaild query parameter: %v", err)
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
 	}
 	return ctrl.MetadataNodeSvc.ListBuckets(ctx, options.Prefix, options.MaxKeys)
 }
 
 // CreateBucket 创建Bucket
 // @Summary 创建Bucket
-// @Description 根据 name 创建Bucket
+// @Description 根据 name 创建Bucket，可以在请求体中指定区域、版本控制、副本数、存储类型、配额和标签
 // @Tags metadata
 // @Accept json
 // @Produce json
 // @Param name path string true "Bucket名字"
+// @Param types.CreateBucketReq body types.CreateBucketReq false "桶配置"
 // @Success 200
 // @Failure 400
-// @Router /metadata/:name [POST]
+// @Failure 409
+// @Router /metadata/bucket/{name} [POST]
 func (ctrl *MetadataNodeController) CreateBucket(ctx *gin.Context) error {
 	bucketName := ctx.Param("name")
 	if bucketName == "" {
-		return fmt.Errorf("invalid path param, %s is blank", bucketName)
+		return errors.WithCode(errors.CodeInvalidBucketName, "invalid path param, bucket name is blank")
 	}
-	return ctrl.MetadataNodeSvc.CreateBucket(ctx, bucketName)
+	options := types.CreateBucketReq{}
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&options); err != nil {
+			return fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+		}
+	}
+	return ctrl.MetadataNodeSvc.CreateBucket(ctx, types.BucketConfig{
+		Name:           bucketName,
+		OwnerID:        ctx.GetUint(middleware.ContextUserID),
+		Owner:          ctx.GetString(middleware.ContextUserName),
+		Region:         options.Region,
+		BucketSettings: options.BucketSettings,
+	})
+}
+
+// GetBucketConfig 获取Bucket配置
+// @Summary 获取Bucket配置
+// @Description 返回桶注册表中记录的版本控制、副本数、存储类型、配额和标签
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200 {object} types.BucketConfig
+// @Failure 404
+// @Router /metadata/bucket/{name}/config [GET]
+func (ctrl *MetadataNodeController) GetBucketConfig(ctx *gin.Context) (interface{}, error) {
+	return ctrl.MetadataNodeSvc.BucketSvc.GetConfig(ctx, ctx.Param("name"))
+}
+
+// PutBucketConfig 修改Bucket配置
+// @Summary 修改Bucket配置
+// @Description 整体替换桶可以修改的配置，未填写的字段恢复默认值；开启过版本控制的桶只能暂停
+// @Tags metadata
+// @Accept json
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Param types.BucketSettings body types.BucketSettings true "桶配置"
+// @Success 200 {object} types.BucketConfig
+// @Failure 400
+// @Failure 404
+// @Router /metadata/bucket/{name}/config [PUT]
+func (ctrl *MetadataNodeController) PutBucketConfig(ctx *gin.Context) (interface{}, error) {
+	settings := types.BucketSettings{}
+	if err := ctx.ShouldBindJSON(&settings); err != nil {
+		return nil, fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.MetadataNodeSvc.BucketSvc.PutConfig(ctx, ctx.Param("name"), settings)
 }
 
 // DeleteBucket 删除Bucket
 // @Summary 删除Bucket
-// @Description 根据 name 删除Bucket
+// @Description 根据 name 删除Bucket，桶不为空时返回 BucketNotEmpty；force 为 true 时创建异步任务清空桶内所有对象和版本后删除桶，
+// @Description 返回任务的 id，通过 /storage/delete/jobs/:id 查询进度
 // @Tags metadata
 // @Accept json
 // @Produce json
 // @Param name path string true "Bucket名字"
-// @Success 200
+// @Param force query bool false "先清空桶再删除"
+// @Success 200 {object} object "force 为 true 时返回任务的 id、状态和进度"
 // @Failure 400
+// @Failure 409 {object} service.Response "BucketNotEmpty"
 // @Router /metadata/:name [DELETE]
-func (ctrl *MetadataNodeController) DeleteBucket(ctx *gin.Context) error {
+func (ctrl *MetadataNodeController) DeleteBucket(ctx *gin.Context) (interface{}, error) {
 	bucketName := ctx.Param("name")
 	if bucketName == "" {
-		return fmt.Errorf("invalid path param, %s is blank", bucketName)
+		return nil, errors.WithCode(errors.CodeInvalidBucketName, "invalid path param, bucket name is blank")
+	}
+	force, err := strconv.ParseBool(ctx.DefaultQuery("force", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid force: %v", errors.ErrBadRequest, err)
+	}
+	if force {
+		return ctrl.DeleteJobSvc.CreateBucketJob(ctx, bucketName)
 	}
-	return ctrl.MetadataNodeSvc.DeleteBucket(ctx, bucketName)
+	return nil, ctrl.MetadataNodeSvc.DeleteBucket(ctx, bucketName)
+}
+
+// GetBucketUsage 获取Bucket用量
+// @Summary 获取Bucket用量
+// @Description 返回桶内对象的总大小、对象数以及是否超过软配额和硬配额
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200 {object} types.UsageReport
+// @Failure 404
+// @Router /metadata/bucket/{name}/usage [GET]
+func (ctrl *MetadataNodeController) GetBucketUsage(ctx *gin.Context) (interface{}, error) {
+	return ctrl.QuotaSvc.BucketReport(ctx, ctx.Param("name"))
+}
+
+// GetBucketLifecycle 获取Bucket生命周期规则
+// @Summary 获取Bucket生命周期规则
+// @Description 返回桶的生命周期规则，没有设置时 rules 为空
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200 {object} types.BucketLifecycle
+// @Failure 404
+// @Router /metadata/bucket/{name}/lifecycle [GET]
+func (ctrl *MetadataNodeController) GetBucketLifecycle(ctx *gin.Context) (interface{}, error) {
+	return ctrl.LifecycleSvc.GetLifecycle(ctx, ctx.Param("name"))
+}
+
+// PutBucketLifecycle 设置Bucket生命周期规则
+// @Summary 设置Bucket生命周期规则
+// @Description 整体替换桶的生命周期规则：按前缀和标签过期删除对象、删除历史版本、取消未完成的分片上传
+// @Tags metadata
+// @Accept json
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Param types.BucketLifecycle body types.BucketLifecycle true "生命周期规则"
+// @Success 200 {object} types.BucketLifecycle
+// @Failure 400
+// @Failure 404
+// @Router /metadata/bucket/{name}/lifecycle [PUT]
+func (ctrl *MetadataNodeController) PutBucketLifecycle(ctx *gin.Context) (interface{}, error) {
+	lifecycle := types.BucketLifecycle{}
+	if err := ctx.ShouldBindJSON(&lifecycle); err != nil {
+		return nil, fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.LifecycleSvc.PutLifecycle(ctx, ctx.Param("name"), lifecycle)
+}
+
+// DeleteBucketLifecycle 删除Bucket生命周期规则
+// @Summary 删除Bucket生命周期规则
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200
+// @Failure 404
+// @Router /metadata/bucket/{name}/lifecycle [DELETE]
+func (ctrl *MetadataNodeController) DeleteBucketLifecycle(ctx *gin.Context) error {
+	return ctrl.LifecycleSvc.DeleteLifecycle(ctx, ctx.Param("name"))
+}
+
+// GetBucketReplication 获取Bucket复制规则
+// @Summary 获取Bucket复制规则
+// @Description 返回桶的跨集群复制规则，不返回目标的 secret_key，没有设置时 rules 为空
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200 {object} types.BucketReplication
+// @Failure 404
+// @Router /metadata/bucket/{name}/replication [GET]
+func (ctrl *MetadataNodeController) GetBucketReplication(ctx *gin.Context) (interface{}, error) {
+	return ctrl.ReplicationSvc.GetReplication(ctx, ctx.Param("name"))
+}
+
+// PutBucketReplication 设置Bucket复制规则
+// @Summary 设置Bucket复制规则
+// @Description 整体替换桶的复制规则，之后写入和删除的对象异步复制到 S3 兼容存储或另一个集群的网关；secret_key 为空时沿用同一规则原来的值
+// @Tags metadata
+// @Accept json
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Param types.BucketReplication body types.BucketReplication true "复制规则"
+// @Success 200 {object} types.BucketReplication
+// @Failure 400
+// @Failure 404
+// @Router /metadata/bucket/{name}/replication [PUT]
+func (ctrl *MetadataNodeController) PutBucketReplication(ctx *gin.Context) (interface{}, error) {
+	replication := types.BucketReplication{}
+	if err := ctx.ShouldBindJSON(&replication); err != nil {
+		return nil, fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.ReplicationSvc.PutReplication(ctx, ctx.Param("name"), replication)
+}
+
+// DeleteBucketReplication 删除Bucket复制规则
+// @Summary 删除Bucket复制规则
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200
+// @Failure 404
+// @Router /metadata/bucket/{name}/replication [DELETE]
+func (ctrl *MetadataNodeController) DeleteBucketReplication(ctx *gin.Context) error {
+	return ctrl.ReplicationSvc.DeleteReplication(ctx, ctx.Param("name"))
+}
+
+// ResyncBucketReplication 重新同步Bucket内已有的对象
+// @Summary 重新同步Bucket内已有的对象
+// @Description 将桶内所有匹配复制规则的已有对象重新加入复制队列，用于新增规则或复制失败之后
+// @Tags metadata
+// @Produce json
+// @Param name path string true "Bucket名字"
+// @Success 200 {object} types.ReplicationResyncReport
+// @Failure 404
+// @Router /metadata/bucket/{name}/replication/resync [POST]
+func (ctrl *MetadataNodeController) ResyncBucketReplication(ctx *gin.Context) (interface{}, error) {
+	return ctrl.ReplicationSvc.Resync(ctx, ctx.Param("name"))
 }
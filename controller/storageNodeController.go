@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"context"
 	"crypto/md5"
+	"distributed-object-storage/errors"
 	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/middleware"
 	"distributed-object-storage/service"
 	"distributed-object-storage/svc"
 	"distributed-object-storage/types"
@@ -10,58 +13,70 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"io"
-	"net/http"
+	"strconv"
 	"time"
 )
 
 type StorageNodeController struct {
 	MetadataNodeSvc *svc.MetadataSvc
 	StorageNodeSvc  *svc.StorageNodeSvc
+	DeleteJobSvc    *svc.DeleteJobSvc
 }
 
 func NewStorageNodeController(daoS *dao.S) *StorageNodeController {
 	return &StorageNodeController{
 		MetadataNodeSvc: svc.NewMetadataSvc(daoS),
 		StorageNodeSvc:  svc.NewStorageNodeSvc(daoS),
+		DeleteJobSvc:    svc.NewDeleteJobSvc(daoS),
 	}
 }
 
 func (ctrl *StorageNodeController) RegisterRouter(r gin.IRouter) {
 	g := r.Group("/storage") // middwares.AuthMiddleware()
-	g.POST("/upload", ctrl.PutObject)
+	g.POST("/upload", service.DataHandlerWrapper(ctrl.PutObject))
 	g.GET("/object", ctrl.GetObject)
-	g.POST("/pause/:uploadId", handlePause)
-	g.POST("/resume/:uploadId", handleResume)
-	g.POST("/cancel/:uploadId", handleCancel)
-	g.GET("/status/:uploadId", handleStatus)
+	g.POST("/pause/:uploadId", service.DataHandlerWrapper(handlePause))
+	g.POST("/resume/:uploadId", service.DataHandlerWrapper(handleResume))
+	g.POST("/cancel/:uploadId", service.DataHandlerWrapper(handleCancel))
+	g.GET("/status/:uploadId", service.DataHandlerWrapper(handleStatus))
 	g.DELETE("/delete", service.NoDataHandlerWrapper(ctrl.DeleteObject))
+	g.POST("/delete/batch", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.DeleteObjects))
+	g.POST("/delete/prefix", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.DeletePrefix))
+	g.GET("/delete/jobs", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.ListDeleteJobs))
+	g.GET("/delete/jobs/:id", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.GetDeleteJob))
+	g.POST("/restore", middleware.AuthMiddleware(), service.NoDataHandlerWrapper(ctrl.RestoreObject))
+	g.POST("/copy", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.CopyObject))
+	g.POST("/rename", middleware.AuthMiddleware(), service.DataHandlerWrapper(ctrl.RenameObject))
+	g.PUT("/replica", middleware.ReplicaAuthMiddleware(), service.NoDataHandlerWrapper(ctrl.PutReplica))
+	g.DELETE("/replica", middleware.ReplicaAuthMiddleware(), service.NoDataHandlerWrapper(ctrl.DeleteReplica))
 }
 
-func handleResume(c *gin.Context) {
-	uploadID := c.Param("uploadId")
+// uploadTask 返回进行中的上传任务，不存在时返回 NoSuchUpload
+func uploadTask(uploadID string) (*types.UploadStatus, error) {
 	types.UploadTasks.RLock()
 	status, exists := types.UploadTasks.Tasks[uploadID]
 	types.UploadTasks.RUnlock()
 	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Upload task not found"})
-		return
+		return nil, errors.WithCode(errors.CodeNoSuchUpload, "upload task %s not found", uploadID)
+	}
+	return status, nil
+}
+
+func handleResume(c *gin.Context) (interface{}, error) {
+	status, err := uploadTask(c.Param("uploadId"))
+	if err != nil {
+		return nil, err
 	}
 	status.Mutex.Lock()
 	status.IsPaused = false
 	status.Mutex.Unlock()
-	c.JSON(http.StatusOK, gin.H{"message": "Upload resumed"})
+	return gin.H{"message": "Upload resumed"}, nil
 }
 
-func handleStatus(c *gin.Context) {
-	uploadID := c.Param("uploadId")
-
-	types.UploadTasks.RLock()
-	status, exists := types.UploadTasks.Tasks[uploadID]
-	types.UploadTasks.RUnlock()
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Upload task not found"})
-		return
+func handleStatus(c *gin.Context) (interface{}, error) {
+	status, err := uploadTask(c.Param("uploadId"))
+	if err != nil {
+		return nil, err
 	}
 
 	status.Mutex.Lock()
@@ -71,20 +86,19 @@ func handleStatus(c *gin.Context) {
 		"current_part":    status.CurrentPart,
 		"completed_parts": len(status.CompletedParts),
 	}
+	if status.Error != nil {
+		response["error"] = status.Error.Error()
+		response["error_code"] = errors.ParseCoder(status.Error).Name()
+	}
 	status.Mutex.Unlock()
-	c.JSON(http.StatusOK, response)
+	return response, nil
 }
 
-func handleCancel(c *gin.Context) {
+func handleCancel(c *gin.Context) (interface{}, error) {
 	uploadID := c.Param("uploadId")
-
-	types.UploadTasks.RLock()
-	status, exists := types.UploadTasks.Tasks[uploadID]
-	types.UploadTasks.RUnlock()
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Upload task not found"})
-		return
+	status, err := uploadTask(uploadID)
+	if err != nil {
+		return nil, err
 	}
 
 	status.Mutex.Lock()
@@ -96,25 +110,20 @@ func handleCancel(c *gin.Context) {
 	delete(types.UploadTasks.Tasks, uploadID)
 	types.UploadTasks.Unlock()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Upload canceled"})
+	return gin.H{"message": "Upload canceled"}, nil
 }
-func handlePause(c *gin.Context) {
-	uploadID := c.Param("uploadId")
 
-	types.UploadTasks.RLock()
-	status, exists := types.UploadTasks.Tasks[uploadID]
-	types.UploadTasks.RUnlock()
-
-	if !exists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Upload task not found"})
-		return
+func handlePause(c *gin.Context) (interface{}, error) {
+	status, err := uploadTask(c.Param("uploadId"))
+	if err != nil {
+		return nil, err
 	}
 
 	status.Mutex.Lock()
 	status.IsPaused = true
 	status.Mutex.Unlock()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Upload paused"})
+	return gin.H{"message": "Upload paused"}, nil
 }
 
 // PutObject 上传文件
@@ -126,16 +135,52 @@ func handlePause(c *gin.Context) {
 // @Param bucket_name formData string true "Bucket Name"
 // @Param object_name formData string true "Object Name"
 // @Param file formData file true "File to upload"
-// @Success 200 {string} string "成功返回上传的文件ETag"
-// @Failure 400   "错误响应"
+// @Param If-Match header string false "对象当前的 ETag 与之相同时才写入"
+// @Param If-None-Match header string false "为 * 时只在对象不存在时写入"
+// @Success 200 {object} service.Response "写入条件已检查通过，返回 upload_id，上传在后台进行，通过 /storage/status/:uploadId 查询是否完成"
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 403 {object} service.Response "QuotaExceeded"
+// @Failure 412   "写入条件不满足"
+// @Failure 503   "服务正在关闭"
 // @Router /storage/upload [POST]
-func (ctrl *StorageNodeController) PutObject(ctx *gin.Context) {
+func (ctrl *StorageNodeController) PutObject(ctx *gin.Context) (interface{}, error) {
 	bucketName := ctx.PostForm("bucket_name")
 	objectName := ctx.PostForm("object_name")
 
 	if bucketName == "" || objectName == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "bucketName or objectName is empty"})
-		return
+		return nil, fmt.Errorf("%w: bucket_name or object_name is empty", errors.ErrBadRequest)
+	}
+	cond := types.WriteCondition{
+		IfMatch:     ctx.GetHeader("If-Match"),
+		IfNoneMatch: ctx.GetHeader("If-None-Match"),
+	}
+
+	// 请求返回后表单中的临时文件会被删除，先打开文件再交给后台上传
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("%w: read file from form: %v", errors.ErrBadRequest, err)
+	}
+	// 同样先检查一次配额以便立即返回 QuotaExceeded，写入数据前会再原子地检查并预占用量
+	if err = ctrl.StorageNodeSvc.CheckQuota(ctx, bucketName, objectName, header.Size); err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open uploaded file: %w", err)
+	}
+
+	// 服务关闭过程中不再接受新的后台上传
+	if !svc.Uploads.Add() {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w: server is shutting down", errors.ErrUnavailable)
+	}
+	// 上传在后台进行，返回前获取对象锁并检查写入条件，条件不满足时直接返回 412；
+	// 锁持有到后台上传结束，期间其他写入不会使检查结果失效
+	pending, err := ctrl.StorageNodeSvc.BeginPutObject(ctx, bucketName, objectName, cond)
+	if err != nil {
+		svc.Uploads.Done()
+		_ = file.Close()
+		return nil, err
 	}
 
 	// 生成 uploadId
@@ -152,39 +197,13 @@ func (ctrl *StorageNodeController) PutObject(ctx *gin.Context) {
 	types.UploadTasks.Tasks[uploadStatus.UploadID] = uploadStatus
 	types.UploadTasks.Unlock()
 
-	// 立即返回响应
-	ctx.JSON(http.StatusOK, gin.H{
-		"upload_id": uploadStatus.UploadID,
-		"message":   "Upload started",
-	})
-
-	// 在 goroutine 中打开和处理文件
+	// 请求返回后 context 会被取消，后台上传只沿用其中的链路信息
+	uploadCtx := context.WithoutCancel(ctx.Request.Context())
 	go func() {
-		err := ctx.Request.ParseMultipartForm(32 << 20) // 32MB buffer
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		// 获取文件头信息
-		header, err := ctx.FormFile("file")
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-
-		file, err := header.Open()
-		if err != nil {
-			types.UploadTasks.Lock()
-			if task, ok := types.UploadTasks.Tasks[uploadStatus.UploadID]; ok {
-				task.Error = err
-			}
-			types.UploadTasks.Unlock()
-			return
-		}
+		defer svc.Uploads.Done()
 		defer file.Close()
 
-		_, err = ctrl.StorageNodeSvc.PutObject(ctx, bucketName, objectName, file, header.Size, uploadStatus.UploadID)
+		_, err := pending.Put(uploadCtx, file, header.Size, uploadStatus.UploadID)
 		if err != nil {
 			types.UploadTasks.Lock()
 			if task, ok := types.UploadTasks.Tasks[uploadStatus.UploadID]; ok {
@@ -193,6 +212,12 @@ func (ctrl *StorageNodeController) PutObject(ctx *gin.Context) {
 			types.UploadTasks.Unlock()
 		}
 	}()
+
+	// 立即返回，通过 /storage/status/:uploadId 查询上传进度
+	return gin.H{
+		"upload_id": uploadStatus.UploadID,
+		"message":   "Upload started",
+	}, nil
 }
 
 // GetObject 下载分文
@@ -203,20 +228,21 @@ func (ctrl *StorageNodeController) PutObject(ctx *gin.Context) {
 // @Produce json
 // @Param  types.GetObjectMetadataReq query  types.GetObjectMetadataReq true "返回文件的相关信息"
 // @Success 200 {object} object "成功返回上传的文件信息"
-// @Failure 400
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 404 {object} service.Response "NoSuchBucket 或 NoSuchKey"
 // @Router /storage/object [GET]
 func (ctrl *StorageNodeController) GetObject(ctx *gin.Context) {
 	req := types.GetObjectMetadataReq{}
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		service.AbortWithError(ctx, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err))
+		return
 	}
-	res := types.GetObjectReq{BucketName: req.BucketName}
 	ioReader, objectInfo, err := ctrl.StorageNodeSvc.GetObject(ctx, req.BucketName, req.ObjectName)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		service.AbortWithError(ctx, err)
+		return
 	}
-	res.ObjectInfo = objectInfo
-	res.FileReader = ioReader
+	defer ioReader.Close()
 	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", objectInfo.Name))
 	ctx.Header("Content-Length", fmt.Sprintf("%d", objectInfo.Size))
 	ctx.Stream(func(w io.Writer) bool {
@@ -225,9 +251,8 @@ func (ctrl *StorageNodeController) GetObject(ctx *gin.Context) {
 			ctx.Error(err)
 			return false
 		}
-		return true // 复制完成后返回 false 结束流
+		return false // 复制完成后返回 false 结束流
 	})
-	return
 }
 
 // DeleteObject 删除文件
@@ -237,13 +262,211 @@ func (ctrl *StorageNodeController) GetObject(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param  types.GetObjectMetadataReq query  types.GetObjectMetadataReq true "Bucket Name"
+// @Param If-Match header string false "对象当前的 ETag 与之相同时才删除"
 // @Success 200
 // @Failure 400
+// @Failure 412   "删除条件不满足"
 // @Router /storage/delete [DELETE]
 func (ctrl *StorageNodeController) DeleteObject(ctx *gin.Context) error {
 	req := types.GetObjectMetadataReq{}
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		return fmt.Errorf("invaild query parameter: %v", err)
+		return fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	cond := types.WriteCondition{IfMatch: ctx.GetHeader("If-Match")}
+	return ctrl.StorageNodeSvc.DeleteObject(ctx, req.BucketName, req.ObjectName, cond)
+}
+
+// DeleteObjects 批量删除文件
+// @Summary 批量删除文件
+// @Description 删除桶内最多 1000 个对象，每个对象单独返回结果，对象不存在时视为删除成功；etag 不为空时只在对象未被修改时删除。quiet 为 true 时只返回删除失败的对象
+// @Tags storage
+// @Accept json
+// @Produce json
+// @Param bucket_name query string true "Bucket Name"
+// @Param quiet query bool false "只返回删除失败的对象"
+// @Param types.DeleteObjectsReq body types.DeleteObjectsReq true "要删除的对象"
+// @Success 200 {object} types.DeleteObjectsResult
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Router /storage/delete/batch [POST]
+func (ctrl *StorageNodeController) DeleteObjects(ctx *gin.Context) (interface{}, error) {
+	req := types.DeleteObjectsReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		return nil, fmt.Errorf("%w: invalid body: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.StorageNodeSvc.DeleteObjects(ctx, req)
+}
+
+// DeletePrefix 删除前缀下的所有文件
+// @Summary 删除前缀下的所有文件
+// @Description 创建异步任务删除桶内以 prefix 开头的所有对象，prefix 为空时清空整个桶；只删除创建任务之前写入的对象。通过 /storage/delete/jobs/:id 查询进度
+// @Tags storage
+// @Produce json
+// @Param  types.DeletePrefixReq query  types.DeletePrefixReq true "Bucket Name 和前缀"
+// @Success 200 {object} object "任务的 id、状态和进度"
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 404 {object} service.Response "NoSuchBucket"
+// @Router /storage/delete/prefix [POST]
+func (ctrl *StorageNodeController) DeletePrefix(ctx *gin.Context) (interface{}, error) {
+	req := types.DeletePrefixReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.DeleteJobSvc.CreateJob(ctx, req)
+}
+
+// GetDeleteJob 查询删除任务的进度
+// @Summary 查询删除任务的进度
+// @Tags storage
+// @Produce json
+// @Param id path int true "任务 id"
+// @Success 200 {object} object "任务的 id、状态和进度"
+// @Failure 404
+// @Router /storage/delete/jobs/{id} [GET]
+func (ctrl *StorageNodeController) GetDeleteJob(ctx *gin.Context) (interface{}, error) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid job id: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.DeleteJobSvc.GetJob(ctx, uint(id))
+}
+
+// ListDeleteJobs 分页查询删除任务
+// @Summary 分页查询删除任务
+// @Description 根据 bucket_name 和 state（PENDING/RUNNING/COMPLETED/FAILED）分页查询删除任务
+// @Tags storage
+// @Produce json
+// @Param  types.ListDeleteJobReq query  types.ListDeleteJobReq false "查询条件"
+// @Success 200 {object} dao.PagedData
+// @Failure 400
+// @Router /storage/delete/jobs [GET]
+func (ctrl *StorageNodeController) ListDeleteJobs(ctx *gin.Context) (interface{}, error) {
+	req := types.ListDeleteJobReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.DeleteJobSvc.ListJobs(ctx, req)
+}
+
+// RestoreObject 将冷存储中的对象恢复到存储节点
+// @Summary 恢复冷存储中的对象
+// @Description 将生命周期规则转移到冷存储的对象写回存储节点，对象不在冷存储中时不做处理
+// @Tags storage
+// @Accept json
+// @Produce json
+// @Param  types.GetObjectMetadataReq query  types.GetObjectMetadataReq true "Bucket Name"
+// @Success 200
+// @Failure 400 {object} service.Response "InvalidArgument 或未配置冷存储"
+// @Failure 404 {object} service.Response "NoSuchKey"
+// @Router /storage/restore [POST]
+func (ctrl *StorageNodeController) RestoreObject(ctx *gin.Context) error {
+	req := types.GetObjectMetadataReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	return ctrl.StorageNodeSvc.RestoreObject(ctx, req.BucketName, req.ObjectName)
+}
+
+// CopyObject 服务端复制对象
+// @Summary 服务端复制对象
+// @Description 将 source_bucket/source_object 复制为 bucket_name/object_name，数据不经过客户端。metadata_directive 为 REPLACE 时目标对象使用请求中的 content_type 和 storage_class
+// @Tags storage
+// @Produce json
+// @Param  types.CopyObjectReq query  types.CopyObjectReq true "源对象和目标对象"
+// @Param If-Match header string false "目标对象当前的 ETag 与之相同时才写入"
+// @Param If-None-Match header string false "为 * 时只在目标对象不存在时写入"
+// @Success 200 {object} types.CopyObjectResult
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 403 {object} service.Response "QuotaExceeded"
+// @Failure 404 {object} service.Response "NoSuchKey"
+// @Failure 412   "写入条件不满足"
+// @Router /storage/copy [POST]
+func (ctrl *StorageNodeController) CopyObject(ctx *gin.Context) (interface{}, error) {
+	req := types.CopyObjectReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	cond := types.WriteCondition{
+		IfMatch:     ctx.GetHeader("If-Match"),
+		IfNoneMatch: ctx.GetHeader("If-None-Match"),
+	}
+	return ctrl.StorageNodeSvc.CopyObject(ctx, req, cond)
+}
+
+// RenameObject 重命名对象
+// @Summary 重命名对象
+// @Description 将 bucket_name/object_name 重命名为 new_bucket_name/new_object_name，new_bucket_name 为空时在原来的桶内重命名。客户端看到的重命名是原子的
+// @Tags storage
+// @Produce json
+// @Param  types.RenameObjectReq query  types.RenameObjectReq true "原对象和新对象名"
+// @Param If-None-Match header string false "为 * 时只在新对象不存在时重命名"
+// @Success 200 {object} types.CopyObjectResult
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 404 {object} service.Response "NoSuchKey"
+// @Failure 412   "写入条件不满足"
+// @Router /storage/rename [POST]
+func (ctrl *StorageNodeController) RenameObject(ctx *gin.Context) (interface{}, error) {
+	req := types.RenameObjectReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return nil, fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	cond := types.WriteCondition{
+		IfMatch:     ctx.GetHeader("If-Match"),
+		IfNoneMatch: ctx.GetHeader("If-None-Match"),
+	}
+	return ctrl.StorageNodeSvc.RenameObject(ctx, req, cond)
+}
+
+// PutReplica 写入其他集群复制来的对象
+// @Summary 写入其他集群复制来的对象
+// @Description 供其他集群的复制任务调用，请求需带 X-Replica-Timestamp 和 X-Replica-Signature 签名；请求体为对象内容，同步写入后返回；对象标记为 REPLICA，不会再向外复制
+// @Tags storage
+// @Accept octet-stream
+// @Produce json
+// @Param  types.GetObjectMetadataReq query  types.GetObjectMetadataReq true "Bucket Name"
+// @Success 200
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 401 {object} service.Response "Unauthorized"
+// @Failure 403 {object} service.Response "QuotaExceeded"
+// @Router /storage/replica [PUT]
+func (ctrl *StorageNodeController) PutReplica(ctx *gin.Context) error {
+	req := types.GetObjectMetadataReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
+	}
+	if ctx.Request.ContentLength < 0 {
+		return fmt.Errorf("%w: Content-Length is required", errors.ErrBadRequest)
+	}
+	// 大对象分片写入时需要对应的上传任务，写入完成后删除
+	uploadID := fmt.Sprintf("replica-%d", time.Now().UnixNano())
+	types.UploadTasks.Lock()
+	types.UploadTasks.Tasks[uploadID] = &types.UploadStatus{UploadID: uploadID}
+	types.UploadTasks.Unlock()
+	defer func() {
+		types.UploadTasks.Lock()
+		delete(types.UploadTasks.Tasks, uploadID)
+		types.UploadTasks.Unlock()
+	}()
+	_, err := ctrl.StorageNodeSvc.PutReplica(ctx, req.BucketName, req.ObjectName, ctx.Request.Body, ctx.Request.ContentLength, uploadID)
+	return err
+}
+
+// DeleteReplica 删除其他集群同步来的对象
+// @Summary 删除其他集群同步来的对象
+// @Description 供其他集群的复制任务调用，请求需带 X-Replica-Timestamp 和 X-Replica-Signature 签名；删除不会再向外复制
+// @Tags storage
+// @Produce json
+// @Param  types.GetObjectMetadataReq query  types.GetObjectMetadataReq true "Bucket Name"
+// @Success 200
+// @Failure 400 {object} service.Response "InvalidArgument"
+// @Failure 401 {object} service.Response "Unauthorized"
+// @Router /storage/replica [DELETE]
+func (ctrl *StorageNodeController) DeleteReplica(ctx *gin.Context) error {
+	req := types.GetObjectMetadataReq{}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		return fmt.Errorf("%w: invaild query parameter: %v", errors.ErrBadRequest, err)
 	}
-	return ctrl.StorageNodeSvc.DeleteObject(ctx, req.BucketName, req.ObjectName)
+	return ctrl.StorageNodeSvc.DeleteReplica(ctx, req.BucketName, req.ObjectName)
 }
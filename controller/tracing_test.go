@@ -0,0 +1,129 @@
+package controller_test
+
+import (
+	"context"
+	"distributed-object-storage/controller"
+	"distributed-object-storage/pkg/db"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/middleware"
+	"distributed-object-storage/pkg/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// setupTracing 将全局 TracerProvider 替换为写入内存的实现，测试结束后恢复
+func setupTracing(t *testing.T) (*tracetest.InMemoryExporter, func()) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(exporter, "test", 1)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+	return exporter, func() {
+		if err := tp.ForceFlush(context.Background()); err != nil {
+			t.Fatalf("flush spans: %v", err)
+		}
+	}
+}
+
+// newTestDao 使用内存中的 sqlite 创建 dao，并注册与 mysql 相同的 tracing 插件
+func newTestDao(t *testing.T) *dao.S {
+	t.Helper()
+	gdb, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err = gdb.Use(db.TracingPlugin()); err != nil {
+		t.Fatalf("register tracing plugin: %v", err)
+	}
+	sqlDB, _ := gdb.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	s := dao.New(gdb)
+	if err = s.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return s
+}
+
+func TestTracingSpanHierarchy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter, flush := setupTracing(t)
+	daoS := newTestDao(t)
+
+	engine := gin.New()
+	engine.ContextWithFallback = true
+	engine.Use(middleware.Tracing())
+	controller.NewStorageNodeController(daoS).RegisterRouter(engine)
+
+	const (
+		upstreamTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		upstreamSpan  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodGet, "/storage/object?bucket_name=photos&object_name=missing.jpg", nil)
+	req.Header.Set("traceparent", "00-"+upstreamTrace+"-"+upstreamSpan+"-01")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, http.StatusNotFound, rec.Body.String())
+	}
+	if got := rec.Header().Get(middleware.TraceIDHeader); got != upstreamTrace {
+		t.Fatalf("%s = %q, want %q", middleware.TraceIDHeader, got, upstreamTrace)
+	}
+
+	flush()
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	server, ok := spans["GET /storage/object"]
+	if !ok {
+		t.Fatalf("no server span in %v", spanNames(exporter))
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("server span kind = %v", server.SpanKind)
+	}
+	// traceparent 中的链路作为请求 span 的上级
+	if got := server.SpanContext.TraceID().String(); got != upstreamTrace {
+		t.Fatalf("server trace id = %s, want %s", got, upstreamTrace)
+	}
+	if got := server.Parent.SpanID().String(); got != upstreamSpan || !server.Parent.IsRemote() {
+		t.Fatalf("server parent = %s (remote %v), want remote %s", got, server.Parent.IsRemote(), upstreamSpan)
+	}
+	svcSpan := assertChild(t, spans, "StorageNodeSvc.GetObject", server)
+	assertChild(t, spans, "mysql.query", svcSpan)
+}
+
+// assertChild 检查名为 name 的 span 存在，并且是 parent 的直接子 span
+func assertChild(t *testing.T, spans map[string]tracetest.SpanStub, name string, parent tracetest.SpanStub) tracetest.SpanStub {
+	t.Helper()
+	span, ok := spans[name]
+	if !ok {
+		t.Fatalf("no span %q", name)
+	}
+	if span.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+		t.Fatalf("span %q trace id = %s, want %s", name, span.SpanContext.TraceID(), parent.SpanContext.TraceID())
+	}
+	if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Fatalf("parent of %q = %s, want %q (%s)", name, span.Parent.SpanID(), parent.Name, parent.SpanContext.SpanID())
+	}
+	return span
+}
+
+func spanNames(exporter *tracetest.InMemoryExporter) []string {
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	return names
+}
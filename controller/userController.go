@@ -1,22 +1,26 @@
 package controller
 
 import (
+	"distributed-object-storage/errors"
 	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/service"
 	"distributed-object-storage/svc"
 	"distributed-object-storage/types"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"gorm.io/gorm"
 	"strconv"
 )
 
 type UserController struct {
-	userSvc *svc.UserSvc
+	userSvc  *svc.UserSvc
+	quotaSvc *svc.QuotaSvc
 }
 
 func NewUserController(daoS *dao.S) *UserController {
 	return &UserController{
-		userSvc: svc.NewUserSvc(daoS),
+		userSvc:  svc.NewUserSvc(daoS),
+		quotaSvc: svc.NewQuotaSvc(daoS),
 	}
 }
 
@@ -24,27 +28,30 @@ func (ctrl *UserController) RegisterRouter(r gin.IRouter) {
 	g := r.Group("/user") // middwares.AuthMiddleware()
 	g.GET("/list", service.DataHandlerWrapper(ctrl.ListAllUser))
 	g.POST("/info/:id", service.DataHandlerWrapper(ctrl.UserInfo))
+	g.GET("/usage/:id", service.DataHandlerWrapper(ctrl.UserUsage))
 }
 
 // ListAllUser 得到所有的用户信息列表
 func (ctrl *UserController) ListAllUser(ctx *gin.Context) (interface{}, error) {
 	userList, err := ctrl.userSvc.FindAllUser()
 	if err != nil {
-		return err.Error(), err
+		return nil, err
 	}
 	return userList, err
 }
 
 // UserInfo 给用户Id返回它的所有信息
 func (ctrl *UserController) UserInfo(ctx *gin.Context) (interface{}, error) {
-	id := ctx.Param("id")
-	userID, err := strconv.Atoi(id)
+	userID, err := userIDParam(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, err
+	}
+	userinfo, err := ctrl.userSvc.GetUserInfoByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: user %d", errors.ErrNotFound, userID)
 	}
-	userinfo, err := ctrl.userSvc.GetUserInfoByID(uint(userID))
 	if err != nil {
-		return err.Error(), err
+		return nil, err
 	}
 	userMetaData := &types.UserMetaData{
 		Id:       userinfo.Id,
@@ -52,3 +59,22 @@ func (ctrl *UserController) UserInfo(ctx *gin.Context) (interface{}, error) {
 	}
 	return userMetaData, err
 }
+
+// UserUsage 返回用户拥有的所有桶的用量之和以及用户的配额
+func (ctrl *UserController) UserUsage(ctx *gin.Context) (interface{}, error) {
+	userID, err := userIDParam(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ctrl.quotaSvc.UserReport(ctx, userID)
+}
+
+// userIDParam 解析路径中的用户 ID
+func userIDParam(ctx *gin.Context) (uint, error) {
+	id := ctx.Param("id")
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid user id %q", errors.ErrBadRequest, id)
+	}
+	return uint(userID), nil
+}
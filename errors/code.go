@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -8,12 +9,14 @@ import (
 )
 
 var (
-	unknownCoder defaultCoder = defaultCoder{500, http.StatusInternalServerError, "An internal server error occurred", "请咨询长庆消防排查"}
+	unknownCoder defaultCoder = defaultCoder{CodeInternalError, http.StatusInternalServerError, "InternalError", "An internal server error occurred", "请按响应中的 request_id 查找日志排查"}
 )
 
 // Coder defines an interface for an error code detail information.
 type Coder interface {
 	HTTPStatus() int
+	// Name 错误码的名称，如 NoSuchBucket
+	Name() string
 	String() string
 	Reference() string
 	Code() int
@@ -26,6 +29,9 @@ type defaultCoder struct {
 	// HTTP status that should be used for the associated error code.
 	HTTP int
 
+	// N 错误码的名称
+	N string
+
 	// External (user) facing error text.
 	Ext string
 
@@ -39,6 +45,11 @@ func (coder defaultCoder) Code() int {
 
 }
 
+// Name returns the name of the coder.
+func (coder defaultCoder) Name() string {
+	return coder.N
+}
+
 // String implements stringer. String returns the external error message,
 func (coder defaultCoder) String() string {
 	return coder.Ext
@@ -109,14 +120,20 @@ func callers() *stack {
 
 // ParseCoder parse any error into *withCode.
 // nil error will return nil direct.
-// None withStack error will be parsed as ErrUnknown.
+// 错误链中没有 *withCode 时按通用错误（如 ErrNotFound）确定错误码，都没有时返回 ErrUnknown.
 func ParseCoder(err error) Coder {
 	if err == nil {
 		return nil
 	}
 
-	if v, ok := err.(*withCode); ok {
-		if coder, ok := codes[v.code]; ok {
+	var v *withCode
+	if errors.As(err, &v) {
+		if coder, ok := lookup(v.code); ok {
+			return coder
+		}
+	}
+	if code, ok := sentinelCode(err); ok {
+		if coder, ok := lookup(code); ok {
 			return coder
 		}
 	}
@@ -124,6 +141,13 @@ func ParseCoder(err error) Coder {
 	return unknownCoder
 }
 
+func lookup(code int) (Coder, bool) {
+	codeMux.Lock()
+	defer codeMux.Unlock()
+	coder, ok := codes[code]
+	return coder, ok
+}
+
 func WithCode(code int, format string, args ...interface{}) error {
 	return &withCode{
 		err:   fmt.Errorf(format, args...),
@@ -148,18 +172,22 @@ func WrapC(err error, code int, format string, args ...interface{}) error {
 // Error return the externally-safe error message.
 func (w *withCode) Error() string { return w.err.Error() }
 
+// Unwrap 返回被包装的错误，使 errors.Is/As 可以检查错误链
+func (w *withCode) Unwrap() error {
+	if w.cause != nil {
+		return w.cause
+	}
+	return errors.Unwrap(w.err)
+}
+
 // IsCode reports whether any error in errs chain contains the given error code.
 func IsCode(err error, code int) bool {
-	if v, ok := err.(*withCode); ok {
+	var v *withCode
+	for errors.As(err, &v) {
 		if v.code == code {
 			return true
 		}
-
-		if v.cause != nil {
-			return IsCode(v.cause, code)
-		}
-
-		return false
+		err = v.Unwrap()
 	}
 
 	return false
@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 注册的错误码：10xxxx 通用错误，11xxxx 桶，12xxxx 对象和上传，13xxxx 用户
+const (
+	CodeInternalError      = 500
+	CodeInvalidArgument    = 100001
+	CodeAccessDenied       = 100002
+	CodeUnauthorized       = 100003
+	CodeNotFound           = 100004
+	CodeConflict           = 100005
+	CodePreconditionFailed = 100006
+	CodeServiceUnavailable = 100007
+
+	CodeNoSuchBucket        = 110001
+	CodeBucketAlreadyExists = 110002
+	CodeBucketNotEmpty      = 110003
+	CodeInvalidBucketName   = 110004
+
+	CodeNoSuchKey         = 120001
+	CodeInvalidObjectName = 120002
+	CodeEntityTooLarge    = 120003
+	CodeInvalidPart       = 120004
+	CodeNoSuchUpload      = 120005
+	CodeQuotaExceeded     = 120006
+
+	CodeInvalidCredentials = 130001
+	CodeUserAlreadyExists  = 130002
+)
+
+var catalog = []defaultCoder{
+	{CodeInvalidArgument, http.StatusBadRequest, "InvalidArgument", "Invalid argument", "请求参数不合法"},
+	{CodeAccessDenied, http.StatusForbidden, "AccessDenied", "Access denied", "没有执行该操作的权限"},
+	{CodeUnauthorized, http.StatusUnauthorized, "Unauthorized", "Missing or invalid token", "缺少或无效的登录 token"},
+	{CodeNotFound, http.StatusNotFound, "NotFound", "Resource not found", "请求的资源不存在"},
+	{CodeConflict, http.StatusConflict, "Conflict", "Resource is being modified by another request", "资源正在被其他请求修改，请稍后重试"},
+	{CodePreconditionFailed, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the preconditions did not hold", "If-Match 或 If-None-Match 条件不满足"},
+	{CodeServiceUnavailable, http.StatusServiceUnavailable, "ServiceUnavailable", "Service unavailable, please retry later", "服务正在关闭或依赖不可用，请稍后重试"},
+
+	{CodeNoSuchBucket, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist", "桶不存在"},
+	{CodeBucketAlreadyExists, http.StatusConflict, "BucketAlreadyExists", "The requested bucket name is not available", "桶已存在"},
+	{CodeBucketNotEmpty, http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty", "桶中还有对象，删除对象后再删除桶"},
+	{CodeInvalidBucketName, http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid", "桶名只能包含小写字母、数字、点和短横线，长度 3-63"},
+
+	{CodeNoSuchKey, http.StatusNotFound, "NoSuchKey", "The specified key does not exist", "对象不存在"},
+	{CodeInvalidObjectName, http.StatusBadRequest, "InvalidObjectName", "The specified object name is not valid", "对象名不合法"},
+	{CodeEntityTooLarge, http.StatusRequestEntityTooLarge, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size", "对象大小超过上限"},
+	{CodeInvalidPart, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found", "分片不存在或 ETag 不匹配"},
+	{CodeNoSuchUpload, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist", "上传任务不存在或已结束"},
+	{CodeQuotaExceeded, http.StatusForbidden, "QuotaExceeded", "The bucket or user quota has been exceeded", "超过桶或用户的存储配额"},
+
+	{CodeInvalidCredentials, http.StatusUnauthorized, "InvalidCredentials", "Invalid username or password", "用户名或密码错误"},
+	{CodeUserAlreadyExists, http.StatusConflict, "UserAlreadyExists", "The username is already taken", "用户名已被占用"},
+}
+
+// sentinels 未指定错误码的通用错误对应的错误码
+var sentinels = []struct {
+	err  error
+	code int
+}{
+	{ErrNotFound, CodeNotFound},
+	{ErrBadRequest, CodeInvalidArgument},
+	{ErrConflict, CodeConflict},
+	{ErrPreconditionFailed, CodePreconditionFailed},
+	{ErrUnavailable, CodeServiceUnavailable},
+}
+
+func init() {
+	for _, coder := range catalog {
+		MustRegister(coder)
+	}
+}
+
+// sentinelCode 返回错误链中通用错误对应的错误码
+func sentinelCode(err error) (int, bool) {
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return s.code, true
+		}
+	}
+	return 0, false
+}
@@ -7,17 +7,27 @@ import (
 
 // common errs
 var (
-	ErrNotFound   = errors.New("resource not found")
-	ErrBadRequest = errors.New("bad request")
+	ErrNotFound           = errors.New("resource not found")
+	ErrBadRequest         = errors.New("bad request")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnavailable        = errors.New("service unavailable")
 )
 
-// ErrorToHTTPCode ..
+// Is 同标准库 errors.Is，方便引入本包的代码检查错误链
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As 同标准库 errors.As
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// ErrorToHTTPCode 返回错误对应的 HTTP 状态码，err 为 nil 时返回 200
 func ErrorToHTTPCode(err error) int {
-	if errors.Is(err, ErrNotFound) {
-		return http.StatusNotFound
-	}
-	if errors.Is(err, ErrBadRequest) {
-		return http.StatusBadRequest
+	if err == nil {
+		return http.StatusOK
 	}
-	return http.StatusInternalServerError
+	return ParseCoder(err).HTTPStatus()
 }
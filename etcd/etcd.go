@@ -1,13 +1,76 @@
 package etcd
 
-import clientv3 "go.etcd.io/etcd/client/v3"
+import (
+	"context"
+	"distributed-object-storage/config"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"sync"
+	"time"
+)
 
-func GetEtcdClient() *clientv3.Client {
-	etcdClient, err := clientv3.New(clientv3.Config{
-		Endpoints: []string{"http://0.0.0.0:2379"},
+var (
+	client    *clientv3.Client
+	clientErr error
+	once      sync.Once
+)
+
+func newClient() (*clientv3.Client, error) {
+	c := config.GetEtcd()
+	return clientv3.New(clientv3.Config{
+		Endpoints:   c.Endpoints,
+		DialTimeout: time.Duration(c.DialTimeoutSeconds) * time.Second,
 	})
+}
+
+func GetEtcdClient() *clientv3.Client {
+	etcdClient, err := newClient()
 	if err != nil {
 		return nil
 	}
 	return etcdClient
 }
+
+// Client 返回进程内共享的 etcd 客户端，选主等需要长连接的场景使用
+func Client() (*clientv3.Client, error) {
+	once.Do(func() {
+		client, clientErr = newClient()
+	})
+	return client, clientErr
+}
+
+// Close 关闭共享的 etcd 客户端，未创建时什么也不做
+func Close() error {
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+// WatchKey 读取 key 的当前值并持续监听变化，每次变化调用 fn，key 被删除时 value 为 nil。
+// 直到 ctx 取消才返回。
+func WatchKey(ctx context.Context, key string, fn func(value []byte)) error {
+	cli, err := Client()
+	if err != nil {
+		return err
+	}
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) > 0 {
+		fn(resp.Kvs[0].Value)
+	}
+	for wresp := range cli.Watch(ctx, key, clientv3.WithRev(resp.Header.Revision+1)) {
+		if err = wresp.Err(); err != nil {
+			return err
+		}
+		for _, ev := range wresp.Events {
+			if ev.Type == clientv3.EventTypeDelete {
+				fn(nil)
+			} else {
+				fn(ev.Kv.Value)
+			}
+		}
+	}
+	return ctx.Err()
+}
@@ -1,27 +1,18 @@
 package main
 
 import (
-	"context"
 	"distributed-object-storage/config"
-	"distributed-object-storage/controller"
 	_ "distributed-object-storage/docs"
-	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/pkg/log"
 	"distributed-object-storage/redis"
-	"distributed-object-storage/syncer"
 	"fmt"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
-	"github.com/swaggo/files"
-	"github.com/swaggo/gin-swagger"
 	"github.com/urfave/cli"
 	"os"
 )
 
 type Commands struct {
-	app    *cli.App
-	server *gin.Engine
-	cfg    *config.Config
+	app *cli.App
+	cfg *config.Config
 }
 
 func (cmd *Commands) GetConfig() *config.Config {
@@ -30,47 +21,62 @@ func (cmd *Commands) GetConfig() *config.Config {
 
 func NewCommands() *Commands {
 	cmd := &Commands{
-		app:    cli.NewApp(),
-		server: gin.Default(), // 初始化 server
+		app: cli.NewApp(),
 	}
-	cmd.app.Action = func(cli *cli.Context) {
-		cmd.cfg = config.InitConfig(cli)
+	cmd.app.Name = "dos"
+	cmd.app.Usage = "distributed object storage"
+	cmd.app.Flags = config.Flags
+	// 不带子命令时启动服务，与之前的行为保持一致
+	cmd.app.Action = cmd.withConfig(cmd.serve)
+	cmd.app.Commands = []cli.Command{
+		{
+			Name:   "serve",
+			Usage:  "start the api server and syncers",
+			Action: cmd.withConfig(cmd.serve),
+		},
+		cmd.nodeAgentCommand(),
+		{
+			Name:   "migrate",
+			Usage:  "create or update the metadata tables",
+			Action: cmd.withConfig(runMigrate),
+		},
+		cmd.userCommand(),
+		cmd.bucketCommand(),
+		cmd.fsckCommand(),
 	}
-	if err := cmd.app.Run(os.Args); err != nil {
-		fmt.Println(err)
-	}
-
 	return cmd
 }
 
-func main() {
-	app := NewCommands()
-	app.server.Use(cors.Default())
-	//app.server.Use(middwares.AuthMiddleware())
-	initApp(app)
-	port := 3002
-	log.Infof("Server starting on port %v", port)
-	err := app.server.Run(fmt.Sprintf("0.0.0.0:%d", port))
-	if err != nil {
-		return
+// withConfig 加载配置后再执行子命令，所有子命令共用全局参数和配置文件
+func (cmd *Commands) withConfig(action func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		cfg, err := config.InitConfig(c)
+		if err != nil {
+			return err
+		}
+		cmd.cfg = cfg
+		return action(c)
 	}
 }
 
-func initApp(app *Commands) {
-	dos := dao.Init()
-	if err := redis.Init(); err != nil {
-		log.Errorf("Redis can not init %v", err)
+// withRedis 连接 redis 后再执行子命令，会加对象锁的子命令需要，执行完后关闭连接
+func withRedis(action func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if err := redis.Init(); err != nil {
+			return fmt.Errorf("connect redis: %v", err)
+		}
+		defer func() {
+			if err := redis.Close(); err != nil {
+				log.Warnf("close redis failed: %v", err)
+			}
+		}()
+		return action(c)
+	}
+}
+
+func main() {
+	if err := NewCommands().app.Run(os.Args); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	metaDataController := controller.NewMetadataNodeController(dos)
-	storageController := controller.NewStorageNodeController(dos)
-	authController := controller.NewAuthController(dos)
-	app.server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	metaDataController.RegisterRouter(app.server)
-	storageController.RegisterRouter(app.server)
-	authController.RegisterRouter(app.server)
-	go func() {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		syncer.Init(ctx)
-	}()
 }
@@ -0,0 +1,70 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Audit 审计日志只提供写入和查询，不提供修改和删除
+type Audit struct {
+	*Base
+}
+
+func NewAudit(db *gorm.DB) *Audit {
+	return &Audit{
+		Base: &Base{DB: db},
+	}
+}
+
+// AuditFilter 查询审计日志的条件，零值的字段不作为条件
+type AuditFilter struct {
+	UserID     uint
+	UserName   string
+	Action     string
+	BucketName string
+	ObjectName string
+	Result     string
+	From       time.Time
+	To         time.Time
+}
+
+// Create 写入一条审计记录
+func (obj *Audit) Create(ctx context.Context, entry *dbm.AuditLog) error {
+	return obj.DB.Model(&dbm.AuditLog{}).WithContext(ctx).Create(entry).Error
+}
+
+// List 按操作时间倒序分页查询审计记录
+func (obj *Audit) List(ctx context.Context, filter AuditFilter, page *PageCondition) (results []*dbm.AuditLog, count int64, err error) {
+	results = []*dbm.AuditLog{}
+	tx := obj.DB.Model(&dbm.AuditLog{}).WithContext(ctx)
+	if filter.UserID != 0 {
+		tx = tx.Where("user_id = ?", filter.UserID)
+	}
+	for col, v := range map[string]string{
+		"user_name":   filter.UserName,
+		"action":      filter.Action,
+		"bucket_name": filter.BucketName,
+		"object_name": filter.ObjectName,
+		"result":      filter.Result,
+	} {
+		if v != "" {
+			tx = tx.Where(col+" = ?", v)
+		}
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("created_at < ?", filter.To)
+	}
+	if err = tx.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("created_at desc, id desc").Offset(page.Offset()).Limit(page.Limit()).Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Bucket struct {
+	*Base
+}
+
+func NewBucket(db *gorm.DB) *Bucket {
+	return &Bucket{
+		Base: &Base{DB: db},
+	}
+}
+
+// Create 注册一个桶
+func (obj *Bucket) Create(ctx context.Context, bucket *dbm.Bucket) error {
+	return obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Create(bucket).Error
+}
+
+// Get 根据桶名获取桶的配置，未注册时返回 gorm.ErrRecordNotFound
+func (obj *Bucket) Get(ctx context.Context, name string) (tmp *dbm.Bucket, err error) {
+	err = obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Where("name = ?", name).First(&tmp).Error
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// List 按桶名顺序返回所有注册的桶
+func (obj *Bucket) List(ctx context.Context) (results []*dbm.Bucket, err error) {
+	results = []*dbm.Bucket{}
+	if err = obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Order("name").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListByPrefix 按桶名顺序返回名称以 prefix 开头的注册桶，limit <= 0 时不限制条数
+func (obj *Bucket) ListByPrefix(ctx context.Context, prefix string, limit int) (results []*dbm.Bucket, err error) {
+	results = []*dbm.Bucket{}
+	tx := obj.DB.Model(&dbm.Bucket{}).WithContext(ctx)
+	if prefix != "" {
+		tx = tx.Where("name LIKE ?", escapeLike(prefix)+"%")
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	if err = tx.Order("name").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateSettings 修改桶创建后可以修改的配置
+func (obj *Bucket) UpdateSettings(ctx context.Context, bucket *dbm.Bucket) error {
+	return obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Where("name = ?", bucket.Name).
+		Select("zone", "versioning", "replicas", "storage_class", "quota_bytes", "quota_objects",
+			"soft_quota_bytes", "soft_quota_objects", "tags", "updated_at").
+		Updates(bucket).Error
+}
+
+// Delete 删除桶的注册信息
+func (obj *Bucket) Delete(ctx context.Context, name string) error {
+	return obj.DB.WithContext(ctx).Where("name = ?", name).Delete(&dbm.Bucket{}).Error
+}
+
+// UpdateReplication 修改桶的复制规则
+func (obj *Bucket) UpdateReplication(ctx context.Context, name, replication string) error {
+	return obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Where("name = ?", name).
+		Select("replication", "updated_at").
+		Updates(&dbm.Bucket{Replication: replication, UpdatedAt: time.Now()}).Error
+}
+
+// UpdateLifecycle 修改桶的生命周期规则
+func (obj *Bucket) UpdateLifecycle(ctx context.Context, name, lifecycle string) error {
+	return obj.DB.Model(&dbm.Bucket{}).WithContext(ctx).Where("name = ?", name).
+		Select("lifecycle", "updated_at").
+		Updates(&dbm.Bucket{Lifecycle: lifecycle, UpdatedAt: time.Now()}).Error
+}
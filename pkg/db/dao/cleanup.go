@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Cleanup struct {
+	*Base
+}
+
+func NewCleanup(db *gorm.DB) *Cleanup {
+	return &Cleanup{
+		Base: &Base{DB: db},
+	}
+}
+
+// enqueueCleanup 写入待删除数据的对象，同一对象已有记录时以新记录覆盖
+func enqueueCleanup(db *gorm.DB, cleanup *dbm.ObjectCleanup) error {
+	if cleanup == nil {
+		return nil
+	}
+	return db.Model(&dbm.ObjectCleanup{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bucket_name"}, {Name: "object_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"tier_key", "attempts", "next_attempt_at", "last_error", "created_at", "updated_at",
+		}),
+	}).Create(cleanup).Error
+}
+
+// ListDue 返回到了重试时间的记录，最多 limit 条
+func (obj *Cleanup) ListDue(ctx context.Context, now time.Time, limit int) (results []*dbm.ObjectCleanup, err error) {
+	results = []*dbm.ObjectCleanup{}
+	err = obj.DB.Model(&dbm.ObjectCleanup{}).WithContext(ctx).
+		Where("next_attempt_at <= ?", now).Order("next_attempt_at").Limit(limit).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListByNames 查询桶内 objectNames 中等待删除数据的对象
+func (obj *Cleanup) ListByNames(ctx context.Context, bucketName string, objectNames []string) (results []*dbm.ObjectCleanup, err error) {
+	results = []*dbm.ObjectCleanup{}
+	if len(objectNames) == 0 {
+		return results, nil
+	}
+	err = obj.DB.Model(&dbm.ObjectCleanup{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name IN ?", bucketName, objectNames).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// current 只匹配读取后没有被新记录覆盖的记录
+func (obj *Cleanup) current(ctx context.Context, cleanup *dbm.ObjectCleanup) *gorm.DB {
+	return obj.DB.Model(&dbm.ObjectCleanup{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name = ? AND created_at = ?", cleanup.BucketName, cleanup.ObjectName, cleanup.CreatedAt)
+}
+
+// Delete 删除数据已删除的记录，记录已被新记录覆盖时不删除
+func (obj *Cleanup) Delete(ctx context.Context, cleanup *dbm.ObjectCleanup) error {
+	return obj.current(ctx, cleanup).Delete(&dbm.ObjectCleanup{}).Error
+}
+
+// UpdateAttempt 记录一次失败的尝试，记录已被新记录覆盖时不修改
+func (obj *Cleanup) UpdateAttempt(ctx context.Context, cleanup *dbm.ObjectCleanup) error {
+	return obj.current(ctx, cleanup).
+		Select("attempts", "next_attempt_at", "last_error", "updated_at").
+		Updates(&dbm.ObjectCleanup{
+			Attempts:      cleanup.Attempts,
+			NextAttemptAt: cleanup.NextAttemptAt,
+			LastError:     cleanup.LastError,
+			UpdatedAt:     time.Now(),
+		}).Error
+}
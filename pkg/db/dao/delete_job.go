@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+)
+
+type DeleteJob struct {
+	*Base
+}
+
+func NewDeleteJob(db *gorm.DB) *DeleteJob {
+	return &DeleteJob{
+		Base: &Base{DB: db},
+	}
+}
+
+// CreateJob 创建删除任务
+func (obj *DeleteJob) CreateJob(ctx context.Context, job *dbm.DeleteJob) error {
+	return obj.DB.Model(&dbm.DeleteJob{}).WithContext(ctx).Create(job).Error
+}
+
+// GetJob 根据 id 获取删除任务
+func (obj *DeleteJob) GetJob(ctx context.Context, id uint) (tmp *dbm.DeleteJob, err error) {
+	err = obj.DB.Model(&dbm.DeleteJob{}).WithContext(ctx).Where("id = ?", id).First(&tmp).Error
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// ListJobsByState 按创建顺序返回状态在 states 中的任务
+func (obj *DeleteJob) ListJobsByState(ctx context.Context, states []string) (results []*dbm.DeleteJob, err error) {
+	results = []*dbm.DeleteJob{}
+	err = obj.DB.Model(&dbm.DeleteJob{}).WithContext(ctx).
+		Where("state IN ?", states).Order("id").Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// UpdateProgress 保存任务的状态和进度
+func (obj *DeleteJob) UpdateProgress(ctx context.Context, job *dbm.DeleteJob) error {
+	return obj.DB.Model(&dbm.DeleteJob{}).WithContext(ctx).Where("id = ?", job.Id).
+		Select("state", "last_id", "deleted", "deleted_bytes", "failed", "last_error", "updated_at", "finished_at").
+		Updates(job).Error
+}
+
+// ListJobs 按创建时间倒序分页查询，bucketName、state 为空时不过滤
+func (obj *DeleteJob) ListJobs(ctx context.Context, bucketName, state string, page *PageCondition) (results []*dbm.DeleteJob, count int64, err error) {
+	results = []*dbm.DeleteJob{}
+	tx := obj.DB.Model(&dbm.DeleteJob{}).WithContext(ctx)
+	if bucketName != "" {
+		tx = tx.Where("bucket_name = ?", bucketName)
+	}
+	if state != "" {
+		tx = tx.Where("state = ?", state)
+	}
+	if err = tx.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("id desc").Offset(page.Offset()).Limit(page.Limit()).Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
@@ -2,6 +2,7 @@ package dao
 
 import (
 	"distributed-object-storage/pkg/db"
+	"distributed-object-storage/pkg/db/dbm"
 	"gorm.io/gorm"
 )
 
@@ -10,15 +11,53 @@ type S struct {
 	DB           *gorm.DB
 	MetadataNode *MetadataNode
 	User         *User
+	Scrub        *Scrub
+	Audit        *Audit
+	Bucket       *Bucket
+	Quota        *Quota
+	Lifecycle    *Lifecycle
+	Replication  *Replication
+	DeleteJob    *DeleteJob
+	Cleanup      *Cleanup
 }
 
 func Init() *S {
+	return New(db.Db())
+}
+
+// New 使用指定的数据库连接创建所有 dao
+func New(db *gorm.DB) *S {
 	return &S{
 		Base: &Base{
-			DB: db.Db(),
+			DB: db,
 		},
-		DB:           db.Db(),
-		MetadataNode: NewMetadataNode(db.Db()),
-		User:         NewUser(db.Db()),
+		DB:           db,
+		MetadataNode: NewMetadataNode(db),
+		User:         NewUser(db),
+		Scrub:        NewScrub(db),
+		Audit:        NewAudit(db),
+		Bucket:       NewBucket(db),
+		Quota:        NewQuota(db),
+		Lifecycle:    NewLifecycle(db),
+		Replication:  NewReplication(db),
+		DeleteJob:    NewDeleteJob(db),
+		Cleanup:      NewCleanup(db),
 	}
 }
+
+// AutoMigrate 创建或更新 dao 使用的数据表
+func (s *S) AutoMigrate() error {
+	return s.DB.AutoMigrate(
+		&dbm.UserInfo{},
+		&dbm.ObjectMetadata{},
+		&dbm.ScrubIssue{},
+		&dbm.AuditLog{},
+		&dbm.Bucket{},
+		&dbm.UserQuota{},
+		&dbm.LifecycleLog{},
+		&dbm.ReplicationTask{},
+		&dbm.DeleteJob{},
+		&dbm.FencingToken{},
+		&dbm.ObjectCleanup{},
+	)
+}
@@ -0,0 +1,45 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+)
+
+type Lifecycle struct {
+	*Base
+}
+
+func NewLifecycle(db *gorm.DB) *Lifecycle {
+	return &Lifecycle{
+		Base: &Base{DB: db},
+	}
+}
+
+// CreateLogs 批量记录生命周期规则删除的内容
+func (obj *Lifecycle) CreateLogs(ctx context.Context, logs []*dbm.LifecycleLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return obj.DB.Model(&dbm.LifecycleLog{}).WithContext(ctx).CreateInBatches(logs, 100).Error
+}
+
+// ListLogs 按删除时间倒序分页查询，bucketName、action 为空时不过滤
+func (obj *Lifecycle) ListLogs(ctx context.Context, bucketName, action string, page *PageCondition) (results []*dbm.LifecycleLog, count int64, err error) {
+	results = []*dbm.LifecycleLog{}
+	tx := obj.DB.Model(&dbm.LifecycleLog{}).WithContext(ctx)
+	if bucketName != "" {
+		tx = tx.Where("bucket_name = ?", bucketName)
+	}
+	if action != "" {
+		tx = tx.Where("action = ?", action)
+	}
+	if err = tx.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("created_at desc").Offset(page.Offset()).Limit(page.Limit()).Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
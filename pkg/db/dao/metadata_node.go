@@ -4,6 +4,9 @@ import (
 	"context"
 	"distributed-object-storage/pkg/db/dbm"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
 )
 
 type MetadataNode struct {
@@ -26,3 +29,204 @@ func (obj *MetadataNode) FindAllEmergencyPlan() (results []*dbm.ObjectMetadata,
 	}
 	return results, err
 }
+
+// GetObjectMetadata 根据桶名和对象名获取对象元数据
+func (obj *MetadataNode) GetObjectMetadata(ctx context.Context, bucketName, objectName string) (tmp *dbm.ObjectMetadata, err error) {
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name = ?", bucketName, objectName).First(&tmp).Error
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// SaveObjectMetadata 写入对象元数据，同一桶内同名对象已存在时覆盖
+func (obj *MetadataNode) SaveObjectMetadata(ctx context.Context, meta *dbm.ObjectMetadata) error {
+	return saveObjectMetadata(obj.DB.WithContext(ctx), meta)
+}
+
+func saveObjectMetadata(db *gorm.DB, meta *dbm.ObjectMetadata) error {
+	return db.Model(&dbm.ObjectMetadata{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bucket_name"}, {Name: "object_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"size", "content_type", "etag", "last_modified", "storage_nodes", "version_id", "is_latest", "storage_class", "tier_key",
+			"replication_status",
+		}),
+	}).Create(meta).Error
+}
+
+// SaveObjectMetadataWithTasks 在同一个事务中写入对象元数据和复制任务，元数据写入成功时任务一定已入队
+func (obj *MetadataNode) SaveObjectMetadataWithTasks(ctx context.Context, meta *dbm.ObjectMetadata, tasks []*dbm.ReplicationTask) error {
+	return obj.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveObjectMetadata(tx, meta); err != nil {
+			return err
+		}
+		return enqueueReplicationTasks(tx, tasks)
+	})
+}
+
+// RenameObjectMetadata 在同一个事务中写入目标对象的元数据、删除源对象的元数据，
+// 并写入复制任务和源对象待删除数据的记录
+func (obj *MetadataNode) RenameObjectMetadata(ctx context.Context, meta *dbm.ObjectMetadata, srcBucket, srcObject string,
+	tasks []*dbm.ReplicationTask, cleanup *dbm.ObjectCleanup) error {
+	return obj.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := saveObjectMetadata(tx, meta); err != nil {
+			return err
+		}
+		err := tx.Where("bucket_name = ? AND object_name = ?", srcBucket, srcObject).Delete(&dbm.ObjectMetadata{}).Error
+		if err != nil {
+			return err
+		}
+		if err = enqueueCleanup(tx, cleanup); err != nil {
+			return err
+		}
+		return enqueueReplicationTasks(tx, tasks)
+	})
+}
+
+// UpdateStorageNodes 更新对象所在的节点列表
+func (obj *MetadataNode) UpdateStorageNodes(ctx context.Context, id uint, storageNodes string) error {
+	return obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("id = ?", id).Update("storage_nodes", storageNodes).Error
+}
+
+// DeleteObjectMetadata 删除对象元数据
+func (obj *MetadataNode) DeleteObjectMetadata(ctx context.Context, bucketName, objectName string) error {
+	return obj.DB.WithContext(ctx).
+		Where("bucket_name = ? AND object_name = ?", bucketName, objectName).Delete(&dbm.ObjectMetadata{}).Error
+}
+
+// DeleteObjectMetadataWithTasks 在同一个事务中删除对象元数据和写入复制任务
+func (obj *MetadataNode) DeleteObjectMetadataWithTasks(ctx context.Context, bucketName, objectName string, tasks []*dbm.ReplicationTask) error {
+	return obj.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("bucket_name = ? AND object_name = ?", bucketName, objectName).Delete(&dbm.ObjectMetadata{}).Error
+		if err != nil {
+			return err
+		}
+		return enqueueReplicationTasks(tx, tasks)
+	})
+}
+
+// ListObjectMetadataAfter 按 id 顺序分批遍历对象元数据，返回 id 大于 afterID 的最多 limit 条
+func (obj *MetadataNode) ListObjectMetadataAfter(ctx context.Context, afterID uint, limit int) (results []*dbm.ObjectMetadata, err error) {
+	results = []*dbm.ObjectMetadata{}
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("id > ?", afterID).Order("id").Limit(limit).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListBucketNames 返回元数据中出现过的所有桶名
+func (obj *MetadataNode) ListBucketNames(ctx context.Context) (results []string, err error) {
+	results = []string{}
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Distinct("bucket_name").Order("bucket_name").Pluck("bucket_name", &results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListBucketObjectMetadataAfter 按 id 顺序分批遍历某个桶内的对象元数据
+func (obj *MetadataNode) ListBucketObjectMetadataAfter(ctx context.Context, bucketName string, afterID uint, limit int) (results []*dbm.ObjectMetadata, err error) {
+	results = []*dbm.ObjectMetadata{}
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND id > ?", bucketName, afterID).Order("id").Limit(limit).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListObjectMetadataByNames 查询桶内 objectNames 中各对象的元数据，不存在的对象不在结果中
+func (obj *MetadataNode) ListObjectMetadataByNames(ctx context.Context, bucketName string, objectNames []string) (results []*dbm.ObjectMetadata, err error) {
+	results = []*dbm.ObjectMetadata{}
+	if len(objectNames) == 0 {
+		return results, nil
+	}
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name IN ?", bucketName, objectNames).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BucketUsage 桶内对象的总大小和对象数
+type BucketUsage struct {
+	BucketName string `gorm:"column:bucket_name"`
+	Bytes      int64  `gorm:"column:bytes"`
+	Objects    int64  `gorm:"column:objects"`
+}
+
+// SumUsageByBucket 按桶统计元数据中记录的对象总大小和对象数
+func (obj *MetadataNode) SumUsageByBucket(ctx context.Context) (results []*BucketUsage, err error) {
+	results = []*BucketUsage{}
+	err = obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Select("bucket_name, COALESCE(SUM(size), 0) AS bytes, COUNT(*) AS objects").
+		Group("bucket_name").Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListObjectMetadataBefore 按 id 顺序分批返回桶内以 prefix 开头、最后修改时间早于 before 的对象，id 大于 afterID
+func (obj *MetadataNode) ListObjectMetadataBefore(ctx context.Context, bucketName, prefix string, before time.Time,
+	afterID uint, limit int) (results []*dbm.ObjectMetadata, err error) {
+	results = []*dbm.ObjectMetadata{}
+	tx := obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND last_modified < ? AND id > ?", bucketName, before, afterID)
+	if prefix != "" {
+		tx = tx.Where("object_name LIKE ?", escapeLike(prefix)+"%")
+	}
+	if err = tx.Order("id").Limit(limit).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListObjectMetadataByPrefix 按对象名顺序返回桶内以 prefix 开头、对象名大于 after 的最多 limit 个对象
+func (obj *MetadataNode) ListObjectMetadataByPrefix(ctx context.Context, bucketName, prefix, after string, limit int) (results []*dbm.ObjectMetadata, err error) {
+	results = []*dbm.ObjectMetadata{}
+	tx := obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name > ?", bucketName, after)
+	if prefix != "" {
+		tx = tx.Where("object_name LIKE ?", escapeLike(prefix)+"%")
+	}
+	if err = tx.Order("object_name").Limit(limit).Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// HasColdObjects 桶内是否有已转移到冷存储的对象
+func (obj *MetadataNode) HasColdObjects(ctx context.Context, bucketName string) (bool, error) {
+	var count int64
+	err := obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND tier_key <> ''", bucketName).Count(&count).Error
+	return count > 0, err
+}
+
+// UpdateReplicationStatus 更新对象的复制状态，对象已被重新写入（ETag 不同）时不修改
+func (obj *MetadataNode) UpdateReplicationStatus(ctx context.Context, bucketName, objectName, etag, status string) error {
+	return obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("bucket_name = ? AND object_name = ? AND etag = ?", bucketName, objectName, etag).
+		Update("replication_status", status).Error
+}
+
+// SetReplicationStatus 批量设置对象的复制状态
+func (obj *MetadataNode) SetReplicationStatus(ctx context.Context, ids []uint, status string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return obj.DB.Model(&dbm.ObjectMetadata{}).WithContext(ctx).
+		Where("id IN ?", ids).Update("replication_status", status).Error
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Quota struct {
+	*Base
+}
+
+func NewQuota(db *gorm.DB) *Quota {
+	return &Quota{
+		Base: &Base{DB: db},
+	}
+}
+
+// GetUserQuota 获取用户配额，没有设置时返回 gorm.ErrRecordNotFound
+func (obj *Quota) GetUserQuota(ctx context.Context, userID uint) (tmp *dbm.UserQuota, err error) {
+	err = obj.DB.Model(&dbm.UserQuota{}).WithContext(ctx).Where("user_id = ?", userID).First(&tmp).Error
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// ListUserQuotas 返回所有设置了配额的用户
+func (obj *Quota) ListUserQuotas(ctx context.Context) (results []*dbm.UserQuota, err error) {
+	results = []*dbm.UserQuota{}
+	if err = obj.DB.Model(&dbm.UserQuota{}).WithContext(ctx).Order("user_id").Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SaveUserQuota 写入用户配额，已存在时覆盖
+func (obj *Quota) SaveUserQuota(ctx context.Context, quota *dbm.UserQuota) error {
+	return obj.DB.Model(&dbm.UserQuota{}).WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"quota_bytes", "quota_objects", "soft_quota_bytes", "soft_quota_objects", "updated_at",
+		}),
+	}).Create(quota).Error
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Replication struct {
+	*Base
+}
+
+func NewReplication(db *gorm.DB) *Replication {
+	return &Replication{
+		Base: &Base{DB: db},
+	}
+}
+
+// EnqueueTasks 写入复制任务，同一对象已有任务时以新任务覆盖
+func (obj *Replication) EnqueueTasks(ctx context.Context, tasks []*dbm.ReplicationTask) error {
+	return enqueueReplicationTasks(obj.DB.WithContext(ctx), tasks)
+}
+
+func enqueueReplicationTasks(db *gorm.DB, tasks []*dbm.ReplicationTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	return db.Model(&dbm.ReplicationTask{}).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bucket_name"}, {Name: "object_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"operation", "etag", "rule_id", "state", "attempts", "next_attempt_at", "last_error", "created_at", "updated_at",
+		}),
+	}).CreateInBatches(tasks, 100).Error
+}
+
+// ListDueTasks 返回状态为 state 且到了重试时间的任务，最多 limit 条
+func (obj *Replication) ListDueTasks(ctx context.Context, state string, now time.Time, limit int) (results []*dbm.ReplicationTask, err error) {
+	results = []*dbm.ReplicationTask{}
+	err = obj.DB.Model(&dbm.ReplicationTask{}).WithContext(ctx).
+		Where("state = ? AND next_attempt_at <= ?", state, now).
+		Order("next_attempt_at").Limit(limit).Find(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// current 只匹配读取后没有被新任务覆盖的任务
+func (obj *Replication) current(ctx context.Context, task *dbm.ReplicationTask) *gorm.DB {
+	return obj.DB.Model(&dbm.ReplicationTask{}).WithContext(ctx).
+		Where("id = ? AND operation = ? AND etag = ? AND created_at = ?", task.Id, task.Operation, task.ETag, task.CreatedAt)
+}
+
+// DeleteTask 删除已完成的任务，任务已被新任务覆盖时不删除
+func (obj *Replication) DeleteTask(ctx context.Context, task *dbm.ReplicationTask) error {
+	return obj.current(ctx, task).Delete(&dbm.ReplicationTask{}).Error
+}
+
+// UpdateAttempt 记录一次失败的尝试，任务已被新任务覆盖时不修改
+func (obj *Replication) UpdateAttempt(ctx context.Context, task *dbm.ReplicationTask) error {
+	return obj.current(ctx, task).
+		Select("state", "attempts", "next_attempt_at", "last_error", "updated_at").
+		Updates(&dbm.ReplicationTask{
+			State:         task.State,
+			Attempts:      task.Attempts,
+			NextAttemptAt: task.NextAttemptAt,
+			LastError:     task.LastError,
+			UpdatedAt:     time.Now(),
+		}).Error
+}
+
+// CountByState 按状态统计任务数
+func (obj *Replication) CountByState(ctx context.Context, state string) (count int64, err error) {
+	err = obj.DB.Model(&dbm.ReplicationTask{}).WithContext(ctx).Where("state = ?", state).Count(&count).Error
+	return count, err
+}
+
+// OldestTask 返回状态为 state 的任务中最早入队的一个，没有时返回 gorm.ErrRecordNotFound
+func (obj *Replication) OldestTask(ctx context.Context, state string) (tmp *dbm.ReplicationTask, err error) {
+	err = obj.DB.Model(&dbm.ReplicationTask{}).WithContext(ctx).
+		Where("state = ?", state).Order("created_at").First(&tmp).Error
+	if err != nil {
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// ListTasks 按入队时间倒序分页查询，bucketName、state 为空时不过滤
+func (obj *Replication) ListTasks(ctx context.Context, bucketName, state string, page *PageCondition) (results []*dbm.ReplicationTask, count int64, err error) {
+	results = []*dbm.ReplicationTask{}
+	tx := obj.DB.Model(&dbm.ReplicationTask{}).WithContext(ctx)
+	if bucketName != "" {
+		tx = tx.Where("bucket_name = ?", bucketName)
+	}
+	if state != "" {
+		tx = tx.Where("state = ?", state)
+	}
+	if err = tx.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("created_at desc").Offset(page.Offset()).Limit(page.Limit()).Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
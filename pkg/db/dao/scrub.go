@@ -0,0 +1,39 @@
+package dao
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"gorm.io/gorm"
+)
+
+type Scrub struct {
+	*Base
+}
+
+func NewScrub(db *gorm.DB) *Scrub {
+	return &Scrub{
+		Base: &Base{DB: db},
+	}
+}
+
+// CreateIssue 记录一条校验发现的问题
+func (obj *Scrub) CreateIssue(ctx context.Context, issue *dbm.ScrubIssue) error {
+	return obj.DB.Model(&dbm.ScrubIssue{}).WithContext(ctx).Create(issue).Error
+}
+
+// ListIssues 按发现时间倒序分页查询问题，bucketName 为空时查询全部
+func (obj *Scrub) ListIssues(ctx context.Context, bucketName string, page *PageCondition) (results []*dbm.ScrubIssue, count int64, err error) {
+	results = []*dbm.ScrubIssue{}
+	tx := obj.DB.Model(&dbm.ScrubIssue{}).WithContext(ctx)
+	if bucketName != "" {
+		tx = tx.Where("bucket_name = ?", bucketName)
+	}
+	if err = tx.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order("detected_at desc").Offset(page.Offset()).Limit(page.Limit()).Find(&results).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, count, nil
+}
@@ -46,3 +46,8 @@ func (obj *User) GetUserInfoByID(id uint) (tmp *dbm.UserInfo, err error) {
 func (obj *User) CreateUser(ctx context.Context, user *dbm.UserInfo) (err error) {
 	return obj.DB.Model(&dbm.UserInfo{}).WithContext(ctx).Create(&user).Error
 }
+
+// UpdatePassword 更新用户密码，password 为加密后的值
+func (obj *User) UpdatePassword(ctx context.Context, id uint, password string) error {
+	return obj.DB.Model(&dbm.UserInfo{}).WithContext(ctx).Where("id = ?", id).Update("password", password).Error
+}
@@ -1,36 +1,61 @@
 package db
 
 import (
-	"fmt"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/log"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"os"
+	"sync"
 	"time"
 )
 
 var (
-	db *gorm.DB
+	db   *gorm.DB
+	once sync.Once
 )
 
-func init() {
-	var dsn string
-	env := os.Getenv("USER")
-	if env == "root" {
-		dsn = "root:123456@tcp(127.0.0.1:3306)/xfzddataV2?charset=utf8mb4&parseTime=True&loc=Local"
-	} else {
-		dsn = "root:123456@tcp(0.0.0.0:3306)/test?charset=utf8mb4&parseTime=True&loc=Local"
-	}
+// open 按配置连接元数据库，第一次调用 Db 时执行，此时配置已经加载
+func open() {
+	c := config.GetMySQL()
 	var err error
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err = gorm.Open(mysql.Open(c.DSN), &gorm.Config{})
 	if err != nil {
-		fmt.Println(err)
+		log.Errorf("connect mysql failed: %v", err)
+		return
+	}
+	if err = db.Use(TracingPlugin()); err != nil {
+		log.Errorf("register tracing plugin failed: %v", err)
+	}
+	if err = db.Use(FencingPlugin()); err != nil {
+		log.Errorf("register fencing plugin failed: %v", err)
 	}
 	sql, _ := db.DB()
-	sql.SetConnMaxLifetime(60 * time.Minute)
-	sql.SetMaxOpenConns(100)
-	sql.SetMaxIdleConns(50)
+	sql.SetConnMaxLifetime(time.Duration(c.ConnMaxLifetimeMinutes) * time.Minute)
+	sql.SetMaxOpenConns(c.MaxOpenConns)
+	sql.SetMaxIdleConns(c.MaxIdleConns)
 }
 
 func Db() *gorm.DB {
+	once.Do(open)
 	return db
 }
+
+// Set 使用指定的数据库连接代替按配置连接的数据库，供测试使用，返回恢复原来连接的函数
+func Set(conn *gorm.DB) (restore func()) {
+	once.Do(func() {})
+	prev := db
+	db = conn
+	return func() { db = prev }
+}
+
+// Close 关闭元数据库连接，未连接时什么也不做
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	sql, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sql.Close()
+}
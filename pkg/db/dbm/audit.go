@@ -0,0 +1,35 @@
+package dbm
+
+import "time"
+
+const (
+	AuditResultSuccess = "success" // 操作成功
+	AuditResultFailure = "failure" // 参数错误、对象不存在或服务端错误
+	AuditResultDenied  = "denied"  // 未登录或没有权限
+)
+
+// AuditLog 一次数据或管理操作的审计记录，只追加不修改
+type AuditLog struct {
+	Id         uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	RequestID  string    `gorm:"column:request_id;type:varchar(128);index" json:"request_id"`  //请求 id
+	UserID     uint      `gorm:"column:user_id;index" json:"user_id"`                          //操作用户，未登录时为 0
+	UserName   string    `gorm:"column:user_name;type:varchar(64)" json:"user_name"`           //操作用户名
+	SourceIP   string    `gorm:"column:source_ip;type:varchar(64)" json:"source_ip"`           //客户端地址
+	Action     string    `gorm:"column:action;type:varchar(64);index" json:"action"`           //操作类型，如 object.put
+	Method     string    `gorm:"column:method;type:varchar(16)" json:"method"`                 //HTTP 方法
+	Path       string    `gorm:"column:path;type:varchar(512)" json:"path"`                    //请求路径
+	BucketName string    `gorm:"column:bucket_name;type:varchar(64);index" json:"bucket_name"` //操作的桶
+	ObjectName string    `gorm:"column:object_name;type:varchar(512)" json:"object_name"`      //操作的对象
+	VersionID  string    `gorm:"column:version_id;type:varchar(64)" json:"version_id"`         //操作的对象版本
+	Result     string    `gorm:"column:result;type:varchar(16);index" json:"result"`           //操作结果 success/failure/denied
+	StatusCode int       `gorm:"column:status_code" json:"status_code"`                        //响应状态码
+	Error      string    `gorm:"column:error;type:varchar(512)" json:"error,omitempty"`        //失败原因
+	BytesIn    int64     `gorm:"column:bytes_in" json:"bytes_in"`                              //请求体大小
+	BytesOut   int64     `gorm:"column:bytes_out" json:"bytes_out"`                            //响应体大小
+	LatencyMs  int64     `gorm:"column:latency_ms" json:"latency_ms"`                          //处理耗时（毫秒）
+	CreatedAt  time.Time `gorm:"column:created_at;index" json:"created_at"`                    //操作时间
+}
+
+func (*AuditLog) TableName() string {
+	return "audit_log"
+}
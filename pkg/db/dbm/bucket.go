@@ -0,0 +1,29 @@
+package dbm
+
+import "time"
+
+// Bucket 桶注册表，记录桶的所有者、区域和配置
+type Bucket struct {
+	Id               uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	Name             string    `gorm:"column:name;type:varchar(64);uniqueIndex" json:"name"`       //桶名称
+	OwnerID          uint      `gorm:"column:owner_id;index" json:"owner_id"`                      //所有者的用户 ID
+	Owner            string    `gorm:"column:owner;type:varchar(64)" json:"owner"`                 //所有者的用户名
+	Region           string    `gorm:"column:region;type:varchar(32)" json:"region"`               //桶所在的区域
+	Zone             string    `gorm:"column:zone;type:varchar(64)" json:"zone"`                   //主可用区
+	Versioning       string    `gorm:"column:versioning;type:varchar(16)" json:"versioning"`       //版本控制状态
+	Replicas         int       `gorm:"column:replicas" json:"replicas"`                            //副本数，0 表示使用默认值
+	StorageClass     string    `gorm:"column:storage_class;type:varchar(32)" json:"storage_class"` //默认存储类型
+	QuotaBytes       int64     `gorm:"column:quota_bytes" json:"quota_bytes"`                      //对象总大小上限
+	QuotaObjects     int64     `gorm:"column:quota_objects" json:"quota_objects"`                  //对象数上限
+	SoftQuotaBytes   int64     `gorm:"column:soft_quota_bytes" json:"soft_quota_bytes"`            //对象总大小告警线
+	SoftQuotaObjects int64     `gorm:"column:soft_quota_objects" json:"soft_quota_objects"`        //对象数告警线
+	Tags             string    `gorm:"column:tags;type:text" json:"tags"`                          //标签，json 格式
+	Lifecycle        string    `gorm:"column:lifecycle;type:text" json:"lifecycle"`                //生命周期规则，json 格式
+	Replication      string    `gorm:"column:replication;type:text" json:"replication"`            //复制规则，json 格式
+	CreatedAt        time.Time `gorm:"column:created_at" json:"created_at"`                        //创建时间
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`                        //最近一次修改配置的时间
+}
+
+func (*Bucket) TableName() string {
+	return "bucket"
+}
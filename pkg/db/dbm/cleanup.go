@@ -0,0 +1,20 @@
+package dbm
+
+import "time"
+
+// ObjectCleanup 元数据已删除、数据还没有从存储中删除的对象，如重命名后的源对象，删除失败时按退避时间重试
+type ObjectCleanup struct {
+	Id            uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName    string    `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_cleanup_object,priority:1" json:"bucket_name"`  //对象所属的桶名称
+	ObjectName    string    `gorm:"column:object_name;type:varchar(512);uniqueIndex:idx_cleanup_object,priority:2" json:"object_name"` //对象的名称
+	TierKey       string    `gorm:"column:tier_key;type:varchar(1024)" json:"tier_key"`                                                //对象在冷存储中的 key，为空表示数据在存储节点上
+	Attempts      int       `gorm:"column:attempts" json:"attempts"`                                                                   //已尝试的次数
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;index" json:"next_attempt_at"`                                               //下一次尝试的时间
+	LastError     string    `gorm:"column:last_error;type:varchar(1024)" json:"last_error"`                                            //最近一次失败的原因
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (*ObjectCleanup) TableName() string {
+	return "object_cleanup"
+}
@@ -0,0 +1,24 @@
+package dbm
+
+import "time"
+
+// DeleteJob 删除桶内某个前缀下所有对象的异步任务，按元数据 id 顺序分批删除，LastID 记录进度以便中断后继续
+type DeleteJob struct {
+	Id           uint       `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName   string     `gorm:"column:bucket_name;type:varchar(64);index" json:"bucket_name"` //桶名称
+	Prefix       string     `gorm:"column:prefix;type:varchar(512)" json:"prefix"`                //要删除的对象前缀，为空时清空整个桶
+	DeleteBucket bool       `gorm:"column:delete_bucket" json:"delete_bucket"`                    //清空桶后删除桶
+	State        string     `gorm:"column:state;type:varchar(16);index" json:"state"`             //PENDING/RUNNING/COMPLETED/FAILED
+	LastID       uint       `gorm:"column:last_id" json:"-"`                                      //已处理到的元数据 id
+	Deleted      int64      `gorm:"column:deleted" json:"deleted"`                                //已删除的对象数
+	DeletedBytes int64      `gorm:"column:deleted_bytes" json:"deleted_bytes"`                    //已删除的字节数
+	Failed       int64      `gorm:"column:failed" json:"failed"`                                  //删除失败的对象数
+	LastError    string     `gorm:"column:last_error;type:varchar(1024)" json:"last_error"`       //最近一次失败的原因
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`                          //创建时间，只删除在此之前写入的对象
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	FinishedAt   *time.Time `gorm:"column:finished_at" json:"finished_at"`
+}
+
+func (*DeleteJob) TableName() string {
+	return "delete_job"
+}
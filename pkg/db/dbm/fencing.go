@@ -0,0 +1,14 @@
+package dbm
+
+import "time"
+
+// FencingToken 每个后台任务已提交过的最大 fencing token，token 更小的写入来自已失去领导权的实例
+type FencingToken struct {
+	Resource  string    `gorm:"column:resource;type:varchar(191);primary_key" json:"resource"` //选主方式和任务名
+	Token     int64     `gorm:"column:token" json:"token"`                                     //已提交过的最大 token
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (*FencingToken) TableName() string {
+	return "fencing_token"
+}
@@ -0,0 +1,21 @@
+package dbm
+
+import "time"
+
+// LifecycleLog 生命周期规则删除的对象、历史版本或取消的分片上传
+type LifecycleLog struct {
+	Id         uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName string    `gorm:"column:bucket_name;type:varchar(64);index" json:"bucket_name"` //对象所属的桶名称
+	ObjectName string    `gorm:"column:object_name;type:varchar(512)" json:"object_name"`      //对象的名称
+	VersionID  string    `gorm:"column:version_id;type:varchar(64)" json:"version_id"`         //删除的历史版本
+	UploadID   string    `gorm:"column:upload_id;type:varchar(256)" json:"upload_id"`          //取消的分片上传
+	NodeID     string    `gorm:"column:node_id;type:varchar(64)" json:"node_id"`               //历史版本和分片上传所在的节点
+	Action     string    `gorm:"column:action;type:varchar(16)" json:"action"`                 //expire/noncurrent/abort_upload
+	RuleID     string    `gorm:"column:rule_id;type:varchar(255)" json:"rule_id"`              //匹配的规则
+	Size       int64     `gorm:"column:size" json:"size"`                                      //删除的字节数
+	CreatedAt  time.Time `gorm:"column:created_at;index" json:"created_at"`                    //删除时间
+}
+
+func (*LifecycleLog) TableName() string {
+	return "lifecycle_log"
+}
@@ -1,20 +1,46 @@
 package dbm
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // ObjectMetadata 定义了对象的元数据结构。
 type ObjectMetadata struct {
-	BucketName   string    //对象所属的桶名称
-	ObjectName   string    //对象的名称
-	Size         int64     //对象的⼤⼩（字节）
-	ContentType  string    // 对象的内容类型
-	ETag         string    // 对象的 ETag （通常是内容的 MD5 哈希）
-	LastModified time.Time //对象最后修改时间
-	StorageNodes []string  // 存储该对象的节点列表
-	VersionID    string    // 对象的版本 ID （如果启⽤了版本控制）
-	IsLatest     bool      // 是否是最新版本
+	Id           uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName   string    `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_bucket_object,priority:1" json:"bucket_name"`  //对象所属的桶名称
+	ObjectName   string    `gorm:"column:object_name;type:varchar(512);uniqueIndex:idx_bucket_object,priority:2" json:"object_name"` //对象的名称
+	Size         int64     `gorm:"column:size" json:"size"`                                                                          //对象的⼤⼩（字节）
+	ContentType  string    `gorm:"column:content_type;type:varchar(128)" json:"content_type"`                                        // 对象的内容类型
+	ETag         string    `gorm:"column:etag;type:varchar(64)" json:"etag"`                                                         // 对象的 ETag （通常是内容的 MD5 哈希）
+	LastModified time.Time `gorm:"column:last_modified" json:"last_modified"`                                                        //对象最后修改时间
+	StorageNodes string    `gorm:"column:storage_nodes;type:varchar(512)" json:"storage_nodes"`                                      // 存储该对象的节点列表，逗号分隔
+	VersionID    string    `gorm:"column:version_id;type:varchar(64)" json:"version_id"`                                             // 对象的版本 ID （如果启⽤了版本控制）
+	IsLatest     bool      `gorm:"column:is_latest" json:"is_latest"`                                                                // 是否是最新版本
+	StorageClass string    `gorm:"column:storage_class;type:varchar(32)" json:"storage_class"`                                       // 对象的存储类型
+	TierKey      string    `gorm:"column:tier_key;type:varchar(1024)" json:"tier_key"`                                               // 对象在冷存储中的 key，为空表示对象在存储节点上
+	// ReplicationStatus 跨集群复制状态 PENDING/COMPLETED/FAILED/REPLICA，桶没有复制规则时为空
+	ReplicationStatus string `gorm:"column:replication_status;type:varchar(16)" json:"replication_status"`
 }
 
 func (obj *ObjectMetadata) TableName() string {
 	return "object_metadata"
 }
+
+// IsCold 对象是否已转移到冷存储
+func (obj *ObjectMetadata) IsCold() bool {
+	return obj.TierKey != ""
+}
+
+// Nodes 返回存储该对象的节点 ID 列表
+func (obj *ObjectMetadata) Nodes() []string {
+	if obj.StorageNodes == "" {
+		return []string{}
+	}
+	return strings.Split(obj.StorageNodes, ",")
+}
+
+// SetNodes 设置存储该对象的节点 ID 列表
+func (obj *ObjectMetadata) SetNodes(nodes []string) {
+	obj.StorageNodes = strings.Join(nodes, ",")
+}
@@ -0,0 +1,17 @@
+package dbm
+
+import "time"
+
+// UserQuota 用户配额，没有记录的用户不限制
+type UserQuota struct {
+	UserID           uint      `gorm:"column:user_id;primary_key;autoIncrement:false" json:"user_id"` //用户 ID
+	QuotaBytes       int64     `gorm:"column:quota_bytes" json:"quota_bytes"`                         //对象总大小上限
+	QuotaObjects     int64     `gorm:"column:quota_objects" json:"quota_objects"`                     //对象数上限
+	SoftQuotaBytes   int64     `gorm:"column:soft_quota_bytes" json:"soft_quota_bytes"`               //对象总大小告警线
+	SoftQuotaObjects int64     `gorm:"column:soft_quota_objects" json:"soft_quota_objects"`           //对象数告警线
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`                           //最近一次修改的时间
+}
+
+func (*UserQuota) TableName() string {
+	return "user_quota"
+}
@@ -0,0 +1,23 @@
+package dbm
+
+import "time"
+
+// ReplicationTask 等待复制到其他集群的对象写入或删除，同一对象只保留最新的一次
+type ReplicationTask struct {
+	Id            uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName    string    `gorm:"column:bucket_name;type:varchar(64);uniqueIndex:idx_replication_object,priority:1" json:"bucket_name"`  //对象所属的桶名称
+	ObjectName    string    `gorm:"column:object_name;type:varchar(512);uniqueIndex:idx_replication_object,priority:2" json:"object_name"` //对象的名称
+	Operation     string    `gorm:"column:operation;type:varchar(16)" json:"operation"`                                                    //put/delete
+	ETag          string    `gorm:"column:etag;type:varchar(64)" json:"etag"`                                                              //写入时对象的 ETag
+	RuleID        string    `gorm:"column:rule_id;type:varchar(255)" json:"rule_id"`                                                       //匹配的复制规则
+	State         string    `gorm:"column:state;type:varchar(16);index:idx_replication_due,priority:1" json:"state"`                       //PENDING/FAILED
+	Attempts      int       `gorm:"column:attempts" json:"attempts"`                                                                       //已尝试的次数
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;index:idx_replication_due,priority:2" json:"next_attempt_at"`                    //下一次尝试的时间
+	LastError     string    `gorm:"column:last_error;type:varchar(1024)" json:"last_error"`                                                //最近一次失败的原因
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`                                                                   //入队时间，用于计算复制延迟
+	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (*ReplicationTask) TableName() string {
+	return "replication_task"
+}
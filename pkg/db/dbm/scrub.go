@@ -0,0 +1,24 @@
+package dbm
+
+import "time"
+
+const (
+	ScrubIssueMissing   = "missing"   // 副本不存在
+	ScrubIssueCorrupted = "corrupted" // 副本大小或 ETag 与元数据不一致
+)
+
+// ScrubIssue 后台校验发现的副本问题
+type ScrubIssue struct {
+	Id         uint      `gorm:"column:id;primary_key;not null" json:"id"`
+	BucketName string    `gorm:"column:bucket_name;type:varchar(64);index" json:"bucket_name"` //对象所属的桶名称
+	ObjectName string    `gorm:"column:object_name;type:varchar(512)" json:"object_name"`      //对象的名称
+	NodeID     string    `gorm:"column:node_id;type:varchar(64)" json:"node_id"`               //出现问题的节点
+	Kind       string    `gorm:"column:kind;type:varchar(16)" json:"kind"`                     //问题类型 missing/corrupted
+	Detail     string    `gorm:"column:detail;type:varchar(512)" json:"detail"`                //问题描述
+	Repaired   bool      `gorm:"column:repaired" json:"repaired"`                              //是否已从健康副本修复
+	DetectedAt time.Time `gorm:"column:detected_at;index" json:"detected_at"`                  //发现时间
+}
+
+func (*ScrubIssue) TableName() string {
+	return "scrub_issue"
+}
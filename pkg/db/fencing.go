@@ -0,0 +1,89 @@
+package db
+
+import (
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const fencingTable = "fencing_token"
+
+// fencingPlugin 后台任务写入数据时，在同一个事务内把 fencing_token 表中记录的 token 推进到本次写入的 token，
+// 记录的 token 更大时拒绝写入。新的领导者提交过一次之后，旧领导者的写入都会返回 ErrStaleToken。
+type fencingPlugin struct{}
+
+// FencingPlugin 返回在提交时检查 fencing token 的插件，连接其他数据库时通过 db.Use 注册
+func FencingPlugin() gorm.Plugin {
+	return fencingPlugin{}
+}
+
+func (fencingPlugin) Name() string {
+	return "fencing"
+}
+
+func (fencingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:begin_transaction").Before("gorm:create").Register("fencing:create", checkFencingToken); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:begin_transaction").Before("gorm:update").Register("fencing:update", checkFencingToken); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:begin_transaction").Before("gorm:delete").Register("fencing:delete", checkFencingToken)
+}
+
+// checkFencingToken 以条件更新推进记录的 token。MySQL 的影响行数只计算值有变化的行，
+// 同一个 token 在同一毫秒内重复写入时也不会更新任何行，此时读取记录的 token 判断是否已有更大的 token
+func checkFencingToken(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Table == fencingTable {
+		return
+	}
+	token := fencing.FromContext(tx.Statement.Context)
+	if token == nil || token.Resource == "" {
+		return
+	}
+	// 新会话沿用当前语句的连接，在同一个事务内执行
+	session := tx.Session(&gorm.Session{NewDB: true})
+	result := session.Exec("UPDATE "+fencingTable+" SET token = ?, updated_at = ? WHERE resource = ? AND token <= ?",
+		token.Value, time.Now(), token.Resource, token.Value)
+	if result.Error != nil {
+		_ = tx.AddError(result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		return
+	}
+	stale, err := staleFencingToken(session, token)
+	if err != nil {
+		_ = tx.AddError(err)
+		return
+	}
+	if stale {
+		_ = tx.AddError(fencing.ErrStaleToken)
+	}
+}
+
+// staleFencingToken 条件更新没有更新任何行时，锁定并读取记录的 token，比 token 更大时返回 true；
+// 还没有记录时写入 token，与其他领导者同时写入时以先写入的为准
+func staleFencingToken(session *gorm.DB, token *fencing.Token) (bool, error) {
+	var stored dbm.FencingToken
+	err := session.Clauses(clause.Locking{Strength: "UPDATE"}).Where("resource = ?", token.Resource).Take(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		result := session.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&dbm.FencingToken{Resource: token.Resource, Token: token.Value, UpdatedAt: time.Now()})
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return false, nil
+		}
+		err = session.Clauses(clause.Locking{Strength: "UPDATE"}).Where("resource = ?", token.Resource).Take(&stored).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	return stored.Token > token.Value, nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"errors"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func openFencedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = db.Use(FencingPlugin()); err != nil {
+		t.Fatalf("use fencing plugin: %v", err)
+	}
+	if err = db.AutoMigrate(&dbm.FencingToken{}, &dbm.Bucket{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func fencedContext(token int64) context.Context {
+	return fencing.NewContext(context.Background(), &fencing.Token{Resource: "redis/rebalancer", Value: token})
+}
+
+func TestFencingPluginRejectsStaleToken(t *testing.T) {
+	db := openFencedDB(t)
+	if err := db.WithContext(fencedContext(5)).Create(&dbm.Bucket{Name: "old-leader"}).Error; err != nil {
+		t.Fatalf("create with token 5: %v", err)
+	}
+	if err := db.WithContext(fencedContext(7)).Create(&dbm.Bucket{Name: "new-leader"}).Error; err != nil {
+		t.Fatalf("create with token 7: %v", err)
+	}
+
+	// 新的领导者提交之后，旧领导者的写入被拒绝且不生效
+	err := db.WithContext(fencedContext(5)).Model(&dbm.Bucket{}).Where("name = ?", "old-leader").Update("owner", "stale").Error
+	if !errors.Is(err, fencing.ErrStaleToken) {
+		t.Fatalf("update with token 5 = %v, want ErrStaleToken", err)
+	}
+	err = db.WithContext(fencedContext(5)).Where("name = ?", "new-leader").Delete(&dbm.Bucket{}).Error
+	if !errors.Is(err, fencing.ErrStaleToken) {
+		t.Fatalf("delete with token 5 = %v, want ErrStaleToken", err)
+	}
+	var buckets []dbm.Bucket
+	if err = db.Order("name").Find(&buckets).Error; err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if len(buckets) != 2 || buckets[1].Owner != "" {
+		t.Fatalf("buckets = %+v", buckets)
+	}
+
+	var stored dbm.FencingToken
+	if err = db.First(&stored, "resource = ?", "redis/rebalancer").Error; err != nil {
+		t.Fatalf("get stored token: %v", err)
+	}
+	if stored.Token != 7 {
+		t.Fatalf("stored token = %d, want 7", stored.Token)
+	}
+	// 不带 token 的请求不受影响
+	if err = db.Create(&dbm.Bucket{Name: "request"}).Error; err != nil {
+		t.Fatalf("create without token: %v", err)
+	}
+}
+
+func TestStaleFencingTokenReadsStoredToken(t *testing.T) {
+	db := openFencedDB(t)
+	session := db.Session(&gorm.Session{NewDB: true})
+	// MySQL 中同一个 token 重复写入时条件更新不会更新任何行，由记录的 token 判断
+	for _, c := range []struct {
+		token int64
+		stale bool
+	}{
+		{token: 7, stale: false}, // 还没有记录，写入 7
+		{token: 7, stale: false},
+		{token: 5, stale: true},
+		{token: 9, stale: false},
+	} {
+		stale, err := staleFencingToken(session, &fencing.Token{Resource: "redis/rebalancer", Value: c.token})
+		if err != nil {
+			t.Fatalf("token %d: %v", c.token, err)
+		}
+		if stale != c.stale {
+			t.Fatalf("token %d stale = %v, want %v", c.token, stale, c.stale)
+		}
+	}
+	var stored dbm.FencingToken
+	if err := db.First(&stored, "resource = ?", "redis/rebalancer").Error; err != nil {
+		t.Fatalf("get stored token: %v", err)
+	}
+	if stored.Token != 7 {
+		t.Fatalf("stored token = %d, want 7", stored.Token)
+	}
+}
+
+func TestFencingPluginAcceptsSameToken(t *testing.T) {
+	db := openFencedDB(t)
+	ctx := fencedContext(7)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := db.WithContext(ctx).Create(&dbm.Bucket{Name: name}).Error; err != nil {
+			t.Fatalf("create %s with the same token: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"distributed-object-storage/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// tracingPlugin 为每条 SQL 创建 span，dao 需要通过 WithContext 传入请求的 context
+type tracingPlugin struct{}
+
+// TracingPlugin 返回为 SQL 创建 span 的插件，连接其他数据库（例如测试使用的 sqlite）时通过 db.Use 注册
+func TracingPlugin() gorm.Plugin {
+	return tracingPlugin{}
+}
+
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+func (p tracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name   string
+		before func(name string, fn func(*gorm.DB)) error
+		after  func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		operation := h.name
+		if err := h.before("tracing:before_"+operation, func(tx *gorm.DB) {
+			ctx, span := tracing.Tracer().Start(tx.Statement.Context, "mysql."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", "mysql")))
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanKey, span)
+		}); err != nil {
+			return err
+		}
+		if err := h.after("tracing:after_"+operation, func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(spanKey)
+			if !ok {
+				return
+			}
+			span := v.(trace.Span)
+			span.SetAttributes(
+				attribute.String("db.sql.table", tx.Statement.Table),
+				attribute.String("db.statement", tx.Statement.SQL.String()),
+				attribute.Int64("db.rows_affected", tx.RowsAffected),
+			)
+			err := tx.Error
+			if err == gorm.ErrRecordNotFound {
+				err = nil
+			}
+			tracing.End(span, err)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
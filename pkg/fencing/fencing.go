@@ -0,0 +1,42 @@
+package fencing
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrStaleToken = errors.New("fencing token is stale, leadership lost")
+
+// Token 后台任务持有领导权期间的凭证。
+// Value 随每次选主单调递增，Validate 检查该凭证是否仍然有效。
+// Resource 不为空时，写入元数据库的语句在提交时与库中记录的 token 比较，见 db.FencingPlugin。
+type Token struct {
+	Resource string
+	Value    int64
+	Validate func(ctx context.Context) error
+}
+
+type tokenKey struct{}
+
+// NewContext 将凭证放入 ctx，传给 Syncer.Sync
+func NewContext(ctx context.Context, token *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// FromContext 取出 ctx 中的凭证，没有时返回 nil
+func FromContext(ctx context.Context) *Token {
+	token, _ := ctx.Value(tokenKey{}).(*Token)
+	return token
+}
+
+// Check 在提交结果前调用，ctx 中的凭证已失效时返回 ErrStaleToken，没有凭证时返回 nil
+func Check(ctx context.Context) error {
+	token := FromContext(ctx)
+	if token == nil || token.Validate == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return ErrStaleToken
+	}
+	return token.Validate(ctx)
+}
@@ -0,0 +1,65 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker 记录请求返回后仍在后台进行的任务（如异步上传），进程退出前等待它们完成。
+// Close 之后不再接受新任务。
+type Tracker struct {
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+	running int
+	closed  bool
+}
+
+// Add 登记一个任务，已经 Close 时返回 false，调用方应拒绝该任务
+func (t *Tracker) Add() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return false
+	}
+	t.running++
+	t.wg.Add(1)
+	return true
+}
+
+// Done 任务结束，与 Add 成对调用
+func (t *Tracker) Done() {
+	t.mutex.Lock()
+	t.running--
+	t.mutex.Unlock()
+	t.wg.Done()
+}
+
+// Running 进行中的任务数
+func (t *Tracker) Running() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.running
+}
+
+// Close 停止接受新任务
+func (t *Tracker) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closed = true
+}
+
+// Wait 停止接受新任务并等待进行中的任务完成，ctx 先结束时返回 ctx 的错误
+func (t *Tracker) Wait(ctx context.Context) error {
+	t.Close()
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Fields = logrus.Fields
+
+// 常用的上下文字段
+const (
+	FieldRequestID = "request_id"
+	FieldUserID    = "user_id"
+	FieldBucket    = "bucket"
+	FieldObject    = "object"
+	FieldUploadID  = "upload_id"
+	FieldNode      = "node"
+	FieldSyncer    = "syncer"
+	FieldTraceID   = "trace_id"
+)
+
+type fieldsKey struct{}
+
+// NewContext 返回附加了日志字段的 ctx，与 ctx 中已有的字段合并，同名字段以 fields 为准
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	merged := make(Fields, len(fields))
+	for k, v := range FieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext 返回 ctx 中的日志字段，不要修改返回值
+func FieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return fields
+}
+
+// RequestID 返回 ctx 中的请求 id，没有时返回空
+func RequestID(ctx context.Context) string {
+	id, _ := FieldsFromContext(ctx)[FieldRequestID].(string)
+	return id
+}
+
+// Ctx 返回带有 ctx 中日志字段和 trace id 的 logger
+func Ctx(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logger)
+	if ctx == nil {
+		return entry
+	}
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		entry = entry.WithFields(fields)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.WithField(FieldTraceID, sc.TraceID().String())
+	}
+	return entry
+}
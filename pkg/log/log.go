@@ -1,23 +1,81 @@
 package log
 
 import (
-	"fmt"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
 	"os"
+	"sync"
 )
 
 var logger *logrus.Logger
 
 func init() {
+	// 配置加载前输出到标准错误，serve 按 log.file 配置切换到文件
 	logger = logrus.New()
-	file, err := os.OpenFile("server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err == nil {
-		logger.Out = file
+	logger.Out = os.Stderr
+}
+
+// FileOptions 日志文件及按大小切割的参数
+type FileOptions struct {
+	// Path 日志文件路径，为空时输出到标准错误
+	Path string
+	// MaxSizeMB 单个文件的最大大小，超过后切割
+	MaxSizeMB int
+	// MaxBackups 保留的旧文件个数，0 表示不限制
+	MaxBackups int
+	// MaxAgeDays 旧文件保留天数，0 表示不限制
+	MaxAgeDays int
+	// Compress 是否压缩切割后的旧文件
+	Compress bool
+}
+
+var output = struct {
+	sync.Mutex
+	opts   FileOptions
+	writer io.WriteCloser
+}{}
+
+// SetOutput 设置日志输出，参数没有变化时保留当前文件
+func SetOutput(opts FileOptions) {
+	output.Lock()
+	defer output.Unlock()
+	if output.writer != nil && output.opts == opts {
+		return
+	}
+	previous := output.writer
+	if opts.Path == "" {
+		output.writer = nil
+		logger.SetOutput(os.Stderr)
 	} else {
-		fmt.Println("Failed to log to file, using default stderr")
+		output.writer = &lumberjack.Logger{
+			Filename:   opts.Path,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAgeDays,
+			Compress:   opts.Compress,
+		}
+		logger.SetOutput(output.writer)
+	}
+	output.opts = opts
+	if previous != nil {
+		_ = previous.Close()
 	}
 }
 
+// Close 关闭日志文件，进程退出前调用
+func Close() error {
+	output.Lock()
+	defer output.Unlock()
+	if output.writer == nil {
+		return nil
+	}
+	logger.SetOutput(os.Stderr)
+	err := output.writer.Close()
+	output.writer = nil
+	return err
+}
+
 func Info(args ...interface{}) {
 	logger.Info(args...)
 }
@@ -31,6 +89,16 @@ func SetLogLevel(level logrus.Level) {
 	logger.SetLevel(level)
 }
 
+// SetLevel 按名字设置日志级别，如 debug、info
+func SetLevel(level string) error {
+	l, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(l)
+	return nil
+}
+
 // Warn 级别日志
 func Warn(args ...interface{}) {
 	logger.Warn(args...)
@@ -53,6 +121,10 @@ func Debug(args ...interface{}) {
 	logger.Debug(args...)
 }
 
+func Debugf(format string, args ...interface{}) {
+	logger.Debugf(format, args...)
+}
+
 func SetLogFormat(format string) {
 	if format == "json" {
 		logger.SetFormatter(&logrus.JSONFormatter{})
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package metrics
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime 进程累计使用的 CPU 时间
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
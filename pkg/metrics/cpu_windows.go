@@ -0,0 +1,8 @@
+package metrics
+
+import "time"
+
+// processCPUTime 暂不支持 windows
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const namespace = "dos"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	requestBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_request_bytes_total",
+		Help:      "Bytes received in HTTP request bodies.",
+	}, []string{"route"})
+	responseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_response_bytes_total",
+		Help:      "Bytes sent in HTTP response bodies.",
+	}, []string{"route"})
+	requestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+
+	storageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of requests to storage nodes by node, operation and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"node", "operation", "status"})
+
+	uploadsInProgress = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "uploads_in_progress",
+		Help:      "Uploads being written to storage nodes.",
+	})
+	uploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploads_total",
+		Help:      "Finished uploads by result.",
+	}, []string{"result"})
+	uploadPartsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_parts_total",
+		Help:      "Multipart upload parts written to storage nodes.",
+	})
+	uploadBytesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes written to storage nodes by uploads.",
+	})
+
+	syncerRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "syncer_run_duration_seconds",
+		Help:      "Duration of syncer runs.",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, []string{"name"})
+	syncerRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "syncer_runs_total",
+		Help:      "Syncer runs by result.",
+	}, []string{"name", "result"})
+
+	replicationTasksTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "replication_tasks_total",
+		Help:      "Replication attempts by operation and result.",
+	}, []string{"operation", "result"})
+	replicationLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "replication_lag_seconds",
+		Help:      "Time from a write or delete to its replication to the target.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 300, 900, 3600, 21600, 86400},
+	}, []string{"operation"})
+	replicationBacklog = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "replication_backlog_tasks",
+		Help:      "Replication tasks in the queue by state.",
+	}, []string{"state"})
+	replicationOldestPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "replication_oldest_pending_seconds",
+		Help:      "Age of the oldest replication task not yet completed.",
+	})
+
+	lockAcquireTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lock_acquire_total",
+		Help:      "Distributed lock acquire attempts by kind and result, contended means held by others.",
+	}, []string{"kind", "result"})
+	lockWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_wait_duration_seconds",
+		Help:      "Time spent waiting for a distributed lock.",
+		Buckets:   []float64{.005, .01, .05, .1, .5, 1, 2, 5, 10},
+	}, []string{"kind", "result"})
+)
+
+const (
+	ResultOK        = "ok"
+	ResultError     = "error"
+	ResultContended = "contended"
+)
+
+// Result 按 err 返回 ok 或 error
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// totals 进程内的累计值，用于生成 PerformanceMetrics 摘要
+var totals struct {
+	requests  atomic.Int64
+	errors    atomic.Int64
+	latencyNs atomic.Int64
+	inFlight  atomic.Int64
+}
+
+// RequestStarted 请求开始处理，与 ObserveRequest 成对调用
+func RequestStarted() {
+	requestsInFlight.Inc()
+	totals.inFlight.Add(1)
+}
+
+// ObserveRequest 记录一次请求，route 为路由模板而不是实际路径，避免标签过多
+func ObserveRequest(method, route string, status int, elapsed time.Duration, in, out int64) {
+	requestsInFlight.Dec()
+	totals.inFlight.Add(-1)
+
+	code := strconv.Itoa(status)
+	requestsTotal.WithLabelValues(method, route, code).Inc()
+	requestDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+	if in > 0 {
+		requestBytes.WithLabelValues(route).Add(float64(in))
+	}
+	if out > 0 {
+		responseBytes.WithLabelValues(route).Add(float64(out))
+	}
+
+	totals.requests.Add(1)
+	totals.latencyNs.Add(int64(elapsed))
+	if status >= 500 {
+		totals.errors.Add(1)
+	}
+}
+
+// ObserveStorage 记录一次对存储节点的请求
+func ObserveStorage(node, operation, status string, elapsed time.Duration) {
+	storageDuration.WithLabelValues(node, operation, status).Observe(elapsed.Seconds())
+}
+
+// UploadStarted 开始向存储节点写入，返回结束时调用的函数
+func UploadStarted() func(err error) {
+	uploadsInProgress.Inc()
+	return func(err error) {
+		uploadsInProgress.Dec()
+		uploadsTotal.WithLabelValues(Result(err)).Inc()
+	}
+}
+
+// ObserveUploadPart 记录写入成功的分片，非分片上传 part 为 false
+func ObserveUploadPart(size int64, part bool) {
+	if part {
+		uploadPartsTotal.Inc()
+	}
+	uploadBytesTotal.Add(float64(size))
+}
+
+// ObserveSyncerRun 记录后台任务的一次执行
+func ObserveSyncerRun(name string, elapsed time.Duration, err error) {
+	syncerRunDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+	syncerRunsTotal.WithLabelValues(name, Result(err)).Inc()
+}
+
+// ObserveReplication 记录一次复制尝试，成功时记录从写入到复制完成的延迟
+func ObserveReplication(operation string, lag time.Duration, err error) {
+	replicationTasksTotal.WithLabelValues(operation, Result(err)).Inc()
+	if err == nil {
+		replicationLag.WithLabelValues(operation).Observe(lag.Seconds())
+	}
+}
+
+// SetReplicationBacklog 更新复制队列的积压情况
+func SetReplicationBacklog(pending, failed int64, oldest time.Duration) {
+	replicationBacklog.WithLabelValues("pending").Set(float64(pending))
+	replicationBacklog.WithLabelValues("failed").Set(float64(failed))
+	replicationOldestPending.Set(oldest.Seconds())
+}
+
+// ObserveLockAttempt 记录一次获取锁的尝试，result 为 ok、contended 或 error
+func ObserveLockAttempt(key, result string) {
+	lockAcquireTotal.WithLabelValues(lockKind(key), result).Inc()
+}
+
+// ObserveLockWait 记录阻塞获取锁的等待时间
+func ObserveLockWait(key string, elapsed time.Duration, err error) {
+	lockWaitDuration.WithLabelValues(lockKind(key), Result(err)).Observe(elapsed.Seconds())
+}
+
+// lockKind 取锁 key 的第一段作为标签，如 object:lock:<bucket>/<object> 为 object
+func lockKind(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
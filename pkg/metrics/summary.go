@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"distributed-object-storage/types"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	sampleInterval = 10 * time.Second
+	// sampleCount 计算速率使用最近一分钟的采样
+	sampleCount = 6
+)
+
+type sample struct {
+	at        time.Time
+	requests  int64
+	errors    int64
+	latencyNs int64
+	cpu       time.Duration
+}
+
+var samples = struct {
+	sync.Mutex
+	list []sample
+}{}
+
+func takeSample() sample {
+	return sample{
+		at:        time.Now(),
+		requests:  totals.requests.Load(),
+		errors:    totals.errors.Load(),
+		latencyNs: totals.latencyNs.Load(),
+		cpu:       processCPUTime(),
+	}
+}
+
+// RunSampler 定期记录累计值，直到 ctx 取消
+func RunSampler(ctx context.Context) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	record := func() {
+		s := takeSample()
+		samples.Lock()
+		defer samples.Unlock()
+		samples.list = append(samples.list, s)
+		if len(samples.list) > sampleCount {
+			samples.list = samples.list[len(samples.list)-sampleCount:]
+		}
+	}
+	record()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			record()
+		}
+	}
+}
+
+// Summary 按最近一分钟的采样计算网关的性能指标
+func Summary() types.PerformanceMetrics {
+	now := takeSample()
+	res := types.PerformanceMetrics{
+		ActiveConnections: int(totals.inFlight.Load()),
+		MemoryUsage:       memoryUsage(),
+		Timestamp:         now.at,
+	}
+
+	samples.Lock()
+	var first sample
+	if len(samples.list) > 0 {
+		first = samples.list[0]
+	}
+	samples.Unlock()
+	if first.at.IsZero() {
+		return res
+	}
+	elapsed := now.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return res
+	}
+	requests := now.requests - first.requests
+	res.RequestRate = float64(requests) / elapsed
+	if requests > 0 {
+		res.ErrorRate = float64(now.errors-first.errors) / float64(requests) * 100
+		res.AverageLatency = float64(now.latencyNs-first.latencyNs) / float64(requests) / float64(time.Millisecond)
+	}
+	res.CPUUsage = (now.cpu - first.cpu).Seconds() / elapsed / float64(runtime.NumCPU()) * 100
+	return res
+}
+
+// memoryUsage 进程内存占 GOMEMLIMIT 的百分比，未设置内存上限时为 0
+func memoryUsage() float64 {
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		return 0
+	}
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return float64(stats.Sys) / float64(limit) * 100
+}
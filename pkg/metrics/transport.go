@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport 记录对存储节点的每个请求的耗时，node 为节点地址，operation 为 HTTP 方法
+type Transport struct {
+	Node string
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	status := ResultError
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	ObserveStorage(t.Node, req.Method, status, time.Since(start))
+	return resp, err
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dbm"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuditRecordsIdentity(t *testing.T) {
+	prev := config.Get()
+	config.Set(&config.Config{JWT: config.JWTConfig{Secret: "a-long-enough-jwt-secret"}})
+	t.Cleanup(func() { config.Set(prev) })
+	token, err := GenerateJWT(7, "alice")
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	var entries []*dbm.AuditLog
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Audit(func(ctx context.Context, entry *dbm.AuditLog) { entries = append(entries, entry) }))
+	r.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/private", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/public", "/private"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// 没有 token 的请求不记录用户
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/public", nil))
+
+	if len(entries) != 3 {
+		t.Fatalf("%d audit entries, want 3", len(entries))
+	}
+	for _, entry := range entries[:2] {
+		if entry.UserID != 7 || entry.UserName != "alice" {
+			t.Errorf("%s recorded user %d %q, want 7 alice", entry.Path, entry.UserID, entry.UserName)
+		}
+	}
+	if entries[2].UserID != 0 || entries[2].UserName != "" {
+		t.Errorf("anonymous request recorded user %d %q", entries[2].UserID, entries[2].UserName)
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"distributed-object-storage/config"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// CORS 跨域中间件，允许的来源每次请求时从配置读取，热加载后立即生效
+func CORS() gin.HandlerFunc {
+	return cors.New(cors.Config{
+		AllowOriginFunc: func(origin string) bool {
+			return config.GetCORS().AllowOrigin(origin)
+		},
+		AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Length", "Content-Type", "Authorization", "If-Match", "If-None-Match", RequestIDHeader},
+		ExposeHeaders: []string{"Content-Disposition", "Content-Length", RequestIDHeader, TraceIDHeader},
+		MaxAge:        12 * time.Hour,
+	})
+}
@@ -1,16 +1,27 @@
 package middleware
 
 import (
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/service"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"net/http"
 	"strings"
 	"time"
 )
 
-// 定义 secret key 用于签发和验证 JWT
-var jwtSecret = []byte("AFaGfgddjtyrjty46$xds")
+// gin.Context 中保存当前用户身份的 key
+const (
+	ContextUserID   = "userID"
+	ContextUserName = "userName"
+)
+
+// jwtSecret 签发和验证 JWT 使用的 secret key
+func jwtSecret() []byte {
+	return []byte(config.GetJWT().Secret)
+}
 
 // User 模拟一个用户结构，包含用户 ID 和权限
 type User struct {
@@ -38,13 +49,13 @@ func GenerateJWT(userID uint, userName string) (string, error) {
 		UserID:   userID,
 		UserName: userName,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)), // JWT 24小时后过期
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(config.GetJWT().ExpireHours))),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// 使用 secret key 签名并生成 JWT 字符串
-	tokenString, err := token.SignedString(jwtSecret)
+	tokenString, err := token.SignedString(jwtSecret())
 	if err != nil {
 		return "", err
 	}
@@ -60,7 +71,7 @@ func validateJWT(tokenString string) (*Claims, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
+		return jwtSecret(), nil
 	})
 
 	// 检查 token 是否有效
@@ -76,8 +87,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := c.GetHeader("Authorization")
 
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
-			c.Abort()
+			service.AbortWithError(c, errors.WithCode(errors.CodeUnauthorized, "missing token"))
 			return
 		}
 
@@ -86,15 +96,29 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		claims, err := validateJWT(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			service.AbortWithError(c, errors.WithCode(errors.CodeUnauthorized, "invalid token"))
 			return
 		}
 
-		// 将用户 ID 和角色存储到上下文中，便于后续使用
-		c.Set("userID", claims.UserID)
-		c.Set("role", claims.UserName)
-
+		setIdentity(c, claims)
 		c.Next()
 	}
 }
+
+// identify 请求带有有效 token 时记录用户身份，token 缺失或无效时不拒绝请求，由 AuthMiddleware 决定是否需要登录
+func identify(c *gin.Context) {
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		return
+	}
+	if claims, err := validateJWT(tokenString); err == nil {
+		setIdentity(c, claims)
+	}
+}
+
+// setIdentity 将用户 ID 和用户名存储到上下文中，便于后续使用
+func setIdentity(c *gin.Context, claims *Claims) {
+	c.Set(ContextUserID, claims.UserID)
+	c.Set(ContextUserName, claims.UserName)
+	c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), log.Fields{log.FieldUserID: claims.UserID}))
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"distributed-object-storage/pkg/metrics"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// Metrics 记录每个请求的路由、状态码、耗时和收发字节数
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		metrics.RequestStarted()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start),
+			c.Request.ContentLength, int64(c.Writer.Size()))
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/service"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recovery 处理函数 panic 时记录日志和调用栈，以统一的响应格式返回 500
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// 客户端断开等情况由 net/http 处理
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			log.Ctx(c.Request.Context()).WithField("stack", string(debug.Stack())).Errorf("panic: %v", recovered)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			service.AbortWithError(c, fmt.Errorf("panic: %v", recovered))
+		}()
+		c.Next()
+	}
+}
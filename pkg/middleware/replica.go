@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/replicaauth"
+	"distributed-object-storage/service"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// ReplicaAuthMiddleware 校验其他集群复制请求的签名，未配置 replication.replica_secret 时拒绝所有复制请求
+func ReplicaAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := replicaauth.Verify(c.Request, config.GetReplication().ReplicaSecret, time.Now()); err != nil {
+			log.Ctx(c.Request.Context()).Warnf("reject replica request from %s: %v", c.ClientIP(), err)
+			service.AbortWithError(c, errors.WithCode(errors.CodeUnauthorized, "%v", err))
+			return
+		}
+		c.Next()
+	}
+}
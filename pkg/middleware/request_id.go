@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"distributed-object-storage/pkg/log"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"time"
+)
+
+// RequestIDHeader 请求 id 的请求头和响应头
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength 上游传入的请求 id 超过该长度时重新生成
+const maxRequestIDLength = 128
+
+// RequestID 沿用上游传入的 X-Request-Id，没有时生成一个，写入响应头和日志上下文
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(log.NewContext(c.Request.Context(), log.Fields{log.FieldRequestID: id}))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog 请求结束后输出一条访问日志，代替 gin 默认输出到标准输出的日志
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		entry := log.Ctx(c.Request.Context()).WithFields(log.Fields{
+			"method":     c.Request.Method,
+			"path":       path,
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"bytes_out":  c.Writer.Size(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			entry.Error("request")
+		case status >= 400:
+			entry.Warn("request")
+		default:
+			entry.Info("request")
+		}
+	}
+}
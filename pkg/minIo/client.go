@@ -3,11 +3,17 @@ package minIo
 import (
 	"bytes"
 	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/etcd"
 	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/metrics"
+	"distributed-object-storage/pkg/tracing"
 	"distributed-object-storage/types"
 	"fmt"
 	"github.com/minio/minio-go/v7"
 	client "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/time/rate"
 	"io"
 	"os"
 	"sort"
@@ -19,7 +25,9 @@ import (
 )
 
 var StorageClient *MinioHelper
-var etcdClient *client.Client
+
+// clients 按 endpoint 缓存的 minio 客户端
+var clients sync.Map
 
 const (
 	ChunkPartSize = 1024 * 1024 * 5
@@ -27,36 +35,54 @@ const (
 )
 
 func GetStorageNodeList() ([]types.KvStorage, error) {
+	return GetStorageNodeListContext(context.Background())
+}
+
+// GetStorageNodeListContext 读取 etcd 中注册的节点，ctx 用于超时控制和链路追踪
+func GetStorageNodeListContext(ctx context.Context) (list []types.KvStorage, err error) {
+	ctx, span := tracing.Start(ctx, "etcd.ListStorageNodes")
+	defer func() { tracing.End(span, err) }()
+
 	storageNodeList := make([]types.KvStorage, 0)
-	var err error
-	etcdClient, err = client.New(client.Config{
-		Endpoints: []string{"http://0.0.0.0:2379"},
-	})
+	etcdClient, err := etcd.Client()
 	if err != nil {
 		return storageNodeList, err
 	}
-	resp, err := etcdClient.Get(context.Background(), "minio/", client.WithPrefix())
+	resp, err := etcdClient.Get(ctx, StorageNodePrefix, client.WithPrefix())
 	if err != nil {
 		return storageNodeList, err
 	}
 	for _, node := range resp.Kvs {
-		suffix := strings.TrimPrefix(string(node.Key), "minio/")
+		suffix := strings.TrimPrefix(string(node.Key), StorageNodePrefix)
 		storageNodeList = append(storageNodeList, types.KvStorage{Key: suffix, Value: string(node.Value)})
 	}
 	return storageNodeList, nil
 }
 
 func GetMinioClient(endpoint string) *MinioHelper {
+	if helper, ok := clients.Load(endpoint); ok {
+		StorageClient = helper.(*MinioHelper)
+		return StorageClient
+	}
+	c := config.GetMinio()
+	transport, err := minio.DefaultTransport(c.Secure)
+	if err != nil {
+		panic(err.Error())
+	}
 	core, err := minio.NewCore(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4("root", "rootroot", ""),
-		Secure: false,
+		Creds:  credentials.NewStaticV4(c.AccessKey, c.SecretKey, ""),
+		Secure: c.Secure,
+		// 记录每个节点上各类请求的耗时
+		Transport: &metrics.Transport{Node: endpoint, Base: transport},
 	})
 	if err != nil {
 		panic(err.Error())
 	}
-	StorageClient = &MinioHelper{
+	helper, _ := clients.LoadOrStore(endpoint, &MinioHelper{
 		MinioCore: core,
-	}
+		Endpoint:  endpoint,
+	})
+	StorageClient = helper.(*MinioHelper)
 	return StorageClient
 }
 
@@ -93,20 +119,29 @@ type fileChunk struct {
 
 type MinioHelper struct {
 	MinioCore *minio.Core
+	Endpoint  string
 }
 
-func (helper *MinioHelper) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, UploadID string) (*minio.UploadInfo, error) {
+func (helper *MinioHelper) Upload(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, UploadID string) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "minio.Upload", append(tracing.Object(bucketName, objectName),
+		attribute.String("node", helper.Endpoint), attribute.Int64("size", size))...)
+	done := metrics.UploadStarted()
+	defer func() {
+		done(err)
+		tracing.End(span, err)
+	}()
+
 	// If the size is small enough, upload directly
 	if size <= ChunkPartSize {
-		return uploadFile(ctx, bucketName, objectName, reader, size)
+		return helper.uploadFile(ctx, bucketName, objectName, reader, size)
 	}
 
 	// For larger files, use multipart upload
-	return uploadFileWithCP(ctx, reader, bucketName, objectName, size, UploadID)
+	return helper.uploadFileWithCP(ctx, reader, bucketName, objectName, size, UploadID)
 }
 
 // uploadFileWithCP 通过checkPoint文件上传
-func uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectName string, size int64, uploadID string) (*minio.UploadInfo, error) {
+func (helper *MinioHelper) uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectName string, size int64, uploadID string) (*minio.UploadInfo, error) {
 	chunkCount := int(size / ChunkPartSize)
 	if size%ChunkPartSize != 0 {
 		chunkCount++
@@ -123,9 +158,9 @@ func uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectN
 	}
 
 	// Initialize multipart upload
-	minioUploadID, err := StorageClient.MinioCore.NewMultipartUpload(ctx, bucketName, objectName, minio.PutObjectOptions{})
+	minioUploadID, err := helper.MinioCore.NewMultipartUpload(ctx, bucketName, objectName, minio.PutObjectOptions{})
 	if err != nil {
-		log.Errorf("NewMultipartUpload error: %v", err)
+		log.Ctx(ctx).WithField(log.FieldNode, helper.Endpoint).Errorf("NewMultipartUpload error: %v", err)
 		return nil, err
 	}
 	cpFile.UploadId = minioUploadID
@@ -185,7 +220,7 @@ func uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectN
 					return
 				}
 
-				part, err := chunkUpload(ctx, buffer, bucketName, objectName, minioUploadID, chunk.PartNumber)
+				part, err := helper.chunkUpload(ctx, buffer, bucketName, objectName, minioUploadID, chunk.PartNumber)
 				if err != nil {
 					errs <- fmt.Errorf("upload chunk error: %v", err)
 					return
@@ -236,10 +271,10 @@ func uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectN
 				// All workers have finished
 				if resultCount == chunkCount {
 					// 所有分片都上传成功
-					return complete(ctx, bucketName, objectName, cpFile, "")
+					return helper.complete(ctx, bucketName, objectName, cpFile, "")
 				}
 				// Some parts failed
-				_ = StorageClient.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
+				_ = helper.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
 				return nil, fmt.Errorf("incomplete upload: got %d/%d parts", resultCount, chunkCount)
 			}
 			cpFile.CompletedParts[part.PartNumber-1] = part
@@ -248,36 +283,42 @@ func uploadFileWithCP(ctx context.Context, reader io.Reader, bucketName, objectN
 		case err := <-errs:
 			// 如果是取消操作，清理已上传的部分
 			if status.IsCanceled {
-				_ = StorageClient.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
+				_ = helper.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
 			}
 			return nil, err
 
 		case <-ctx.Done():
-			_ = StorageClient.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
+			_ = helper.MinioCore.AbortMultipartUpload(ctx, bucketName, objectName, minioUploadID)
 			return nil, ctx.Err()
 		}
 	}
 }
 
 // uploadFile 不分片直接上传
-func uploadFile(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64) (*minio.UploadInfo, error) {
-	uploadInfo, err := StorageClient.MinioCore.PutObject(ctx, bucketName, objectName, reader, size, "", "", minio.PutObjectOptions{})
+func (helper *MinioHelper) uploadFile(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64) (*minio.UploadInfo, error) {
+	uploadInfo, err := helper.MinioCore.PutObject(ctx, bucketName, objectName, reader, size, "", "", minio.PutObjectOptions{})
 	if err != nil {
-		log.Errorf("put object error: %v", err)
+		log.Ctx(ctx).WithField(log.FieldNode, helper.Endpoint).Errorf("put object error: %v", err)
 		return nil, err
 	}
+	metrics.ObserveUploadPart(uploadInfo.Size, false)
 	return &uploadInfo, nil
 }
 
 // chunkUpload 上传分片
-func chunkUpload(ctx context.Context, buf []byte, bucketName string, fileName, uploadId string, partNumber int) (minio.CompletePart, error) {
+func (helper *MinioHelper) chunkUpload(ctx context.Context, buf []byte, bucketName string, fileName, uploadId string, partNumber int) (part minio.CompletePart, err error) {
+	ctx, span := tracing.Start(ctx, "minio.UploadPart", attribute.String("node", helper.Endpoint),
+		attribute.Int("part_number", partNumber), attribute.Int("size", len(buf)))
+	defer func() { tracing.End(span, err) }()
+
 	buffer := bytes.NewBuffer(buf)
-	objectPart, err := StorageClient.MinioCore.PutObjectPart(ctx, bucketName, fileName, uploadId, partNumber, buffer, int64(buffer.Len()), minio.PutObjectPartOptions{})
+	objectPart, err := helper.MinioCore.PutObjectPart(ctx, bucketName, fileName, uploadId, partNumber, buffer, int64(buffer.Len()), minio.PutObjectPartOptions{})
 	if err != nil {
-		log.Errorf("Upload part error: %s", err)
+		log.Ctx(ctx).WithField(log.FieldNode, helper.Endpoint).Errorf("Upload part error: %s", err)
 		return minio.CompletePart{}, err
 	}
-	log.Info("Upload chunk success, objectPart PartNumber:", objectPart.PartNumber)
+	log.Ctx(ctx).WithField(log.FieldNode, helper.Endpoint).Debugf("Upload chunk success, objectPart PartNumber: %d", objectPart.PartNumber)
+	metrics.ObserveUploadPart(objectPart.Size, true)
 	return minio.CompletePart{
 		ETag:       objectPart.ETag,
 		PartNumber: objectPart.PartNumber,
@@ -285,16 +326,140 @@ func chunkUpload(ctx context.Context, buf []byte, bucketName string, fileName, u
 }
 
 // complete 合并分片
-func complete(ctx context.Context, bucketName, objectName string, cpFile *MultipartFile, cpFilePath string) (*minio.UploadInfo, error) {
+func (helper *MinioHelper) complete(ctx context.Context, bucketName, objectName string, cpFile *MultipartFile, cpFilePath string) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "minio.CompleteMultipartUpload", attribute.String("node", helper.Endpoint),
+		attribute.Int("parts", len(cpFile.CompletedParts)))
+	defer func() { tracing.End(span, err) }()
+
 	sort.Slice(cpFile.CompletedParts, func(i, j int) bool {
 		return cpFile.CompletedParts[i].PartNumber < cpFile.CompletedParts[j].PartNumber
 	})
 
-	uploadInfo, err := StorageClient.MinioCore.CompleteMultipartUpload(ctx, bucketName, objectName, cpFile.UploadId, cpFile.CompletedParts, minio.PutObjectOptions{})
+	uploadInfo, err := helper.MinioCore.CompleteMultipartUpload(ctx, bucketName, objectName, cpFile.UploadId, cpFile.CompletedParts, minio.PutObjectOptions{})
 	if err != nil {
-		log.Errorf("CompleteMultipartUpload err: %s", err)
+		log.Ctx(ctx).WithField(log.FieldNode, helper.Endpoint).Errorf("CompleteMultipartUpload err: %s", err)
 		return nil, err
 	}
 
 	return &uploadInfo, nil
 }
+
+// EnsureBucket 桶不存在时创建，新加入的节点上可能还没有对应的桶
+func (helper *MinioHelper) EnsureBucket(ctx context.Context, bucketName string) error {
+	exists, err := helper.MinioCore.BucketExists(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return helper.MinioCore.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+}
+
+// CopyObjectFrom 将 src 节点上的对象以流的方式复制到当前节点
+func (helper *MinioHelper) CopyObjectFrom(ctx context.Context, src *MinioHelper, bucketName, objectName string) (*minio.UploadInfo, error) {
+	return helper.CopyObjectFromWithLimit(ctx, src, bucketName, objectName, nil)
+}
+
+// CopyObjectFromWithLimit 同 CopyObjectFrom，limiter 不为空时按每秒字节数限制复制带宽
+func (helper *MinioHelper) CopyObjectFromWithLimit(ctx context.Context, src *MinioHelper, bucketName, objectName string, limiter *rate.Limiter) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "minio.CopyObject", append(tracing.Object(bucketName, objectName),
+		attribute.String("source", src.Endpoint), attribute.String("node", helper.Endpoint))...)
+	defer func() { tracing.End(span, err) }()
+
+	object, objectInfo, _, err := src.MinioCore.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get object from %s: %w", src.Endpoint, err)
+	}
+	defer object.Close()
+
+	reader := NewLimitedReader(ctx, object, limiter)
+
+	if err = helper.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, fmt.Errorf("ensure bucket on %s: %w", helper.Endpoint, err)
+	}
+	// 分片大小与 Upload 保持一致，副本的 ETag 才能与原对象相同
+	uploadInfo, err := helper.MinioCore.Client.PutObject(ctx, bucketName, objectName, reader, objectInfo.Size, minio.PutObjectOptions{
+		ContentType:  objectInfo.ContentType,
+		UserMetadata: objectInfo.UserMetadata,
+		PartSize:     ChunkPartSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("put object to %s: %w", helper.Endpoint, err)
+	}
+	return &uploadInfo, nil
+}
+
+// CopyObjectWithin 在节点内部复制对象，数据不经过网关，contentType 不为空时替换目标对象的 Content-Type
+func (helper *MinioHelper) CopyObjectWithin(ctx context.Context, srcBucket, srcObject, destBucket, destObject, contentType string) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "minio.CopyObjectWithin", append(tracing.Object(destBucket, destObject),
+		attribute.String("source", srcBucket+"/"+srcObject), attribute.String("node", helper.Endpoint))...)
+	defer func() { tracing.End(span, err) }()
+
+	dst := minio.CopyDestOptions{Bucket: destBucket, Object: destObject}
+	if contentType != "" {
+		dst.ReplaceMetadata = true
+		dst.UserMetadata = map[string]string{"Content-Type": contentType}
+	}
+	// 单个请求的服务端复制，单次上传的对象复制后 ETag 不变，分片合并得到的 ETag 与原对象不同
+	uploadInfo, err := helper.MinioCore.Client.CopyObject(ctx, dst, minio.CopySrcOptions{Bucket: srcBucket, Object: srcObject})
+	if err != nil {
+		return nil, err
+	}
+	return &uploadInfo, nil
+}
+
+// PutStream 以流的方式写入 size 字节的对象，分片大小与 Upload 保持一致，从其他存储写回的对象 ETag 不变
+func (helper *MinioHelper) PutStream(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "minio.PutStream", append(tracing.Object(bucketName, objectName),
+		attribute.String("node", helper.Endpoint), attribute.Int64("size", size))...)
+	defer func() { tracing.End(span, err) }()
+
+	uploadInfo, err := helper.MinioCore.Client.PutObject(ctx, bucketName, objectName, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    ChunkPartSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &uploadInfo, nil
+}
+
+// NewLimitedReader 返回按 limiter 速率读取的 reader，limiter 为空时原样返回
+func NewLimitedReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+// limitedReader 按 limiter 的速率读取数据
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if burst := l.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if waitErr := l.limiter.WaitN(l.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// SetVersioning 开启（Enabled）或暂停（Suspended）桶的版本控制，Off 不做处理
+func (helper *MinioHelper) SetVersioning(ctx context.Context, bucketName, versioning string) error {
+	switch versioning {
+	case types.BucketVersioningEnabled:
+		return helper.MinioCore.Client.EnableVersioning(ctx, bucketName)
+	case types.BucketVersioningSuspended:
+		return helper.MinioCore.Client.SuspendVersioning(ctx, bucketName)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package minIo
+
+import (
+	"distributed-object-storage/errors"
+	"github.com/minio/minio-go/v7"
+)
+
+// errorCodes 存储节点（MinIO/S3）返回的错误码对应的错误码
+var errorCodes = map[string]int{
+	"NoSuchBucket":            errors.CodeNoSuchBucket,
+	"BucketAlreadyExists":     errors.CodeBucketAlreadyExists,
+	"BucketAlreadyOwnedByYou": errors.CodeBucketAlreadyExists,
+	"BucketNotEmpty":          errors.CodeBucketNotEmpty,
+	"InvalidBucketName":       errors.CodeInvalidBucketName,
+	"NoSuchKey":               errors.CodeNoSuchKey,
+	"XMinioInvalidObjectName": errors.CodeInvalidObjectName,
+	"EntityTooLarge":          errors.CodeEntityTooLarge,
+	"InvalidPart":             errors.CodeInvalidPart,
+	"InvalidPartOrder":        errors.CodeInvalidPart,
+	"NoSuchUpload":            errors.CodeNoSuchUpload,
+	"AccessDenied":            errors.CodeAccessDenied,
+	"PreconditionFailed":      errors.CodePreconditionFailed,
+	"XMinioStorageFull":       errors.CodeServiceUnavailable,
+	"SlowDown":                errors.CodeServiceUnavailable,
+}
+
+// ToError 为存储节点返回的错误加上对应的错误码，无法识别的错误原样返回
+func ToError(err error) error {
+	if err == nil {
+		return nil
+	}
+	// 分片上传等返回的错误可能经过包装
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return err
+	}
+	code, ok := errorCodes[resp.Code]
+	if !ok {
+		return err
+	}
+	return errors.WrapC(err, code, "%v", err)
+}
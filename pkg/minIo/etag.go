@@ -0,0 +1,50 @@
+package minIo
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// IsMultipartETag 分片上传的对象 ETag 形如 <md5>-<分片数>
+func IsMultipartETag(etag string) bool {
+	return strings.Contains(strings.Trim(etag, "\""), "-")
+}
+
+// ComputeETag 读取全部数据计算 ETag。
+// multipart 为 true 时按 ChunkPartSize 分片计算，与 Upload 分片上传得到的 ETag 一致。
+func ComputeETag(reader io.Reader, multipart bool) (string, int64, error) {
+	if !multipart {
+		h := md5.New()
+		n, err := io.Copy(h, reader)
+		if err != nil {
+			return "", n, err
+		}
+		return hex.EncodeToString(h.Sum(nil)), n, nil
+	}
+
+	var (
+		total int64
+		parts int
+		sums  []byte
+	)
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, reader, ChunkPartSize)
+		if n > 0 {
+			total += n
+			parts++
+			sums = append(sums, h.Sum(nil)...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", total, err
+		}
+	}
+	sum := md5.Sum(sums)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), parts), total, nil
+}
@@ -0,0 +1,99 @@
+package minIo
+
+import (
+	"context"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// StorageNodePrefix 存储节点在 etcd 中注册的 key 前缀，完整的 key 为 minio/<id>
+const StorageNodePrefix = "minio/"
+
+// StorageNodeKey 返回节点在 etcd 中的 key
+func StorageNodeKey(id string) string {
+	return StorageNodePrefix + id
+}
+
+// DefaultStorageNode etcd 中没有注册任何节点时使用的本地节点
+var DefaultStorageNode = types.StorageNodeInfo{
+	ID:       "default",
+	Endpoint: "http://127.0.0.1:9000",
+	Weight:   1,
+}
+
+// staticNodes 不为空时代替 etcd 中注册的节点
+var staticNodes atomic.Pointer[[]types.StorageNodeInfo]
+
+// SetStorageNodes 使用固定的节点列表代替 etcd 中注册的节点，供测试使用，返回恢复原来节点列表的函数
+func SetStorageNodes(nodes []types.StorageNodeInfo) (restore func()) {
+	prev := staticNodes.Swap(&nodes)
+	return func() { staticNodes.Store(prev) }
+}
+
+// ParseStorageNode 解析 etcd 中 minio/<id> 的值。
+// 值可以是节点注册时写入的 JSON，也可以是旧版本直接写入的地址。
+func ParseStorageNode(key, value string) types.StorageNodeInfo {
+	node := types.StorageNodeInfo{}
+	if strings.HasPrefix(strings.TrimSpace(value), "{") && json.Unmarshal([]byte(value), &node) == nil {
+		if node.ID == "" {
+			node.ID = key
+		}
+	} else {
+		node.ID = key
+		node.Endpoint = value
+	}
+	if node.Weight <= 0 {
+		node.Weight = 1
+	}
+	return node
+}
+
+// GetStorageNodes 返回所有已注册的存储节点，没有注册节点时返回 DefaultStorageNode
+func GetStorageNodes() ([]types.StorageNodeInfo, error) {
+	return GetStorageNodesContext(context.Background())
+}
+
+// GetStorageNodesContext 与 GetStorageNodes 相同，ctx 用于超时控制和链路追踪
+func GetStorageNodesContext(ctx context.Context) ([]types.StorageNodeInfo, error) {
+	if nodes := staticNodes.Load(); nodes != nil {
+		return append([]types.StorageNodeInfo(nil), *nodes...), nil
+	}
+	kvs, err := GetStorageNodeListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]types.StorageNodeInfo, 0, len(kvs))
+	for _, kv := range kvs {
+		nodes = append(nodes, ParseStorageNode(kv.Key, kv.Value))
+	}
+	if len(nodes) == 0 {
+		nodes = append(nodes, DefaultStorageNode)
+	}
+	return nodes, nil
+}
+
+// GetNodeClient 返回指定存储节点的客户端
+func GetNodeClient(node types.StorageNodeInfo) *MinioHelper {
+	return GetMinioClient(node.Host())
+}
+
+// CheckNodeHealth 调用 minio 的存活检查接口，超时由 ctx 控制
+func CheckNodeHealth(ctx context.Context, node types.StorageNodeInfo) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, node.Endpoint+"/minio/health/live", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check of %s returned %d", node.ID, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package placement
+
+import (
+	"distributed-object-storage/config"
+	"distributed-object-storage/types"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+var ErrNoWritableNode = errors.New("no writable storage node")
+
+// capacityTier 按容量计算权重时的粒度，剩余空间按该粒度取整后参与计算，
+// 上报的容量有细微差别或写入少量数据时权重不变
+const capacityTier = 64 << 30
+
+// Policy 根据节点磁盘剩余空间和权重，用加权 rendezvous hash 为对象选择存储节点，剩余空间多的节点接收更多对象。
+// 同一个对象在节点列表和权重不变时总会得到相同的结果，读写两端无需额外记录即可定位。
+// 剩余空间按 capacityTier 分档，跨过分档时权重才变化，由 rebalancer 迁移放置结果变化的对象。
+type Policy struct {
+	Replicas      int
+	HighWaterMark float64
+	NodeWeights   map[string]float64
+	// Zone 主副本优先放在该可用区，为空时不限制
+	Zone string
+}
+
+func NewPolicy(c config.PlacementConfig) *Policy {
+	return &Policy{
+		Replicas:      c.Replicas,
+		HighWaterMark: c.HighWaterMark,
+		NodeWeights:   c.NodeWeights,
+	}
+}
+
+// Default 使用当前配置创建放置策略
+func Default() *Policy {
+	return NewPolicy(config.GetPlacement())
+}
+
+// ForBucket 按桶的配置调整放置策略：桶设置了副本数时覆盖默认值，主副本优先放在桶的主可用区
+func (p *Policy) ForBucket(bucket types.BucketConfig) *Policy {
+	res := *p
+	if bucket.Replicas > 0 {
+		res.Replicas = bucket.Replicas
+	}
+	res.Zone = bucket.Zone
+	return &res
+}
+
+// Key 对象在放置计算中使用的键
+func Key(bucketName, objectName string) string {
+	return bucketName + "/" + objectName
+}
+
+// Place 为写入选择 Replicas 个节点，第一个为主副本，设置了 Zone 时主副本优先选该可用区的节点。
+// 磁盘使用率超过 HighWaterMark 或权重为 0 的节点不参与选择；副本优先分散到不同 zone，其次不同 rack。
+// 可写节点不足 Replicas 个时返回全部可写节点。
+func (p *Policy) Place(key string, nodes []types.StorageNodeInfo) ([]types.StorageNodeInfo, error) {
+	writable := make([]types.StorageNodeInfo, 0, len(nodes))
+	for _, node := range nodes {
+		// 已满或权重被设置为 0（下线中）的节点不再接收新对象
+		if p.IsFull(node) || p.weight(node) == 0 {
+			continue
+		}
+		writable = append(writable, node)
+	}
+	if len(writable) == 0 {
+		return nil, ErrNoWritableNode
+	}
+	return spread(p.preferZone(p.Rank(key, writable)), p.Replicas), nil
+}
+
+// preferZone 将 Zone 中得分最高的节点移到最前面作为主副本，其余顺序不变
+func (p *Policy) preferZone(ranked []types.StorageNodeInfo) []types.StorageNodeInfo {
+	if p.Zone == "" {
+		return ranked
+	}
+	for i, node := range ranked {
+		if node.Zone != p.Zone {
+			continue
+		}
+		res := make([]types.StorageNodeInfo, 0, len(ranked))
+		res = append(res, node)
+		res = append(res, ranked[:i]...)
+		return append(res, ranked[i+1:]...)
+	}
+	return ranked
+}
+
+// Rank 按该对象的得分从高到低返回全部节点，读取时依次尝试
+func (p *Policy) Rank(key string, nodes []types.StorageNodeInfo) []types.StorageNodeInfo {
+	weights := p.Weights(nodes)
+	type scored struct {
+		node  types.StorageNodeInfo
+		score float64
+	}
+	list := make([]scored, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, scored{node: node, score: score(key, node.ID, weights[node.ID])})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].score == list[j].score {
+			return list[i].node.ID < list[j].node.ID
+		}
+		return list[i].score > list[j].score
+	})
+	res := make([]types.StorageNodeInfo, 0, len(list))
+	for _, s := range list {
+		res = append(res, s.node)
+	}
+	return res
+}
+
+// Weights 返回各节点在放置计算中的实际权重：运维设置的权重乘以按 capacityTier 取整的剩余空间
+func (p *Policy) Weights(nodes []types.StorageNodeInfo) map[string]float64 {
+	fallback := defaultCapacity(nodes)
+	weights := make(map[string]float64, len(nodes))
+	for _, node := range nodes {
+		weights[node.ID] = p.weight(node) * nodeCapacity(node, fallback)
+	}
+	return weights
+}
+
+// IsFull 节点磁盘使用率是否已达到高水位
+func (p *Policy) IsFull(node types.StorageNodeInfo) bool {
+	if node.DiskUsage.TotalSpace <= 0 {
+		return false
+	}
+	return usagePercentage(node.DiskUsage) >= p.HighWaterMark
+}
+
+func (p *Policy) weight(node types.StorageNodeInfo) float64 {
+	if w, ok := p.NodeWeights[node.ID]; ok {
+		return math.Max(w, 0)
+	}
+	if node.Weight <= 0 {
+		return 1
+	}
+	return node.Weight
+}
+
+func usagePercentage(usage types.DiskUsage) float64 {
+	if usage.UsagePercentage > 0 {
+		return usage.UsagePercentage
+	}
+	return float64(usage.TotalSpace-usage.AvailableSpace) * 100 / float64(usage.TotalSpace)
+}
+
+// defaultCapacity 未上报磁盘信息的节点按已上报节点的平均剩余空间计算
+func defaultCapacity(nodes []types.StorageNodeInfo) float64 {
+	var total float64
+	var count int
+	for _, node := range nodes {
+		if node.DiskUsage.TotalSpace > 0 {
+			total += nodeCapacity(node, 1)
+			count++
+		}
+	}
+	if count == 0 {
+		return 1
+	}
+	return math.Round(total / float64(count))
+}
+
+// nodeCapacity 节点磁盘剩余空间折算成的 capacityTier 个数，至少为 1
+func nodeCapacity(node types.StorageNodeInfo, fallback float64) float64 {
+	if node.DiskUsage.TotalSpace <= 0 {
+		return fallback
+	}
+	return math.Max(math.Round(float64(node.DiskUsage.AvailableSpace)/capacityTier), 1)
+}
+
+// score 加权 rendezvous hash：-weight / ln(u)，u 为 (0,1) 上的均匀哈希值
+func score(key, nodeID string, weight float64) float64 {
+	if weight <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(nodeID))
+	u := (float64(h.Sum64()>>11) + 0.5) / float64(1<<53)
+	return -weight / math.Log(u)
+}
+
+// spread 从排好序的节点中选出 n 个，优先不同 zone，其次不同 rack，最后按顺序补齐
+func spread(ranked []types.StorageNodeInfo, n int) []types.StorageNodeInfo {
+	if n <= 0 {
+		n = 1
+	}
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	picked := make([]bool, len(ranked))
+	res := make([]types.StorageNodeInfo, 0, n)
+	zones := make(map[string]bool)
+	racks := make(map[string]bool)
+	pick := func(accept func(node types.StorageNodeInfo) bool) {
+		for i, node := range ranked {
+			if len(res) == n {
+				return
+			}
+			if picked[i] || !accept(node) {
+				continue
+			}
+			picked[i] = true
+			zones[node.Zone] = true
+			racks[node.Zone+"/"+node.Rack] = true
+			res = append(res, node)
+		}
+	}
+	pick(func(node types.StorageNodeInfo) bool { return !zones[node.Zone] })
+	pick(func(node types.StorageNodeInfo) bool { return !racks[node.Zone+"/"+node.Rack] })
+	pick(func(node types.StorageNodeInfo) bool { return true })
+	return res
+}
@@ -0,0 +1,86 @@
+package placement
+
+import (
+	"distributed-object-storage/types"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func testNodes(available ...int64) []types.StorageNodeInfo {
+	nodes := make([]types.StorageNodeInfo, 0, len(available))
+	for i, free := range available {
+		nodes = append(nodes, types.StorageNodeInfo{
+			ID:        fmt.Sprintf("node-%d", i+1),
+			Weight:    1,
+			DiskUsage: types.DiskUsage{TotalSpace: 1 << 40, AvailableSpace: free},
+		})
+	}
+	return nodes
+}
+
+func TestPlaceStableWithinCapacityTier(t *testing.T) {
+	policy := &Policy{Replicas: 2, HighWaterMark: 90}
+	before := testNodes(900<<30, 800<<30, 700<<30, 600<<30)
+	// 写入数据后各节点的剩余空间都有少量变化，没有跨过分档
+	after := testNodes(890<<30, 790<<30, 695<<30, 599<<30)
+	for i := 0; i < 200; i++ {
+		key := Key("photos", fmt.Sprintf("object-%d", i))
+		a, err := policy.Place(key, before)
+		if err != nil {
+			t.Fatalf("place: %v", err)
+		}
+		b, err := policy.Place(key, after)
+		if err != nil {
+			t.Fatalf("place: %v", err)
+		}
+		for j := range a {
+			if a[j].ID != b[j].ID {
+				t.Fatalf("placement of %s changed from %v to %v", key, a, b)
+			}
+		}
+	}
+}
+
+func TestPlacePrefersFreeSpace(t *testing.T) {
+	policy := &Policy{Replicas: 1, HighWaterMark: 90}
+	nodes := testNodes(800<<30, 200<<30)
+	primaries := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		placed, err := policy.Place(Key("photos", fmt.Sprintf("object-%d", i)), nodes)
+		if err != nil {
+			t.Fatalf("place: %v", err)
+		}
+		primaries[placed[0].ID]++
+	}
+	// 剩余空间为 4:1，主副本的分布应接近 4:1
+	if primaries["node-1"] < 3*primaries["node-2"] {
+		t.Fatalf("primaries = %v, want most on node-1", primaries)
+	}
+}
+
+func TestWeightsUseCapacityTiers(t *testing.T) {
+	policy := &Policy{NodeWeights: map[string]float64{"c": 2}}
+	nodes := []types.StorageNodeInfo{
+		{ID: "a", DiskUsage: types.DiskUsage{TotalSpace: 4 << 40, AvailableSpace: 1 << 40}},
+		// 剩余空间相差不到一个粒度的节点权重相同
+		{ID: "b", DiskUsage: types.DiskUsage{TotalSpace: 2 << 40, AvailableSpace: 1<<40 + 10<<30}},
+		{ID: "c", DiskUsage: types.DiskUsage{TotalSpace: 4 << 40, AvailableSpace: 1 << 40}},
+		{ID: "d", DiskUsage: types.DiskUsage{TotalSpace: 4 << 40, AvailableSpace: 2 << 40}},
+		// 未上报磁盘信息的节点按平均剩余空间计算
+		{ID: "e"},
+		// 剩余空间不到一个粒度的节点至少为 1
+		{ID: "f", DiskUsage: types.DiskUsage{TotalSpace: 4 << 40, AvailableSpace: 1 << 30}},
+	}
+	weights := policy.Weights(nodes)
+	tier := float64(1<<40) / capacityTier
+	want := map[string]float64{"a": tier, "b": tier, "c": 2 * tier, "d": 2 * tier, "f": 1}
+	for id, w := range want {
+		if weights[id] != w {
+			t.Errorf("weight of %s = %g, want %g", id, weights[id], w)
+		}
+	}
+	if avg := math.Round((5*tier + 1) / 5); weights["e"] != avg {
+		t.Errorf("weight of e = %g, want %g", weights["e"], avg)
+	}
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName 本项目创建的 span 统一使用的 tracer 名称
+const instrumentationName = "distributed-object-storage"
+
+func init() {
+	// 未开启导出时也按 W3C trace context 透传上游的链路
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init 按配置创建 OTLP/HTTP 导出器并设置为全局 TracerProvider，返回退出时调用的函数，用于导出剩余的 span。
+// 未开启时使用 otel 默认的空实现，不产生开销。
+func Init(ctx context.Context, c config.TracingConfig) (func(ctx context.Context) error, error) {
+	if !c.Enabled {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	tp := NewProvider(exporter, c.ServiceName, c.SampleRatio)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider 使用指定导出器创建 TracerProvider，测试时可以传入 tracetest.NewInMemoryExporter()
+func NewProvider(exporter sdktrace.SpanExporter, serviceName string, ratio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
+
+// Tracer 返回本项目使用的 tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 从 ctx 中的 span 创建子 span，没有上级 span 时创建新的链路
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束 span，err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Object 对象相关 span 的通用属性
+func Object(bucketName, objectName string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("bucket", bucketName),
+		attribute.String("object", objectName),
+	}
+}
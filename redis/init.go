@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"distributed-object-storage/config"
 	"distributed-object-storage/pkg/log"
 	"github.com/go-redis/redis/v8"
 )
@@ -16,11 +17,14 @@ type Config struct {
 }
 
 func Init() error {
+	c := config.GetRedis()
 	rdb = redis.NewClient(&redis.Options{
-		Addr:       "0.0.0.0:6379",
+		Addr:       c.Addr,
+		Password:   c.Password,
 		MaxRetries: -1, // Not Retry
-		DB:         0,  // use default DB
+		DB:         c.DB,
 	})
+	rdb.AddHook(tracingHook{})
 
 	pong, err := rdb.Ping(context.Background()).Result()
 	if err != nil {
@@ -36,3 +40,16 @@ func Redis() *redis.Client {
 	}
 	return rdb
 }
+
+// Close 关闭 redis 连接，未初始化时什么也不做
+func Close() error {
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
+
+// Initialized 是否已经调用过 Init
+func Initialized() bool {
+	return rdb != nil
+}
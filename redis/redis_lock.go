@@ -2,20 +2,43 @@ package redis
 
 import (
 	"context"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/metrics"
+	"errors"
+	"fmt"
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v8"
-	"github.com/prometheus/common/log"
+	"math/rand"
+	"sync"
 	"time"
 )
 
 var (
 	DefaultExpire = 12 * time.Second
+
+	// ErrLockNotObtained 在超时前没有获取到锁
+	ErrLockNotObtained = errors.New("lock not obtained")
+	// ErrLockNotHeld 锁已释放、续期失败或已被其他持有者获取
+	ErrLockNotHeld = errors.New("lock not held")
+)
+
+const (
+	minBackoff = 50 * time.Millisecond
+	maxBackoff = 2 * time.Second
 )
 
+// Lock 基于 redsync 的分布式锁。
+// 持有期间后台自动续期，续期失败时 Context() 被取消；每次获取锁都会分配一个单调递增的 fencing token，
+// 持有者提交结果前可以通过 Check 确认没有其他持有者在此之后获取过锁。
 type Lock struct {
 	key    string
 	m      *redsync.Mutex
 	expire time.Duration
+
+	mutex  sync.Mutex
+	token  int64
+	until  time.Time // 锁的过期时间，续期 goroutine 修改 redsync.Mutex 时不能并发读取，在这里保存一份
+	held   context.Context
 	cancel context.CancelFunc
 }
 
@@ -24,14 +47,16 @@ func NewRedisLock(key string) *Lock {
 }
 
 func NewRedisLockWithExpire(key string, expire time.Duration) *Lock {
+	if expire <= 0 {
+		expire = DefaultExpire
+	}
 	pool := goredis.NewPool(Redis())
 	rs := redsync.New(pool)
 	m := rs.NewMutex(key, redsync.WithTries(1), redsync.WithExpiry(expire))
 	return &Lock{
 		key:    key,
 		m:      m,
-		expire: DefaultExpire,
-		cancel: nil,
+		expire: expire,
 	}
 }
 
@@ -43,28 +68,110 @@ func (l *Lock) Key() string {
 	return l.key
 }
 
-func (l *Lock) Lock(ctx context.Context) error {
+func (l *Lock) tokenKey() string {
+	return fmt.Sprintf("%s:token", l.key)
+}
+
+// Token 当前持有锁的 fencing token，未持有时返回 0
+func (l *Lock) Token() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.token
+}
+
+// Context 持有锁期间有效，释放锁或续期失败后被取消。未持有锁时返回已取消的 context。
+func (l *Lock) Context() context.Context {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.held == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	return l.held
+}
+
+// Lost 锁丢失或释放时关闭的 channel
+func (l *Lock) Lost() <-chan struct{} {
+	return l.Context().Done()
+}
+
+// TryLock 在 timeout 内尝试获取锁，timeout <= 0 时只尝试一次。获取失败返回 ErrLockNotObtained。
+func (l *Lock) TryLock(ctx context.Context, timeout time.Duration) error {
+	if timeout <= 0 {
+		return l.acquire(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := l.Lock(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrLockNotObtained
+	}
+	return err
+}
+
+// Lock 阻塞直到获取锁或 ctx 取消，两次尝试之间按指数退避等待
+func (l *Lock) Lock(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveLockWait(l.key, time.Since(start), err) }()
+	backoff := minBackoff
+	for {
+		err := l.acquire(ctx)
+		if err != ErrLockNotObtained {
+			return err
+		}
+		// 加入随机抖动，避免多个等待者同时重试
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (l *Lock) acquire(ctx context.Context) error {
 	err := l.m.LockContext(ctx)
 	if err != nil {
+		var taken *redsync.ErrTaken
+		if errors.As(err, &taken) || errors.Is(err, redsync.ErrFailed) {
+			metrics.ObserveLockAttempt(l.key, metrics.ResultContended)
+			return ErrLockNotObtained
+		}
+		metrics.ObserveLockAttempt(l.key, metrics.ResultError)
 		return err
 	}
+	metrics.ObserveLockAttempt(l.key, metrics.ResultOK)
 
-	log.Debugf("get lock %s success", l.key)
-
-	if l.cancel == nil {
-		c, cancel := context.WithCancel(context.TODO())
-		l.cancel = cancel
-		l.RenewLock(c)
+	token, err := Redis().Incr(ctx, l.tokenKey()).Result()
+	if err != nil {
+		_, _ = l.m.UnlockContext(context.Background())
+		return fmt.Errorf("get fencing token of %s: %w", l.key, err)
 	}
+	log.Debugf("get lock %s success, token %d", l.key, token)
 
+	held, cancel := context.WithCancel(context.Background())
+	l.mutex.Lock()
+	if l.cancel != nil {
+		l.cancel()
+	}
+	l.token, l.until, l.held, l.cancel = token, l.m.Until(), held, cancel
+	l.mutex.Unlock()
+	go l.renew(held, cancel)
 	return nil
 }
 
 func (l *Lock) UnLock(ctx context.Context) error {
+	l.mutex.Lock()
 	if l.cancel != nil {
 		l.cancel()
-		l.cancel = nil
 	}
+	l.token, l.until, l.held, l.cancel = 0, time.Time{}, nil, nil
+	l.mutex.Unlock()
+
 	_, err := l.m.UnlockContext(ctx)
 	if err != nil {
 		return err
@@ -73,21 +180,52 @@ func (l *Lock) UnLock(ctx context.Context) error {
 	return nil
 }
 
-func (l *Lock) RenewLock(ctx context.Context) {
-	go func() {
-		for {
-			time.Sleep(l.expire * 2 / 3)
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				_, err := l.m.ExtendContext(ctx)
-				if err != nil {
-					log.Warnf("extend err: %v", err)
-				} else {
-					log.Debugf("extend lock: %s success", l.key)
-				}
+// renew 每隔 1/3 过期时间续期一次。锁已被其他持有者获取，或连续失败直到锁过期时取消持有 context。
+func (l *Lock) renew(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(l.expire / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := l.m.ExtendContext(ctx)
+		if ok {
+			l.mutex.Lock()
+			if l.held == ctx {
+				l.until = l.m.Until()
 			}
+			l.mutex.Unlock()
+			log.Debugf("extend lock: %s success", l.key)
+			continue
 		}
-	}()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil || !time.Now().Before(l.m.Until()) {
+			log.Warnf("lock %s lost: %v", l.key, err)
+			cancel()
+			return
+		}
+		log.Warnf("extend lock %s err: %v", l.key, err)
+	}
+}
+
+// Check 确认仍持有锁，且之后没有其他持有者获取过锁（token 未变化），否则返回 ErrLockNotHeld
+func (l *Lock) Check(ctx context.Context) error {
+	l.mutex.Lock()
+	token, until, held := l.token, l.until, l.held
+	l.mutex.Unlock()
+	if held == nil || held.Err() != nil || !time.Now().Before(until) {
+		return ErrLockNotHeld
+	}
+	current, err := Redis().Get(ctx, l.tokenKey()).Int64()
+	if err != nil {
+		return fmt.Errorf("get fencing token of %s: %w", l.key, err)
+	}
+	if current != token {
+		return ErrLockNotHeld
+	}
+	return nil
 }
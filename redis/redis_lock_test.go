@@ -0,0 +1,229 @@
+package redis
+
+import (
+	"context"
+	"distributed-object-storage/pkg/metrics"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupRedis 将包内的客户端指向一个 miniredis，测试结束后恢复
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	prev := rdb
+	rdb = redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() {
+		_ = rdb.Close()
+		rdb = prev
+	})
+	return mr
+}
+
+func TestLockFencingTokenIncrements(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+
+	a := NewRedisLockWithExpire("test:fencing", time.Second)
+	if err := a.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock a: %v", err)
+	}
+	first := a.Token()
+	if first <= 0 {
+		t.Fatalf("token = %d, want > 0", first)
+	}
+	if err := a.Check(ctx); err != nil {
+		t.Fatalf("check a: %v", err)
+	}
+	if err := a.UnLock(ctx); err != nil {
+		t.Fatalf("unlock a: %v", err)
+	}
+	if a.Token() != 0 {
+		t.Fatalf("token after unlock = %d, want 0", a.Token())
+	}
+
+	b := NewRedisLockWithExpire("test:fencing", time.Second)
+	if err := b.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock b: %v", err)
+	}
+	defer b.UnLock(ctx)
+	if b.Token() <= first {
+		t.Fatalf("token of b = %d, want > %d", b.Token(), first)
+	}
+	// a 已释放锁，之后 b 获取了新的 token，a 的提交必须被拒绝
+	if err := a.Check(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("check a after b locked = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockCheckRejectsStaleToken(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+
+	l := NewRedisLockWithExpire("test:stale", time.Second)
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer l.UnLock(ctx)
+	// 锁过期后被其他持有者获取时 token 会增加
+	if _, err := mr.Incr(l.tokenKey(), 1); err != nil {
+		t.Fatalf("incr token: %v", err)
+	}
+	if err := l.Check(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("check = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockRenewal(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+
+	l := NewRedisLockWithExpire("test:renew", 300*time.Millisecond)
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer l.UnLock(ctx)
+	// 持有时间超过过期时间时续期使锁一直有效
+	time.Sleep(time.Second)
+	select {
+	case <-l.Lost():
+		t.Fatal("lock lost while renewing")
+	default:
+	}
+	if err := l.Check(ctx); err != nil {
+		t.Fatalf("check after renewals: %v", err)
+	}
+	if ttl := mr.TTL(l.Key()); ttl <= 0 {
+		t.Fatalf("ttl = %v, want > 0", ttl)
+	}
+}
+
+func TestLockRenewalFailure(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+
+	l := NewRedisLockWithExpire("test:renew-fail", 300*time.Millisecond)
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer l.UnLock(ctx)
+	// 其他持有者在锁过期后获取了锁，续期时 value 不匹配
+	if err := mr.Set(l.Key(), "other-holder"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	select {
+	case <-l.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock not reported lost after renewal failed")
+	}
+	if err := l.Context().Err(); err == nil {
+		t.Fatal("lock context not canceled")
+	}
+	if err := l.Check(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("check = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockRenewalStopsWhenRedisUnavailable(t *testing.T) {
+	mr := setupRedis(t)
+	ctx := context.Background()
+
+	l := NewRedisLockWithExpire("test:renew-down", 300*time.Millisecond)
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer l.UnLock(ctx)
+	// 续期连续出错，直到锁过期后才认为锁已丢失
+	mr.Close()
+	select {
+	case <-l.Lost():
+		t.Fatal("lock reported lost before it expired")
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-l.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock not reported lost after it expired")
+	}
+}
+
+func TestTryLockBacksOff(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+
+	holder := NewRedisLockWithExpire("backoff:test", 10*time.Second)
+	if err := holder.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock holder: %v", err)
+	}
+	defer holder.UnLock(ctx)
+
+	waiter := NewRedisLockWithExpire("backoff:test", 10*time.Second)
+	before := contendedAttempts(t, "backoff")
+	start := time.Now()
+	err := waiter.TryLock(ctx, 500*time.Millisecond)
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrLockNotObtained) {
+		t.Fatalf("try lock = %v, want ErrLockNotObtained", err)
+	}
+	if elapsed < 500*time.Millisecond {
+		t.Fatalf("try lock returned after %v, want to wait for the timeout", elapsed)
+	}
+	// 从 minBackoff 开始指数退避，500ms 内只会尝试少数几次
+	attempts := contendedAttempts(t, "backoff") - before
+	if attempts < 2 || attempts > 8 {
+		t.Fatalf("%v attempts while waiting, want between 2 and 8", attempts)
+	}
+}
+
+// contendedAttempts 返回 kind 类锁因被占用而获取失败的累计次数
+func contendedAttempts(t *testing.T, kind string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if !strings.HasSuffix(family.GetName(), "_lock_acquire_total") {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["kind"] == kind && labels["result"] == metrics.ResultContended {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestLockWaitsForRelease(t *testing.T) {
+	setupRedis(t)
+	ctx := context.Background()
+
+	holder := NewRedisLockWithExpire("test:wait", time.Second)
+	if err := holder.TryLock(ctx, 0); err != nil {
+		t.Fatalf("lock holder: %v", err)
+	}
+	first := holder.Token()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = holder.UnLock(ctx)
+	}()
+
+	waiter := NewRedisLockWithExpire("test:wait", time.Second)
+	if err := waiter.TryLock(ctx, 3*time.Second); err != nil {
+		t.Fatalf("waiter lock: %v", err)
+	}
+	defer waiter.UnLock(ctx)
+	if waiter.Token() <= first {
+		t.Fatalf("token of waiter = %d, want > %d", waiter.Token(), first)
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/log"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -11,15 +12,19 @@ import (
 type NoDataHandler = func(ctx *gin.Context) error
 type DataHandler = func(ctx *gin.Context) (interface{}, error)
 type Response struct {
-	// 返回Code码，成功为200，为了mock方便,swagger的min和max都是200
+	// 返回Code码，成功为200，失败时为 errors 中注册的错误码
 	Code int `json:"status,omitempty"`
 	// 返回数据，如果有数据的话
 	Data interface{} `json:"data,omitempty"`
+	// 错误码名称，如 NoSuchBucket，成功时为空
+	ErrCode string `json:"code,omitempty"`
 	// 错误描述
 	Msg string `json:"msg,omitempty"`
 
 	// Reference returns the reference document which maybe useful to solve this error.
 	Reference string `json:"reference,omitempty"`
+	// 请求 id，与响应头 X-Request-Id 相同，排查问题时按此查找日志
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // stack represents a stack of program counters.
@@ -37,14 +42,8 @@ func (r *Response) wrapWithErr(err error) int {
 	}
 
 	coder := errors.ParseCoder(err)
-
-	if coder.Code() == 500 {
-		r.Code = errors.ErrorToHTTPCode(err)
-		r.Msg = err.Error()
-		return http.StatusOK
-	}
-
 	r.Code = coder.Code()
+	r.ErrCode = coder.Name()
 	r.Msg = fmt.Sprintf("%s: %s", coder.String(), err.Error())
 	r.Reference = coder.Reference()
 
@@ -52,21 +51,33 @@ func (r *Response) wrapWithErr(err error) int {
 }
 
 func handleJSONResp(ctx *gin.Context, httpStatus int, resp Response) {
+	resp.RequestID = log.RequestID(ctx)
 	_, err := json.Marshal(resp)
 	if err != nil {
-		fmt.Println(err)
+		log.Ctx(ctx).Errorf("marshal response failed: %v", err)
 	}
 	//logs.Debug("resp:%s", string(data))
 
 	ctx.JSON(httpStatus, resp)
 }
 
+// AbortWithError 以统一的响应格式返回错误并终止后续处理，用于不经过 HandlerWrapper 的处理函数和中间件
+func AbortWithError(ctx *gin.Context, err error) {
+	var resp Response
+	_ = ctx.Error(err)
+	httpStatus := resp.wrapWithErr(err)
+	handleJSONResp(ctx, httpStatus, resp)
+	ctx.Abort()
+}
+
 func DataHandlerWrapper(handler DataHandler) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var resp Response
 		httpStatus := http.StatusOK
 		ret, err := handler(ctx)
 		if err != nil {
+			// 记录到 gin 的错误列表，访问日志和审计日志从中读取失败原因
+			_ = ctx.Error(err)
 			httpStatus = resp.wrapWithErr(err)
 		} else {
 			resp.Code = http.StatusOK
@@ -81,6 +92,8 @@ func NoDataHandlerWrapper(handler NoDataHandler) gin.HandlerFunc {
 		err := handler(ctx)
 		httpStatus := http.StatusOK
 		if err != nil {
+			// 记录到 gin 的错误列表，访问日志和审计日志从中读取失败原因
+			_ = ctx.Error(err)
 			httpStatus = resp.wrapWithErr(err)
 		} else {
 			resp.Code = http.StatusOK
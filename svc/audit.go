@@ -0,0 +1,171 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"sync"
+)
+
+// AuditSvc 查询审计日志
+type AuditSvc struct {
+	auditDao *dao.Audit
+}
+
+func NewAuditSvc(s *dao.S) *AuditSvc {
+	return &AuditSvc{
+		auditDao: s.Audit,
+	}
+}
+
+// List 按条件分页查询审计日志，只有写入 mysql 的审计日志可以查询
+func (m *AuditSvc) List(ctx context.Context, req types.ListAuditLogReq) (*dao.PagedData, error) {
+	if sink := config.GetAudit().Sink; sink != config.AuditSinkMySQL {
+		return nil, fmt.Errorf("%w: audit log is written to %s, not queryable", errors.ErrBadRequest, sink)
+	}
+	page := dao.NewPageCondition(req.Current, req.PageSize)
+	filter := dao.AuditFilter{
+		UserID:     req.UserID,
+		UserName:   req.UserName,
+		Action:     req.Action,
+		BucketName: req.BucketName,
+		ObjectName: req.ObjectName,
+		Result:     req.Result,
+		From:       req.From,
+		To:         req.To,
+	}
+	entries, count, err := m.auditDao.List(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	return &dao.PagedData{
+		Results:  entries,
+		Count:    count,
+		Current:  page.CurrentPage(),
+		PageSize: page.PageSize(),
+	}, nil
+}
+
+// auditSink 审计记录的写入位置
+type auditSink interface {
+	Write(ctx context.Context, entry *dbm.AuditLog) error
+	Close() error
+}
+
+type mysqlAuditSink struct {
+	auditDao *dao.Audit
+}
+
+func (s *mysqlAuditSink) Write(ctx context.Context, entry *dbm.AuditLog) error {
+	return s.auditDao.Create(ctx, entry)
+}
+
+func (s *mysqlAuditSink) Close() error {
+	return nil
+}
+
+// fileAuditSink 每条记录写为一行 json，文件按大小切割
+type fileAuditSink struct {
+	writer *lumberjack.Logger
+	enc    *json.Encoder
+}
+
+func newFileAuditSink(c config.AuditConfig) *fileAuditSink {
+	writer := &lumberjack.Logger{
+		Filename:   c.File,
+		MaxSize:    c.MaxSizeMB,
+		MaxBackups: c.MaxBackups,
+		MaxAge:     c.MaxAgeDays,
+	}
+	return &fileAuditSink{writer: writer, enc: json.NewEncoder(writer)}
+}
+
+func (s *fileAuditSink) Write(ctx context.Context, entry *dbm.AuditLog) error {
+	return s.enc.Encode(entry)
+}
+
+func (s *fileAuditSink) Close() error {
+	return s.writer.Close()
+}
+
+// auditRecord 等待写入的审计记录，ctx 保留请求的链路信息
+type auditRecord struct {
+	ctx   context.Context
+	entry *dbm.AuditLog
+}
+
+// AuditRecorder 在后台按顺序写入审计记录，不阻塞请求。
+// 待写入的记录达到 audit.buffer_size 后 Record 等待写入，保证不丢失记录。
+type AuditRecorder struct {
+	sink    auditSink
+	records chan auditRecord
+	done    chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// NewAuditRecorder 按配置创建审计记录的写入器并启动后台写入，audit.disabled 为 true 时返回的写入器丢弃所有记录
+func NewAuditRecorder(s *dao.S) *AuditRecorder {
+	c := config.GetAudit()
+	r := &AuditRecorder{done: make(chan struct{})}
+	if c.Disabled {
+		r.closed = true
+		close(r.done)
+		return r
+	}
+	if c.Sink == config.AuditSinkFile {
+		r.sink = newFileAuditSink(c)
+	} else {
+		r.sink = &mysqlAuditSink{auditDao: s.Audit}
+	}
+	r.records = make(chan auditRecord, c.BufferSize)
+	go r.run()
+	return r
+}
+
+// Record 提交一条审计记录，请求结束后 ctx 被取消不影响写入
+func (r *AuditRecorder) Record(ctx context.Context, entry *dbm.AuditLog) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return
+	}
+	r.records <- auditRecord{ctx: context.WithoutCancel(ctx), entry: entry}
+}
+
+func (r *AuditRecorder) run() {
+	defer close(r.done)
+	for record := range r.records {
+		if err := r.sink.Write(record.ctx, record.entry); err != nil {
+			// 写入失败时把记录输出到日志，避免丢失
+			log.Ctx(record.ctx).Errorf("write audit log failed: %v, entry: %+v", err, *record.entry)
+		}
+	}
+	if err := r.sink.Close(); err != nil {
+		log.Warnf("close audit sink failed: %v", err)
+	}
+}
+
+// Close 停止接收新记录，等待已提交的记录写入完成或 ctx 超时
+func (r *AuditRecorder) Close(ctx context.Context) error {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.records)
+	}
+	r.mutex.Unlock()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,256 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"regexp"
+	"time"
+)
+
+const (
+	maxBucketTags     = 50
+	maxBucketTagKey   = 128
+	maxBucketTagValue = 256
+	maxBucketReplicas = 16
+	bucketNameMinLen  = 3
+	bucketNameMaxLen  = 63
+)
+
+// bucketNamePattern 桶名只能包含小写字母、数字、点和短横线，以字母或数字开头和结尾
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+
+// storageClasses 支持的存储类型
+var storageClasses = map[string]bool{
+	types.StorageClassStandard: true,
+}
+
+// BucketSvc 读写桶注册表中的桶配置
+type BucketSvc struct {
+	bucketDao *dao.Bucket
+}
+
+func NewBucketSvc(s *dao.S) *BucketSvc {
+	return &BucketSvc{
+		bucketDao: s.Bucket,
+	}
+}
+
+// GetConfig 返回桶的配置，桶未注册时返回 NoSuchBucket
+func (m *BucketSvc) GetConfig(ctx context.Context, name string) (types.BucketConfig, error) {
+	bucket, err := getBucket(ctx, m.bucketDao, name)
+	if err != nil {
+		return types.BucketConfig{}, err
+	}
+	return toBucketConfig(bucket), nil
+}
+
+// Lookup 返回写入对象时使用的桶配置。注册表出现之前创建的桶没有记录，按默认配置处理。
+func (m *BucketSvc) Lookup(ctx context.Context, name string) (types.BucketConfig, error) {
+	cfg, err := m.GetConfig(ctx, name)
+	if errors.IsCode(err, errors.CodeNoSuchBucket) {
+		cfg = types.BucketConfig{Name: name}
+		err = normalizeBucketSettings(&cfg.BucketSettings)
+	}
+	return cfg, err
+}
+
+// PutConfig 修改桶的配置，版本控制状态变化时同步到所有存储节点
+func (m *BucketSvc) PutConfig(ctx context.Context, name string, settings types.BucketSettings) (types.BucketConfig, error) {
+	cfg, err := m.GetConfig(ctx, name)
+	if err != nil {
+		return cfg, err
+	}
+	if err = normalizeBucketSettings(&settings); err != nil {
+		return cfg, err
+	}
+	// 与 S3 相同，开启过版本控制的桶只能暂停，不能关闭
+	if cfg.Versioning != types.BucketVersioningOff && settings.Versioning == types.BucketVersioningOff {
+		return cfg, fmt.Errorf("%w: versioning of bucket %s can only be suspended", errors.ErrBadRequest, name)
+	}
+	if settings.Versioning != cfg.Versioning {
+		if err = setBucketVersioning(ctx, name, settings.Versioning); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.BucketSettings = settings
+	cfg.UpdatedAt = time.Now()
+	if err = m.bucketDao.UpdateSettings(ctx, toBucketModel(cfg)); err != nil {
+		return cfg, fmt.Errorf("update bucket %s: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// register 在注册表中记录新建的桶，桶已存在时返回 BucketAlreadyExists
+func (m *BucketSvc) register(ctx context.Context, cfg *types.BucketConfig) error {
+	if err := validateBucketName(cfg.Name); err != nil {
+		return err
+	}
+	if err := normalizeBucketSettings(&cfg.BucketSettings); err != nil {
+		return err
+	}
+	if cfg.Region == "" {
+		cfg.Region = types.DefaultBucketRegion
+	}
+	_, err := m.bucketDao.Get(ctx, cfg.Name)
+	if err == nil {
+		return errors.WithCode(errors.CodeBucketAlreadyExists, "bucket %s already exists", cfg.Name)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get bucket %s: %w", cfg.Name, err)
+	}
+	cfg.CreationDate = time.Now()
+	cfg.UpdatedAt = cfg.CreationDate
+	if err = m.bucketDao.Create(ctx, toBucketModel(*cfg)); err != nil {
+		return fmt.Errorf("register bucket %s: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+// unregister 删除桶的注册信息
+func (m *BucketSvc) unregister(ctx context.Context, name string) error {
+	if err := m.bucketDao.Delete(ctx, name); err != nil {
+		return fmt.Errorf("unregister bucket %s: %w", name, err)
+	}
+	return nil
+}
+
+// list 返回名称以 prefix 开头的已登记桶的配置，按桶名索引
+func (m *BucketSvc) list(ctx context.Context, prefix string) (map[string]types.BucketConfig, error) {
+	buckets, err := m.bucketDao.ListByPrefix(ctx, prefix, 0)
+	if err != nil {
+		return nil, fmt.Errorf("list buckets: %w", err)
+	}
+	res := make(map[string]types.BucketConfig, len(buckets))
+	for _, bucket := range buckets {
+		res[bucket.Name] = toBucketConfig(bucket)
+	}
+	return res, nil
+}
+
+func validateBucketName(name string) error {
+	if len(name) < bucketNameMinLen || len(name) > bucketNameMaxLen || !bucketNamePattern.MatchString(name) {
+		return errors.WithCode(errors.CodeInvalidBucketName, "invalid bucket name %q", name)
+	}
+	return nil
+}
+
+// normalizeBucketSettings 补齐默认值并检查取值
+func normalizeBucketSettings(s *types.BucketSettings) error {
+	if s.Versioning == "" {
+		s.Versioning = types.BucketVersioningOff
+	}
+	if s.StorageClass == "" {
+		s.StorageClass = types.StorageClassStandard
+	}
+	switch s.Versioning {
+	case types.BucketVersioningOff, types.BucketVersioningEnabled, types.BucketVersioningSuspended:
+	default:
+		return fmt.Errorf("%w: versioning %q is not one of Off, Enabled, Suspended", errors.ErrBadRequest, s.Versioning)
+	}
+	if !storageClasses[s.StorageClass] {
+		return fmt.Errorf("%w: unsupported storage class %q", errors.ErrBadRequest, s.StorageClass)
+	}
+	if s.Replicas < 0 || s.Replicas > maxBucketReplicas {
+		return fmt.Errorf("%w: replicas %d out of range 0-%d", errors.ErrBadRequest, s.Replicas, maxBucketReplicas)
+	}
+	if err := validateQuota(s.Quota); err != nil {
+		return err
+	}
+	if len(s.Tags) > maxBucketTags {
+		return fmt.Errorf("%w: at most %d tags", errors.ErrBadRequest, maxBucketTags)
+	}
+	for k, v := range s.Tags {
+		if k == "" || len(k) > maxBucketTagKey || len(v) > maxBucketTagValue {
+			return fmt.Errorf("%w: invalid tag %q, key must be 1-%d and value at most %d characters",
+				errors.ErrBadRequest, k, maxBucketTagKey, maxBucketTagValue)
+		}
+	}
+	return nil
+}
+
+// setBucketVersioning 在所有存储节点上开启或暂停桶的版本控制
+func setBucketVersioning(ctx context.Context, name, versioning string) error {
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if err = minIo.GetNodeClient(node).SetVersioning(ctx, name, versioning); err != nil {
+			return fmt.Errorf("set versioning of bucket %s on node %s: %w", name, node.ID, minIo.ToError(err))
+		}
+	}
+	return nil
+}
+
+func toBucketConfig(bucket *dbm.Bucket) types.BucketConfig {
+	cfg := types.BucketConfig{
+		Name:         bucket.Name,
+		OwnerID:      bucket.OwnerID,
+		Owner:        bucket.Owner,
+		Region:       bucket.Region,
+		CreationDate: bucket.CreatedAt,
+		UpdatedAt:    bucket.UpdatedAt,
+		BucketSettings: types.BucketSettings{
+			Zone:         bucket.Zone,
+			Versioning:   bucket.Versioning,
+			Replicas:     bucket.Replicas,
+			StorageClass: bucket.StorageClass,
+			Quota: types.Quota{
+				QuotaBytes:       bucket.QuotaBytes,
+				QuotaObjects:     bucket.QuotaObjects,
+				SoftQuotaBytes:   bucket.SoftQuotaBytes,
+				SoftQuotaObjects: bucket.SoftQuotaObjects,
+			},
+			Tags: map[string]string{},
+		},
+	}
+	if bucket.Tags != "" {
+		_ = json.Unmarshal([]byte(bucket.Tags), &cfg.Tags)
+	}
+	return cfg
+}
+
+func toBucketModel(cfg types.BucketConfig) *dbm.Bucket {
+	tags := ""
+	if len(cfg.Tags) > 0 {
+		data, _ := json.Marshal(cfg.Tags)
+		tags = string(data)
+	}
+	return &dbm.Bucket{
+		Name:             cfg.Name,
+		OwnerID:          cfg.OwnerID,
+		Owner:            cfg.Owner,
+		Region:           cfg.Region,
+		Zone:             cfg.Zone,
+		Versioning:       cfg.Versioning,
+		Replicas:         cfg.Replicas,
+		StorageClass:     cfg.StorageClass,
+		QuotaBytes:       cfg.QuotaBytes,
+		QuotaObjects:     cfg.QuotaObjects,
+		SoftQuotaBytes:   cfg.SoftQuotaBytes,
+		SoftQuotaObjects: cfg.SoftQuotaObjects,
+		Tags:             tags,
+		CreatedAt:        cfg.CreationDate,
+		UpdatedAt:        cfg.UpdatedAt,
+	}
+}
+
+// getBucket 返回注册表中的桶，桶不存在时返回 NoSuchBucket
+func getBucket(ctx context.Context, bucketDao *dao.Bucket, bucketName string) (*dbm.Bucket, error) {
+	bucket, err := bucketDao.Get(ctx, bucketName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.WithCode(errors.CodeNoSuchBucket, "bucket %s not found", bucketName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get bucket %s: %w", bucketName, err)
+	}
+	return bucket, nil
+}
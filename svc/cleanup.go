@@ -0,0 +1,150 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	cleanupBatchSize    = 100
+	cleanupRetryBase    = time.Minute
+	cleanupRetryMax     = time.Hour
+	maxCleanupLastError = 1024
+)
+
+// CleanupSvc 重试删除元数据已删除、数据删除失败的对象，如重命名后的源对象
+type CleanupSvc struct {
+	metadataDao *dao.MetadataNode
+	cleanupDao  *dao.Cleanup
+}
+
+func NewCleanupSvc(s *dao.S) *CleanupSvc {
+	return &CleanupSvc{
+		metadataDao: s.MetadataNode,
+		cleanupDao:  s.Cleanup,
+	}
+}
+
+// newCleanup 返回对象数据待删除的记录，与删除元数据在同一个事务中写入。
+// 创建时间用于识别记录是否被覆盖，截断到数据库能保存的毫秒精度
+func newCleanup(meta *dbm.ObjectMetadata) *dbm.ObjectCleanup {
+	now := time.Now().Truncate(time.Millisecond)
+	return &dbm.ObjectCleanup{
+		BucketName:    meta.BucketName,
+		ObjectName:    meta.ObjectName,
+		TierKey:       meta.TierKey,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// Run 删除到了重试时间的对象数据，返回删除完成的对象数
+func (c *CleanupSvc) Run(ctx context.Context) (int, error) {
+	cleanups, err := c.cleanupDao.ListDue(ctx, time.Now(), cleanupBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("list object cleanups: %w", err)
+	}
+	done := 0
+	for _, cleanup := range cleanups {
+		if err = ctx.Err(); err != nil {
+			return done, err
+		}
+		ok, err := c.cleanupObject(ctx, cleanup)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			done++
+		}
+	}
+	return done, nil
+}
+
+// cleanupObject 持有对象锁删除一个对象的数据，对象正在被写入时跳过，下次再试。
+// 对象已被重新写入时数据属于新对象，只删除记录
+func (c *CleanupSvc) cleanupObject(ctx context.Context, cleanup *dbm.ObjectCleanup) (bool, error) {
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: cleanup.BucketName, log.FieldObject: cleanup.ObjectName})
+	lock, err := lockObject(ctx, cleanup.BucketName, cleanup.ObjectName)
+	if errors.Is(err, errors.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer unlockObject(ctx, lock)
+
+	_, err = c.metadataDao.GetObjectMetadata(ctx, cleanup.BucketName, cleanup.ObjectName)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("get object metadata %s/%s: %w", cleanup.BucketName, cleanup.ObjectName, err)
+	}
+	if err == nil && cleanup.TierKey == "" {
+		// 对象已被重新写入，节点上的数据属于新对象
+		return finishCleanup(ctx, c.cleanupDao, cleanup, nil), nil
+	}
+	return finishCleanup(ctx, c.cleanupDao, cleanup, removeObjectData(ctx, cleanup)), nil
+}
+
+// removeObjectData 删除对象在冷存储或所有存储节点上的数据
+func removeObjectData(ctx context.Context, cleanup *dbm.ObjectCleanup) error {
+	if cleanup.TierKey != "" {
+		tier, err := GetColdTier()
+		if err != nil {
+			return err
+		}
+		if err = tier.Delete(ctx, cleanup.TierKey); err != nil {
+			return fmt.Errorf("delete %s from cold tier: %w", cleanup.TierKey, err)
+		}
+		return nil
+	}
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return fmt.Errorf("get storage nodes: %w", err)
+	}
+	// 副本可能分布在任意节点上，逐个节点删除
+	for _, node := range nodes {
+		err = minIo.GetNodeClient(node).MinioCore.RemoveObject(ctx, cleanup.BucketName, cleanup.ObjectName, minio.RemoveObjectOptions{})
+		if err != nil && minio.ToErrorResponse(err).Code != "NoSuchBucket" {
+			return fmt.Errorf("delete object on node %s: %w", node.ID, minIo.ToError(err))
+		}
+	}
+	return nil
+}
+
+// finishCleanup 删除成功时删除记录，失败时记录原因并推迟下一次尝试，返回是否删除成功
+func finishCleanup(ctx context.Context, cleanupDao *dao.Cleanup, cleanup *dbm.ObjectCleanup, err error) bool {
+	if err == nil {
+		if err = cleanupDao.Delete(ctx, cleanup); err != nil {
+			log.Ctx(ctx).Warnf("delete object cleanup failed: %v", err)
+		}
+		return true
+	}
+	cleanup.Attempts++
+	cleanup.NextAttemptAt = time.Now().Add(cleanupBackoff(cleanup.Attempts))
+	cleanup.LastError = err.Error()
+	if len(cleanup.LastError) > maxCleanupLastError {
+		cleanup.LastError = cleanup.LastError[:maxCleanupLastError]
+	}
+	log.Ctx(ctx).Warnf("remove object data failed, retry at %s: %v", cleanup.NextAttemptAt.Format(time.RFC3339), err)
+	if err = cleanupDao.UpdateAttempt(ctx, cleanup); err != nil {
+		log.Ctx(ctx).Warnf("update object cleanup failed: %v", err)
+	}
+	return false
+}
+
+// cleanupBackoff 第 attempts 次失败后到下一次重试的等待时间，从 cleanupRetryBase 开始每次翻倍
+func cleanupBackoff(attempts int) time.Duration {
+	d := cleanupRetryBase
+	for i := 1; i < attempts && d < cleanupRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, cleanupRetryMax)
+}
@@ -0,0 +1,309 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/placement"
+	"distributed-object-storage/pkg/tracing"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"io"
+	"strings"
+	"time"
+)
+
+// CopyObject 在服务端复制对象，源和目标可以在不同的桶中。cond 为目标对象的写入条件。
+// 目标节点上有源对象的副本时在节点内部复制，否则在节点之间以流的方式复制；冷存储中的对象复制后写回存储节点。
+func (s *StorageNodeSvc) CopyObject(ctx context.Context, req types.CopyObjectReq, cond types.WriteCondition) (*types.CopyObjectResult, error) {
+	return s.copyObject(ctx, req, cond, false)
+}
+
+// RenameObject 重命名对象，目标对象的元数据写入和源对象的元数据删除在同一个事务中完成，
+// 客户端不会同时看到两个对象，也不会两个都看不到。源对象的数据在提交后删除，
+// 待删除记录与元数据在同一个事务中写入，删除失败时由后台任务重试。
+func (s *StorageNodeSvc) RenameObject(ctx context.Context, req types.RenameObjectReq, cond types.WriteCondition) (*types.CopyObjectResult, error) {
+	if req.NewBucketName == "" {
+		req.NewBucketName = req.BucketName
+	}
+	return s.copyObject(ctx, types.CopyObjectReq{
+		SourceBucket: req.BucketName,
+		SourceObject: req.ObjectName,
+		BucketName:   req.NewBucketName,
+		ObjectName:   req.NewObjectName,
+	}, cond, true)
+}
+
+func (s *StorageNodeSvc) copyObject(ctx context.Context, req types.CopyObjectReq, cond types.WriteCondition, rename bool) (res *types.CopyObjectResult, err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.CopyObject", tracing.Object(req.BucketName, req.ObjectName)...)
+	defer func() { tracing.End(span, err) }()
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: req.BucketName, log.FieldObject: req.ObjectName})
+
+	if err = normalizeCopyObject(&req); err != nil {
+		return nil, err
+	}
+	locks, err := lockObjects(ctx, req.SourceBucket, req.SourceObject, req.BucketName, req.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockObjects(ctx, locks)
+
+	src, err := s.getObjectMetadata(ctx, req.SourceBucket, req.SourceObject)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := s.MetaDataDao.GetObjectMetadata(ctx, req.BucketName, req.ObjectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		prev, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object metadata %s/%s: %w", req.BucketName, req.ObjectName, err)
+	}
+	if err = checkWriteCondition(prev, cond); err != nil {
+		return nil, err
+	}
+	bucket, err := s.BucketSvc.Lookup(ctx, req.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.replicationRule(ctx, req.BucketName, req.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+	// 重命名时源对象的删除与目标对象的写入在同一个事务中加入复制队列
+	var srcRule *types.ReplicationRule
+	if rename {
+		if srcRule, err = s.replicationRule(ctx, req.SourceBucket, req.SourceObject); err != nil {
+			return nil, err
+		}
+	}
+
+	// 桶内重命名不改变用量，跨桶时目标桶预占用量，源桶在删除源对象后扣减
+	delta := types.Usage{Bytes: src.Size, Objects: 1}
+	if prev != nil {
+		delta = types.Usage{Bytes: src.Size - prev.Size}
+	}
+	if rename && req.SourceBucket == req.BucketName {
+		delta.Bytes -= src.Size
+		delta.Objects--
+	}
+	reservation, err := s.QuotaSvc.Reserve(ctx, bucket, delta)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.QuotaSvc.Release(ctx, reservation)
+		}
+	}()
+
+	contentType, storageClass := src.ContentType, src.StorageClass
+	if src.IsCold() {
+		storageClass = bucket.StorageClass
+	}
+	if req.MetadataDirective == types.MetadataDirectiveReplace {
+		contentType, storageClass = req.ContentType, req.StorageClass
+		if storageClass == "" {
+			storageClass = bucket.StorageClass
+		}
+	}
+	policy := placement.Default().ForBucket(bucket)
+	targets, err := placeObject(ctx, policy, req.BucketName, req.ObjectName)
+	if err != nil {
+		return nil, err
+	}
+	uploadInfo, stored, err := s.copyReplicas(ctx, src, req.BucketName, req.ObjectName, contentType, targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) < policy.Replicas {
+		log.Ctx(ctx).Warnf("copied with %d replicas, less than expected", len(stored))
+	}
+	for _, lock := range locks {
+		if err = checkObjectLock(ctx, lock); err != nil {
+			return nil, err
+		}
+	}
+
+	meta := &dbm.ObjectMetadata{
+		BucketName:   req.BucketName,
+		ObjectName:   req.ObjectName,
+		Size:         src.Size,
+		ContentType:  contentType,
+		ETag:         strings.Trim(uploadInfo.ETag, "\""),
+		LastModified: time.Now(),
+		VersionID:    uploadInfo.VersionID,
+		IsLatest:     true,
+		StorageClass: storageClass,
+	}
+	if rule != nil {
+		meta.ReplicationStatus = types.ReplicationStatusPending
+	}
+	meta.SetNodes(stored)
+	tasks := replicationTasks(rule, types.ReplicationOpPut, meta)
+	var cleanup *dbm.ObjectCleanup
+	if rename {
+		tasks = append(tasks, replicationTasks(srcRule, types.ReplicationOpDelete, src)...)
+		cleanup = newCleanup(src)
+		err = s.MetaDataDao.RenameObjectMetadata(ctx, meta, req.SourceBucket, req.SourceObject, tasks, cleanup)
+	} else {
+		err = s.MetaDataDao.SaveObjectMetadataWithTasks(ctx, meta, tasks)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("save object metadata: %w", err)
+	}
+	if prev != nil && prev.IsCold() {
+		deleteColdCopy(ctx, prev.TierKey)
+	}
+	if rename {
+		s.removeRenamedSource(ctx, cleanup)
+		if req.SourceBucket != req.BucketName {
+			s.chargeBucket(ctx, req.SourceBucket, types.Usage{Bytes: -src.Size, Objects: -1})
+		}
+	}
+	return &types.CopyObjectResult{
+		BucketName:   meta.BucketName,
+		ObjectName:   meta.ObjectName,
+		ETag:         meta.ETag,
+		Size:         meta.Size,
+		LastModified: meta.LastModified,
+	}, nil
+}
+
+// copyReplicas 将源对象复制到 targets，第一个节点写入失败时返回错误，其余节点失败只记录日志。
+// 目标节点上有源对象的副本时在节点内部复制，数据不经过网关；否则与上传相同，源对象以流的方式写入第一个节点，
+// 其余节点从第一个节点复制。节点内部复制的 ETag 与期望不同时（如分片上传的对象）改为跨节点复制，保证所有副本的 ETag 相同
+func (s *StorageNodeSvc) copyReplicas(ctx context.Context, src *dbm.ObjectMetadata, bucketName, objectName, contentType string,
+	targets []types.StorageNodeInfo) (*minio.UploadInfo, []string, error) {
+	held := make(map[string]bool)
+	if !src.IsCold() {
+		for _, id := range src.Nodes() {
+			held[id] = true
+		}
+	}
+	primary := minIo.GetNodeClient(targets[0])
+	if err := primary.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, nil, minIo.ToError(err)
+	}
+	var uploadInfo *minio.UploadInfo
+	if held[targets[0].ID] {
+		uploadInfo = copyWithin(ctx, primary, src, bucketName, objectName, contentType, src.ETag)
+	}
+	if uploadInfo == nil {
+		var err error
+		if uploadInfo, err = s.streamObject(ctx, src, primary, bucketName, objectName, contentType); err != nil {
+			return nil, nil, minIo.ToError(err)
+		}
+	}
+	stored := []string{targets[0].ID}
+	for _, node := range targets[1:] {
+		client := minIo.GetNodeClient(node)
+		if held[node.ID] && client.EnsureBucket(ctx, bucketName) == nil &&
+			copyWithin(ctx, client, src, bucketName, objectName, contentType, uploadInfo.ETag) != nil {
+			stored = append(stored, node.ID)
+			continue
+		}
+		if _, err := client.CopyObjectFrom(ctx, primary, bucketName, objectName); err != nil {
+			log.Ctx(ctx).WithField(log.FieldNode, node.ID).Warnf("replicate copy failed: %v", err)
+			continue
+		}
+		stored = append(stored, node.ID)
+	}
+	return uploadInfo, stored, nil
+}
+
+// copyWithin 在节点内部复制源对象的副本，复制后读取目标对象的 ETag，复制失败或 ETag 不是 etag 时返回 nil，由调用方跨节点复制
+func copyWithin(ctx context.Context, client *minIo.MinioHelper, src *dbm.ObjectMetadata, bucketName, objectName, contentType, etag string) *minio.UploadInfo {
+	logger := log.Ctx(ctx).WithField(log.FieldNode, client.Endpoint)
+	info, err := client.CopyObjectWithin(ctx, src.BucketName, src.ObjectName, bucketName, objectName, contentType)
+	if err != nil {
+		logger.Warnf("copy within node failed, copy across nodes: %v", err)
+		return nil
+	}
+	stat, err := client.MinioCore.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{VersionID: info.VersionID})
+	if err != nil {
+		logger.Warnf("stat copied object failed, copy across nodes: %v", err)
+		return nil
+	}
+	if got := strings.Trim(stat.ETag, "\""); got != strings.Trim(etag, "\"") {
+		logger.Infof("copy within node got etag %s, want %s, copy across nodes", got, etag)
+		return nil
+	}
+	info.ETag = stat.ETag
+	return info
+}
+
+// streamObject 从源对象的副本或冷存储读取数据写入 dest 节点
+func (s *StorageNodeSvc) streamObject(ctx context.Context, src *dbm.ObjectMetadata, dest *minIo.MinioHelper,
+	bucketName, objectName, contentType string) (*minio.UploadInfo, error) {
+	var object io.ReadCloser
+	if src.IsCold() {
+		tier, err := GetColdTier()
+		if err != nil {
+			return nil, err
+		}
+		if object, err = tier.Get(ctx, src.TierKey); err != nil {
+			return nil, fmt.Errorf("get %s from cold tier: %w", src.TierKey, err)
+		}
+	} else {
+		var (
+			info minio.ObjectInfo
+			err  error
+		)
+		if object, info, _, err = s.openReplica(ctx, src); err != nil {
+			return nil, err
+		}
+		if contentType == "" {
+			contentType = info.ContentType
+		}
+	}
+	defer object.Close()
+	return dest.PutStream(ctx, bucketName, objectName, object, src.Size, contentType)
+}
+
+// removeRenamedSource 元数据提交后删除源对象的数据，失败时保留待删除记录，由后台任务重试
+func (s *StorageNodeSvc) removeRenamedSource(ctx context.Context, cleanup *dbm.ObjectCleanup) {
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: cleanup.BucketName, log.FieldObject: cleanup.ObjectName})
+	finishCleanup(ctx, s.CleanupDao, cleanup, removeObjectData(ctx, cleanup))
+}
+
+// chargeBucket 修改桶的用量，失败只记录日志
+func (s *StorageNodeSvc) chargeBucket(ctx context.Context, bucketName string, delta types.Usage) {
+	bucket, err := s.BucketSvc.Lookup(ctx, bucketName)
+	if err != nil {
+		log.Ctx(ctx).Warnf("update usage of bucket %s failed: %v", bucketName, err)
+		return
+	}
+	s.QuotaSvc.Charge(ctx, bucket, delta)
+}
+
+// normalizeCopyObject 补齐默认值并检查复制请求，不允许将对象复制到自身
+func normalizeCopyObject(req *types.CopyObjectReq) error {
+	if req.SourceBucket == "" || req.SourceObject == "" || req.BucketName == "" || req.ObjectName == "" {
+		return fmt.Errorf("%w: source and destination bucket and object names are required", errors.ErrBadRequest)
+	}
+	if req.SourceBucket == req.BucketName && req.SourceObject == req.ObjectName {
+		return fmt.Errorf("%w: source and destination are the same object", errors.ErrBadRequest)
+	}
+	req.MetadataDirective = strings.ToUpper(req.MetadataDirective)
+	if req.MetadataDirective == "" {
+		req.MetadataDirective = types.MetadataDirectiveCopy
+	}
+	switch req.MetadataDirective {
+	case types.MetadataDirectiveCopy:
+		if req.ContentType != "" || req.StorageClass != "" {
+			return fmt.Errorf("%w: content_type and storage_class require metadata directive REPLACE", errors.ErrBadRequest)
+		}
+	case types.MetadataDirectiveReplace:
+		if req.StorageClass != "" && !storageClasses[req.StorageClass] {
+			return fmt.Errorf("%w: unsupported storage class %q", errors.ErrBadRequest, req.StorageClass)
+		}
+	default:
+		return fmt.Errorf("%w: metadata directive %q is not one of COPY, REPLACE", errors.ErrBadRequest, req.MetadataDirective)
+	}
+	return nil
+}
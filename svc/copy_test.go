@@ -0,0 +1,144 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCopyObjectWithinNode(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "same node")
+
+	// 两个节点上都有源对象的副本，复制在节点内部完成
+	res, err := env.svc.CopyObject(ctx, types.CopyObjectReq{
+		SourceBucket: "photos",
+		SourceObject: "a.txt",
+		BucketName:   "photos",
+		ObjectName:   "b.txt",
+	}, types.WriteCondition{})
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "b.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if len(meta.Nodes()) != 2 || meta.ETag != res.ETag {
+		t.Fatalf("copied to %v with etag %s, result etag %s", meta.Nodes(), meta.ETag, res.ETag)
+	}
+	for _, id := range meta.Nodes() {
+		if got := readBackend(t, env.nodes[id], "photos", "b.txt"); got != "same node" {
+			t.Fatalf("replica on %s = %q", id, got)
+		}
+		object, err := env.nodes[id].HeadObject("photos", "b.txt")
+		if err != nil {
+			t.Fatalf("head on %s: %v", id, err)
+		}
+		if etag := hex.EncodeToString(object.Hash); etag != meta.ETag {
+			t.Fatalf("etag on %s = %s, metadata %s", id, etag, meta.ETag)
+		}
+	}
+}
+
+func TestCopyObjectWithinNodeChecksETag(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "original")
+	// node-2 上的副本已损坏，节点内部复制得到的 ETag 与源对象不同
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-2"}, "photos", "a.txt", "0riginal")
+
+	_, err := env.svc.CopyObject(ctx, types.CopyObjectReq{
+		SourceBucket: "photos",
+		SourceObject: "a.txt",
+		BucketName:   "photos",
+		ObjectName:   "b.txt",
+	}, types.WriteCondition{})
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "b.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	// 所有副本与元数据中的 ETag 一致
+	for _, id := range meta.Nodes() {
+		object, err := env.nodes[id].HeadObject("photos", "b.txt")
+		if err != nil {
+			t.Fatalf("head on %s: %v", id, err)
+		}
+		if etag := hex.EncodeToString(object.Hash); etag != meta.ETag {
+			t.Fatalf("etag on %s = %s, metadata %s", id, etag, meta.ETag)
+		}
+	}
+}
+
+// renameLeavingSource 重命名对象，删除源对象数据时有一个节点无法连接，源对象的数据残留在节点上。
+// 返回去掉该节点的函数
+func renameLeavingSource(t *testing.T, env *testEnv, bucketName, objectName, newObjectName string) (restore func()) {
+	t.Helper()
+	live, err := minIo.GetStorageNodes()
+	if err != nil {
+		t.Fatalf("get storage nodes: %v", err)
+	}
+	// 无法连接的节点，已满所以不会被选为目标节点，但删除源对象时要逐个节点删除
+	dead := httptest.NewServer(nil)
+	dead.Close()
+	full := types.DiskUsage{TotalSpace: 100, UsedSpace: 100, UsagePercentage: 100}
+	restore = minIo.SetStorageNodes(append([]types.StorageNodeInfo{{ID: "node-0", Endpoint: dead.URL, DiskUsage: full}}, live...))
+	_, err = env.svc.RenameObject(context.Background(), types.RenameObjectReq{
+		BucketName:    bucketName,
+		ObjectName:    objectName,
+		NewObjectName: newObjectName,
+	}, types.WriteCondition{})
+	if err != nil {
+		restore()
+		t.Fatalf("rename: %v", err)
+	}
+	if env.nodesHolding(bucketName, objectName) == 0 {
+		restore()
+		t.Fatalf("renamed source removed")
+	}
+	return restore
+}
+
+func TestRenameRetriesSourceCleanup(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "renamed")
+	restore := renameLeavingSource(t, env, "photos", "a.txt", "b.txt")
+	cleanups, err := env.dao.Cleanup.ListByNames(ctx, "photos", []string{"a.txt"})
+	if err != nil {
+		t.Fatalf("list cleanups: %v", err)
+	}
+	if len(cleanups) != 1 || cleanups[0].Attempts != 1 {
+		t.Fatalf("cleanups = %+v, want one failed attempt", cleanups)
+	}
+
+	// 节点恢复后由后台任务重试
+	restore()
+	err = env.dao.DB.Model(&dbm.ObjectCleanup{}).Where("id = ?", cleanups[0].Id).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatalf("update cleanup: %v", err)
+	}
+	done, err := NewCleanupSvc(env.dao).Run(ctx)
+	if err != nil || done != 1 {
+		t.Fatalf("run cleanup = %d, %v", done, err)
+	}
+	if n := env.nodesHolding("photos", "a.txt"); n != 0 {
+		t.Fatalf("renamed source still on %d nodes", n)
+	}
+	if content, _ := env.getObject(t, "photos", "b.txt"); content != "renamed" {
+		t.Fatalf("content = %q", content)
+	}
+	if cleanups, _ = env.dao.Cleanup.ListByNames(ctx, "photos", []string{"a.txt"}); len(cleanups) != 0 {
+		t.Fatalf("cleanup not removed: %+v", cleanups)
+	}
+}
@@ -0,0 +1,368 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+const (
+	maxDeleteObjects      = 1000
+	deleteConcurrency     = 8
+	deleteBatchSize       = 500
+	maxDeleteJobLastError = 1024
+)
+
+// DeleteObjects 批量删除桶内的对象，最多 maxDeleteObjects 个，每个对象单独返回结果。
+// 对象不存在时视为删除成功，指定了 ETag 时也一样，quiet 为 true 时结果中只有删除失败的对象。
+func (s *StorageNodeSvc) DeleteObjects(ctx context.Context, req types.DeleteObjectsReq) (*types.DeleteObjectsResult, error) {
+	if req.BucketName == "" {
+		return nil, fmt.Errorf("%w: bucket_name is empty", errors.ErrBadRequest)
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > maxDeleteObjects {
+		return nil, fmt.Errorf("%w: between 1 and %d objects can be deleted at a time", errors.ErrBadRequest, maxDeleteObjects)
+	}
+	for _, key := range req.Objects {
+		if key.ObjectName == "" {
+			return nil, fmt.Errorf("%w: object_name is empty", errors.ErrBadRequest)
+		}
+	}
+	errs := s.deleteEach(ctx, req.BucketName, req.Objects)
+	res := &types.DeleteObjectsResult{Deleted: []types.DeleteObjectsKey{}, Errors: []types.DeleteObjectsError{}}
+	for i, err := range errs {
+		if err == nil || errors.Is(err, errObjectNotExist) {
+			if !req.Quiet {
+				res.Deleted = append(res.Deleted, req.Objects[i])
+			}
+			continue
+		}
+		res.Errors = append(res.Errors, types.DeleteObjectsError{
+			ObjectName: req.Objects[i].ObjectName,
+			Code:       errors.ParseCoder(err).Name(),
+			Message:    err.Error(),
+		})
+	}
+	return res, nil
+}
+
+// deleteEach 并发删除 keys 中的对象，返回与 keys 一一对应的错误
+func (s *StorageNodeSvc) deleteEach(ctx context.Context, bucketName string, keys []types.DeleteObjectsKey) []error {
+	errs := make([]error, len(keys))
+	sem := make(chan struct{}, deleteConcurrency)
+	wg := new(sync.WaitGroup)
+	for i, key := range keys {
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(i int, key types.DeleteObjectsKey) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = s.DeleteObject(ctx, bucketName, key.ObjectName, types.WriteCondition{IfMatch: key.ETag})
+		}(i, key)
+	}
+	wg.Wait()
+	return errs
+}
+
+// prefixDeleteProgress 分批删除前缀下的对象时一批的结果
+type prefixDeleteProgress struct {
+	lastID    uint
+	deleted   int64
+	bytes     int64
+	failed    int64
+	lastError error
+}
+
+// deletePrefix 按元数据 id 顺序分批删除桶内以 prefix 开头、在 before 之前写入且 id 大于 afterID 的对象，
+// 每删除一批调用一次 progress。删除期间被重新写入的对象不删除。
+func (s *StorageNodeSvc) deletePrefix(ctx context.Context, bucketName, prefix string, before time.Time, afterID uint,
+	progress func(p prefixDeleteProgress) error) error {
+	for {
+		if err := fencing.Check(ctx); err != nil {
+			return err
+		}
+		batch, err := s.MetaDataDao.ListObjectMetadataBefore(ctx, bucketName, prefix, before, afterID, deleteBatchSize)
+		if err != nil {
+			return fmt.Errorf("list objects of bucket %s: %w", bucketName, err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		keys := make([]types.DeleteObjectsKey, len(batch))
+		for i, meta := range batch {
+			keys[i] = types.DeleteObjectsKey{ObjectName: meta.ObjectName, ETag: meta.ETag}
+		}
+		p := prefixDeleteProgress{lastID: batch[len(batch)-1].Id}
+		for i, err := range s.deleteEach(ctx, bucketName, keys) {
+			switch {
+			case err == nil:
+				p.deleted++
+				p.bytes += batch[i].Size
+			case errors.Is(err, errors.ErrPreconditionFailed):
+				// 对象已被重新写入或删除
+			default:
+				p.failed++
+				p.lastError = fmt.Errorf("delete %s: %w", batch[i].ObjectName, err)
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = progress(p); err != nil {
+			return err
+		}
+		afterID = p.lastID
+	}
+}
+
+// DeleteJobSvc 创建和执行异步删除前缀下所有对象的任务
+type DeleteJobSvc struct {
+	bucketDao    *dao.Bucket
+	deleteJobDao *dao.DeleteJob
+	storageSvc   *StorageNodeSvc
+	metadataSvc  *MetadataSvc
+}
+
+func NewDeleteJobSvc(s *dao.S) *DeleteJobSvc {
+	return &DeleteJobSvc{
+		bucketDao:    s.Bucket,
+		deleteJobDao: s.DeleteJob,
+		storageSvc:   NewStorageNodeSvc(s),
+		metadataSvc:  NewMetadataSvc(s),
+	}
+}
+
+// CreateJob 创建删除任务，由后台任务异步执行，只删除创建任务之前写入的对象
+func (m *DeleteJobSvc) CreateJob(ctx context.Context, req types.DeletePrefixReq) (*dbm.DeleteJob, error) {
+	if _, err := getBucket(ctx, m.bucketDao, req.BucketName); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &dbm.DeleteJob{
+		BucketName: req.BucketName,
+		Prefix:     req.Prefix,
+		State:      types.DeleteJobPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := m.deleteJobDao.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("create delete job: %w", err)
+	}
+	return job, nil
+}
+
+// CreateBucketJob 创建清空并删除桶的任务：先按前缀任务的方式删除所有对象，
+// 再删除各节点上没有元数据的对象和所有历史版本，最后删除桶
+func (m *DeleteJobSvc) CreateBucketJob(ctx context.Context, bucketName string) (*dbm.DeleteJob, error) {
+	if _, err := getBucket(ctx, m.bucketDao, bucketName); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &dbm.DeleteJob{
+		BucketName:   bucketName,
+		DeleteBucket: true,
+		State:        types.DeleteJobPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := m.deleteJobDao.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("create delete job: %w", err)
+	}
+	return job, nil
+}
+
+// GetJob 返回删除任务的状态和进度，任务不存在时返回 ErrNotFound
+func (m *DeleteJobSvc) GetJob(ctx context.Context, id uint) (*dbm.DeleteJob, error) {
+	job, err := m.deleteJobDao.GetJob(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: delete job %d", errors.ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get delete job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+// ListJobs 分页查询删除任务
+func (m *DeleteJobSvc) ListJobs(ctx context.Context, req types.ListDeleteJobReq) (*dao.PagedData, error) {
+	page := dao.NewPageCondition(req.Current, req.PageSize)
+	jobs, count, err := m.deleteJobDao.ListJobs(ctx, req.BucketName, req.State, page)
+	if err != nil {
+		return nil, err
+	}
+	return &dao.PagedData{
+		Results:  jobs,
+		Count:    count,
+		Current:  page.CurrentPage(),
+		PageSize: page.PageSize(),
+	}, nil
+}
+
+// Run 依次执行等待中的任务和上次中断的任务，返回执行完成的任务数
+func (m *DeleteJobSvc) Run(ctx context.Context) (int, error) {
+	jobs, err := m.deleteJobDao.ListJobsByState(ctx, []string{types.DeleteJobPending, types.DeleteJobRunning})
+	if err != nil {
+		return 0, fmt.Errorf("list delete jobs: %w", err)
+	}
+	for i, job := range jobs {
+		if err = m.runJob(ctx, job); err != nil {
+			return i, err
+		}
+	}
+	return len(jobs), nil
+}
+
+// runJob 执行一个任务，每删除一批保存一次进度，中断后从保存的进度继续
+func (m *DeleteJobSvc) runJob(ctx context.Context, job *dbm.DeleteJob) error {
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: job.BucketName})
+	job.State = types.DeleteJobRunning
+	err := m.storageSvc.deletePrefix(ctx, job.BucketName, job.Prefix, job.CreatedAt, job.LastID, func(p prefixDeleteProgress) error {
+		job.LastID = p.lastID
+		job.Deleted += p.deleted
+		job.DeletedBytes += p.bytes
+		job.Failed += p.failed
+		setLastError(job, p.lastError)
+		job.UpdatedAt = time.Now()
+		return m.deleteJobDao.UpdateProgress(ctx, job)
+	})
+	if err != nil {
+		return fmt.Errorf("delete job %d: %w", job.Id, err)
+	}
+	if job.DeleteBucket && job.Failed == 0 {
+		if err = m.deleteBucket(ctx, job); err != nil {
+			return fmt.Errorf("delete job %d: %w", job.Id, err)
+		}
+	}
+	now := time.Now()
+	job.State = types.DeleteJobCompleted
+	if job.Failed > 0 {
+		job.State = types.DeleteJobFailed
+	}
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	if err = m.deleteJobDao.UpdateProgress(ctx, job); err != nil {
+		return fmt.Errorf("finish delete job %d: %w", job.Id, err)
+	}
+	log.Ctx(ctx).Infof("delete job %d under prefix %q finished, deleted %d objects (%d bytes), %d failed",
+		job.Id, job.Prefix, job.Deleted, job.DeletedBytes, job.Failed)
+	return nil
+}
+
+// deleteBucket 删除各节点上剩余的对象版本后删除桶。桶无法删除时记录原因，任务结束为失败状态
+func (m *DeleteJobSvc) deleteBucket(ctx context.Context, job *dbm.DeleteJob) error {
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		removed, err := m.removeNodeVersions(ctx, node, job.BucketName)
+		job.Deleted += removed
+		if err != nil {
+			if errors.Is(err, fencing.ErrStaleToken) || ctx.Err() != nil {
+				return err
+			}
+			job.Failed++
+			setLastError(job, fmt.Errorf("empty bucket on node %s: %w", node.ID, err))
+			return nil
+		}
+	}
+	if err = fencing.Check(ctx); err != nil {
+		return err
+	}
+	if err = m.metadataSvc.DeleteBucket(ctx, job.BucketName); err != nil {
+		job.Failed++
+		setLastError(job, err)
+	}
+	return nil
+}
+
+// removeNodeVersions 删除节点上桶内所有对象的所有版本，包括删除标记和没有元数据的数据，桶不存在时不做处理。
+// 每个对象在对象锁内确认元数据不存在后才删除，任务开始后新写入的对象不删除，桶也因此无法删除。
+func (m *DeleteJobSvc) removeNodeVersions(ctx context.Context, node types.StorageNodeInfo, bucketName string) (int64, error) {
+	// 提前返回时取消列举，避免列举的 goroutine 阻塞
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	client := minIo.GetNodeClient(node)
+	objects := client.MinioCore.Client.ListObjects(listCtx, bucketName, minio.ListObjectsOptions{
+		Recursive:    true,
+		WithVersions: true,
+	})
+	var (
+		removed int64
+		lock    *redis.Lock
+		key     string
+		skip    bool
+	)
+	release := func() {
+		if lock != nil {
+			unlockObject(ctx, lock)
+			lock = nil
+		}
+	}
+	defer release()
+	// 同一对象的版本连续返回，每个对象只加一次锁
+	for object := range objects {
+		if object.Err != nil {
+			if minio.ToErrorResponse(object.Err).Code == "NoSuchBucket" {
+				return removed, nil
+			}
+			return removed, minIo.ToError(object.Err)
+		}
+		if lock == nil || object.Key != key {
+			release()
+			key = object.Key
+			if err := fencing.Check(ctx); err != nil {
+				return removed, err
+			}
+			var err error
+			if lock, err = lockObject(ctx, bucketName, key); err != nil {
+				return removed, err
+			}
+			_, err = m.storageSvc.MetaDataDao.GetObjectMetadata(ctx, bucketName, key)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return removed, fmt.Errorf("get object metadata %s/%s: %w", bucketName, key, err)
+			}
+			skip = err == nil
+			if skip {
+				log.Ctx(ctx).Infof("keep %s/%s written after the delete job started", bucketName, key)
+			}
+		}
+		if skip {
+			continue
+		}
+		err := client.MinioCore.RemoveObject(ctx, bucketName, object.Key, minio.RemoveObjectOptions{VersionID: object.VersionID})
+		if err != nil {
+			return removed, fmt.Errorf("remove version %s of %s: %w", object.VersionID, object.Key, minIo.ToError(err))
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// setLastError 记录最近一次失败的原因
+func setLastError(job *dbm.DeleteJob, err error) {
+	if err == nil {
+		return
+	}
+	job.LastError = err.Error()
+	if len(job.LastError) > maxDeleteJobLastError {
+		job.LastError = job.LastError[:maxDeleteJobLastError]
+	}
+}
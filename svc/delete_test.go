@@ -0,0 +1,110 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/types"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// createBucket 通过 MetadataSvc 创建桶
+func createBucket(t *testing.T, env *testEnv, cfg types.BucketConfig) {
+	t.Helper()
+	if err := NewMetadataSvc(env.dao).CreateBucket(context.Background(), cfg); err != nil {
+		t.Fatalf("create bucket %s: %v", cfg.Name, err)
+	}
+}
+
+func TestDeleteObjectsMissingWithETag(t *testing.T) {
+	env := newTestEnv(t, 2)
+	env.putObject(t, "photos", "a.txt", "kept")
+	res, err := env.svc.DeleteObjects(context.Background(), types.DeleteObjectsReq{
+		BucketName: "photos",
+		Objects: []types.DeleteObjectsKey{
+			{ObjectName: "missing.txt", ETag: "0123456789abcdef"},
+			{ObjectName: "a.txt", ETag: "0123456789abcdef"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("delete objects: %v", err)
+	}
+	if len(res.Deleted) != 1 || res.Deleted[0].ObjectName != "missing.txt" {
+		t.Fatalf("deleted = %v, want missing.txt", res.Deleted)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].ObjectName != "a.txt" {
+		t.Fatalf("errors = %v, want a.txt", res.Errors)
+	}
+	if content, _ := env.getObject(t, "photos", "a.txt"); content != "kept" {
+		t.Fatalf("content = %q", content)
+	}
+}
+
+func TestBucketDeleteJob(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	createBucket(t, env, types.BucketConfig{Name: "photos", BucketSettings: types.BucketSettings{Versioning: types.BucketVersioningEnabled}})
+	env.putObject(t, "photos", "a.txt", "v1")
+	env.putObject(t, "photos", "a.txt", "v2")
+	env.putObject(t, "photos", "b.txt", "b")
+	// 没有元数据的数据
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-3"}, "photos", "orphan.txt", "orphan")
+
+	jobs := NewDeleteJobSvc(env.dao)
+	job, err := jobs.CreateBucketJob(ctx, "photos")
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err = jobs.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	job, err = jobs.GetJob(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != types.DeleteJobCompleted || job.LastError != "" {
+		t.Fatalf("job %s: %s", job.State, job.LastError)
+	}
+	for id, backend := range env.nodes {
+		if exists, _ := backend.BucketExists("photos"); exists {
+			t.Fatalf("bucket still exists on %s", id)
+		}
+	}
+	if _, err = env.dao.Bucket.Get(ctx, "photos"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("bucket not unregistered: %v", err)
+	}
+}
+
+func TestBucketDeleteJobKeepsNewWrites(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	createBucket(t, env, types.BucketConfig{Name: "photos"})
+	env.putObject(t, "photos", "a.txt", "old")
+
+	jobs := NewDeleteJobSvc(env.dao)
+	job, err := jobs.CreateBucketJob(ctx, "photos")
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	// 任务开始执行之前写入的对象
+	env.putObject(t, "photos", "b.txt", "new")
+	if _, err = jobs.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	job, err = jobs.GetJob(ctx, job.Id)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != types.DeleteJobFailed {
+		t.Fatalf("job state = %s, want %s", job.State, types.DeleteJobFailed)
+	}
+	if content, _ := env.getObject(t, "photos", "b.txt"); content != "new" {
+		t.Fatalf("content = %q", content)
+	}
+	if _, err = env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("old object not deleted: %v", err)
+	}
+	if _, err = env.dao.Bucket.Get(ctx, "photos"); err != nil {
+		t.Fatalf("bucket unregistered: %v", err)
+	}
+}
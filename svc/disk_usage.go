@@ -0,0 +1,28 @@
+//go:build !windows
+
+package svc
+
+import (
+	"distributed-object-storage/types"
+	"syscall"
+)
+
+// DiskUsageOf 返回 path 所在文件系统的磁盘使用情况
+func DiskUsageOf(path string) (types.DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return types.DiskUsage{}, err
+	}
+	total := int64(stat.Blocks) * int64(stat.Bsize)
+	available := int64(stat.Bavail) * int64(stat.Bsize)
+	used := total - int64(stat.Bfree)*int64(stat.Bsize)
+	usage := types.DiskUsage{
+		TotalSpace:     total,
+		UsedSpace:      used,
+		AvailableSpace: available,
+	}
+	if total > 0 {
+		usage.UsagePercentage = float64(used) / float64(total) * 100
+	}
+	return usage, nil
+}
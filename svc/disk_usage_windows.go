@@ -0,0 +1,11 @@
+package svc
+
+import (
+	"distributed-object-storage/types"
+	"fmt"
+)
+
+// DiskUsageOf 暂不支持 windows
+func DiskUsageOf(path string) (types.DiskUsage, error) {
+	return types.DiskUsage{}, fmt.Errorf("disk usage is not supported on windows")
+}
@@ -0,0 +1,157 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testColdBucket = "cold"
+
+// testEnv svc 测试使用的本地环境：sqlite 元数据库、miniredis 对象锁，
+// 存储节点和冷存储都是内存中的 S3 兼容服务
+type testEnv struct {
+	dao   *dao.S
+	svc   *StorageNodeSvc
+	nodes map[string]*s3mem.Backend
+	cold  *s3mem.Backend
+	redis *miniredis.Miniredis
+}
+
+// newTestEnv 启动 nodes 个存储节点和一个冷存储，对象默认保存 2 个副本
+func newTestEnv(t *testing.T, nodes int) *testEnv {
+	t.Helper()
+	env := &testEnv{nodes: make(map[string]*s3mem.Backend)}
+	env.redis = miniredis.RunT(t)
+
+	var infos []types.StorageNodeInfo
+	for i := 0; i < nodes; i++ {
+		backend, url := newS3Server(t)
+		id := fmt.Sprintf("node-%d", i+1)
+		env.nodes[id] = backend
+		infos = append(infos, types.StorageNodeInfo{ID: id, Endpoint: url, Weight: 1})
+	}
+	t.Cleanup(minIo.SetStorageNodes(infos))
+
+	cold, coldURL := newS3Server(t)
+	if err := cold.CreateBucket(testColdBucket); err != nil {
+		t.Fatalf("create cold bucket: %v", err)
+	}
+	env.cold = cold
+
+	prev := config.Get()
+	config.Set(&config.Config{
+		Redis: config.RedisConfig{Addr: env.redis.Addr()},
+		Minio: config.MinioConfig{AccessKey: "test-access-key", SecretKey: "test-secret-key"},
+		OssConfig: config.OssConfig{
+			AK:           "test-access-key",
+			SK:           "test-secret-key",
+			Endpoint:     coldURL,
+			Bucket:       testColdBucket,
+			S3Compatible: true,
+		},
+		Placement: config.PlacementConfig{Replicas: 2},
+	})
+	t.Cleanup(func() { config.Set(prev) })
+	if err := redis.Init(); err != nil {
+		t.Fatalf("init redis: %v", err)
+	}
+	t.Cleanup(func() { _ = redis.Close() })
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	// 内存数据库只在同一个连接内可见
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	env.dao = dao.New(db)
+	if err = env.dao.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	env.svc = NewStorageNodeSvc(env.dao)
+	return env
+}
+
+// newS3Server 启动一个内存中的 S3 兼容服务，返回其存储和地址
+func newS3Server(t *testing.T) (*s3mem.Backend, string) {
+	t.Helper()
+	backend := s3mem.New()
+	handler := gofakes3.New(backend).Server()
+	// minio-go 递归列出对象时带空的 delimiter 参数，gofakes3 会把它当作分隔符，列不出任何对象
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if query := r.URL.Query(); query.Has("delimiter") && query.Get("delimiter") == "" {
+			query.Del("delimiter")
+			r.URL.RawQuery = query.Encode()
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return backend, server.URL
+}
+
+// putObject 通过 StorageNodeSvc 写入对象
+func (env *testEnv) putObject(t *testing.T, bucketName, objectName, content string) {
+	t.Helper()
+	_, err := env.svc.PutObject(context.Background(), bucketName, objectName, strings.NewReader(content),
+		int64(len(content)), "", types.WriteCondition{})
+	if err != nil {
+		t.Fatalf("put %s/%s: %v", bucketName, objectName, err)
+	}
+}
+
+// getObject 通过 StorageNodeSvc 读取对象的内容
+func (env *testEnv) getObject(t *testing.T, bucketName, objectName string) (string, types.ObjectInfo) {
+	t.Helper()
+	reader, info, err := env.svc.GetObject(context.Background(), bucketName, objectName)
+	if err != nil {
+		t.Fatalf("get %s/%s: %v", bucketName, objectName, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read %s/%s: %v", bucketName, objectName, err)
+	}
+	return string(data), info
+}
+
+// nodesHolding 返回保存了该对象的节点数
+func (env *testEnv) nodesHolding(bucketName, objectName string) int {
+	count := 0
+	for _, backend := range env.nodes {
+		if _, err := backend.HeadObject(bucketName, objectName); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// readBackend 读取内存存储中对象的内容
+func readBackend(t *testing.T, backend *s3mem.Backend, bucketName, objectName string) string {
+	t.Helper()
+	object, err := backend.GetObject(bucketName, objectName, nil)
+	if err != nil {
+		t.Fatalf("get %s/%s from backend: %v", bucketName, objectName, err)
+	}
+	defer object.Contents.Close()
+	data, err := io.ReadAll(object.Contents)
+	if err != nil {
+		t.Fatalf("read %s/%s from backend: %v", bucketName, objectName, err)
+	}
+	return string(data)
+}
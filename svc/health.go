@@ -0,0 +1,153 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/etcd"
+	"distributed-object-storage/pkg/db"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout 单个依赖检查的超时时间，需要小于探针的超时时间
+const healthCheckTimeout = 2 * time.Second
+
+type HealthSvc struct {
+	checks []healthCheck
+}
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) (string, error)
+}
+
+func NewHealthSvc() *HealthSvc {
+	return &HealthSvc{
+		checks: []healthCheck{
+			{name: "mysql", check: checkMySQL},
+			{name: "redis", check: checkRedis},
+			{name: "etcd", check: checkEtcd},
+			{name: "storage", check: checkStorageNodes},
+		},
+	}
+}
+
+// Ready 并发检查所有依赖，任一依赖异常时整体为 error
+func (h *HealthSvc) Ready(ctx context.Context) types.Readiness {
+	res := types.Readiness{
+		Status: types.HealthStatusOK,
+		Checks: make([]types.DependencyHealth, len(h.checks)),
+	}
+	wg := new(sync.WaitGroup)
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			msg, err := c.run(ctx)
+			dep := types.DependencyHealth{
+				Name:      c.name,
+				Status:    types.HealthStatusOK,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Message:   msg,
+			}
+			if err != nil {
+				dep.Status = types.HealthStatusError
+				dep.Error = err.Error()
+			}
+			res.Checks[i] = dep
+		}(i, c)
+	}
+	wg.Wait()
+	for _, dep := range res.Checks {
+		if dep.Status != types.HealthStatusOK {
+			res.Status = types.HealthStatusError
+		}
+	}
+	return res
+}
+
+// run 执行检查，不响应 ctx 的客户端（如读取节点列表）也会在超时后返回
+func (c healthCheck) run(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	type result struct {
+		msg string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		msg, err := c.check(ctx)
+		done <- result{msg, err}
+	}()
+	select {
+	case r := <-done:
+		return r.msg, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("check %s: %w", c.name, ctx.Err())
+	}
+}
+
+func checkMySQL(ctx context.Context) (string, error) {
+	conn := db.Db()
+	if conn == nil {
+		return "", fmt.Errorf("not connected")
+	}
+	sql, err := conn.DB()
+	if err != nil {
+		return "", err
+	}
+	return "", sql.PingContext(ctx)
+}
+
+func checkRedis(ctx context.Context) (string, error) {
+	if !redis.Initialized() {
+		return "", fmt.Errorf("not connected")
+	}
+	return "", redis.Redis().Ping(ctx).Err()
+}
+
+func checkEtcd(ctx context.Context) (string, error) {
+	cli, err := etcd.Client()
+	if err != nil {
+		return "", err
+	}
+	// 与 etcdctl endpoint health 相同，读取一个不存在的 key
+	_, err = cli.Get(ctx, "health")
+	return "", err
+}
+
+// checkStorageNodes 至少有一个存储节点健康时视为正常
+func checkStorageNodes(ctx context.Context) (string, error) {
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	var healthy int
+	var mutex sync.Mutex
+	var lastErr error
+	wg := new(sync.WaitGroup)
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node types.StorageNodeInfo) {
+			defer wg.Done()
+			err := minIo.CheckNodeHealth(ctx, node)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			healthy++
+		}(node)
+	}
+	wg.Wait()
+	msg := fmt.Sprintf("%d/%d nodes healthy", healthy, len(nodes))
+	if healthy == 0 {
+		return msg, fmt.Errorf("no healthy storage node: %v", lastErr)
+	}
+	return msg, nil
+}
@@ -0,0 +1,532 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/minio/minio-go/v7"
+	"time"
+)
+
+const (
+	lifecycleReportKey = "lifecycle:report"
+	maxLifecycleRules  = 1000
+	maxLifecycleRuleID = 255
+)
+
+// LifecycleSvc 读写桶的生命周期规则，并按规则删除过期的对象、历史版本和未完成的分片上传，将旧对象转移到冷存储
+type LifecycleSvc struct {
+	bucketDao    *dao.Bucket
+	lifecycleDao *dao.Lifecycle
+	metadataDao  *dao.MetadataNode
+	storageSvc   *StorageNodeSvc
+}
+
+func NewLifecycleSvc(s *dao.S) *LifecycleSvc {
+	return &LifecycleSvc{
+		bucketDao:    s.Bucket,
+		lifecycleDao: s.Lifecycle,
+		metadataDao:  s.MetadataNode,
+		storageSvc:   NewStorageNodeSvc(s),
+	}
+}
+
+// GetLifecycle 返回桶的生命周期规则，没有设置时 Rules 为空
+func (m *LifecycleSvc) GetLifecycle(ctx context.Context, bucketName string) (types.BucketLifecycle, error) {
+	bucket, err := getBucket(ctx, m.bucketDao, bucketName)
+	if err != nil {
+		return types.BucketLifecycle{}, err
+	}
+	return parseLifecycle(bucket.Lifecycle)
+}
+
+// PutLifecycle 整体替换桶的生命周期规则
+func (m *LifecycleSvc) PutLifecycle(ctx context.Context, bucketName string, lifecycle types.BucketLifecycle) (types.BucketLifecycle, error) {
+	if _, err := getBucket(ctx, m.bucketDao, bucketName); err != nil {
+		return lifecycle, err
+	}
+	if err := normalizeLifecycle(&lifecycle); err != nil {
+		return lifecycle, err
+	}
+	data, err := json.Marshal(lifecycle)
+	if err != nil {
+		return lifecycle, err
+	}
+	if err = m.bucketDao.UpdateLifecycle(ctx, bucketName, string(data)); err != nil {
+		return lifecycle, fmt.Errorf("update lifecycle of bucket %s: %w", bucketName, err)
+	}
+	return lifecycle, nil
+}
+
+// DeleteLifecycle 删除桶的所有生命周期规则
+func (m *LifecycleSvc) DeleteLifecycle(ctx context.Context, bucketName string) error {
+	if _, err := getBucket(ctx, m.bucketDao, bucketName); err != nil {
+		return err
+	}
+	if err := m.bucketDao.UpdateLifecycle(ctx, bucketName, ""); err != nil {
+		return fmt.Errorf("delete lifecycle of bucket %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// ListLogs 分页查询生命周期规则删除的内容
+func (m *LifecycleSvc) ListLogs(ctx context.Context, req types.ListLifecycleLogReq) (*dao.PagedData, error) {
+	page := dao.NewPageCondition(req.Current, req.PageSize)
+	logs, count, err := m.lifecycleDao.ListLogs(ctx, req.BucketName, req.Action, page)
+	if err != nil {
+		return nil, err
+	}
+	return &dao.PagedData{
+		Results:  logs,
+		Count:    count,
+		Current:  page.CurrentPage(),
+		PageSize: page.PageSize(),
+	}, nil
+}
+
+// SaveReport 保存最近一次执行的结果
+func (m *LifecycleSvc) SaveReport(ctx context.Context, report types.LifecycleReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, lifecycleReportKey, data, 0).Err()
+}
+
+// GetLastReport 返回最近一次执行的结果，还没有执行过时返回零值
+func (m *LifecycleSvc) GetLastReport(ctx context.Context) (types.LifecycleReport, error) {
+	report := types.LifecycleReport{}
+	data, err := redis.Redis().Get(ctx, lifecycleReportKey).Bytes()
+	if err == goredis.Nil {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("get lifecycle report: %w", err)
+	}
+	if err = json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("unmarshal lifecycle report: %w", err)
+	}
+	return report, nil
+}
+
+// lifecycleRun 一次执行中共享的状态
+type lifecycleRun struct {
+	now       time.Time
+	batchSize int
+	nodes     []types.StorageNodeInfo
+	report    *types.LifecycleReport
+	logs      []*dbm.LifecycleLog
+}
+
+func (r *lifecycleRun) fail(ctx context.Context, err error) {
+	r.report.Failed++
+	r.report.LastError = err.Error()
+	log.Ctx(ctx).Warnf("lifecycle: %v", err)
+}
+
+// Run 对所有设置了生命周期规则的桶执行一次规则，每删除 batchSize 个对象记录一次删除记录。
+// 单个对象删除失败只计入 Failed，失去领导权或查询元数据失败时中止。
+func (m *LifecycleSvc) Run(ctx context.Context, batchSize int) (types.LifecycleReport, error) {
+	report := types.LifecycleReport{StartedAt: time.Now()}
+	buckets, err := m.bucketDao.List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("list buckets: %w", err)
+	}
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return report, err
+	}
+	run := &lifecycleRun{now: report.StartedAt, batchSize: batchSize, nodes: nodes, report: &report}
+	for _, bucket := range buckets {
+		lifecycle, err := parseLifecycle(bucket.Lifecycle)
+		if err != nil {
+			run.fail(ctx, fmt.Errorf("bucket %s: %w", bucket.Name, err))
+			continue
+		}
+		if len(lifecycle.Rules) == 0 {
+			continue
+		}
+		report.Buckets++
+		for _, rule := range lifecycle.Rules {
+			if rule.Status != types.LifecycleRuleEnabled {
+				continue
+			}
+			if err = m.applyRule(ctx, run, bucket, rule); err != nil {
+				return report, err
+			}
+		}
+	}
+	err = m.flush(ctx, run)
+	report.FinishedAt = time.Now()
+	return report, err
+}
+
+func (m *LifecycleSvc) applyRule(ctx context.Context, run *lifecycleRun, bucket *dbm.Bucket, rule types.LifecycleRule) error {
+	if rule.Expiration != nil {
+		if err := m.expireObjects(ctx, run, bucket.Name, rule); err != nil {
+			return err
+		}
+	}
+	// 先删除过期的对象，避免把马上过期的对象转移到冷存储
+	if rule.Transition != nil {
+		if err := m.transitionObjects(ctx, run, bucket.Name, rule); err != nil {
+			return err
+		}
+	}
+	// 历史版本只存在于开启过版本控制的桶中
+	if rule.NoncurrentVersionExpirationDays > 0 && bucket.Versioning != types.BucketVersioningOff {
+		if err := m.expireNoncurrentVersions(ctx, run, bucket.Name, rule); err != nil {
+			return err
+		}
+	}
+	if rule.AbortIncompleteMultipartUploadDays > 0 {
+		if err := m.abortIncompleteUploads(ctx, run, bucket.Name, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record 记录一条删除记录，攒够一批后写入数据库
+func (m *LifecycleSvc) record(ctx context.Context, run *lifecycleRun, entry *dbm.LifecycleLog) error {
+	entry.CreatedAt = time.Now()
+	run.logs = append(run.logs, entry)
+	if len(run.logs) < run.batchSize {
+		return nil
+	}
+	return m.flush(ctx, run)
+}
+
+func (m *LifecycleSvc) flush(ctx context.Context, run *lifecycleRun) error {
+	if err := m.lifecycleDao.CreateLogs(context.WithoutCancel(ctx), run.logs); err != nil {
+		return fmt.Errorf("save lifecycle logs: %w", err)
+	}
+	run.logs = run.logs[:0]
+	return nil
+}
+
+// expireObjects 分批删除最后修改时间早于过期时间的当前版本。
+// 删除时要求对象的 ETag 与查询时一致，期间被重新写入的对象不会被删除。
+func (m *LifecycleSvc) expireObjects(ctx context.Context, run *lifecycleRun, bucketName string, rule types.LifecycleRule) error {
+	cutoff, ok := rule.Expiration.Cutoff(run.now)
+	if !ok {
+		return nil
+	}
+	var afterID uint
+	for {
+		if err := fencing.Check(ctx); err != nil {
+			return err
+		}
+		batch, err := m.metadataDao.ListObjectMetadataBefore(ctx, bucketName, rule.Filter.Prefix, cutoff, afterID, run.batchSize)
+		if err != nil {
+			return fmt.Errorf("list expired objects of bucket %s: %w", bucketName, err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		afterID = batch[len(batch)-1].Id
+		for _, meta := range batch {
+			matched, err := m.matchTags(ctx, run, meta, rule.Filter.Tags)
+			if err != nil {
+				run.fail(ctx, err)
+				continue
+			}
+			if !matched {
+				continue
+			}
+			err = m.storageSvc.DeleteObject(ctx, bucketName, meta.ObjectName, types.WriteCondition{IfMatch: meta.ETag})
+			if errors.Is(err, errors.ErrPreconditionFailed) {
+				continue
+			}
+			if err != nil {
+				run.fail(ctx, fmt.Errorf("expire %s/%s: %w", bucketName, meta.ObjectName, err))
+				continue
+			}
+			run.report.Expired++
+			run.report.ExpiredBytes += meta.Size
+			err = m.record(ctx, run, &dbm.LifecycleLog{
+				BucketName: bucketName,
+				ObjectName: meta.ObjectName,
+				VersionID:  meta.VersionID,
+				Action:     types.LifecycleActionExpire,
+				RuleID:     rule.ID,
+				Size:       meta.Size,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// transitionObjects 将最后修改时间早于规则天数的对象转移到冷存储
+func (m *LifecycleSvc) transitionObjects(ctx context.Context, run *lifecycleRun, bucketName string, rule types.LifecycleRule) error {
+	cutoff := run.now.AddDate(0, 0, -rule.Transition.Days)
+	var afterID uint
+	for {
+		if err := fencing.Check(ctx); err != nil {
+			return err
+		}
+		batch, err := m.metadataDao.ListObjectMetadataBefore(ctx, bucketName, rule.Filter.Prefix, cutoff, afterID, run.batchSize)
+		if err != nil {
+			return fmt.Errorf("list objects to transition of bucket %s: %w", bucketName, err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		afterID = batch[len(batch)-1].Id
+		for _, meta := range batch {
+			if meta.IsCold() {
+				continue
+			}
+			matched, err := m.matchTags(ctx, run, meta, rule.Filter.Tags)
+			if err != nil {
+				run.fail(ctx, err)
+				continue
+			}
+			if !matched {
+				continue
+			}
+			err = m.storageSvc.TransitionObject(ctx, bucketName, meta.ObjectName, meta.ETag)
+			if errors.Is(err, errors.ErrPreconditionFailed) {
+				continue
+			}
+			if err != nil {
+				run.fail(ctx, fmt.Errorf("transition %s/%s: %w", bucketName, meta.ObjectName, err))
+				continue
+			}
+			run.report.Transitioned++
+			run.report.TransitionedBytes += meta.Size
+			err = m.record(ctx, run, &dbm.LifecycleLog{
+				BucketName: bucketName,
+				ObjectName: meta.ObjectName,
+				VersionID:  meta.VersionID,
+				Action:     types.LifecycleActionTransition,
+				RuleID:     rule.ID,
+				Size:       meta.Size,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// matchTags 对象的标签是否包含 want 中的所有标签，冷存储中的对象不保留标签，带标签条件的规则不匹配
+func (m *LifecycleSvc) matchTags(ctx context.Context, run *lifecycleRun, meta *dbm.ObjectMetadata, want map[string]string) (bool, error) {
+	if len(want) == 0 {
+		return true, nil
+	}
+	if meta.IsCold() {
+		return false, nil
+	}
+	var lastErr error
+	for _, id := range meta.Nodes() {
+		for _, node := range run.nodes {
+			if node.ID != id {
+				continue
+			}
+			tags, err := minIo.GetNodeClient(node).MinioCore.GetObjectTagging(ctx, meta.BucketName, meta.ObjectName, minio.GetObjectTaggingOptions{})
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			have := tags.ToMap()
+			for k, v := range want {
+				if have[k] != v {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no live replica on %s", meta.StorageNodes)
+	}
+	return false, fmt.Errorf("get tags of %s/%s: %w", meta.BucketName, meta.ObjectName, lastErr)
+}
+
+// expireNoncurrentVersions 删除各节点上成为历史版本超过规定天数的版本。
+// 版本成为历史版本的时间取其后一个版本的最后修改时间。
+func (m *LifecycleSvc) expireNoncurrentVersions(ctx context.Context, run *lifecycleRun, bucketName string, rule types.LifecycleRule) error {
+	cutoff := run.now.AddDate(0, 0, -rule.NoncurrentVersionExpirationDays)
+	for _, node := range run.nodes {
+		if err := m.expireNodeVersions(ctx, run, node, bucketName, rule, cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *LifecycleSvc) expireNodeVersions(ctx context.Context, run *lifecycleRun, node types.StorageNodeInfo, bucketName string,
+	rule types.LifecycleRule, cutoff time.Time) error {
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	client := minIo.GetNodeClient(node)
+	objects := client.MinioCore.Client.ListObjects(listCtx, bucketName, minio.ListObjectsOptions{
+		Prefix:       rule.Filter.Prefix,
+		Recursive:    true,
+		WithVersions: true,
+	})
+	// 同一对象的版本从新到旧连续返回
+	key, successor := "", time.Time{}
+	removed := 0
+	for object := range objects {
+		if object.Err != nil {
+			if minio.ToErrorResponse(object.Err).Code == "NoSuchBucket" {
+				return nil
+			}
+			run.fail(ctx, fmt.Errorf("list versions of bucket %s on node %s: %w", bucketName, node.ID, object.Err))
+			return nil
+		}
+		noncurrentSince := successor
+		if object.Key != key {
+			noncurrentSince = time.Time{}
+		}
+		key, successor = object.Key, object.LastModified
+		if object.IsLatest || noncurrentSince.IsZero() || noncurrentSince.After(cutoff) {
+			continue
+		}
+		if removed%run.batchSize == 0 {
+			if err := fencing.Check(ctx); err != nil {
+				return err
+			}
+		}
+		err := client.MinioCore.RemoveObject(ctx, bucketName, object.Key, minio.RemoveObjectOptions{VersionID: object.VersionID})
+		if err != nil {
+			run.fail(ctx, fmt.Errorf("remove version %s of %s/%s on node %s: %w", object.VersionID, bucketName, object.Key, node.ID, err))
+			continue
+		}
+		removed++
+		run.report.NoncurrentRemoved++
+		err = m.record(ctx, run, &dbm.LifecycleLog{
+			BucketName: bucketName,
+			ObjectName: object.Key,
+			VersionID:  object.VersionID,
+			NodeID:     node.ID,
+			Action:     types.LifecycleActionNoncurrent,
+			RuleID:     rule.ID,
+			Size:       object.Size,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abortIncompleteUploads 取消各节点上开始超过规定天数仍未完成的分片上传
+func (m *LifecycleSvc) abortIncompleteUploads(ctx context.Context, run *lifecycleRun, bucketName string, rule types.LifecycleRule) error {
+	cutoff := run.now.AddDate(0, 0, -rule.AbortIncompleteMultipartUploadDays)
+	for _, node := range run.nodes {
+		client := minIo.GetNodeClient(node)
+		keyMarker, uploadIDMarker := "", ""
+		for {
+			if err := fencing.Check(ctx); err != nil {
+				return err
+			}
+			result, err := client.MinioCore.ListMultipartUploads(ctx, bucketName, rule.Filter.Prefix, keyMarker, uploadIDMarker, "", run.batchSize)
+			if err != nil {
+				if minio.ToErrorResponse(err).Code != "NoSuchBucket" {
+					run.fail(ctx, fmt.Errorf("list uploads of bucket %s on node %s: %w", bucketName, node.ID, err))
+				}
+				break
+			}
+			for _, upload := range result.Uploads {
+				if upload.Initiated.After(cutoff) {
+					continue
+				}
+				if err = client.MinioCore.AbortMultipartUpload(ctx, bucketName, upload.Key, upload.UploadID); err != nil {
+					run.fail(ctx, fmt.Errorf("abort upload %s of %s/%s on node %s: %w", upload.UploadID, bucketName, upload.Key, node.ID, err))
+					continue
+				}
+				run.report.UploadsAborted++
+				err = m.record(ctx, run, &dbm.LifecycleLog{
+					BucketName: bucketName,
+					ObjectName: upload.Key,
+					UploadID:   upload.UploadID,
+					NodeID:     node.ID,
+					Action:     types.LifecycleActionAbortUpload,
+					RuleID:     rule.ID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			if !result.IsTruncated {
+				break
+			}
+			keyMarker, uploadIDMarker = result.NextKeyMarker, result.NextUploadIDMarker
+		}
+	}
+	return nil
+}
+
+func parseLifecycle(data string) (types.BucketLifecycle, error) {
+	lifecycle := types.BucketLifecycle{Rules: []types.LifecycleRule{}}
+	if data == "" {
+		return lifecycle, nil
+	}
+	if err := json.Unmarshal([]byte(data), &lifecycle); err != nil {
+		return lifecycle, fmt.Errorf("unmarshal lifecycle: %w", err)
+	}
+	return lifecycle, nil
+}
+
+// normalizeLifecycle 补齐规则 ID 和状态的默认值并检查规则
+func normalizeLifecycle(lifecycle *types.BucketLifecycle) error {
+	if len(lifecycle.Rules) > maxLifecycleRules {
+		return fmt.Errorf("%w: at most %d lifecycle rules", errors.ErrBadRequest, maxLifecycleRules)
+	}
+	if lifecycle.Rules == nil {
+		lifecycle.Rules = []types.LifecycleRule{}
+	}
+	ids := make(map[string]bool)
+	for i := range lifecycle.Rules {
+		rule := &lifecycle.Rules[i]
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.ID) > maxLifecycleRuleID || ids[rule.ID] {
+			return fmt.Errorf("%w: rule id %q is too long or duplicated", errors.ErrBadRequest, rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Status == "" {
+			rule.Status = types.LifecycleRuleEnabled
+		}
+		if rule.Status != types.LifecycleRuleEnabled && rule.Status != types.LifecycleRuleDisabled {
+			return fmt.Errorf("%w: rule %s status %q is not one of Enabled, Disabled", errors.ErrBadRequest, rule.ID, rule.Status)
+		}
+		if rule.Expiration == nil && rule.Transition == nil && rule.NoncurrentVersionExpirationDays == 0 && rule.AbortIncompleteMultipartUploadDays == 0 {
+			return fmt.Errorf("%w: rule %s has no action", errors.ErrBadRequest, rule.ID)
+		}
+		if rule.NoncurrentVersionExpirationDays < 0 || rule.AbortIncompleteMultipartUploadDays < 0 {
+			return fmt.Errorf("%w: rule %s days must not be negative", errors.ErrBadRequest, rule.ID)
+		}
+		if e := rule.Expiration; e != nil && (e.Days < 0 || (e.Days > 0) == (e.Date != nil)) {
+			return fmt.Errorf("%w: rule %s expiration needs exactly one of days and date", errors.ErrBadRequest, rule.ID)
+		}
+		if t := rule.Transition; t != nil {
+			if t.StorageClass == "" {
+				t.StorageClass = types.StorageClassCold
+			}
+			if t.Days <= 0 || t.StorageClass != types.StorageClassCold {
+				return fmt.Errorf("%w: rule %s transition needs positive days and storage class COLD", errors.ErrBadRequest, rule.ID)
+			}
+			if !config.GetOss().ColdTierEnabled() {
+				return fmt.Errorf("%w: rule %s transition needs a configured cold tier", errors.ErrBadRequest, rule.ID)
+			}
+		}
+	}
+	return nil
+}
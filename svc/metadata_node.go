@@ -3,50 +3,31 @@ package svc
 import (
 	"context"
 	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
 	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
 	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/tracing"
 	"distributed-object-storage/types"
 	"fmt"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/minio/minio-go/v7"
-	"math/rand"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-var regions = []string{
-	"us-east-1",
-	"us-east-2",
-	"us-west-1",
-	"us-west-2",
-	"ca-central-1",
-	"eu-west-1",
-	"eu-west-2",
-	"eu-west-3",
-	"eu-central-1",
-	"eu-north-1",
-	"ap-east-1",
-	"ap-south-1",
-	"ap-southeast-1",
-	"ap-southeast-2",
-	"ap-northeast-1",
-	"ap-northeast-2",
-	"ap-northeast-3",
-	"me-south-1",
-	"sa-east-1",
-	"us-gov-west-1",
-	"us-gov-east-1",
-	"cn-north-1",
-	"cn-northwest-1",
-}
-
 type MetadataNode interface {
 	CreateObjectMetadata(ctx context.Context, meta types.ObjectMetadata) error
 	GetObjectMetadata(ctx context.Context, bucketName, objectName string) (types.ObjectMetadata, error)
 	UpdateObjectMetadata(ctx context.Context, meta types.ObjectMetadata) error
 	DeleteObjectMetadata(ctx context.Context, bucketName, objectName string) error
-	CreateBucket(ctx context.Context, bucketName string) error
+	CreateBucket(ctx context.Context, cfg types.BucketConfig) error
 	DeleteBucket(ctx context.Context, bucketName string) error
 	ListBuckets(ctx context.Context, prefix string, maxKeys int) ([]types.BucketInfo, error)
 	ListObjects(ctx context.Context, bucketName string, prefix string, maxKeys int) ([]types.ObjectInfo, error)
@@ -54,28 +35,30 @@ type MetadataNode interface {
 	GetObjectVersions(ctx context.Context, bucketName, objectName string) ([]types.ObjectMetadata, error)
 	InitiateMultipartUpload(ctx context.Context, bucketName, objectName string) (string, error)
 	CompleteMultipartUpload(ctx context.Context, bucketName, objectName, uploadID string, parts []types.CompletedPart) error
-	RecordObjectCopy(ctx context.Context, sourceBucket, sourceObject, destBucket, destObject string) error
-	RecordObjectMigration(ctx context.Context, bucketName, objectName string, fromNode, toNode string) error
 }
 
 type MetadataSvc struct {
 	MetaDataDao *dao.MetadataNode
+	BucketSvc   *BucketSvc
 }
 
 func NewMetadataSvc(s *dao.S) *MetadataSvc {
 	return &MetadataSvc{
 		MetaDataDao: s.MetadataNode,
+		BucketSvc:   NewBucketSvc(s),
 	}
 }
 
 func (m *MetadataSvc) CreateObjectMetadata(ctx context.Context, meta types.ObjectMetadata) error {
-	//TODO implement me
-	panic("implement me")
+	return m.MetaDataDao.SaveObjectMetadata(ctx, toObjectMetadataModel(meta))
 }
 
-func (m *MetadataSvc) GetObjectMetadata(ctx context.Context, bucketName, objectName string) (types.ObjectMetadata, error) {
+func (m *MetadataSvc) GetObjectMetadata(ctx context.Context, bucketName, objectName string) (_ types.ObjectMetadata, err error) {
+	ctx, span := tracing.Start(ctx, "MetadataSvc.GetObjectMetadata", tracing.Object(bucketName, objectName)...)
+	defer func() { tracing.End(span, err) }()
+
 	res := types.ObjectMetadata{}
-	ossConfig := config.ConfigDetail.OssConfig
+	ossConfig := config.GetOss()
 
 	// 创建OSS客户端
 	client, err := oss.New(ossConfig.Endpoint, ossConfig.AK, ossConfig.SK)
@@ -92,7 +75,7 @@ func (m *MetadataSvc) GetObjectMetadata(ctx context.Context, bucketName, objectN
 	// 获取对象详细元数据
 	props, err := bucket.GetObjectDetailedMeta(objectName)
 	if err != nil {
-		return res, fmt.Errorf("failed to get object metadata: %v", err)
+		return res, fmt.Errorf("failed to get object metadata: %w", ossError(err))
 	}
 
 	// 提取并处理元数据
@@ -124,94 +107,156 @@ func (m *MetadataSvc) GetObjectMetadata(ctx context.Context, bucketName, objectN
 }
 
 func (m *MetadataSvc) UpdateObjectMetadata(ctx context.Context, meta types.ObjectMetadata) error {
-	//TODO implement me
-	panic("implement me")
+	return m.MetaDataDao.SaveObjectMetadata(ctx, toObjectMetadataModel(meta))
 }
 
 func (m *MetadataSvc) DeleteObjectMetadata(ctx context.Context, bucketName, objectName string) error {
-	//TODO implement me
-	panic("implement me")
+	return m.MetaDataDao.DeleteObjectMetadata(ctx, bucketName, objectName)
 }
 
-func (m *MetadataSvc) CreateBucket(ctx context.Context, bucketName string) error {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
-	if client == nil {
-		return fmt.Errorf("minio client is nil")
+// CreateBucket 在注册表中登记桶的配置并在所有存储节点上创建桶，节点上创建失败时撤销登记
+func (m *MetadataSvc) CreateBucket(ctx context.Context, cfg types.BucketConfig) (err error) {
+	ctx, span := tracing.Start(ctx, "MetadataSvc.CreateBucket", attribute.String("bucket", cfg.Name))
+	defer func() { tracing.End(span, err) }()
+
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err = m.BucketSvc.register(ctx, &cfg); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = m.BucketSvc.unregister(context.WithoutCancel(ctx), cfg.Name)
+		}
+	}()
+	// 对象可能被放置到任意节点，桶需要在所有节点上创建
+	for _, node := range nodes {
+		client := minIo.GetNodeClient(node)
+		err = client.MinioCore.MakeBucket(ctx, cfg.Name, minio.MakeBucketOptions{
+			Region:        cfg.Region,
+			ObjectLocking: false,
+		})
+		if err != nil {
+			return fmt.Errorf("create bucket on node %s: %w", node.ID, minIo.ToError(err))
+		}
+		if err = client.SetVersioning(ctx, cfg.Name, cfg.Versioning); err != nil {
+			return fmt.Errorf("set versioning of bucket %s on node %s: %w", cfg.Name, node.ID, minIo.ToError(err))
+		}
 	}
-	region := regions[rand.Intn(len(regions))]
-	return client.MinioCore.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{
-		Region:        region,
-		ObjectLocking: false,
-	})
+	return nil
 }
 
-func (m *MetadataSvc) DeleteBucket(ctx context.Context, bucketName string) error {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
-	if client == nil {
-		return fmt.Errorf("minio client is nil")
+// DeleteBucket 删除所有节点上的桶，桶不为空时返回 BucketNotEmpty。清空桶由 DeleteJobSvc.CreateBucketJob 创建的任务完成
+func (m *MetadataSvc) DeleteBucket(ctx context.Context, bucketName string) (err error) {
+	ctx, span := tracing.Start(ctx, "MetadataSvc.DeleteBucket", attribute.String("bucket", bucketName))
+	defer func() { tracing.End(span, err) }()
+
+	// 冷存储中的对象不在节点上，节点上的桶为空时也要拒绝删除
+	cold, err := m.MetaDataDao.HasColdObjects(ctx, bucketName)
+	if err != nil {
+		return fmt.Errorf("check cold objects of bucket %s: %w", bucketName, err)
 	}
-	return client.MinioCore.RemoveBucket(ctx, bucketName)
+	if cold {
+		return errors.WithCode(errors.CodeBucketNotEmpty, "bucket %s has objects in cold tier", bucketName)
+	}
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		client := minIo.GetNodeClient(node)
+		err = client.MinioCore.RemoveBucket(ctx, bucketName)
+		if err != nil && minio.ToErrorResponse(err).Code != "NoSuchBucket" {
+			return fmt.Errorf("delete bucket on node %s: %w", node.ID, minIo.ToError(err))
+		}
+	}
+	return m.BucketSvc.unregister(ctx, bucketName)
 }
 
-func (m *MetadataSvc) ListBuckets(ctx context.Context, prefix string, maxKeys int) ([]types.BucketInfo, error) {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
+// ListBuckets 按桶名顺序列出各节点上名称以 prefix 开头的桶，maxKeys > 0 时最多返回 maxKeys 个
+func (m *MetadataSvc) ListBuckets(ctx context.Context, prefix string, maxKeys int) (_ []types.BucketInfo, err error) {
+	ctx, span := tracing.Start(ctx, "MetadataSvc.ListBuckets")
+	defer func() { tracing.End(span, err) }()
+
 	res := make([]types.BucketInfo, 0)
-	buckets, err := client.MinioCore.ListBuckets(ctx)
+	nodes, err := minIo.GetStorageNodesContext(ctx)
 	if err != nil {
 		return res, err
 	}
-	for _, bucket := range buckets {
-		region, _ := client.MinioCore.GetBucketLocation(ctx, bucket.Name)
-		bucketInfo := types.BucketInfo{
-			Name:         bucket.Name,
-			CreationDate: bucket.CreationDate,
-			Owner:        "",
-			Location:     "127.0.0.1:9000",
-			StorageClass: "",
-			Region:       region,
+	registered, err := m.BucketSvc.list(ctx, prefix)
+	if err != nil {
+		return res, err
+	}
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		client := minIo.GetNodeClient(node)
+		buckets, err := client.MinioCore.ListBuckets(ctx)
+		if err != nil {
+			return res, minIo.ToError(err)
 		}
-		res = append(res, bucketInfo)
+		for _, bucket := range buckets {
+			if seen[bucket.Name] || !strings.HasPrefix(bucket.Name, prefix) {
+				continue
+			}
+			seen[bucket.Name] = true
+			bucketInfo := types.BucketInfo{
+				Name:         bucket.Name,
+				CreationDate: bucket.CreationDate,
+				Location:     node.Host(),
+			}
+			// 已登记的桶使用注册表中的配置，注册表出现之前创建的桶从节点查询区域
+			if cfg, ok := registered[bucket.Name]; ok {
+				bucketInfo.CreationDate = cfg.CreationDate
+				bucketInfo.Owner = cfg.Owner
+				bucketInfo.StorageClass = cfg.StorageClass
+				bucketInfo.Region = cfg.Region
+			} else {
+				bucketInfo.Region, _ = client.MinioCore.GetBucketLocation(ctx, bucket.Name)
+				bucketInfo.StorageClass = types.StorageClassStandard
+			}
+			res = append(res, bucketInfo)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	if maxKeys > 0 && len(res) > maxKeys {
+		res = res[:maxKeys]
 	}
 	return res, nil
 }
 
-func (m *MetadataSvc) ListObjects(ctx context.Context, bucketName string, prefix string, maxKeys int) ([]types.ObjectInfo, error) {
-	//res := make([]types.ObjectInfo, 0)
-	client := minIo.GetMinioClient("127.0.0.1:9000")
+// ListObjects 按对象名顺序列出桶内以 prefix 开头的对象，只列出有元数据的对象，
+// 节点上没有元数据的数据（写入未完成或删除未完成）不会被列出。maxKeys 为每次查询的条数
+func (m *MetadataSvc) ListObjects(ctx context.Context, bucketName string, prefix string, maxKeys int) (_ []types.ObjectInfo, err error) {
+	ctx, span := tracing.Start(ctx, "MetadataSvc.ListObjects", attribute.String("bucket", bucketName), attribute.String("prefix", prefix))
+	defer func() { tracing.End(span, err) }()
 
 	if maxKeys <= 0 {
 		maxKeys = 100 // 设置默认值
 	}
-	var allObjects []minio.ObjectInfo
-	continuationToken := ""
+	res := make([]types.ObjectInfo, 0)
+	after := ""
 	for {
-		result, err := client.MinioCore.ListObjectsV2(bucketName, prefix, "", continuationToken, "", maxKeys)
+		batch, err := m.MetaDataDao.ListObjectMetadataByPrefix(ctx, bucketName, prefix, after, maxKeys)
 		if err != nil {
-			return nil, err
-		}
-
-		allObjects = append(allObjects, result.Contents...)
-		// 检查是否还有更多对象
-		if len(result.Contents) < maxKeys {
-			break // 没有更多对象了
+			return nil, fmt.Errorf("list objects of bucket %s: %w", bucketName, err)
 		}
-		continuationToken = result.NextContinuationToken // 更新 continuationToken
-	}
-	res := make([]types.ObjectInfo, 0)
-	for _, object := range allObjects {
-		objectInfo := types.ObjectInfo{
-			Name:         object.Key,
-			ETag:         strings.Trim(object.ETag, "\""),
-			Size:         object.Size,
-			LastModified: object.LastModified,
-			StorageClass: object.StorageClass,
+		for _, meta := range batch {
+			res = append(res, types.ObjectInfo{
+				Name:         meta.ObjectName,
+				ETag:         meta.ETag,
+				Size:         meta.Size,
+				LastModified: meta.LastModified,
+				StorageClass: meta.StorageClass,
+				Header:       make(map[string][]string),
+			})
 		}
-		if len(object.Metadata) == 0 {
-			objectInfo.Header = make(map[string][]string)
+		if len(batch) < maxKeys {
+			return res, nil
 		}
-		res = append(res, objectInfo)
+		after = batch[len(batch)-1].ObjectName
 	}
-	return res, nil
 }
 
 func (m *MetadataSvc) PutObjectVersion(ctx context.Context, meta types.ObjectMetadata) error {
@@ -234,12 +279,106 @@ func (m *MetadataSvc) CompleteMultipartUpload(ctx context.Context, bucketName, o
 	panic("implement me")
 }
 
-func (m *MetadataSvc) RecordObjectCopy(ctx context.Context, sourceBucket, sourceObject, destBucket, destObject string) error {
-	//TODO implement me
-	panic("implement me")
+// CommitObjectMigration 在对象锁内提交迁移结果：added 中的节点加入副本列表，removed 中的节点移出副本列表并删除其上的副本。
+// 复制期间对象被重写、删除或转移到冷存储时，复制的数据已经过期，删除元数据中没有记录的副本后返回 ErrPreconditionFailed。
+func (m *MetadataSvc) CommitObjectMigration(ctx context.Context, migrated *dbm.ObjectMetadata, added, removed []string,
+	nodes map[string]types.StorageNodeInfo) error {
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: migrated.BucketName, log.FieldObject: migrated.ObjectName})
+	lock, err := lockObject(ctx, migrated.BucketName, migrated.ObjectName)
+	if err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	meta, err := m.MetaDataDao.GetObjectMetadata(ctx, migrated.BucketName, migrated.ObjectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		meta, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("get object metadata %s/%s: %w", migrated.BucketName, migrated.ObjectName, err)
+	}
+	if meta == nil || meta.ETag != migrated.ETag || meta.IsCold() {
+		recorded := make(map[string]bool)
+		if meta != nil {
+			for _, id := range meta.Nodes() {
+				recorded[id] = true
+			}
+		}
+		// 新的写入可能也放置到了同一节点，只删除当前元数据中没有记录的副本
+		for _, id := range added {
+			if !recorded[id] {
+				removeMigratedReplica(ctx, nodes, id, migrated)
+			}
+		}
+		return fmt.Errorf("%w: %s/%s changed during migration", errors.ErrPreconditionFailed, migrated.BucketName, migrated.ObjectName)
+	}
+
+	drop := make(map[string]bool)
+	for _, id := range append(added, removed...) {
+		drop[id] = true
+	}
+	list := make([]string, 0, len(meta.Nodes())+len(added))
+	for _, id := range meta.Nodes() {
+		if !drop[id] {
+			list = append(list, id)
+		}
+	}
+	meta.SetNodes(append(list, added...))
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return err
+	}
+	if err = m.MetaDataDao.UpdateStorageNodes(ctx, meta.Id, meta.StorageNodes); err != nil {
+		return fmt.Errorf("update storage nodes of %s/%s: %w", meta.BucketName, meta.ObjectName, err)
+	}
+	for _, id := range removed {
+		removeMigratedReplica(ctx, nodes, id, meta)
+	}
+	return nil
 }
 
-func (m *MetadataSvc) RecordObjectMigration(ctx context.Context, bucketName, objectName string, fromNode, toNode string) error {
-	//TODO implement me
-	panic("implement me")
+// removeMigratedReplica 删除 id 节点上的副本，节点已下线时跳过，失败只记录日志
+func removeMigratedReplica(ctx context.Context, nodes map[string]types.StorageNodeInfo, id string, meta *dbm.ObjectMetadata) {
+	node, ok := nodes[id]
+	if !ok {
+		return
+	}
+	err := minIo.GetNodeClient(node).MinioCore.RemoveObject(ctx, meta.BucketName, meta.ObjectName, minio.RemoveObjectOptions{})
+	if err != nil {
+		log.Ctx(ctx).WithField(log.FieldNode, id).Warnf("remove replica failed: %v", err)
+	}
+}
+
+func toObjectMetadataModel(meta types.ObjectMetadata) *dbm.ObjectMetadata {
+	model := &dbm.ObjectMetadata{
+		BucketName:        meta.BucketName,
+		ObjectName:        meta.ObjectName,
+		Size:              meta.Size,
+		ContentType:       meta.ContentType,
+		ETag:              meta.ETag,
+		LastModified:      meta.LastModified,
+		VersionID:         meta.VersionID,
+		IsLatest:          meta.IsLatest,
+		StorageClass:      meta.StorageClass,
+		TierKey:           meta.TierKey,
+		ReplicationStatus: meta.ReplicationStatus,
+	}
+	model.SetNodes(meta.StorageNodes)
+	return model
+}
+
+// ossError 为 OSS 返回的错误加上对应的错误码，HEAD 请求的错误没有响应体，按状态码判断
+func ossError(err error) error {
+	var serviceErr oss.ServiceError
+	if !errors.As(err, &serviceErr) {
+		return err
+	}
+	switch {
+	case serviceErr.Code == "NoSuchBucket":
+		return errors.WrapC(err, errors.CodeNoSuchBucket, "%v", err)
+	case serviceErr.StatusCode == http.StatusNotFound:
+		return errors.WrapC(err, errors.CodeNoSuchKey, "%v", err)
+	case serviceErr.StatusCode == http.StatusForbidden:
+		return errors.WrapC(err, errors.CodeAccessDenied, "%v", err)
+	}
+	return err
 }
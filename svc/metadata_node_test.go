@@ -0,0 +1,161 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"strings"
+	"testing"
+)
+
+// migrateToSpare 将对象复制到一个没有副本的节点，返回复制前的元数据、新节点和原有的一个节点
+func migrateToSpare(t *testing.T, env *testEnv, bucketName, objectName string) (*dbm.ObjectMetadata, string, string, map[string]types.StorageNodeInfo) {
+	t.Helper()
+	ctx := context.Background()
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, bucketName, objectName)
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	list, err := minIo.GetStorageNodes()
+	if err != nil {
+		t.Fatalf("get storage nodes: %v", err)
+	}
+	nodes := make(map[string]types.StorageNodeInfo)
+	for _, node := range list {
+		nodes[node.ID] = node
+	}
+	recorded := meta.Nodes()
+	for _, node := range list {
+		if node.ID == recorded[0] || node.ID == recorded[1] {
+			continue
+		}
+		_, err = minIo.GetNodeClient(node).CopyObjectFrom(ctx, minIo.GetNodeClient(nodes[recorded[0]]), bucketName, objectName)
+		if err != nil {
+			t.Fatalf("copy to %s: %v", node.ID, err)
+		}
+		return meta, node.ID, recorded[0], nodes
+	}
+	t.Fatal("no spare node")
+	return nil, "", "", nil
+}
+
+func TestCommitObjectMigration(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "v1")
+	meta, to, from, nodes := migrateToSpare(t, env, "photos", "a.txt")
+
+	if err := NewMetadataSvc(env.dao).CommitObjectMigration(ctx, meta, []string{to}, []string{from}, nodes); err != nil {
+		t.Fatalf("commit migration: %v", err)
+	}
+	after, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, id := range after.Nodes() {
+		got[id] = true
+	}
+	if len(got) != 2 || !got[to] || got[from] {
+		t.Fatalf("nodes after migration = %v, want %s instead of %s", after.Nodes(), to, from)
+	}
+	if _, err = env.nodes[from].HeadObject("photos", "a.txt"); err == nil {
+		t.Fatalf("replica on %s not removed", from)
+	}
+}
+
+func TestCommitObjectMigrationRejectsModifiedObject(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "v1")
+	meta, to, from, nodes := migrateToSpare(t, env, "photos", "a.txt")
+	// 复制期间对象被重新写入
+	env.putObject(t, "photos", "a.txt", "v2 with new content")
+	current, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+
+	err = NewMetadataSvc(env.dao).CommitObjectMigration(ctx, meta, []string{to}, []string{from}, nodes)
+	if !errors.Is(err, errors.ErrPreconditionFailed) {
+		t.Fatalf("commit migration = %v, want ErrPreconditionFailed", err)
+	}
+	after, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if after.StorageNodes != current.StorageNodes || after.ETag != current.ETag {
+		t.Fatalf("metadata changed to %s on %s", after.ETag, after.StorageNodes)
+	}
+	// 新的写入中记录的副本都保留，过期的副本被删除
+	for _, id := range after.Nodes() {
+		if got := readBackend(t, env.nodes[id], "photos", "a.txt"); got != "v2 with new content" {
+			t.Fatalf("replica on %s = %q", id, got)
+		}
+	}
+	if !containsNode(after.Nodes(), to) {
+		if _, err = env.nodes[to].HeadObject("photos", "a.txt"); err == nil {
+			t.Fatalf("stale copy on %s not removed", to)
+		}
+	}
+}
+
+func containsNode(nodes []string, id string) bool {
+	for _, node := range nodes {
+		if node == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestListObjectsFromMetadata(t *testing.T) {
+	env := newTestEnv(t, 2)
+	env.putObject(t, "photos", "a.txt", "a")
+	env.putObject(t, "photos", "b.txt", "b")
+	env.putObject(t, "photos", "c.txt", "c")
+	// 没有元数据的数据不列出
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-1"}, "photos", "orphan.txt", "orphan")
+
+	objects, err := NewMetadataSvc(env.dao).ListObjects(context.Background(), "photos", "", 2)
+	if err != nil {
+		t.Fatalf("list objects: %v", err)
+	}
+	var names []string
+	for _, object := range objects {
+		names = append(names, object.Name)
+	}
+	if len(names) != 3 || names[0] != "a.txt" || names[1] != "b.txt" || names[2] != "c.txt" {
+		t.Fatalf("objects = %v, want a.txt, b.txt, c.txt", names)
+	}
+}
+
+func TestListBucketsByPrefix(t *testing.T) {
+	env := newTestEnv(t, 2)
+	for _, name := range []string{"videos", "photos", "photo-archive"} {
+		createBucket(t, env, types.BucketConfig{Name: name})
+	}
+	for _, c := range []struct {
+		prefix  string
+		maxKeys int
+		want    []string
+	}{
+		{prefix: "photo", want: []string{"photo-archive", "photos"}},
+		{prefix: "photo", maxKeys: 1, want: []string{"photo-archive"}},
+		{want: []string{"photo-archive", "photos", "videos"}},
+	} {
+		buckets, err := NewMetadataSvc(env.dao).ListBuckets(context.Background(), c.prefix, c.maxKeys)
+		if err != nil {
+			t.Fatalf("list buckets: %v", err)
+		}
+		var names []string
+		for _, bucket := range buckets {
+			names = append(names, bucket.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(c.want, ",") {
+			t.Errorf("list buckets %q, %d = %v, want %v", c.prefix, c.maxKeys, names, c.want)
+		}
+	}
+}
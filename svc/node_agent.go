@@ -0,0 +1,125 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/etcd"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"time"
+)
+
+// NodeAgent 运行在存储节点上，定期检查本机 minio 是否健康，并把节点信息和磁盘使用情况注册到 etcd。
+// 注册信息绑定租约，agent 退出或节点不健康时 key 被删除，网关不再向该节点放置对象。
+type NodeAgent struct {
+	Node types.StorageNodeInfo
+	// DataDir minio 的数据目录，为空时不上报磁盘使用情况
+	DataDir string
+	// Interval 两次上报的间隔，需要小于 TTL
+	Interval time.Duration
+	// TTL 注册信息的租约时间，超过该时间没有上报时 key 自动删除
+	TTL time.Duration
+}
+
+func NewNodeAgent(node types.StorageNodeInfo, dataDir string, interval, ttl time.Duration) (*NodeAgent, error) {
+	if node.ID == "" || node.Endpoint == "" {
+		return nil, fmt.Errorf("node id and endpoint are required")
+	}
+	if interval <= 0 || ttl <= interval {
+		return nil, fmt.Errorf("interval %s must be positive and less than ttl %s", interval, ttl)
+	}
+	return &NodeAgent{
+		Node:     node,
+		DataDir:  dataDir,
+		Interval: interval,
+		TTL:      ttl,
+	}, nil
+}
+
+// Run 持续上报直到 ctx 取消，退出前撤销租约
+func (a *NodeAgent) Run(ctx context.Context) error {
+	cli, err := etcd.Client()
+	if err != nil {
+		return err
+	}
+	key := minIo.StorageNodeKey(a.Node.ID)
+	var lease clientv3.LeaseID
+	defer func() {
+		if lease != 0 {
+			revokeCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			if _, err := cli.Revoke(revokeCtx, lease); err != nil {
+				log.Warnf("revoke lease of %s failed: %v", key, err)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		if err = a.checkHealth(ctx); err != nil {
+			log.Warnf("node %s is unhealthy: %v", a.Node.ID, err)
+			// 撤销租约使注册信息立即失效，恢复后重新注册
+			if lease != 0 {
+				if _, err = cli.Revoke(ctx, lease); err != nil {
+					log.Warnf("revoke lease of %s failed: %v", key, err)
+				}
+				lease = 0
+			}
+		} else if lease, err = a.register(ctx, cli, key, lease); err != nil {
+			log.Errorf("register node %s failed: %v", a.Node.ID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// register 续约并写入最新的节点信息，租约已失效时重新申请，返回当前使用的租约
+func (a *NodeAgent) register(ctx context.Context, cli *clientv3.Client, key string, lease clientv3.LeaseID) (clientv3.LeaseID, error) {
+	if lease != 0 {
+		if _, err := cli.KeepAliveOnce(ctx, lease); err != nil {
+			log.Warnf("keep alive lease of %s failed: %v", key, err)
+			lease = 0
+		}
+	}
+	if lease == 0 {
+		resp, err := cli.Grant(ctx, int64(a.TTL/time.Second))
+		if err != nil {
+			return 0, err
+		}
+		lease = resp.ID
+		log.Infof("register node %s at %s", a.Node.ID, a.Node.Endpoint)
+	}
+
+	info := a.Node
+	info.UpdatedAt = time.Now()
+	if a.DataDir != "" {
+		usage, err := DiskUsageOf(a.DataDir)
+		if err != nil {
+			log.Warnf("get disk usage of %s failed: %v", a.DataDir, err)
+		} else {
+			info.DiskUsage = usage
+		}
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return lease, err
+	}
+	if _, err = cli.Put(ctx, key, string(data), clientv3.WithLease(lease)); err != nil {
+		return lease, err
+	}
+	return lease, nil
+}
+
+func (a *NodeAgent) checkHealth(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, a.Interval)
+	defer cancel()
+	return minIo.CheckNodeHealth(ctx, a.Node)
+}
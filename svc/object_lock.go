@@ -0,0 +1,119 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// objectLockExpire 对象锁的过期时间，持有期间自动续期
+	objectLockExpire = 30 * time.Second
+	// objectLockTimeout 等待同一对象上其他写入完成的最长时间
+	objectLockTimeout = 10 * time.Second
+)
+
+// errObjectNotExist 带 If-Match 条件写入或删除不存在的对象
+var errObjectNotExist = fmt.Errorf("%w: object does not exist", errors.ErrPreconditionFailed)
+
+func objectLockKey(bucketName, objectName string) string {
+	return fmt.Sprintf("object:lock:%s/%s", bucketName, objectName)
+}
+
+// lockObject 获取对象锁，同一对象的写入、删除和复制串行执行。
+// 超时未获取到时返回 ErrConflict。
+func lockObject(ctx context.Context, bucketName, objectName string) (*redis.Lock, error) {
+	lock := redis.NewRedisLockWithExpire(objectLockKey(bucketName, objectName), objectLockExpire)
+	err := lock.TryLock(ctx, objectLockTimeout)
+	if err == redis.ErrLockNotObtained {
+		return nil, fmt.Errorf("%w: %s/%s is being written by another request", errors.ErrConflict, bucketName, objectName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lock object %s/%s: %w", bucketName, objectName, err)
+	}
+	return lock, nil
+}
+
+// lockObjects 按锁的 key 排序后依次获取两个对象的锁，避免两个请求以相反的顺序加锁时互相等待。
+// 两个对象相同时只获取一次。
+func lockObjects(ctx context.Context, bucketA, objectA, bucketB, objectB string) ([]*redis.Lock, error) {
+	first, second := [2]string{bucketA, objectA}, [2]string{bucketB, objectB}
+	if objectLockKey(bucketB, objectB) < objectLockKey(bucketA, objectA) {
+		first, second = second, first
+	}
+	lock, err := lockObject(ctx, first[0], first[1])
+	if err != nil {
+		return nil, err
+	}
+	locks := []*redis.Lock{lock}
+	if first == second {
+		return locks, nil
+	}
+	if lock, err = lockObject(ctx, second[0], second[1]); err != nil {
+		unlockObjects(ctx, locks)
+		return nil, err
+	}
+	return append(locks, lock), nil
+}
+
+// unlockObjects 释放 lockObjects 获取的锁
+func unlockObjects(ctx context.Context, locks []*redis.Lock) {
+	for _, lock := range locks {
+		unlockObject(ctx, lock)
+	}
+}
+
+// unlockObject 释放对象锁，失败时锁会在过期后自动释放
+func unlockObject(ctx context.Context, lock *redis.Lock) {
+	// 请求已经结束时仍然需要释放锁
+	if err := lock.UnLock(context.WithoutCancel(ctx)); err != nil {
+		log.Ctx(ctx).Warnf("unlock %s failed: %v", lock.Key(), err)
+	}
+}
+
+// checkObjectLock 提交元数据前确认仍持有对象锁，否则返回 ErrConflict
+func checkObjectLock(ctx context.Context, lock *redis.Lock) error {
+	if err := lock.Check(ctx); err != nil {
+		return fmt.Errorf("%w: lost %s: %v", errors.ErrConflict, lock.Key(), err)
+	}
+	return nil
+}
+
+// checkWriteCondition 根据对象当前的元数据检查写入条件，meta 为 nil 表示对象不存在
+func checkWriteCondition(meta *dbm.ObjectMetadata, cond types.WriteCondition) error {
+	if cond.IfNoneMatch != "" {
+		if cond.IfNoneMatch != "*" {
+			return fmt.Errorf("%w: If-None-Match only supports *", errors.ErrBadRequest)
+		}
+		if meta != nil {
+			return fmt.Errorf("%w: object %s/%s already exists", errors.ErrPreconditionFailed, meta.BucketName, meta.ObjectName)
+		}
+	}
+	if cond.IfMatch != "" {
+		if meta == nil {
+			return errObjectNotExist
+		}
+		if !matchETag(cond.IfMatch, meta.ETag) {
+			return fmt.Errorf("%w: etag %s does not match %s", errors.ErrPreconditionFailed, meta.ETag, cond.IfMatch)
+		}
+	}
+	return nil
+}
+
+// matchETag 判断 If-Match 头是否匹配 etag，支持逗号分隔的多个值和 *
+func matchETag(header, etag string) bool {
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimSpace(value)
+		value = strings.TrimPrefix(value, "W/")
+		if value == "*" || strings.Trim(value, "\"") == etag {
+			return true
+		}
+	}
+	return false
+}
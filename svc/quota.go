@@ -0,0 +1,448 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+const (
+	quotaUsagePrefix  = "quota:usage:"
+	quotaReconcileKey = "quota:reconcile"
+	quotaUsageBytes   = "bytes"
+	quotaUsageObjects = "objects"
+)
+
+func bucketUsageKey(bucketName string) string {
+	return quotaUsagePrefix + types.QuotaScopeBucket + ":" + bucketName
+}
+
+func userUsageKey(userID uint) string {
+	return quotaUsagePrefix + types.QuotaScopeUser + ":" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// reserveScript 检查硬配额并增加用量。任一 key 加上增量后超过硬配额时不做任何修改，返回该 key 的下标（从 1 开始），否则返回 0。
+// KEYS 为用量 hash；ARGV 依次为增加的字节数、对象数，以及每个 key 的字节数上限和对象数上限（0 表示不限制）。
+var reserveScript = goredis.NewScript(`
+local bytes = tonumber(ARGV[1])
+local objects = tonumber(ARGV[2])
+for i, key in ipairs(KEYS) do
+	local limitBytes = tonumber(ARGV[1 + i * 2])
+	local limitObjects = tonumber(ARGV[2 + i * 2])
+	local used = redis.call('HMGET', key, 'bytes', 'objects')
+	local usedBytes = tonumber(used[1]) or 0
+	local usedObjects = tonumber(used[2]) or 0
+	if (bytes > 0 and limitBytes > 0 and usedBytes + bytes > limitBytes) or
+		(objects > 0 and limitObjects > 0 and usedObjects + objects > limitObjects) then
+		return i
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('HINCRBY', key, 'bytes', bytes)
+	redis.call('HINCRBY', key, 'objects', objects)
+end
+return 0
+`)
+
+// QuotaSvc 维护桶和用户的用量并检查配额。
+// 用量计数保存在 redis 中，写入和删除对象时增量更新，定时按元数据重新统计以修正误差。
+// 用户的用量为其拥有的所有桶的用量之和。
+type QuotaSvc struct {
+	quotaDao    *dao.Quota
+	userDao     *dao.User
+	bucketSvc   *BucketSvc
+	metadataDao *dao.MetadataNode
+}
+
+func NewQuotaSvc(s *dao.S) *QuotaSvc {
+	return &QuotaSvc{
+		quotaDao:    s.Quota,
+		userDao:     s.User,
+		bucketSvc:   NewBucketSvc(s),
+		metadataDao: s.MetadataNode,
+	}
+}
+
+// quotaSubject 一次写入需要检查配额的桶或用户
+type quotaSubject struct {
+	scope string
+	name  string
+	key   string
+	quota types.Quota
+}
+
+// subjects 返回写入桶时需要检查配额的桶和桶的所有者
+func (q *QuotaSvc) subjects(ctx context.Context, bucket types.BucketConfig) ([]quotaSubject, error) {
+	res := []quotaSubject{{
+		scope: types.QuotaScopeBucket,
+		name:  bucket.Name,
+		key:   bucketUsageKey(bucket.Name),
+		quota: bucket.Quota,
+	}}
+	if bucket.OwnerID == 0 {
+		return res, nil
+	}
+	quota, err := q.GetUserQuota(ctx, bucket.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	return append(res, quotaSubject{
+		scope: types.QuotaScopeUser,
+		name:  strconv.FormatUint(uint64(bucket.OwnerID), 10),
+		key:   userUsageKey(bucket.OwnerID),
+		quota: quota.Quota,
+	}), nil
+}
+
+func quotaExceeded(subject quotaSubject) error {
+	return errors.WithCode(errors.CodeQuotaExceeded, "%s %s exceeds its quota of %d bytes, %d objects",
+		subject.scope, subject.name, subject.quota.QuotaBytes, subject.quota.QuotaObjects)
+}
+
+// Check 检查写入 delta 后是否会超过桶或所有者的硬配额，只读取用量不做修改，用于在接收数据前尽早拒绝
+func (q *QuotaSvc) Check(ctx context.Context, bucket types.BucketConfig, delta types.Usage) error {
+	subjects, err := q.subjects(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	for _, subject := range subjects {
+		usage, err := getUsage(ctx, subject.key)
+		if err != nil {
+			return err
+		}
+		if wouldExceed(subject.quota, usage, delta) {
+			return quotaExceeded(subject)
+		}
+	}
+	return nil
+}
+
+// wouldExceed 增加 delta 后是否超过硬配额，delta 不增加的部分不受配额限制
+func wouldExceed(quota types.Quota, usage, delta types.Usage) bool {
+	return (delta.Bytes > 0 && quota.QuotaBytes > 0 && usage.Bytes+delta.Bytes > quota.QuotaBytes) ||
+		(delta.Objects > 0 && quota.QuotaObjects > 0 && usage.Objects+delta.Objects > quota.QuotaObjects)
+}
+
+// QuotaReservation 写入数据前预占的用量，写入失败时需要 Release
+type QuotaReservation struct {
+	keys  []string
+	delta types.Usage
+}
+
+// Reserve 原子地检查硬配额并预占 delta，超过配额时返回 QuotaExceeded。超过软配额时只记录告警。
+func (q *QuotaSvc) Reserve(ctx context.Context, bucket types.BucketConfig, delta types.Usage) (*QuotaReservation, error) {
+	subjects, err := q.subjects(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(subjects))
+	args := []interface{}{delta.Bytes, delta.Objects}
+	for _, subject := range subjects {
+		keys = append(keys, subject.key)
+		args = append(args, subject.quota.QuotaBytes, subject.quota.QuotaObjects)
+	}
+	exceeded, err := reserveScript.Run(ctx, redis.Redis(), keys, args...).Int()
+	if err != nil {
+		return nil, fmt.Errorf("reserve quota: %w", err)
+	}
+	if exceeded > 0 {
+		return nil, quotaExceeded(subjects[exceeded-1])
+	}
+	for _, subject := range subjects {
+		q.warnSoftQuota(ctx, subject)
+	}
+	return &QuotaReservation{keys: keys, delta: delta}, nil
+}
+
+// Release 写入失败后归还预占的用量
+func (q *QuotaSvc) Release(ctx context.Context, r *QuotaReservation) {
+	if r == nil {
+		return
+	}
+	if err := addUsage(context.WithoutCancel(ctx), r.keys, types.Usage{Bytes: -r.delta.Bytes, Objects: -r.delta.Objects}); err != nil {
+		log.Ctx(ctx).Warnf("release quota reservation failed: %v", err)
+	}
+}
+
+// Charge 删除对象等不需要检查配额的操作直接修改桶和所有者的用量。
+// 计数失败只记录告警，由定时统计修正。
+func (q *QuotaSvc) Charge(ctx context.Context, bucket types.BucketConfig, delta types.Usage) {
+	keys := []string{bucketUsageKey(bucket.Name)}
+	if bucket.OwnerID != 0 {
+		keys = append(keys, userUsageKey(bucket.OwnerID))
+	}
+	if err := addUsage(context.WithoutCancel(ctx), keys, delta); err != nil {
+		log.Ctx(ctx).Warnf("update usage of bucket %s failed: %v", bucket.Name, err)
+	}
+}
+
+func (q *QuotaSvc) warnSoftQuota(ctx context.Context, subject quotaSubject) {
+	if subject.quota.SoftQuotaBytes == 0 && subject.quota.SoftQuotaObjects == 0 {
+		return
+	}
+	usage, err := getUsage(ctx, subject.key)
+	if err != nil {
+		return
+	}
+	if soft, _ := subject.quota.Exceeded(usage); soft {
+		log.Ctx(ctx).Warnf("%s %s exceeds its soft quota, used %d bytes, %d objects",
+			subject.scope, subject.name, usage.Bytes, usage.Objects)
+	}
+}
+
+func addUsage(ctx context.Context, keys []string, delta types.Usage) error {
+	_, err := redis.Redis().TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.HIncrBy(ctx, key, quotaUsageBytes, delta.Bytes)
+			pipe.HIncrBy(ctx, key, quotaUsageObjects, delta.Objects)
+		}
+		return nil
+	})
+	return err
+}
+
+func getUsage(ctx context.Context, key string) (types.Usage, error) {
+	usage := types.Usage{}
+	values, err := redis.Redis().HGetAll(ctx, key).Result()
+	if err != nil {
+		return usage, fmt.Errorf("get usage %s: %w", key, err)
+	}
+	usage.Bytes, _ = strconv.ParseInt(values[quotaUsageBytes], 10, 64)
+	usage.Objects, _ = strconv.ParseInt(values[quotaUsageObjects], 10, 64)
+	return usage, nil
+}
+
+// GetUserQuota 返回用户的配额，没有设置时不限制
+func (q *QuotaSvc) GetUserQuota(ctx context.Context, userID uint) (types.UserQuota, error) {
+	quota, err := q.quotaDao.GetUserQuota(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.UserQuota{UserID: userID}, nil
+	}
+	if err != nil {
+		return types.UserQuota{}, fmt.Errorf("get quota of user %d: %w", userID, err)
+	}
+	return toUserQuota(quota), nil
+}
+
+// PutUserQuota 设置用户的配额，全部为 0 表示不限制
+func (q *QuotaSvc) PutUserQuota(ctx context.Context, userID uint, quota types.Quota) (types.UserQuota, error) {
+	if err := validateQuota(quota); err != nil {
+		return types.UserQuota{}, err
+	}
+	if _, err := q.userDao.GetUserInfoByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.UserQuota{}, fmt.Errorf("%w: user %d", errors.ErrNotFound, userID)
+		}
+		return types.UserQuota{}, err
+	}
+	res := types.UserQuota{UserID: userID, UpdatedAt: time.Now(), Quota: quota}
+	err := q.quotaDao.SaveUserQuota(ctx, &dbm.UserQuota{
+		UserID:           userID,
+		QuotaBytes:       quota.QuotaBytes,
+		QuotaObjects:     quota.QuotaObjects,
+		SoftQuotaBytes:   quota.SoftQuotaBytes,
+		SoftQuotaObjects: quota.SoftQuotaObjects,
+		UpdatedAt:        res.UpdatedAt,
+	})
+	if err != nil {
+		return res, fmt.Errorf("save quota of user %d: %w", userID, err)
+	}
+	return res, nil
+}
+
+// BucketReport 返回桶的用量和配额
+func (q *QuotaSvc) BucketReport(ctx context.Context, bucketName string) (types.UsageReport, error) {
+	cfg, err := q.bucketSvc.GetConfig(ctx, bucketName)
+	if err != nil {
+		return types.UsageReport{}, err
+	}
+	return newUsageReport(ctx, types.QuotaScopeBucket, bucketName, bucketUsageKey(bucketName), cfg.Quota)
+}
+
+// UserReport 返回用户的用量和配额
+func (q *QuotaSvc) UserReport(ctx context.Context, userID uint) (types.UsageReport, error) {
+	quota, err := q.GetUserQuota(ctx, userID)
+	if err != nil {
+		return types.UsageReport{}, err
+	}
+	return newUsageReport(ctx, types.QuotaScopeUser, strconv.FormatUint(uint64(userID), 10), userUsageKey(userID), quota.Quota)
+}
+
+// Overview 返回所有已登记的桶、拥有桶或设置了配额的用户的用量报告
+func (q *QuotaSvc) Overview(ctx context.Context) (types.UsageOverview, error) {
+	res := types.UsageOverview{Buckets: []types.UsageReport{}, Users: []types.UsageReport{}}
+	buckets, err := q.bucketSvc.bucketDao.List(ctx)
+	if err != nil {
+		return res, fmt.Errorf("list buckets: %w", err)
+	}
+	users := make(map[uint]bool)
+	userIDs := make([]uint, 0)
+	addUser := func(id uint) {
+		if id != 0 && !users[id] {
+			users[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	for _, bucket := range buckets {
+		cfg := toBucketConfig(bucket)
+		report, err := newUsageReport(ctx, types.QuotaScopeBucket, cfg.Name, bucketUsageKey(cfg.Name), cfg.Quota)
+		if err != nil {
+			return res, err
+		}
+		res.Buckets = append(res.Buckets, report)
+		addUser(cfg.OwnerID)
+	}
+	quotas, err := q.quotaDao.ListUserQuotas(ctx)
+	if err != nil {
+		return res, fmt.Errorf("list user quotas: %w", err)
+	}
+	for _, quota := range quotas {
+		addUser(quota.UserID)
+	}
+	for _, id := range userIDs {
+		report, err := q.UserReport(ctx, id)
+		if err != nil {
+			return res, err
+		}
+		res.Users = append(res.Users, report)
+	}
+	res.LastReconcile, err = q.GetLastReconcile(ctx)
+	return res, err
+}
+
+func newUsageReport(ctx context.Context, scope, name, key string, quota types.Quota) (types.UsageReport, error) {
+	usage, err := getUsage(ctx, key)
+	if err != nil {
+		return types.UsageReport{}, err
+	}
+	report := types.UsageReport{Scope: scope, Name: name, Usage: usage, Quota: quota}
+	report.SoftExceeded, report.HardExceeded = quota.Exceeded(usage)
+	return report, nil
+}
+
+// Reconcile 按元数据重新统计所有桶和用户的用量并覆盖 redis 中的计数。
+// 统计期间完成的写入可能被覆盖，误差在下一次统计时修正。
+func (q *QuotaSvc) Reconcile(ctx context.Context) (types.UsageReconcileReport, error) {
+	report := types.UsageReconcileReport{StartedAt: time.Now()}
+	sums, err := q.metadataDao.SumUsageByBucket(ctx)
+	if err != nil {
+		return report, fmt.Errorf("sum usage by bucket: %w", err)
+	}
+	buckets, err := q.bucketSvc.bucketDao.List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("list buckets: %w", err)
+	}
+
+	// 已登记但没有对象的桶和所有者的用量也需要清零
+	bucketUsages := make(map[string]types.Usage)
+	userUsages := make(map[uint]types.Usage)
+	owners := make(map[string]uint, len(buckets))
+	for _, bucket := range buckets {
+		bucketUsages[bucket.Name] = types.Usage{}
+		owners[bucket.Name] = bucket.OwnerID
+		if bucket.OwnerID != 0 {
+			userUsages[bucket.OwnerID] = types.Usage{}
+		}
+	}
+	for _, sum := range sums {
+		usage := types.Usage{Bytes: sum.Bytes, Objects: sum.Objects}
+		bucketUsages[sum.BucketName] = usage
+		report.Bytes += usage.Bytes
+		report.Objects += usage.Objects
+		if owner := owners[sum.BucketName]; owner != 0 {
+			total := userUsages[owner]
+			total.Bytes += usage.Bytes
+			total.Objects += usage.Objects
+			userUsages[owner] = total
+		}
+	}
+	report.Buckets, report.Users = len(bucketUsages), len(userUsages)
+	usages := make(map[string]types.Usage, len(bucketUsages)+len(userUsages))
+	for name, usage := range bucketUsages {
+		usages[bucketUsageKey(name)] = usage
+	}
+	for id, usage := range userUsages {
+		usages[userUsageKey(id)] = usage
+	}
+
+	// 删除已不存在的桶和用户的计数
+	stale := make([]string, 0)
+	iter := redis.Redis().Scan(ctx, 0, quotaUsagePrefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		if _, ok := usages[iter.Val()]; !ok {
+			stale = append(stale, iter.Val())
+		}
+	}
+	if err = iter.Err(); err != nil {
+		return report, fmt.Errorf("scan usage keys: %w", err)
+	}
+	_, err = redis.Redis().TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for key, usage := range usages {
+			pipe.HSet(ctx, key, quotaUsageBytes, usage.Bytes, quotaUsageObjects, usage.Objects)
+		}
+		if len(stale) > 0 {
+			pipe.Del(ctx, stale...)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("save usage: %w", err)
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// SaveReconcile 保存最近一次统计的结果
+func (q *QuotaSvc) SaveReconcile(ctx context.Context, report types.UsageReconcileReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, quotaReconcileKey, data, 0).Err()
+}
+
+// GetLastReconcile 返回最近一次统计的结果，还没有统计过时返回零值
+func (q *QuotaSvc) GetLastReconcile(ctx context.Context) (types.UsageReconcileReport, error) {
+	report := types.UsageReconcileReport{}
+	data, err := redis.Redis().Get(ctx, quotaReconcileKey).Bytes()
+	if err == goredis.Nil {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("get usage reconcile report: %w", err)
+	}
+	if err = json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("unmarshal usage reconcile report: %w", err)
+	}
+	return report, nil
+}
+
+func validateQuota(q types.Quota) error {
+	if q.QuotaBytes < 0 || q.QuotaObjects < 0 || q.SoftQuotaBytes < 0 || q.SoftQuotaObjects < 0 {
+		return fmt.Errorf("%w: quota must not be negative", errors.ErrBadRequest)
+	}
+	return nil
+}
+
+func toUserQuota(quota *dbm.UserQuota) types.UserQuota {
+	return types.UserQuota{
+		UserID:    quota.UserID,
+		UpdatedAt: quota.UpdatedAt,
+		Quota: types.Quota{
+			QuotaBytes:       quota.QuotaBytes,
+			QuotaObjects:     quota.QuotaObjects,
+			SoftQuotaBytes:   quota.SoftQuotaBytes,
+			SoftQuotaObjects: quota.SoftQuotaObjects,
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"time"
+)
+
+const (
+	rebalanceProgressKey = "rebalance:progress"
+	rebalancePausedKey   = "rebalance:paused"
+)
+
+// RebalanceSvc 读写对象迁移任务的进度和暂停状态。
+// 状态保存在 redis 中，任意网关都可以查询或暂停正在其他网关上运行的迁移任务。
+type RebalanceSvc struct {
+}
+
+func NewRebalanceSvc() *RebalanceSvc {
+	return &RebalanceSvc{}
+}
+
+// GetProgress 获取迁移进度，没有记录时返回 idle 状态
+func (r *RebalanceSvc) GetProgress(ctx context.Context) (types.RebalanceProgress, error) {
+	progress := types.RebalanceProgress{State: types.RebalanceIdle}
+	data, err := redis.Redis().Get(ctx, rebalanceProgressKey).Bytes()
+	if err != nil && err != goredis.Nil {
+		return progress, fmt.Errorf("get rebalance progress: %w", err)
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &progress); err != nil {
+			return progress, fmt.Errorf("unmarshal rebalance progress: %w", err)
+		}
+	}
+	progress.Paused, err = r.IsPaused(ctx)
+	if err != nil {
+		return progress, err
+	}
+	if progress.Paused && progress.State == types.RebalanceRunning {
+		progress.State = types.RebalancePaused
+	}
+	return progress, nil
+}
+
+// SaveProgress 保存迁移进度
+func (r *RebalanceSvc) SaveProgress(ctx context.Context, progress types.RebalanceProgress) error {
+	progress.UpdatedTime = time.Now()
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, rebalanceProgressKey, data, 0).Err()
+}
+
+// Pause 暂停迁移，正在迁移的对象完成后停止
+func (r *RebalanceSvc) Pause(ctx context.Context) error {
+	return redis.Redis().Set(ctx, rebalancePausedKey, 1, 0).Err()
+}
+
+// Resume 恢复迁移，下一次调度时从暂停处继续
+func (r *RebalanceSvc) Resume(ctx context.Context) error {
+	return redis.Redis().Del(ctx, rebalancePausedKey).Err()
+}
+
+func (r *RebalanceSvc) IsPaused(ctx context.Context) (bool, error) {
+	n, err := redis.Redis().Exists(ctx, rebalancePausedKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("get rebalance pause flag: %w", err)
+	}
+	return n > 0, nil
+}
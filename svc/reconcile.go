@@ -0,0 +1,598 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	reconcileReportKey = "reconcile:report"
+	// reconcileBatchSize 每批比较的对象数
+	reconcileBatchSize = 500
+)
+
+// ReconcileOptions 对账参数
+type ReconcileOptions struct {
+	// BucketName 只检查该桶，为空时检查所有桶
+	BucketName string
+	// Fix 为 true 时修复发现的不一致，否则只生成报告
+	Fix bool
+}
+
+// ReconcileSvc 对比各存储节点上的实际数据与元数据记录，找出没有元数据的数据、
+// 指向不存在数据的元数据以及大小或 ETag 不一致的副本。
+type ReconcileSvc struct {
+	metadataDao *dao.MetadataNode
+	cleanupDao  *dao.Cleanup
+}
+
+func NewReconcileSvc(s *dao.S) *ReconcileSvc {
+	return &ReconcileSvc{
+		metadataDao: s.MetadataNode,
+		cleanupDao:  s.Cleanup,
+	}
+}
+
+// backendCopy 某个节点上的一个副本
+type backendCopy struct {
+	node types.StorageNodeInfo
+	info minio.ObjectInfo
+}
+
+// Reconcile 执行一次对账。对账开始后才写入的对象和元数据不参与比较，避免与正在进行的上传冲突。
+func (r *ReconcileSvc) Reconcile(ctx context.Context, opts ReconcileOptions) (*types.ReconcileReport, error) {
+	report := &types.ReconcileReport{StartedAt: time.Now(), Fix: opts.Fix, Items: []types.ReconcileItem{}}
+	nodes, err := minIo.GetStorageNodes()
+	if err != nil {
+		return nil, err
+	}
+	buckets := []string{opts.BucketName}
+	if opts.BucketName == "" {
+		if buckets, err = r.listBuckets(ctx, nodes); err != nil {
+			return nil, err
+		}
+	}
+	for _, bucketName := range buckets {
+		// 作为后台任务运行时，失去领导权后停止修复
+		if opts.Fix {
+			if err = fencing.Check(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if err = r.reconcileBucket(ctx, bucketName, nodes, opts.Fix, report); err != nil {
+			return nil, fmt.Errorf("reconcile bucket %s: %w", bucketName, err)
+		}
+		report.Buckets++
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// GetLastReport 返回最近一次定时对账的报告，没有时返回 nil
+func (r *ReconcileSvc) GetLastReport(ctx context.Context) (*types.ReconcileReport, error) {
+	data, err := redis.Redis().Get(ctx, reconcileReportKey).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get reconcile report: %w", err)
+	}
+	report := &types.ReconcileReport{}
+	if err = json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("unmarshal reconcile report: %w", err)
+	}
+	return report, nil
+}
+
+func (r *ReconcileSvc) SaveReport(ctx context.Context, report *types.ReconcileReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, reconcileReportKey, data, 0).Err()
+}
+
+// listBuckets 元数据和各节点上出现过的所有桶
+func (r *ReconcileSvc) listBuckets(ctx context.Context, nodes []types.StorageNodeInfo) ([]string, error) {
+	names, err := r.metadataDao.ListBucketNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, node := range nodes {
+		buckets, err := minIo.GetNodeClient(node).MinioCore.ListBuckets(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list buckets on node %s: %w", node.ID, err)
+		}
+		for _, bucket := range buckets {
+			if !seen[bucket.Name] {
+				seen[bucket.Name] = true
+				names = append(names, bucket.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// reconcileBucket 分批比较桶内的数据和元数据：先按 key 的顺序合并各节点上的对象列表，
+// 每批一次查询这些对象的元数据；再遍历元数据，找出所有节点上都没有数据的对象。
+// 内存中只保留一批对象，桶内对象数量不影响内存占用。
+func (r *ReconcileSvc) reconcileBucket(ctx context.Context, bucketName string, nodes []types.StorageNodeInfo, fix bool, report *types.ReconcileReport) error {
+	registered := make(map[string]types.StorageNodeInfo, len(nodes))
+	for _, node := range nodes {
+		registered[node.ID] = node
+	}
+	listing, err := listNodeObjects(ctx, bucketName, nodes)
+	if err != nil {
+		return err
+	}
+	defer listing.close()
+	for {
+		batch, err := listing.next(reconcileBatchSize, report.StartedAt)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		names := make([]string, 0, len(batch))
+		for _, object := range batch {
+			names = append(names, object.key)
+		}
+		metas, err := r.metadataDao.ListObjectMetadataByNames(ctx, bucketName, names)
+		if err != nil {
+			return err
+		}
+		byName := make(map[string]*dbm.ObjectMetadata, len(metas))
+		for _, meta := range metas {
+			byName[meta.ObjectName] = meta
+		}
+		cleanups, err := r.cleanupDao.ListByNames(ctx, bucketName, names)
+		if err != nil {
+			return err
+		}
+		removing := make(map[string]bool, len(cleanups))
+		for _, cleanup := range cleanups {
+			removing[cleanup.ObjectName] = true
+		}
+		for _, object := range batch {
+			meta, ok := byName[object.key]
+			if !ok {
+				// 元数据已删除、等待删除的数据不是孤儿数据，由后台任务删除
+				if removing[object.key] {
+					continue
+				}
+				if err = r.adoptOrphan(ctx, bucketName, object.key, object.copies, fix, report); err != nil {
+					return err
+				}
+				continue
+			}
+			// 已转移到冷存储的对象不在节点上，节点上残留的副本在转移时已尽量删除
+			if meta.LastModified.After(report.StartedAt) || meta.IsCold() {
+				continue
+			}
+			report.Objects++
+			if err = r.reconcileObject(ctx, meta, object.copies, registered, fix, report); err != nil {
+				return err
+			}
+		}
+	}
+	return r.reconcileMissing(ctx, bucketName, registered, fix, report)
+}
+
+// reconcileMissing 遍历元数据，处理所有节点上都没有数据的对象，这些对象不会出现在节点的对象列表中
+func (r *ReconcileSvc) reconcileMissing(ctx context.Context, bucketName string, registered map[string]types.StorageNodeInfo,
+	fix bool, report *types.ReconcileReport) error {
+	var lastID uint
+	for {
+		batch, err := r.metadataDao.ListBucketObjectMetadataAfter(ctx, bucketName, lastID, reconcileBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, meta := range batch {
+			lastID = meta.Id
+			if meta.LastModified.After(report.StartedAt) || meta.IsCold() || hasAnyCopy(ctx, meta, registered) {
+				continue
+			}
+			report.Objects++
+			if err = r.reconcileObject(ctx, meta, map[string]backendCopy{}, registered, fix, report); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// hasAnyCopy 任意节点上是否有该对象，先检查元数据记录的节点。无法确认时视为有，由下一次对账处理
+func hasAnyCopy(ctx context.Context, meta *dbm.ObjectMetadata, registered map[string]types.StorageNodeInfo) bool {
+	order := make([]types.StorageNodeInfo, 0, len(registered))
+	recorded := make(map[string]bool)
+	for _, id := range meta.Nodes() {
+		if node, ok := registered[id]; ok {
+			recorded[id] = true
+			order = append(order, node)
+		}
+	}
+	for id, node := range registered {
+		if !recorded[id] {
+			order = append(order, node)
+		}
+	}
+	for _, node := range order {
+		_, err := minIo.GetNodeClient(node).MinioCore.Client.StatObject(ctx, meta.BucketName, meta.ObjectName, minio.StatObjectOptions{})
+		if code := minio.ToErrorResponse(err).Code; err == nil || (code != "NoSuchKey" && code != "NoSuchBucket") {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileObject 比较一条元数据与各节点上的副本
+func (r *ReconcileSvc) reconcileObject(ctx context.Context, meta *dbm.ObjectMetadata, copies map[string]backendCopy,
+	registered map[string]types.StorageNodeInfo, fix bool, report *types.ReconcileReport) error {
+	listed := make(map[string]bool)
+	matching := make([]backendCopy, 0)
+	keep := make([]string, 0)
+	dangling := make([]int, 0)
+	mismatched := make([]backendCopy, 0)
+	mismatchedItems := make([]int, 0)
+	for _, id := range meta.Nodes() {
+		listed[id] = true
+		// 已离开集群的节点由 rebalancer 负责迁移，这里保留原记录
+		if _, ok := registered[id]; !ok {
+			keep = append(keep, id)
+			continue
+		}
+		c, ok := copies[id]
+		if !ok {
+			report.Dangling++
+			dangling = append(dangling, addReconcileItem(report, types.ReconcileDangling, meta.BucketName, meta.ObjectName, id, "recorded replica not found"))
+			continue
+		}
+		keep = append(keep, id)
+		if detail := compareCopy(meta, c.info); detail != "" {
+			report.Mismatched++
+			mismatched = append(mismatched, c)
+			mismatchedItems = append(mismatchedItems, addReconcileItem(report, types.ReconcileMismatch, meta.BucketName, meta.ObjectName, id, detail))
+			continue
+		}
+		matching = append(matching, c)
+	}
+
+	untracked := make([]backendCopy, 0)
+	for id, c := range copies {
+		if !listed[id] {
+			report.Orphans++
+			untracked = append(untracked, c)
+		}
+	}
+	sort.Slice(untracked, func(i, j int) bool { return untracked[i].node.ID < untracked[j].node.ID })
+	untrackedItems := make([]int, 0, len(untracked))
+	for _, c := range untracked {
+		untrackedItems = append(untrackedItems, addReconcileItem(report, types.ReconcileOrphan, meta.BucketName, meta.ObjectName, c.node.ID, "replica not recorded in metadata"))
+	}
+	if !fix || len(dangling)+len(mismatched)+len(untracked) == 0 {
+		return nil
+	}
+
+	// 比较之后对象可能已被重新写入、删除或迁移，此时比较结果已经过期，不修复
+	lock, err := r.lockUnchanged(ctx, meta)
+	if lock == nil || err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	// 副本与元数据一致时补记到元数据中，不一致时删除
+	for i, c := range untracked {
+		if compareCopy(meta, c.info) == "" {
+			keep = append(keep, c.node.ID)
+		} else if err := removeCopy(ctx, meta.BucketName, meta.ObjectName, c.node); err != nil {
+			continue
+		}
+		markFixed(report, untrackedItems[i])
+	}
+	// 不一致的副本从一致的副本修复；所有副本都不一致但彼此相同时以实际数据为准更新元数据
+	for i, c := range mismatched {
+		if len(matching) > 0 {
+			_, err := minIo.GetNodeClient(c.node).CopyObjectFrom(ctx, minIo.GetNodeClient(matching[0].node), meta.BucketName, meta.ObjectName)
+			if err == nil {
+				markFixed(report, mismatchedItems[i])
+			}
+		}
+	}
+	if len(matching) == 0 && len(mismatched) > 0 && sameContent(mismatched) {
+		meta.Size = mismatched[0].info.Size
+		meta.ETag = strings.Trim(mismatched[0].info.ETag, "\"")
+		for _, item := range mismatchedItems {
+			markFixed(report, item)
+		}
+	}
+	for _, item := range dangling {
+		markFixed(report, item)
+	}
+
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return err
+	}
+	// 所有节点上都没有数据时删除元数据
+	if len(keep) == 0 {
+		return r.metadataDao.DeleteObjectMetadata(ctx, meta.BucketName, meta.ObjectName)
+	}
+	meta.SetNodes(keep)
+	return r.metadataDao.SaveObjectMetadata(ctx, meta)
+}
+
+// lockUnchanged 获取对象锁并确认元数据与 scanned 相同，对象正在被其他请求写入或已经变化时返回 nil
+func (r *ReconcileSvc) lockUnchanged(ctx context.Context, scanned *dbm.ObjectMetadata) (*redis.Lock, error) {
+	lock, err := r.lockForFix(ctx, scanned.BucketName, scanned.ObjectName)
+	if lock == nil || err != nil {
+		return nil, err
+	}
+	meta, err := r.metadataDao.GetObjectMetadata(ctx, scanned.BucketName, scanned.ObjectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		unlockObject(ctx, lock)
+		return nil, nil
+	}
+	if err != nil {
+		unlockObject(ctx, lock)
+		return nil, fmt.Errorf("get object metadata %s/%s: %w", scanned.BucketName, scanned.ObjectName, err)
+	}
+	if meta.ETag != scanned.ETag || meta.Size != scanned.Size || meta.StorageNodes != scanned.StorageNodes || meta.TierKey != scanned.TierKey {
+		unlockObject(ctx, lock)
+		return nil, nil
+	}
+	return lock, nil
+}
+
+// lockForFix 获取对象锁，对象正在被其他请求写入时返回 nil，留给下一次对账
+func (r *ReconcileSvc) lockForFix(ctx context.Context, bucketName, objectName string) (*redis.Lock, error) {
+	lock, err := lockObject(ctx, bucketName, objectName)
+	if errors.Is(err, errors.ErrConflict) {
+		log.Ctx(ctx).Infof("skip fixing %s/%s: %v", bucketName, objectName, err)
+		return nil, nil
+	}
+	return lock, err
+}
+
+// adoptOrphan 处理没有元数据的数据：以最新的副本为准补建元数据，与之不同的副本删除。
+// 列出对象之后可能有上传完成、对象被删除或重命名，修复前在对象锁内确认元数据仍不存在、
+// 数据不在等待删除，并重新读取各副本的信息。
+func (r *ReconcileSvc) adoptOrphan(ctx context.Context, bucketName, objectName string, copies map[string]backendCopy, fix bool, report *types.ReconcileReport) error {
+	list := sortCopies(copies)
+	items := make(map[string]int, len(list))
+	for _, c := range list {
+		report.Orphans++
+		items[c.node.ID] = addReconcileItem(report, types.ReconcileOrphan, bucketName, objectName, c.node.ID, "no metadata for data")
+	}
+	if !fix {
+		return nil
+	}
+
+	lock, err := r.lockForFix(ctx, bucketName, objectName)
+	if lock == nil || err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+	_, err = r.metadataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get object metadata %s/%s: %w", bucketName, objectName, err)
+	}
+	cleanups, err := r.cleanupDao.ListByNames(ctx, bucketName, []string{objectName})
+	if err != nil {
+		return fmt.Errorf("get object cleanup %s/%s: %w", bucketName, objectName, err)
+	}
+	if len(cleanups) > 0 {
+		return nil
+	}
+	current := make(map[string]backendCopy, len(list))
+	for _, c := range list {
+		info, err := minIo.GetNodeClient(c.node).MinioCore.Client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
+		if err != nil {
+			continue
+		}
+		current[c.node.ID] = backendCopy{node: c.node, info: info}
+	}
+	if len(current) == 0 {
+		return nil
+	}
+	list = sortCopies(current)
+
+	newest := list[0].info
+	meta := &dbm.ObjectMetadata{
+		BucketName:   bucketName,
+		ObjectName:   objectName,
+		Size:         newest.Size,
+		ContentType:  newest.ContentType,
+		ETag:         strings.Trim(newest.ETag, "\""),
+		LastModified: newest.LastModified,
+		VersionID:    newest.VersionID,
+		IsLatest:     true,
+		StorageClass: newest.StorageClass,
+	}
+	nodes := make([]string, 0, len(list))
+	for _, c := range list {
+		if compareCopy(meta, c.info) == "" {
+			nodes = append(nodes, c.node.ID)
+		} else if err := removeCopy(ctx, bucketName, objectName, c.node); err != nil {
+			continue
+		}
+		markFixed(report, items[c.node.ID])
+	}
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return err
+	}
+	meta.SetNodes(nodes)
+	return r.metadataDao.SaveObjectMetadata(ctx, meta)
+}
+
+// sortCopies 按修改时间从新到旧排列副本
+func sortCopies(copies map[string]backendCopy) []backendCopy {
+	list := make([]backendCopy, 0, len(copies))
+	for _, c := range copies {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].info.LastModified.Equal(list[j].info.LastModified) {
+			return list[i].node.ID < list[j].node.ID
+		}
+		return list[i].info.LastModified.After(list[j].info.LastModified)
+	})
+	return list
+}
+
+// listedObject 各节点上同一个 key 的副本
+type listedObject struct {
+	key    string
+	copies map[string]backendCopy
+}
+
+// nodeListing 按 key 的字典序合并各节点上的对象列表，每个节点只在内存中保留当前的一条记录
+type nodeListing struct {
+	nodes   []types.StorageNodeInfo
+	streams []<-chan minio.ObjectInfo
+	heads   []*minio.ObjectInfo
+	cancel  context.CancelFunc
+}
+
+// listNodeObjects 开始列出各节点上桶内的对象
+func listNodeObjects(ctx context.Context, bucketName string, nodes []types.StorageNodeInfo) (*nodeListing, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &nodeListing{
+		nodes:   nodes,
+		streams: make([]<-chan minio.ObjectInfo, len(nodes)),
+		heads:   make([]*minio.ObjectInfo, len(nodes)),
+		cancel:  cancel,
+	}
+	for i, node := range nodes {
+		l.streams[i] = minIo.GetNodeClient(node).MinioCore.Client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true})
+		if err := l.advance(i); err != nil {
+			l.close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// advance 读取第 i 个节点的下一个对象，读完或节点上没有该桶时 heads[i] 为 nil
+func (l *nodeListing) advance(i int) error {
+	object, ok := <-l.streams[i]
+	l.heads[i] = nil
+	if !ok {
+		return nil
+	}
+	if object.Err != nil {
+		if minio.ToErrorResponse(object.Err).Code == "NoSuchBucket" {
+			return nil
+		}
+		return fmt.Errorf("list objects on node %s: %w", l.nodes[i].ID, object.Err)
+	}
+	l.heads[i] = &object
+	return nil
+}
+
+// next 返回接下来最多 limit 个 key 及其副本，在 before 之后写入的副本不参与比较
+func (l *nodeListing) next(limit int, before time.Time) ([]listedObject, error) {
+	batch := make([]listedObject, 0, limit)
+	for len(batch) < limit {
+		key := ""
+		for _, head := range l.heads {
+			if head != nil && (key == "" || head.Key < key) {
+				key = head.Key
+			}
+		}
+		if key == "" {
+			break
+		}
+		object := listedObject{key: key, copies: make(map[string]backendCopy)}
+		for i, head := range l.heads {
+			if head == nil || head.Key != key {
+				continue
+			}
+			if !head.LastModified.After(before) {
+				object.copies[l.nodes[i].ID] = backendCopy{node: l.nodes[i], info: *head}
+			}
+			if err := l.advance(i); err != nil {
+				return nil, err
+			}
+		}
+		if len(object.copies) > 0 {
+			batch = append(batch, object)
+		}
+	}
+	return batch, nil
+}
+
+// close 停止列出对象
+func (l *nodeListing) close() {
+	l.cancel()
+}
+
+// compareCopy 比较副本与元数据，一致时返回空
+func compareCopy(meta *dbm.ObjectMetadata, info minio.ObjectInfo) string {
+	if info.Size != meta.Size {
+		return fmt.Sprintf("size %d, expected %d", info.Size, meta.Size)
+	}
+	if etag := strings.Trim(info.ETag, "\""); meta.ETag != "" && etag != meta.ETag {
+		return fmt.Sprintf("etag %s, expected %s", etag, meta.ETag)
+	}
+	return ""
+}
+
+func sameContent(copies []backendCopy) bool {
+	for _, c := range copies[1:] {
+		if c.info.Size != copies[0].info.Size || c.info.ETag != copies[0].info.ETag {
+			return false
+		}
+	}
+	return true
+}
+
+func removeCopy(ctx context.Context, bucketName, objectName string, node types.StorageNodeInfo) error {
+	return minIo.GetNodeClient(node).MinioCore.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+}
+
+// addReconcileItem 记录一条不一致并返回它在 report.Items 中的下标，超过 MaxReconcileItems 后只计数并返回 -1
+func addReconcileItem(report *types.ReconcileReport, kind, bucketName, objectName, nodeID, detail string) int {
+	if len(report.Items) >= types.MaxReconcileItems {
+		return -1
+	}
+	report.Items = append(report.Items, types.ReconcileItem{
+		Kind:       kind,
+		BucketName: bucketName,
+		ObjectName: objectName,
+		NodeID:     nodeID,
+		Detail:     detail,
+	})
+	return len(report.Items) - 1
+}
+
+func markFixed(report *types.ReconcileReport, item int) {
+	if item >= 0 {
+		report.Items[item].Fixed = true
+	}
+	report.Fixed++
+}
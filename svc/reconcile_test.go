@@ -0,0 +1,213 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// listOrphan 列出各节点上 objectName 的副本，返回对账时看到的结果
+func listOrphan(t *testing.T, bucketName, objectName string) map[string]backendCopy {
+	t.Helper()
+	nodes, err := minIo.GetStorageNodes()
+	if err != nil {
+		t.Fatalf("get storage nodes: %v", err)
+	}
+	listing, err := listNodeObjects(context.Background(), bucketName, nodes)
+	if err != nil {
+		t.Fatalf("list objects: %v", err)
+	}
+	defer listing.close()
+	batch, err := listing.next(reconcileBatchSize, time.Now())
+	if err != nil {
+		t.Fatalf("list objects: %v", err)
+	}
+	for _, object := range batch {
+		if object.key == objectName {
+			return object.copies
+		}
+	}
+	t.Fatalf("%s/%s not listed", bucketName, objectName)
+	return nil
+}
+
+func TestReconcileAdoptsOrphan(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-1"}, "photos", "orphan.txt", "no metadata")
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-2"}, "photos", "orphan.txt", "no metadata")
+
+	report, err := NewReconcileSvc(env.dao).Reconcile(ctx, ReconcileOptions{BucketName: "photos", Fix: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Orphans != 2 || report.Fixed != 2 {
+		t.Fatalf("orphans = %d, fixed = %d, want 2 and 2", report.Orphans, report.Fixed)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "orphan.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if len(meta.Nodes()) != 2 || meta.Size != int64(len("no metadata")) {
+		t.Fatalf("adopted metadata size %d on nodes %v", meta.Size, meta.Nodes())
+	}
+	if content, _ := env.getObject(t, "photos", "orphan.txt"); content != "no metadata" {
+		t.Fatalf("content = %q", content)
+	}
+}
+
+func TestAdoptOrphanSkipsDeletedObject(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "deleted later")
+	if err := env.dao.MetadataNode.DeleteObjectMetadata(ctx, "photos", "a.txt"); err != nil {
+		t.Fatalf("delete metadata: %v", err)
+	}
+	// 删除请求已删除元数据，对账列出了尚未删除的数据
+	copies := listOrphan(t, "photos", "a.txt")
+	for _, backend := range env.nodes {
+		_, _ = backend.DeleteObject("photos", "a.txt")
+	}
+
+	report := &types.ReconcileReport{StartedAt: time.Now()}
+	if err := NewReconcileSvc(env.dao).adoptOrphan(ctx, "photos", "a.txt", copies, true, report); err != nil {
+		t.Fatalf("adopt orphan: %v", err)
+	}
+	_, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("deleted object resurrected: %v", err)
+	}
+}
+
+func TestReconcileSkipsRenamedSource(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "renamed")
+	// 重命名后源对象的数据删除失败，等待后台任务重试
+	renameLeavingSource(t, env, "photos", "a.txt", "b.txt")()
+
+	report, err := NewReconcileSvc(env.dao).Reconcile(ctx, ReconcileOptions{BucketName: "photos", Fix: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Orphans != 0 {
+		t.Fatalf("orphans = %d, want 0", report.Orphans)
+	}
+	if _, err = env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("renamed source resurrected: %v", err)
+	}
+	// 对账列出数据之后才重命名的情况，在对象锁内确认
+	copies := listOrphan(t, "photos", "a.txt")
+	if err = NewReconcileSvc(env.dao).adoptOrphan(ctx, "photos", "a.txt", copies, true, report); err != nil {
+		t.Fatalf("adopt orphan: %v", err)
+	}
+	if _, err = env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("renamed source resurrected: %v", err)
+	}
+}
+
+func TestAdoptOrphanSkipsCommittedUpload(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	overwriteReplica(t, env, types.StorageNodeInfo{ID: "node-1"}, "photos", "a.txt", "partial")
+	copies := listOrphan(t, "photos", "a.txt")
+	// 列出之后上传完成并写入了元数据
+	env.putObject(t, "photos", "a.txt", "uploaded")
+	want, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+
+	report := &types.ReconcileReport{StartedAt: time.Now()}
+	if err = NewReconcileSvc(env.dao).adoptOrphan(ctx, "photos", "a.txt", copies, true, report); err != nil {
+		t.Fatalf("adopt orphan: %v", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if meta.ETag != want.ETag || meta.StorageNodes != want.StorageNodes {
+		t.Fatalf("metadata replaced with %s on %s", meta.ETag, meta.StorageNodes)
+	}
+}
+
+func TestReconcileDeletesMetadataWithoutData(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "lost")
+	env.putObject(t, "photos", "b.txt", "kept")
+	for _, backend := range env.nodes {
+		_, _ = backend.DeleteObject("photos", "a.txt")
+	}
+
+	report, err := NewReconcileSvc(env.dao).Reconcile(ctx, ReconcileOptions{BucketName: "photos", Fix: true})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if report.Objects != 2 || report.Dangling != 2 {
+		t.Fatalf("objects = %d, dangling = %d, want 2 and 2", report.Objects, report.Dangling)
+	}
+	if _, err = env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("metadata of lost object not deleted: %v", err)
+	}
+	if _, err = env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "b.txt"); err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+}
+
+func TestNodeListingMergesNodes(t *testing.T) {
+	env := newTestEnv(t, 3)
+	for id, keys := range map[string][]string{
+		"node-1": {"a", "c", "e"},
+		"node-2": {"b", "c"},
+		// node-3 上没有该桶
+	} {
+		for _, key := range keys {
+			overwriteReplica(t, env, types.StorageNodeInfo{ID: id}, "photos", key, key)
+		}
+	}
+	nodes, err := minIo.GetStorageNodes()
+	if err != nil {
+		t.Fatalf("get storage nodes: %v", err)
+	}
+	listing, err := listNodeObjects(context.Background(), "photos", nodes)
+	if err != nil {
+		t.Fatalf("list objects: %v", err)
+	}
+	defer listing.close()
+
+	var keys []string
+	copies := make(map[string]int)
+	for {
+		batch, err := listing.next(2, time.Now())
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if len(batch) > 2 {
+			t.Fatalf("batch of %d objects, limit 2", len(batch))
+		}
+		for _, object := range batch {
+			keys = append(keys, object.key)
+			copies[object.key] = len(object.copies)
+		}
+	}
+	want := []string{"a", "b", "c", "e"}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("keys = %v, want %v", keys, want)
+		}
+	}
+	if copies["c"] != 2 {
+		t.Fatalf("c has %d copies, want 2", copies["c"])
+	}
+}
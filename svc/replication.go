@@ -0,0 +1,553 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/metrics"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/replicaauth"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxReplicationRules     = 100
+	maxReplicationRuleID    = 255
+	maxReplicationLastError = 1024
+	resyncBatchSize         = 500
+)
+
+// ReplicationSvc 读写桶的复制规则，处理复制队列中的任务并统计积压情况
+type ReplicationSvc struct {
+	bucketDao      *dao.Bucket
+	replicationDao *dao.Replication
+	metadataDao    *dao.MetadataNode
+	storageSvc     *StorageNodeSvc
+}
+
+func NewReplicationSvc(s *dao.S) *ReplicationSvc {
+	return &ReplicationSvc{
+		bucketDao:      s.Bucket,
+		replicationDao: s.Replication,
+		metadataDao:    s.MetadataNode,
+		storageSvc:     NewStorageNodeSvc(s),
+	}
+}
+
+// GetReplication 返回桶的复制规则，不返回目标的 SecretKey
+func (m *ReplicationSvc) GetReplication(ctx context.Context, bucketName string) (types.BucketReplication, error) {
+	bucket, err := getBucket(ctx, m.bucketDao, bucketName)
+	if err != nil {
+		return types.BucketReplication{}, err
+	}
+	replication, err := parseReplication(bucket.Replication)
+	if err != nil {
+		return replication, err
+	}
+	return redactReplication(replication), nil
+}
+
+// PutReplication 整体替换桶的复制规则，规则未填 SecretKey 时沿用同 ID 且目标未变的旧规则中的值，
+// 目标的类型、地址、桶或 AccessKey 变化时必须重新填写 SecretKey，避免把旧凭证发给新目标
+func (m *ReplicationSvc) PutReplication(ctx context.Context, bucketName string, replication types.BucketReplication) (types.BucketReplication, error) {
+	bucket, err := getBucket(ctx, m.bucketDao, bucketName)
+	if err != nil {
+		return replication, err
+	}
+	old, err := parseReplication(bucket.Replication)
+	if err != nil {
+		return replication, err
+	}
+	if err = normalizeReplication(bucketName, &replication); err != nil {
+		return replication, err
+	}
+	secrets := make(map[string]types.ReplicationTarget)
+	for _, rule := range old.Rules {
+		secrets[rule.ID] = rule.Target
+	}
+	for i := range replication.Rules {
+		target := &replication.Rules[i].Target
+		if prev, ok := secrets[replication.Rules[i].ID]; ok && target.SecretKey == "" && sameReplicationTarget(*target, prev) {
+			target.SecretKey = prev.SecretKey
+		}
+		if target.Type == types.ReplicationTargetGateway && target.SecretKey == "" {
+			return replication, fmt.Errorf("%w: rule %s gateway target requires secret_key to sign replica requests",
+				errors.ErrBadRequest, replication.Rules[i].ID)
+		}
+	}
+	data, err := json.Marshal(replication)
+	if err != nil {
+		return replication, err
+	}
+	if err = m.bucketDao.UpdateReplication(ctx, bucketName, string(data)); err != nil {
+		return replication, fmt.Errorf("update replication of bucket %s: %w", bucketName, err)
+	}
+	return redactReplication(replication), nil
+}
+
+// sameReplicationTarget 判断两个复制目标除 SecretKey 外是否相同
+func sameReplicationTarget(a, b types.ReplicationTarget) bool {
+	return a.Type == b.Type && a.Endpoint == b.Endpoint && a.Bucket == b.Bucket && a.AccessKey == b.AccessKey
+}
+
+// DeleteReplication 删除桶的复制规则，队列中已有的任务在处理时丢弃
+func (m *ReplicationSvc) DeleteReplication(ctx context.Context, bucketName string) error {
+	if _, err := getBucket(ctx, m.bucketDao, bucketName); err != nil {
+		return err
+	}
+	if err := m.bucketDao.UpdateReplication(ctx, bucketName, ""); err != nil {
+		return fmt.Errorf("delete replication of bucket %s: %w", bucketName, err)
+	}
+	return nil
+}
+
+// Stats 返回复制队列的积压情况，同时更新对应的监控指标
+func (m *ReplicationSvc) Stats(ctx context.Context) (types.ReplicationStats, error) {
+	stats := types.ReplicationStats{}
+	var err error
+	if stats.Pending, err = m.replicationDao.CountByState(ctx, types.ReplicationStatusPending); err != nil {
+		return stats, fmt.Errorf("count pending replication tasks: %w", err)
+	}
+	if stats.Failed, err = m.replicationDao.CountByState(ctx, types.ReplicationStatusFailed); err != nil {
+		return stats, fmt.Errorf("count failed replication tasks: %w", err)
+	}
+	oldest, err := m.replicationDao.OldestTask(ctx, types.ReplicationStatusPending)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return stats, fmt.Errorf("get oldest replication task: %w", err)
+	}
+	var lag time.Duration
+	if oldest != nil {
+		stats.OldestPending = &oldest.CreatedAt
+		lag = time.Since(oldest.CreatedAt)
+		stats.LagSeconds = lag.Seconds()
+	}
+	metrics.SetReplicationBacklog(stats.Pending, stats.Failed, lag)
+	return stats, nil
+}
+
+// ListTasks 分页查询复制队列中的任务
+func (m *ReplicationSvc) ListTasks(ctx context.Context, req types.ListReplicationTaskReq) (*dao.PagedData, error) {
+	page := dao.NewPageCondition(req.Current, req.PageSize)
+	tasks, count, err := m.replicationDao.ListTasks(ctx, req.BucketName, req.State, page)
+	if err != nil {
+		return nil, err
+	}
+	return &dao.PagedData{
+		Results:  tasks,
+		Count:    count,
+		Current:  page.CurrentPage(),
+		PageSize: page.PageSize(),
+	}, nil
+}
+
+// Resync 将桶内所有匹配复制规则的已有对象重新加入复制队列，包括复制失败的对象。
+// 由其他集群复制来的对象不会再向外复制。
+func (m *ReplicationSvc) Resync(ctx context.Context, bucketName string) (types.ReplicationResyncReport, error) {
+	report := types.ReplicationResyncReport{}
+	bucket, err := getBucket(ctx, m.bucketDao, bucketName)
+	if err != nil {
+		return report, err
+	}
+	replication, err := parseReplication(bucket.Replication)
+	if err != nil {
+		return report, err
+	}
+	var afterID uint
+	for {
+		batch, err := m.metadataDao.ListBucketObjectMetadataAfter(ctx, bucketName, afterID, resyncBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("list objects of bucket %s: %w", bucketName, err)
+		}
+		if len(batch) == 0 {
+			return report, nil
+		}
+		afterID = batch[len(batch)-1].Id
+		tasks := make([]*dbm.ReplicationTask, 0, len(batch))
+		ids := make([]uint, 0, len(batch))
+		for _, meta := range batch {
+			report.Objects++
+			if meta.ReplicationStatus == types.ReplicationStatusReplica {
+				continue
+			}
+			rule := matchReplicationRule(replication, meta.ObjectName)
+			if rule == nil {
+				continue
+			}
+			tasks = append(tasks, newReplicationTask(rule, types.ReplicationOpPut, meta))
+			ids = append(ids, meta.Id)
+		}
+		if err = m.replicationDao.EnqueueTasks(ctx, tasks); err != nil {
+			return report, fmt.Errorf("enqueue replication tasks: %w", err)
+		}
+		if err = m.metadataDao.SetReplicationStatus(ctx, ids, types.ReplicationStatusPending); err != nil {
+			return report, fmt.Errorf("mark objects pending: %w", err)
+		}
+		report.Queued += int64(len(tasks))
+	}
+}
+
+// Run 处理到了重试时间的复制任务，直到队列中没有到期的任务，返回处理的任务数。
+// 不同对象的任务并发执行，同一对象的任务按入队顺序依次执行，避免旧的写入覆盖新的写入或删除
+func (m *ReplicationSvc) Run(ctx context.Context, c config.ReplicationConfig) (int, error) {
+	processed := 0
+	for {
+		if err := fencing.Check(ctx); err != nil {
+			return processed, err
+		}
+		batch, err := m.replicationDao.ListDueTasks(ctx, types.ReplicationStatusPending, time.Now(), c.BatchSize)
+		if err != nil {
+			return processed, fmt.Errorf("list replication tasks: %w", err)
+		}
+		if len(batch) == 0 {
+			return processed, nil
+		}
+		sem := make(chan struct{}, c.Concurrency)
+		wg := new(sync.WaitGroup)
+		for _, tasks := range groupReplicationTasks(batch) {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return processed, ctx.Err()
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(tasks []*dbm.ReplicationTask) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				for _, task := range tasks {
+					m.process(ctx, c, task)
+				}
+			}(tasks)
+		}
+		wg.Wait()
+		processed += len(batch)
+	}
+}
+
+// groupReplicationTasks 按对象分组，组内按任务 id 即入队顺序排列
+func groupReplicationTasks(batch []*dbm.ReplicationTask) [][]*dbm.ReplicationTask {
+	index := make(map[string]int)
+	groups := make([][]*dbm.ReplicationTask, 0, len(batch))
+	for _, task := range batch {
+		key := task.BucketName + "/" + task.ObjectName
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], task)
+	}
+	for _, tasks := range groups {
+		sort.Slice(tasks, func(i, j int) bool { return tasks[i].Id < tasks[j].Id })
+	}
+	return groups
+}
+
+// process 执行一个复制任务，失败时按退避时间安排重试，重试次数用完后标记为 FAILED
+func (m *ReplicationSvc) process(ctx context.Context, c config.ReplicationConfig, task *dbm.ReplicationTask) {
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: task.BucketName, log.FieldObject: task.ObjectName})
+	err := m.replicate(ctx, task)
+	metrics.ObserveReplication(task.Operation, time.Since(task.CreatedAt), err)
+	if err == nil {
+		if task.Operation == types.ReplicationOpPut {
+			err = m.metadataDao.UpdateReplicationStatus(ctx, task.BucketName, task.ObjectName, task.ETag, types.ReplicationStatusCompleted)
+			if err != nil {
+				log.Ctx(ctx).Warnf("mark object replicated failed: %v", err)
+			}
+		}
+		if err = m.replicationDao.DeleteTask(ctx, task); err != nil {
+			log.Ctx(ctx).Warnf("delete replication task failed: %v", err)
+		}
+		return
+	}
+
+	task.Attempts++
+	task.LastError = err.Error()
+	if len(task.LastError) > maxReplicationLastError {
+		task.LastError = task.LastError[:maxReplicationLastError]
+	}
+	task.NextAttemptAt = time.Now().Add(replicationBackoff(c, task.Attempts))
+	if task.Attempts >= c.MaxAttempts {
+		task.State = types.ReplicationStatusFailed
+		log.Ctx(ctx).Errorf("replicate %s failed after %d attempts: %v", task.Operation, task.Attempts, err)
+		if task.Operation == types.ReplicationOpPut {
+			err := m.metadataDao.UpdateReplicationStatus(ctx, task.BucketName, task.ObjectName, task.ETag, types.ReplicationStatusFailed)
+			if err != nil {
+				log.Ctx(ctx).Warnf("mark object replication failed: %v", err)
+			}
+		}
+	} else {
+		log.Ctx(ctx).Warnf("replicate %s failed, attempt %d: %v", task.Operation, task.Attempts, err)
+	}
+	if err = m.replicationDao.UpdateAttempt(ctx, task); err != nil {
+		log.Ctx(ctx).Warnf("update replication task failed: %v", err)
+	}
+}
+
+// replicate 将任务对应的写入或删除发送到规则的目标。
+// 规则已被删除或停用、对象已被删除或重新写入时任务已经过期，直接视为完成。
+func (m *ReplicationSvc) replicate(ctx context.Context, task *dbm.ReplicationTask) error {
+	bucket, err := m.bucketDao.Get(ctx, task.BucketName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get bucket %s: %w", task.BucketName, err)
+	}
+	replication, err := parseReplication(bucket.Replication)
+	if err != nil {
+		return err
+	}
+	var rule *types.ReplicationRule
+	for i := range replication.Rules {
+		if replication.Rules[i].ID == task.RuleID && replication.Rules[i].Status == types.ReplicationRuleEnabled {
+			rule = &replication.Rules[i]
+		}
+	}
+	if rule == nil {
+		log.Ctx(ctx).Infof("replication rule %s no longer enabled, drop task", task.RuleID)
+		return nil
+	}
+	target, err := newReplicationTarget(rule.Target)
+	if err != nil {
+		return err
+	}
+	if task.Operation == types.ReplicationOpDelete {
+		return target.Delete(ctx, task.ObjectName)
+	}
+
+	meta, err := m.metadataDao.GetObjectMetadata(ctx, task.BucketName, task.ObjectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get object metadata: %w", err)
+	}
+	if meta.ETag != task.ETag {
+		return nil
+	}
+	object, info, err := m.storageSvc.GetObject(ctx, task.BucketName, task.ObjectName)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	contentType := meta.ContentType
+	if contentType == "" {
+		contentType = http.Header(info.Header).Get("Content-Type")
+	}
+	return target.Put(ctx, task.ObjectName, object, meta.Size, contentType)
+}
+
+// replicationBackoff 第 attempts 次失败后到下一次重试的等待时间，从 RetryBaseSeconds 开始每次翻倍
+func replicationBackoff(c config.ReplicationConfig, attempts int) time.Duration {
+	max := time.Duration(c.RetryMaxSeconds) * time.Second
+	d := time.Duration(c.RetryBaseSeconds) * time.Second
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+// replicationRule 返回对象匹配的复制规则，桶未注册或没有匹配的规则时返回 nil
+func (s *StorageNodeSvc) replicationRule(ctx context.Context, bucketName, objectName string) (*types.ReplicationRule, error) {
+	bucket, err := s.BucketSvc.bucketDao.Get(ctx, bucketName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get bucket %s: %w", bucketName, err)
+	}
+	replication, err := parseReplication(bucket.Replication)
+	if err != nil {
+		return nil, err
+	}
+	return matchReplicationRule(replication, objectName), nil
+}
+
+// replicationTasks 返回对象写入或删除需要加入复制队列的任务，调用方与元数据的修改在同一个事务中提交，
+// 没有匹配的规则或规则不复制删除时返回 nil
+func replicationTasks(rule *types.ReplicationRule, operation string, meta *dbm.ObjectMetadata) []*dbm.ReplicationTask {
+	if rule == nil || (operation == types.ReplicationOpDelete && !rule.DeleteReplication) {
+		return nil
+	}
+	return []*dbm.ReplicationTask{newReplicationTask(rule, operation, meta)}
+}
+
+func newReplicationTask(rule *types.ReplicationRule, operation string, meta *dbm.ObjectMetadata) *dbm.ReplicationTask {
+	now := time.Now()
+	return &dbm.ReplicationTask{
+		BucketName:    meta.BucketName,
+		ObjectName:    meta.ObjectName,
+		Operation:     operation,
+		ETag:          meta.ETag,
+		RuleID:        rule.ID,
+		State:         types.ReplicationStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// matchReplicationRule 返回第一条启用且前缀匹配的规则
+func matchReplicationRule(replication types.BucketReplication, objectName string) *types.ReplicationRule {
+	for i := range replication.Rules {
+		rule := &replication.Rules[i]
+		if rule.Status == types.ReplicationRuleEnabled && strings.HasPrefix(objectName, rule.Prefix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// replicationTarget 复制的目标，key 为对象名
+type replicationTarget interface {
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+}
+
+func newReplicationTarget(t types.ReplicationTarget) (replicationTarget, error) {
+	if t.Type == types.ReplicationTargetGateway {
+		return &gatewayTarget{endpoint: strings.TrimSuffix(t.Endpoint, "/"), bucket: t.Bucket, secret: t.SecretKey}, nil
+	}
+	// 分片大小与 Upload 保持一致，目标上对象的 ETag 与源对象相同
+	return newS3Tier(t.Endpoint, t.AccessKey, t.SecretKey, t.Bucket, minIo.ChunkPartSize)
+}
+
+// gatewayTarget 通过另一个集群网关的 /storage/replica 接口同步写入，写入的对象在目标集群标记为 REPLICA。
+// 请求使用目标的 SecretKey 签名，需要与目标集群配置的 replication.replica_secret 相同
+type gatewayTarget struct {
+	endpoint string
+	bucket   string
+	secret   string
+}
+
+func (t *gatewayTarget) url(key string) string {
+	query := url.Values{"bucket_name": {t.bucket}, "object_name": {key}}
+	return t.endpoint + "/storage/replica?" + query.Encode()
+}
+
+func (t *gatewayTarget) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.url(key), reader)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return t.do(req)
+}
+
+func (t *gatewayTarget) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url(key), nil)
+	if err != nil {
+		return err
+	}
+	return t.do(req)
+}
+
+func (t *gatewayTarget) do(req *http.Request) error {
+	replicaauth.Sign(req, t.secret, time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", req.Method, t.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s: status %d: %s", req.Method, t.endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+func parseReplication(data string) (types.BucketReplication, error) {
+	replication := types.BucketReplication{Rules: []types.ReplicationRule{}}
+	if data == "" {
+		return replication, nil
+	}
+	if err := json.Unmarshal([]byte(data), &replication); err != nil {
+		return replication, fmt.Errorf("parse replication: %w", err)
+	}
+	return replication, nil
+}
+
+// redactReplication 返回去掉 SecretKey 的副本
+func redactReplication(replication types.BucketReplication) types.BucketReplication {
+	rules := make([]types.ReplicationRule, len(replication.Rules))
+	for i, rule := range replication.Rules {
+		rule.Target.SecretKey = ""
+		rules[i] = rule
+	}
+	return types.BucketReplication{Rules: rules}
+}
+
+// normalizeReplication 补齐默认值并检查规则，目标桶默认与源桶同名
+func normalizeReplication(bucketName string, replication *types.BucketReplication) error {
+	if len(replication.Rules) > maxReplicationRules {
+		return fmt.Errorf("%w: at most %d replication rules", errors.ErrBadRequest, maxReplicationRules)
+	}
+	if replication.Rules == nil {
+		replication.Rules = []types.ReplicationRule{}
+	}
+	ids := make(map[string]bool)
+	for i := range replication.Rules {
+		rule := &replication.Rules[i]
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if len(rule.ID) > maxReplicationRuleID || ids[rule.ID] {
+			return fmt.Errorf("%w: rule id %q is too long or duplicated", errors.ErrBadRequest, rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Status == "" {
+			rule.Status = types.ReplicationRuleEnabled
+		}
+		if rule.Status != types.ReplicationRuleEnabled && rule.Status != types.ReplicationRuleDisabled {
+			return fmt.Errorf("%w: rule %s status %q is not one of Enabled, Disabled", errors.ErrBadRequest, rule.ID, rule.Status)
+		}
+		target := &rule.Target
+		if target.Type == "" {
+			target.Type = types.ReplicationTargetS3
+		}
+		if target.Bucket == "" {
+			target.Bucket = bucketName
+		}
+		if err := validateBucketName(target.Bucket); err != nil {
+			return err
+		}
+		if target.Endpoint == "" {
+			return fmt.Errorf("%w: rule %s has no target endpoint", errors.ErrBadRequest, rule.ID)
+		}
+		switch target.Type {
+		case types.ReplicationTargetS3:
+			if strings.Contains(strings.TrimPrefix(strings.TrimPrefix(target.Endpoint, "https://"), "http://"), "/") {
+				return fmt.Errorf("%w: rule %s s3 endpoint must be host:port", errors.ErrBadRequest, rule.ID)
+			}
+		case types.ReplicationTargetGateway:
+			u, err := url.Parse(target.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: rule %s gateway endpoint must be an http(s) url", errors.ErrBadRequest, rule.ID)
+			}
+		default:
+			return fmt.Errorf("%w: rule %s target type %q is not one of s3, gateway", errors.ErrBadRequest, rule.ID, target.Type)
+		}
+	}
+	return nil
+}
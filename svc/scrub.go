@@ -0,0 +1,154 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+const (
+	scrubReportKey = "scrub:report"
+	scrubCursorKey = "scrub:cursor"
+)
+
+// ScrubSvc 读写副本校验的进度、每个桶的校验报告和发现的问题
+type ScrubSvc struct {
+	scrubDao    *dao.Scrub
+	metadataDao *dao.MetadataNode
+}
+
+func NewScrubSvc(s *dao.S) *ScrubSvc {
+	return &ScrubSvc{
+		scrubDao:    s.Scrub,
+		metadataDao: s.MetadataNode,
+	}
+}
+
+// GetReports 返回所有桶最近一次的校验报告，按桶名排序
+func (m *ScrubSvc) GetReports(ctx context.Context) ([]types.ScrubReport, error) {
+	values, err := redis.Redis().HGetAll(ctx, scrubReportKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get scrub report: %w", err)
+	}
+	res := make([]types.ScrubReport, 0, len(values))
+	for _, value := range values {
+		report := types.ScrubReport{}
+		if err = json.Unmarshal([]byte(value), &report); err != nil {
+			return nil, fmt.Errorf("unmarshal scrub report: %w", err)
+		}
+		res = append(res, report)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].BucketName < res[j].BucketName
+	})
+	return res, nil
+}
+
+func (m *ScrubSvc) SaveReport(ctx context.Context, report types.ScrubReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().HSet(ctx, scrubReportKey, report.BucketName, data).Err()
+}
+
+// GetCursor 返回未完成的校验进度，没有时返回 nil
+func (m *ScrubSvc) GetCursor(ctx context.Context) (*types.ScrubCursor, error) {
+	data, err := redis.Redis().Get(ctx, scrubCursorKey).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get scrub cursor: %w", err)
+	}
+	cursor := &types.ScrubCursor{}
+	if err = json.Unmarshal(data, cursor); err != nil {
+		return nil, fmt.Errorf("unmarshal scrub cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+func (m *ScrubSvc) SaveCursor(ctx context.Context, cursor types.ScrubCursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, scrubCursorKey, data, 0).Err()
+}
+
+func (m *ScrubSvc) ClearCursor(ctx context.Context) error {
+	return redis.Redis().Del(ctx, scrubCursorKey).Err()
+}
+
+func (m *ScrubSvc) RecordIssue(ctx context.Context, issue *dbm.ScrubIssue) error {
+	return m.scrubDao.CreateIssue(ctx, issue)
+}
+
+// ListIssues 分页查询校验发现的问题
+func (m *ScrubSvc) ListIssues(ctx context.Context, bucketName string, current, pageSize int) (*dao.PagedData, error) {
+	page := dao.NewPageCondition(current, pageSize)
+	issues, count, err := m.scrubDao.ListIssues(ctx, bucketName, page)
+	if err != nil {
+		return nil, err
+	}
+	return &dao.PagedData{
+		Results:  issues,
+		Count:    count,
+		Current:  page.CurrentPage(),
+		PageSize: page.PageSize(),
+	}, nil
+}
+
+// RepairReplica 在对象锁内从 sources 中与元数据一致的副本复制到 target 节点。
+// 校验之后对象被重写、删除或转移到冷存储时返回 ErrPreconditionFailed，不修复；没有一致的副本时返回错误。
+func (m *ScrubSvc) RepairReplica(ctx context.Context, scanned *dbm.ObjectMetadata, target types.StorageNodeInfo,
+	sources []types.StorageNodeInfo, limiter *rate.Limiter) error {
+	lock, err := lockObject(ctx, scanned.BucketName, scanned.ObjectName)
+	if err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	meta, err := m.metadataDao.GetObjectMetadata(ctx, scanned.BucketName, scanned.ObjectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: object deleted after scrub", errors.ErrPreconditionFailed)
+	}
+	if err != nil {
+		return fmt.Errorf("get object metadata: %w", err)
+	}
+	if meta.ETag != scanned.ETag || meta.IsCold() {
+		return fmt.Errorf("%w: object changed after scrub", errors.ErrPreconditionFailed)
+	}
+	if meta.ETag == "" {
+		return fmt.Errorf("no etag in metadata to choose a replica")
+	}
+	dst := minIo.GetNodeClient(target)
+	for _, node := range sources {
+		src := minIo.GetNodeClient(node)
+		info, err := src.MinioCore.Client.StatObject(ctx, meta.BucketName, meta.ObjectName, minio.StatObjectOptions{})
+		if err != nil || info.Size != meta.Size || strings.Trim(info.ETag, "\"") != meta.ETag {
+			continue
+		}
+		uploadInfo, err := dst.CopyObjectFromWithLimit(ctx, src, meta.BucketName, meta.ObjectName, limiter)
+		if err != nil {
+			return fmt.Errorf("copy from %s: %w", node.ID, err)
+		}
+		if etag := strings.Trim(uploadInfo.ETag, "\""); etag != meta.ETag {
+			return fmt.Errorf("repaired replica has etag %s, expected %s", etag, meta.ETag)
+		}
+		return checkObjectLock(ctx, lock)
+	}
+	return fmt.Errorf("no replica matches etag %s", meta.ETag)
+}
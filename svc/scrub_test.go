@@ -0,0 +1,115 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/types"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrubNodes 返回对象的两个副本所在节点和一个没有副本的节点
+func scrubNodes(t *testing.T, meta *dbm.ObjectMetadata) (first, second, spare types.StorageNodeInfo) {
+	t.Helper()
+	nodes, err := minIo.GetStorageNodes()
+	if err != nil {
+		t.Fatalf("get storage nodes: %v", err)
+	}
+	recorded := meta.Nodes()
+	for _, node := range nodes {
+		switch node.ID {
+		case recorded[0]:
+			first = node
+		case recorded[1]:
+			second = node
+		default:
+			spare = node
+		}
+	}
+	return first, second, spare
+}
+
+// overwriteReplica 直接改写节点上的副本，模拟损坏或过期的数据
+func overwriteReplica(t *testing.T, env *testEnv, node types.StorageNodeInfo, bucketName, objectName, content string) {
+	t.Helper()
+	backend := env.nodes[node.ID]
+	if exists, _ := backend.BucketExists(bucketName); !exists {
+		if err := backend.CreateBucket(bucketName); err != nil {
+			t.Fatalf("create bucket on %s: %v", node.ID, err)
+		}
+	}
+	// 直接写入存储时 gofakes3 不会补充 Last-Modified，读取对象信息时需要该字段
+	meta := map[string]string{"Last-Modified": time.Now().UTC().Format(http.TimeFormat)}
+	_, err := backend.PutObject(bucketName, objectName, meta, strings.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("overwrite replica on %s: %v", node.ID, err)
+	}
+}
+
+func TestRepairReplicaUsesMatchingSource(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "original")
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	damaged, good, spare := scrubNodes(t, meta)
+	overwriteReplica(t, env, damaged, "photos", "a.txt", "bitflip")
+	// spare 上残留的旧数据大小相同但 ETag 不同，不能作为修复的来源
+	overwriteReplica(t, env, spare, "photos", "a.txt", "stale!!!")
+
+	err = NewScrubSvc(env.dao).RepairReplica(ctx, meta, damaged, []types.StorageNodeInfo{spare, good}, nil)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if got := readBackend(t, env.nodes[damaged.ID], "photos", "a.txt"); got != "original" {
+		t.Fatalf("repaired replica = %q", got)
+	}
+}
+
+func TestRepairReplicaWithoutMatchingSource(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "original")
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	damaged, _, spare := scrubNodes(t, meta)
+	overwriteReplica(t, env, damaged, "photos", "a.txt", "bitflip")
+	overwriteReplica(t, env, spare, "photos", "a.txt", "stale!!!")
+
+	err = NewScrubSvc(env.dao).RepairReplica(ctx, meta, damaged, []types.StorageNodeInfo{spare}, nil)
+	if err == nil {
+		t.Fatal("repaired from a replica that does not match the metadata")
+	}
+	if got := readBackend(t, env.nodes[damaged.ID], "photos", "a.txt"); got != "bitflip" {
+		t.Fatalf("damaged replica overwritten with %q", got)
+	}
+}
+
+func TestRepairReplicaSkipsModifiedObject(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "v1")
+	scanned, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	damaged, good, _ := scrubNodes(t, scanned)
+	// 校验之后对象被重新写入
+	env.putObject(t, "photos", "a.txt", "v2")
+
+	err = NewScrubSvc(env.dao).RepairReplica(ctx, scanned, damaged, []types.StorageNodeInfo{good}, nil)
+	if !errors.Is(err, errors.ErrPreconditionFailed) {
+		t.Fatalf("repair = %v, want ErrPreconditionFailed", err)
+	}
+	if got := readBackend(t, env.nodes[damaged.ID], "photos", "a.txt"); got != "v2" {
+		t.Fatalf("replica = %q, want the new write", got)
+	}
+}
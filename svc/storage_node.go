@@ -3,16 +3,26 @@ package svc
 import (
 	"context"
 	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/lifecycle"
+	"distributed-object-storage/pkg/log"
 	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/placement"
+	"distributed-object-storage/pkg/tracing"
+	"distributed-object-storage/redis"
 	"distributed-object-storage/types"
 	"errors"
 	"fmt"
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
 	//"github.com/minio/minio-go/v7"
 	"io"
-	"log"
-	"strconv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
 )
 
 type StorageNode interface {
@@ -21,10 +31,75 @@ type StorageNode interface {
 }
 
 type StorageNodeSvc struct {
+	MetaDataDao    *dao.MetadataNode
+	ReplicationDao *dao.Replication
+	CleanupDao     *dao.Cleanup
+	BucketSvc      *BucketSvc
+	QuotaSvc       *QuotaSvc
 }
 
 func NewStorageNodeSvc(s *dao.S) *StorageNodeSvc {
-	return &StorageNodeSvc{}
+	return &StorageNodeSvc{
+		MetaDataDao:    s.MetadataNode,
+		ReplicationDao: s.Replication,
+		CleanupDao:     s.Cleanup,
+		BucketSvc:      NewBucketSvc(s),
+		QuotaSvc:       NewQuotaSvc(s),
+	}
+}
+
+// Uploads 请求返回后仍在后台进行的上传，服务关闭时等待它们完成
+var Uploads = new(lifecycle.Tracker)
+
+// placeObject 按桶的放置策略为新写入的对象选择节点，第一个为主副本
+func placeObject(ctx context.Context, policy *placement.Policy, bucketName, objectName string) ([]types.StorageNodeInfo, error) {
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get storage nodes: %w", err)
+	}
+	return policy.Place(placement.Key(bucketName, objectName), nodes)
+}
+
+// locateObject 返回读取对象时依次尝试的节点：元数据中记录的节点在前，其余按放置得分排序
+func (s *StorageNodeSvc) locateObject(ctx context.Context, meta *dbm.ObjectMetadata) ([]types.StorageNodeInfo, error) {
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get storage nodes: %w", err)
+	}
+	ranked := placement.Default().Rank(placement.Key(meta.BucketName, meta.ObjectName), nodes)
+	recorded := make(map[string]bool)
+	for _, id := range meta.Nodes() {
+		recorded[id] = true
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return recorded[ranked[i].ID] && !recorded[ranked[j].ID]
+	})
+	return ranked, nil
+}
+
+// usageDelta 写入 size 字节覆盖对象后桶用量的变化，对象不存在时对象数加一
+func (s *StorageNodeSvc) usageDelta(ctx context.Context, bucketName, objectName string, size int64) (types.Usage, error) {
+	meta, err := s.MetaDataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return types.Usage{Bytes: size, Objects: 1}, nil
+	}
+	if err != nil {
+		return types.Usage{}, fmt.Errorf("get object metadata %s/%s: %w", bucketName, objectName, err)
+	}
+	return types.Usage{Bytes: size - meta.Size}, nil
+}
+
+// CheckQuota 检查写入 size 字节的对象是否会超过桶或桶所有者的硬配额，超过时返回 QuotaExceeded
+func (s *StorageNodeSvc) CheckQuota(ctx context.Context, bucketName, objectName string, size int64) error {
+	bucket, err := s.BucketSvc.Lookup(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	delta, err := s.usageDelta(ctx, bucketName, objectName, size)
+	if err != nil {
+		return err
+	}
+	return s.QuotaSvc.Check(ctx, bucket, delta)
 }
 
 /*
@@ -45,17 +120,152 @@ PutObject 存储⼀个完整的对象。
   - 实现数据的冗余存储或纠删码
   - 考虑磁盘空间管理和数据均衡
 */
-func (s *StorageNodeSvc) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, fileSize int64, UploadID string) (*minio.UploadInfo, error) {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
-	return client.Upload(ctx, bucketName, objectName, reader, fileSize, UploadID)
+func (s *StorageNodeSvc) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, fileSize int64, UploadID string,
+	cond types.WriteCondition) (*minio.UploadInfo, error) {
+	pending, err := s.BeginPutObject(ctx, bucketName, objectName, cond)
+	if err != nil {
+		return nil, err
+	}
+	return pending.Put(ctx, reader, fileSize, UploadID)
+}
+
+// PendingPut 已获取对象锁并通过写入条件检查、等待写入数据的上传，由 BeginPutObject 创建
+type PendingPut struct {
+	svc        *StorageNodeSvc
+	bucketName string
+	objectName string
+	lock       *redis.Lock
 }
 
-// 分片上传函数
-func uploadMultipart(bucket *oss.Bucket, objectName string, file io.Reader, partSize int64, metadata map[string]string) error {
-	size, err := strconv.ParseInt(metadata["size"], 10, 64)
+// BeginPutObject 获取对象锁并检查写入条件，条件不满足时返回 ErrPreconditionFailed。
+// 后台上传在返回成功前调用，锁一直持有到 Put 或 Abort 结束，期间其他写入不会改变检查的结果
+func (s *StorageNodeSvc) BeginPutObject(ctx context.Context, bucketName, objectName string, cond types.WriteCondition) (*PendingPut, error) {
+	// 同一对象的并发写入串行执行，持有锁期间检查写入条件，避免两个请求都通过检查后互相覆盖
+	lock, err := lockObject(ctx, bucketName, objectName)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err = s.CheckWriteCondition(ctx, bucketName, objectName, cond); err != nil {
+		unlockObject(ctx, lock)
+		return nil, err
+	}
+	return &PendingPut{svc: s, bucketName: bucketName, objectName: objectName, lock: lock}, nil
+}
+
+// Put 写入对象数据和元数据，结束后释放对象锁
+func (p *PendingPut) Put(ctx context.Context, reader io.Reader, fileSize int64, UploadID string) (*minio.UploadInfo, error) {
+	defer unlockObject(ctx, p.lock)
+	return p.svc.putObject(ctx, p.bucketName, p.objectName, reader, fileSize, UploadID, p.lock, false)
+}
+
+// Abort 放弃写入并释放对象锁
+func (p *PendingPut) Abort(ctx context.Context) {
+	unlockObject(ctx, p.lock)
+}
+
+// PutReplica 写入由其他集群复制来的对象，对象标记为 REPLICA，不会再加入复制队列
+func (s *StorageNodeSvc) PutReplica(ctx context.Context, bucketName, objectName string, reader io.Reader, fileSize int64, UploadID string) (*minio.UploadInfo, error) {
+	lock, err := lockObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockObject(ctx, lock)
+	return s.putObject(ctx, bucketName, objectName, reader, fileSize, UploadID, lock, true)
+}
+
+// putObject 在持有对象锁 lock 时写入对象
+func (s *StorageNodeSvc) putObject(ctx context.Context, bucketName, objectName string, reader io.Reader, fileSize int64, UploadID string,
+	lock *redis.Lock, replica bool) (info *minio.UploadInfo, err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.PutObject", append(tracing.Object(bucketName, objectName),
+		attribute.Int64("size", fileSize))...)
+	defer func() { tracing.End(span, err) }()
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: bucketName, log.FieldObject: objectName, log.FieldUploadID: UploadID})
+
+	bucket, err := s.BucketSvc.Lookup(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	// 由其他集群复制来的对象不再向外复制，避免双向复制时来回写入
+	var rule *types.ReplicationRule
+	replicationStatus := types.ReplicationStatusReplica
+	if !replica {
+		if rule, err = s.replicationRule(ctx, bucketName, objectName); err != nil {
+			return nil, err
+		}
+		replicationStatus = ""
+		if rule != nil {
+			replicationStatus = types.ReplicationStatusPending
+		}
+	}
+	// 写入数据前预占用量，超过硬配额时直接拒绝，写入失败时归还
+	delta, err := s.usageDelta(ctx, bucketName, objectName, fileSize)
+	if err != nil {
+		return nil, err
+	}
+	reservation, err := s.QuotaSvc.Reserve(ctx, bucket, delta)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.QuotaSvc.Release(ctx, reservation)
+		}
+	}()
+	policy := placement.Default().ForBucket(bucket)
+	targets, err := placeObject(ctx, policy, bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	primary := minIo.GetNodeClient(targets[0])
+	if err = primary.EnsureBucket(ctx, bucketName); err != nil {
+		return nil, minIo.ToError(err)
+	}
+	uploadInfo, err := primary.Upload(ctx, bucketName, objectName, reader, fileSize, UploadID)
+	if err != nil {
+		return nil, minIo.ToError(err)
+	}
+	stored := []string{targets[0].ID}
+	// 主副本写入成功后从主副本复制到其余节点，单个副本失败不影响本次上传
+	for _, node := range targets[1:] {
+		if _, err := minIo.GetNodeClient(node).CopyObjectFrom(ctx, primary, bucketName, objectName); err != nil {
+			log.Ctx(ctx).WithField(log.FieldNode, node.ID).Warnf("replicate failed: %v", err)
+			continue
+		}
+		stored = append(stored, node.ID)
+	}
+	if len(stored) < policy.Replicas {
+		log.Ctx(ctx).Warnf("stored with %d replicas, less than expected", len(stored))
+	}
+	meta := &dbm.ObjectMetadata{
+		BucketName:   bucketName,
+		ObjectName:   objectName,
+		Size:         fileSize,
+		ETag:         strings.Trim(uploadInfo.ETag, "\""),
+		LastModified: time.Now(),
+		VersionID:    uploadInfo.VersionID,
+		IsLatest:     true,
+		StorageClass: bucket.StorageClass,
 	}
+	meta.ReplicationStatus = replicationStatus
+	meta.SetNodes(stored)
+	// 上传耗时超过锁的有效期时，其他请求可能已经写入了同一对象，此时不再覆盖元数据
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return nil, err
+	}
+	prev, _ := s.MetaDataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	err = s.MetaDataDao.SaveObjectMetadataWithTasks(ctx, meta, replicationTasks(rule, types.ReplicationOpPut, meta))
+	if err != nil {
+		return nil, fmt.Errorf("save object metadata: %w", err)
+	}
+	// 覆盖已转移到冷存储的对象后，冷存储中的旧数据不再被引用
+	if prev != nil && prev.IsCold() {
+		deleteColdCopy(ctx, prev.TierKey)
+	}
+	return uploadInfo, nil
+}
+
+// uploadMultipart 将 size 字节的 file 按 partSize 分片上传到 OSS，options 会传给每一次请求
+func uploadMultipart(bucket *oss.Bucket, objectName string, file io.Reader, size, partSize int64, options ...oss.Option) error {
 	// 将本地文件分片
 	chunks, err := SplitFileByPartSize(size, partSize)
 	if err != nil {
@@ -63,7 +273,7 @@ func uploadMultipart(bucket *oss.Bucket, objectName string, file io.Reader, part
 	}
 
 	// 步骤1：初始化一个分片上传事件。
-	imur, err := bucket.InitiateMultipartUpload(objectName)
+	imur, err := bucket.InitiateMultipartUpload(objectName, options...)
 	if err != nil {
 		return fmt.Errorf("failed to initiate multipart upload: %w", err)
 	}
@@ -71,11 +281,11 @@ func uploadMultipart(bucket *oss.Bucket, objectName string, file io.Reader, part
 	// 步骤2：上传分片。
 	var parts []oss.UploadPart
 	for _, chunk := range chunks {
-		part, err := bucket.UploadPart(imur, file, chunk.Size, chunk.Number)
+		part, err := bucket.UploadPart(imur, file, chunk.Size, chunk.Number, options...)
 		if err != nil {
 			// 如果上传某个部分失败，尝试取消整个上传任务。
-			if abortErr := bucket.AbortMultipartUpload(imur); abortErr != nil {
-				log.Printf("Failed to abort multipart upload: %v", abortErr)
+			if abortErr := bucket.AbortMultipartUpload(imur, options...); abortErr != nil {
+				log.Warnf("Failed to abort multipart upload: %v", abortErr)
 			}
 			return fmt.Errorf("failed to upload part: %w", err)
 		}
@@ -86,16 +296,16 @@ func uploadMultipart(bucket *oss.Bucket, objectName string, file io.Reader, part
 	objectAcl := oss.ObjectACL(oss.ACLPrivate)
 
 	// 步骤3：完成分片上传。
-	_, err = bucket.CompleteMultipartUpload(imur, parts, objectAcl)
+	_, err = bucket.CompleteMultipartUpload(imur, parts, append(options, objectAcl)...)
 	if err != nil {
 		// 如果完成上传失败，尝试取消上传。
-		if abortErr := bucket.AbortMultipartUpload(imur); abortErr != nil {
-			log.Printf("Failed to abort multipart upload: %v", abortErr)
+		if abortErr := bucket.AbortMultipartUpload(imur, options...); abortErr != nil {
+			log.Warnf("Failed to abort multipart upload: %v", abortErr)
 		}
 		return fmt.Errorf("failed to complete multipart upload: %w", err)
 	}
 
-	log.Printf("Multipart upload completed successfully.")
+	log.Info("Multipart upload completed successfully.")
 	return nil
 }
 
@@ -129,12 +339,21 @@ func SplitFileByPartSize(fileSize int64, chunkSize int64) ([]oss.FileChunk, erro
 	return chunks, nil
 }
 
-func (s *StorageNodeSvc) GetObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, types.ObjectInfo, error) {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
-	if client == nil {
-		return nil, types.ObjectInfo{}, errors.New("minio client is nil")
+// GetObject 读取对象，没有元数据的对象视为不存在，返回 NoSuchKey。
+// 重命名或删除提交元数据后残留在节点上的数据不会被读到
+func (s *StorageNodeSvc) GetObject(ctx context.Context, bucketName, objectName string) (_ io.ReadCloser, _ types.ObjectInfo, err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.GetObject", tracing.Object(bucketName, objectName)...)
+	defer func() { tracing.End(span, err) }()
+
+	meta, err := s.getObjectMetadata(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, types.ObjectInfo{}, err
+	}
+	// 已转移到冷存储的对象直接从冷存储读取
+	if meta.IsCold() {
+		return s.getColdObject(ctx, meta)
 	}
-	object, ObjectInfo, Header, err := client.MinioCore.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	object, ObjectInfo, Header, err := s.openReplica(ctx, meta)
 	if err != nil {
 		return nil, types.ObjectInfo{}, err
 	}
@@ -149,10 +368,140 @@ func (s *StorageNodeSvc) GetObject(ctx context.Context, bucketName, objectName s
 	return object, objectInfo, nil
 }
 
-func (s *StorageNodeSvc) DeleteObject(ctx context.Context, bucketName, objectName string) error {
-	client := minIo.GetMinioClient("127.0.0.1:9000")
-	if client == nil {
-		return errors.New("minio client is nil")
+// openReplica 依次尝试各节点，返回第一个可读的副本
+func (s *StorageNodeSvc) openReplica(ctx context.Context, meta *dbm.ObjectMetadata) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	nodes, err := s.locateObject(ctx, meta)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, nil, err
+	}
+	var (
+		object     io.ReadCloser
+		ObjectInfo minio.ObjectInfo
+		Header     http.Header
+	)
+	for _, node := range nodes {
+		client := minIo.GetNodeClient(node)
+		object, ObjectInfo, Header, err = client.MinioCore.GetObject(ctx, meta.BucketName, meta.ObjectName, minio.GetObjectOptions{})
+		if err == nil {
+			return object, ObjectInfo, Header, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no storage node holds %s/%s", meta.BucketName, meta.ObjectName)
+	}
+	return nil, minio.ObjectInfo{}, nil, minIo.ToError(err)
+}
+
+// getColdObject 从冷存储读取对象，对象信息取自元数据
+func (s *StorageNodeSvc) getColdObject(ctx context.Context, meta *dbm.ObjectMetadata) (io.ReadCloser, types.ObjectInfo, error) {
+	tier, err := GetColdTier()
+	if err != nil {
+		return nil, types.ObjectInfo{}, err
+	}
+	object, err := tier.Get(ctx, meta.TierKey)
+	if err != nil {
+		return nil, types.ObjectInfo{}, fmt.Errorf("get %s from cold tier: %w", meta.TierKey, err)
+	}
+	header := http.Header{}
+	header.Set("ETag", "\""+meta.ETag+"\"")
+	header.Set("Last-Modified", meta.LastModified.UTC().Format(http.TimeFormat))
+	header.Set("X-Amz-Storage-Class", meta.StorageClass)
+	if meta.ContentType != "" {
+		header.Set("Content-Type", meta.ContentType)
+	}
+	return object, types.ObjectInfo{
+		Name:         meta.ObjectName,
+		Size:         meta.Size,
+		ETag:         meta.ETag,
+		LastModified: meta.LastModified,
+		Header:       header,
+		StorageClass: meta.StorageClass,
+	}, nil
+}
+
+// CheckWriteCondition 检查对象当前状态是否满足写入条件，不满足时返回 ErrPreconditionFailed
+func (s *StorageNodeSvc) CheckWriteCondition(ctx context.Context, bucketName, objectName string, cond types.WriteCondition) error {
+	if cond.IfMatch == "" && cond.IfNoneMatch == "" {
+		return nil
+	}
+	meta, err := s.MetaDataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		meta, err = nil, nil
+	}
+	if err != nil {
+		return fmt.Errorf("get object metadata %s/%s: %w", bucketName, objectName, err)
+	}
+	return checkWriteCondition(meta, cond)
+}
+
+// DeleteObject 删除对象的所有副本和元数据，cond 不为空时持有对象锁期间检查对象当前状态，不满足时返回 ErrPreconditionFailed
+func (s *StorageNodeSvc) DeleteObject(ctx context.Context, bucketName, objectName string, cond types.WriteCondition) error {
+	return s.deleteObject(ctx, bucketName, objectName, cond, false)
+}
+
+// DeleteReplica 删除由其他集群同步的删除，不会再加入复制队列
+func (s *StorageNodeSvc) DeleteReplica(ctx context.Context, bucketName, objectName string) error {
+	return s.deleteObject(ctx, bucketName, objectName, types.WriteCondition{}, true)
+}
+
+func (s *StorageNodeSvc) deleteObject(ctx context.Context, bucketName, objectName string, cond types.WriteCondition, replica bool) (err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.DeleteObject", tracing.Object(bucketName, objectName)...)
+	defer func() { tracing.End(span, err) }()
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: bucketName, log.FieldObject: objectName})
+
+	lock, err := lockObject(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		return err
+	}
+	meta, err := s.MetaDataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("get object metadata %s/%s: %w", bucketName, objectName, err)
+	}
+	if err = checkWriteCondition(meta, cond); err != nil {
+		return err
 	}
-	return client.MinioCore.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+	// 由其他集群复制来的删除不再向外复制
+	var tasks []*dbm.ReplicationTask
+	if meta != nil && !replica {
+		rule, err := s.replicationRule(ctx, bucketName, objectName)
+		if err != nil {
+			return err
+		}
+		tasks = replicationTasks(rule, types.ReplicationOpDelete, meta)
+	}
+	// 副本可能分布在任意节点上，逐个节点删除
+	for _, node := range nodes {
+		client := minIo.GetNodeClient(node)
+		err = client.MinioCore.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+		if err != nil && minio.ToErrorResponse(err).Code != "NoSuchBucket" {
+			return fmt.Errorf("delete object on node %s: %w", node.ID, minIo.ToError(err))
+		}
+	}
+	if meta != nil && meta.IsCold() {
+		tier, err := GetColdTier()
+		if err != nil {
+			return err
+		}
+		if err = tier.Delete(ctx, meta.TierKey); err != nil {
+			return fmt.Errorf("delete %s from cold tier: %w", meta.TierKey, err)
+		}
+	}
+	if err = s.MetaDataDao.DeleteObjectMetadataWithTasks(ctx, bucketName, objectName, tasks); err != nil {
+		return err
+	}
+	if meta != nil {
+		bucket, err := s.BucketSvc.Lookup(ctx, bucketName)
+		if err != nil {
+			log.Ctx(ctx).Warnf("update usage failed: %v", err)
+			return nil
+		}
+		s.QuotaSvc.Charge(ctx, bucket, types.Usage{Bytes: -meta.Size, Objects: -1})
+	}
+	return nil
 }
@@ -0,0 +1,134 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// MaxSyncerRuns 每个后台任务保留的执行记录数
+const MaxSyncerRuns = 50
+
+// SyncerSvc 读写后台任务的执行记录和手动触发标记，数据保存在 redis 中，所有网关共享
+type SyncerSvc struct {
+}
+
+func NewSyncerSvc() *SyncerSvc {
+	return &SyncerSvc{}
+}
+
+func syncerRunsKey(name string) string {
+	return fmt.Sprintf("syncer:runs:%s", name)
+}
+
+func syncerTriggerKey(name string) string {
+	return fmt.Sprintf("syncer:trigger:%s", name)
+}
+
+func syncerLeaderKey(name string) string {
+	return fmt.Sprintf("syncer:leader:%s", name)
+}
+
+// RecordRun 记录一次执行，只保留最近 MaxSyncerRuns 条
+func (m *SyncerSvc) RecordRun(ctx context.Context, run types.SyncerRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	key := syncerRunsKey(run.Name)
+	_, err = redis.Redis().TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.LPush(ctx, key, data)
+		pipe.LTrim(ctx, key, 0, MaxSyncerRuns-1)
+		return nil
+	})
+	return err
+}
+
+// ListRuns 按时间倒序返回最近的 limit 条执行记录
+func (m *SyncerSvc) ListRuns(ctx context.Context, name string, limit int) ([]types.SyncerRun, error) {
+	if limit <= 0 || limit > MaxSyncerRuns {
+		limit = MaxSyncerRuns
+	}
+	values, err := redis.Redis().LRange(ctx, syncerRunsKey(name), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list syncer runs: %w", err)
+	}
+	res := make([]types.SyncerRun, 0, len(values))
+	for _, value := range values {
+		run := types.SyncerRun{}
+		if err = json.Unmarshal([]byte(value), &run); err != nil {
+			return nil, fmt.Errorf("unmarshal syncer run: %w", err)
+		}
+		res = append(res, run)
+	}
+	return res, nil
+}
+
+// LastRun 返回最近一次执行记录，没有时返回 nil
+func (m *SyncerSvc) LastRun(ctx context.Context, name string) (*types.SyncerRun, error) {
+	runs, err := m.ListRuns(ctx, name, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// Trigger 标记任务需要立即执行，持有该任务锁的网关会在一秒内开始执行
+func (m *SyncerSvc) Trigger(ctx context.Context, name string) error {
+	return redis.Redis().Set(ctx, syncerTriggerKey(name), 1, 0).Err()
+}
+
+// IsTriggered 任务是否被手动触发且尚未执行
+func (m *SyncerSvc) IsTriggered(ctx context.Context, name string) (bool, error) {
+	n, err := redis.Redis().Exists(ctx, syncerTriggerKey(name)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ConsumeTrigger 取走手动触发标记，返回是否被触发
+func (m *SyncerSvc) ConsumeTrigger(ctx context.Context, name string) (bool, error) {
+	n, err := redis.Redis().Del(ctx, syncerTriggerKey(name)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SaveLeader 记录 redis 选主方式下的当前领导者，name 为区分环境分组后的任务名
+func (m *SyncerSvc) SaveLeader(ctx context.Context, name string, leader types.SyncerLeader) error {
+	data, err := json.Marshal(leader)
+	if err != nil {
+		return err
+	}
+	return redis.Redis().Set(ctx, syncerLeaderKey(name), data, 0).Err()
+}
+
+// ClearLeader 释放锁后清除领导者记录，只清除 token 相同的记录
+func (m *SyncerSvc) ClearLeader(ctx context.Context, name string, token int64) error {
+	leader, err := m.GetLeader(ctx, name)
+	if err != nil || leader == nil || leader.Token != token {
+		return err
+	}
+	return redis.Redis().Del(ctx, syncerLeaderKey(name)).Err()
+}
+
+// GetLeader 返回 redis 选主方式下记录的领导者，没有时返回 nil
+func (m *SyncerSvc) GetLeader(ctx context.Context, name string) (*types.SyncerLeader, error) {
+	data, err := redis.Redis().Get(ctx, syncerLeaderKey(name)).Bytes()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get syncer leader: %w", err)
+	}
+	leader := &types.SyncerLeader{}
+	if err = json.Unmarshal(data, leader); err != nil {
+		return nil, fmt.Errorf("unmarshal syncer leader: %w", err)
+	}
+	return leader, nil
+}
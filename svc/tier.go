@@ -0,0 +1,304 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/placement"
+	"distributed-object-storage/pkg/tracing"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"gorm.io/gorm"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ColdTier 冷存储，保存生命周期规则转移出存储节点的对象
+type ColdTier interface {
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// coldTier 按 OSS 配置缓存的冷存储客户端，配置不变时所有请求共用同一个客户端
+var coldTier struct {
+	sync.Mutex
+	config config.OssConfig
+	tier   ColdTier
+}
+
+// GetColdTier 返回按 OSS 配置创建的冷存储，客户端只在第一次使用或配置变化时创建
+func GetColdTier() (ColdTier, error) {
+	c := config.GetOss()
+	if !c.ColdTierEnabled() {
+		return nil, fmt.Errorf("%w: cold tier is not configured", errors.ErrBadRequest)
+	}
+	coldTier.Lock()
+	defer coldTier.Unlock()
+	if coldTier.tier != nil && coldTier.config == c {
+		return coldTier.tier, nil
+	}
+	tier, err := newColdTier(c)
+	if err != nil {
+		return nil, err
+	}
+	coldTier.config, coldTier.tier = c, tier
+	return tier, nil
+}
+
+// newColdTier 创建冷存储客户端，S3Compatible 为 true 时以 S3 协议访问，便于用本地 MinIO 代替 OSS
+func newColdTier(c config.OssConfig) (ColdTier, error) {
+	if c.S3Compatible {
+		return newS3Tier(c.Endpoint, c.AK, c.SK, c.Bucket, uint64(c.PartSizeMB)<<20)
+	}
+	client, err := c.NewOssClient()
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := client.Bucket(c.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("get OSS bucket %s: %w", c.Bucket, err)
+	}
+	return &ossTier{bucket: bucket, partSize: int64(c.PartSizeMB) << 20}, nil
+}
+
+// coldTierKey 对象在冷存储中的 key
+func coldTierKey(bucketName, objectName string) string {
+	return config.GetOss().Prefix + bucketName + "/" + objectName
+}
+
+// ossTier 以阿里云 OSS 作为冷存储
+type ossTier struct {
+	bucket   *oss.Bucket
+	partSize int64
+}
+
+func (t *ossTier) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	options := []oss.Option{oss.WithContext(ctx)}
+	if contentType != "" {
+		options = append(options, oss.ContentType(contentType))
+	}
+	if size > t.partSize {
+		return ossError(uploadMultipart(t.bucket, key, reader, size, t.partSize, options...))
+	}
+	return ossError(t.bucket.PutObject(key, reader, append(options, oss.ContentLength(size))...))
+}
+
+func (t *ossTier) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := t.bucket.GetObject(key, oss.WithContext(ctx))
+	if err != nil {
+		return nil, ossError(err)
+	}
+	return body, nil
+}
+
+func (t *ossTier) Delete(ctx context.Context, key string) error {
+	return ossError(t.bucket.DeleteObject(key, oss.WithContext(ctx)))
+}
+
+// s3Tier 以 S3 兼容的存储作为冷存储，也用作跨集群复制的目标
+type s3Tier struct {
+	client   *minio.Core
+	bucket   string
+	partSize uint64
+}
+
+// newS3Tier endpoint 以 https:// 开头时使用 TLS，没有协议时使用 HTTP
+func newS3Tier(endpoint, accessKey, secretKey, bucket string, partSize uint64) (*s3Tier, error) {
+	host, secure := strings.CutPrefix(endpoint, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	client, err := minio.NewCore(host, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: secure,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create s3 client for %s: %w", endpoint, err)
+	}
+	return &s3Tier{client: client, bucket: bucket, partSize: partSize}, nil
+}
+
+func (t *s3Tier) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	_, err := t.client.Client.PutObject(ctx, t.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    t.partSize,
+	})
+	return minIo.ToError(err)
+}
+
+func (t *s3Tier) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, _, _, err := t.client.GetObject(ctx, t.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minIo.ToError(err)
+	}
+	return object, nil
+}
+
+func (t *s3Tier) Delete(ctx context.Context, key string) error {
+	return minIo.ToError(t.client.RemoveObject(ctx, t.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+// deleteColdCopy 删除冷存储中不再被引用的数据，失败只记录日志
+func deleteColdCopy(ctx context.Context, key string) {
+	tier, err := GetColdTier()
+	if err == nil {
+		err = tier.Delete(ctx, key)
+	}
+	if err != nil {
+		log.Ctx(ctx).Warnf("delete %s from cold tier failed: %v", key, err)
+	}
+}
+
+// getObjectMetadata 返回对象元数据，对象不存在时返回 NoSuchKey
+func (s *StorageNodeSvc) getObjectMetadata(ctx context.Context, bucketName, objectName string) (*dbm.ObjectMetadata, error) {
+	meta, err := s.MetaDataDao.GetObjectMetadata(ctx, bucketName, objectName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.WithCode(errors.CodeNoSuchKey, "object %s/%s not found", bucketName, objectName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get object metadata %s/%s: %w", bucketName, objectName, err)
+	}
+	return meta, nil
+}
+
+// TransitionObject 将对象转移到冷存储，etag 不为空时只在对象未被修改时转移，否则返回 ErrPreconditionFailed。
+// 元数据更新后才删除节点上的副本，转移中途失败时对象仍可从节点读取。
+func (s *StorageNodeSvc) TransitionObject(ctx context.Context, bucketName, objectName, etag string) (err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.TransitionObject", tracing.Object(bucketName, objectName)...)
+	defer func() { tracing.End(span, err) }()
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: bucketName, log.FieldObject: objectName})
+
+	tier, err := GetColdTier()
+	if err != nil {
+		return err
+	}
+	lock, err := lockObject(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	meta, err := s.getObjectMetadata(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if err = checkWriteCondition(meta, types.WriteCondition{IfMatch: etag}); err != nil {
+		return err
+	}
+	if meta.IsCold() {
+		return nil
+	}
+	object, info, _, err := s.openReplica(ctx, meta)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	key := coldTierKey(bucketName, objectName)
+	if err = tier.Put(ctx, key, object, meta.Size, info.ContentType); err != nil {
+		return fmt.Errorf("put %s to cold tier: %w", key, err)
+	}
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return err
+	}
+	recorded := make(map[string]bool)
+	for _, id := range meta.Nodes() {
+		recorded[id] = true
+	}
+	meta.TierKey = key
+	meta.StorageClass = types.StorageClassCold
+	meta.ContentType = info.ContentType
+	meta.SetNodes(nil)
+	if err = s.MetaDataDao.SaveObjectMetadata(ctx, meta); err != nil {
+		return fmt.Errorf("save object metadata: %w", err)
+	}
+	nodes, err := minIo.GetStorageNodesContext(ctx)
+	if err != nil {
+		log.Ctx(ctx).Warnf("get storage nodes failed, transitioned replicas are kept: %v", err)
+		return nil
+	}
+	for _, node := range nodes {
+		if !recorded[node.ID] {
+			continue
+		}
+		err := minIo.GetNodeClient(node).MinioCore.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
+		if err != nil {
+			log.Ctx(ctx).WithField(log.FieldNode, node.ID).Warnf("remove transitioned replica failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// RestoreObject 将冷存储中的对象按桶的放置策略写回存储节点，并删除冷存储中的数据
+func (s *StorageNodeSvc) RestoreObject(ctx context.Context, bucketName, objectName string) (err error) {
+	ctx, span := tracing.Start(ctx, "StorageNodeSvc.RestoreObject", tracing.Object(bucketName, objectName)...)
+	defer func() { tracing.End(span, err) }()
+	ctx = log.NewContext(ctx, log.Fields{log.FieldBucket: bucketName, log.FieldObject: objectName})
+
+	lock, err := lockObject(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	defer unlockObject(ctx, lock)
+
+	meta, err := s.getObjectMetadata(ctx, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	if !meta.IsCold() {
+		return nil
+	}
+	tier, err := GetColdTier()
+	if err != nil {
+		return err
+	}
+	bucket, err := s.BucketSvc.Lookup(ctx, bucketName)
+	if err != nil {
+		return err
+	}
+	policy := placement.Default().ForBucket(bucket)
+	targets, err := placeObject(ctx, policy, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	object, err := tier.Get(ctx, meta.TierKey)
+	if err != nil {
+		return fmt.Errorf("get %s from cold tier: %w", meta.TierKey, err)
+	}
+	defer object.Close()
+	primary := minIo.GetNodeClient(targets[0])
+	if err = primary.EnsureBucket(ctx, bucketName); err != nil {
+		return minIo.ToError(err)
+	}
+	uploadInfo, err := primary.PutStream(ctx, bucketName, objectName, object, meta.Size, meta.ContentType)
+	if err != nil {
+		return minIo.ToError(err)
+	}
+	stored := []string{targets[0].ID}
+	for _, node := range targets[1:] {
+		if _, err := minIo.GetNodeClient(node).CopyObjectFrom(ctx, primary, bucketName, objectName); err != nil {
+			log.Ctx(ctx).WithField(log.FieldNode, node.ID).Warnf("replicate failed: %v", err)
+			continue
+		}
+		stored = append(stored, node.ID)
+	}
+	if err = checkObjectLock(ctx, lock); err != nil {
+		return err
+	}
+	key := meta.TierKey
+	meta.TierKey = ""
+	meta.StorageClass = bucket.StorageClass
+	meta.ETag = strings.Trim(uploadInfo.ETag, "\"")
+	meta.VersionID = uploadInfo.VersionID
+	meta.SetNodes(stored)
+	if err = s.MetaDataDao.SaveObjectMetadata(ctx, meta); err != nil {
+		return fmt.Errorf("save object metadata: %w", err)
+	}
+	deleteColdCopy(ctx, key)
+	return nil
+}
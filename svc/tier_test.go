@@ -0,0 +1,158 @@
+package svc
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/types"
+	"net/http"
+	"testing"
+)
+
+func TestTransitionObject(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "hello cold tier")
+	before, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if got := env.nodesHolding("photos", "a.txt"); got != 2 {
+		t.Fatalf("object on %d nodes after put, want 2", got)
+	}
+
+	if err = env.svc.TransitionObject(ctx, "photos", "a.txt", before.ETag); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if !meta.IsCold() || meta.TierKey != "photos/a.txt" {
+		t.Fatalf("tier key = %q, want photos/a.txt", meta.TierKey)
+	}
+	if meta.StorageClass != types.StorageClassCold || len(meta.Nodes()) != 0 {
+		t.Fatalf("storage class %q on nodes %v after transition", meta.StorageClass, meta.Nodes())
+	}
+	if meta.ETag != before.ETag {
+		t.Fatalf("etag = %s, want %s", meta.ETag, before.ETag)
+	}
+	if got := readBackend(t, env.cold, testColdBucket, meta.TierKey); got != "hello cold tier" {
+		t.Fatalf("cold copy = %q", got)
+	}
+	// 元数据更新后删除节点上的副本
+	if got := env.nodesHolding("photos", "a.txt"); got != 0 {
+		t.Fatalf("object still on %d nodes after transition", got)
+	}
+}
+
+func TestTransitionObjectModified(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "v1")
+
+	err := env.svc.TransitionObject(ctx, "photos", "a.txt", "etag-of-an-older-version")
+	if !errors.Is(err, errors.ErrPreconditionFailed) {
+		t.Fatalf("transition = %v, want ErrPreconditionFailed", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if meta.IsCold() || env.nodesHolding("photos", "a.txt") != 2 {
+		t.Fatalf("modified object was transitioned")
+	}
+}
+
+func TestGetColdObjectReadsThrough(t *testing.T) {
+	env := newTestEnv(t, 2)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "read through")
+	if err := env.svc.TransitionObject(ctx, "photos", "a.txt", ""); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+
+	content, info := env.getObject(t, "photos", "a.txt")
+	if content != "read through" {
+		t.Fatalf("content = %q", content)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if info.ETag != meta.ETag || info.Size != int64(len("read through")) {
+		t.Fatalf("object info = %+v, want etag %s", info, meta.ETag)
+	}
+	if got := http.Header(info.Header).Get("X-Amz-Storage-Class"); got != types.StorageClassCold {
+		t.Fatalf("storage class header = %q", got)
+	}
+	// 读取不会把对象写回存储节点
+	if got := env.nodesHolding("photos", "a.txt"); got != 0 {
+		t.Fatalf("object on %d nodes after read", got)
+	}
+}
+
+func TestRestoreObject(t *testing.T) {
+	env := newTestEnv(t, 3)
+	ctx := context.Background()
+	env.putObject(t, "photos", "a.txt", "restore me")
+	before, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if err = env.svc.TransitionObject(ctx, "photos", "a.txt", ""); err != nil {
+		t.Fatalf("transition: %v", err)
+	}
+
+	if err = env.svc.RestoreObject(ctx, "photos", "a.txt"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	meta, err := env.dao.MetadataNode.GetObjectMetadata(ctx, "photos", "a.txt")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	if meta.IsCold() || len(meta.Nodes()) != 2 {
+		t.Fatalf("restored object has tier key %q on nodes %v", meta.TierKey, meta.Nodes())
+	}
+	// 分片大小与上传时一致，写回后 ETag 不变
+	if meta.ETag != before.ETag {
+		t.Fatalf("etag = %s, want %s", meta.ETag, before.ETag)
+	}
+	for _, id := range meta.Nodes() {
+		if got := readBackend(t, env.nodes[id], "photos", "a.txt"); got != "restore me" {
+			t.Fatalf("replica on %s = %q", id, got)
+		}
+	}
+	if _, err = env.cold.HeadObject(testColdBucket, "photos/a.txt"); err == nil {
+		t.Fatal("cold copy not deleted after restore")
+	}
+	if content, _ := env.getObject(t, "photos", "a.txt"); content != "restore me" {
+		t.Fatalf("content = %q", content)
+	}
+}
+
+func TestGetColdTierReusesClient(t *testing.T) {
+	newTestEnv(t, 1)
+	first, err := GetColdTier()
+	if err != nil {
+		t.Fatalf("get cold tier: %v", err)
+	}
+	second, err := GetColdTier()
+	if err != nil {
+		t.Fatalf("get cold tier: %v", err)
+	}
+	if first != second {
+		t.Fatal("cold tier client created again with the same config")
+	}
+
+	cfg := *config.Get()
+	cfg.OssConfig.Endpoint = "http://127.0.0.1:1"
+	config.Set(&cfg)
+	third, err := GetColdTier()
+	if err != nil {
+		t.Fatalf("get cold tier: %v", err)
+	}
+	if third == first {
+		t.Fatal("cold tier client not recreated after the config changed")
+	}
+}
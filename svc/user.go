@@ -5,6 +5,7 @@ import (
 	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/pkg/db/dbm"
 	"distributed-object-storage/types"
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"sync"
 )
@@ -64,3 +65,16 @@ func (UserSvc *UserSvc) CreateUser(ctx context.Context, userInfo *types.UserMeta
 	}
 	return nil
 }
+
+// ResetPassword 重置用户密码
+func (UserSvc *UserSvc) ResetPassword(ctx context.Context, userName, password string) error {
+	userinfo, err := UserSvc.userDao.GetUserInfoByName(userName)
+	if err != nil {
+		return fmt.Errorf("get user %s: %w", userName, err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return UserSvc.userDao.UpdatePassword(ctx, userinfo.Id, string(hashedPassword))
+}
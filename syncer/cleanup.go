@@ -0,0 +1,48 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type Cleanup struct {
+}
+
+func (c *Cleanup) Interval() time.Duration {
+	return time.Second * 30
+}
+
+func (c *Cleanup) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Cleanup) RunOnce() bool {
+	return false
+}
+
+func (c *Cleanup) EnvIsolation() bool {
+	return false
+}
+
+// CleanupSyncer 定时重试删除元数据已删除、数据删除失败的对象，如重命名后的源对象
+type CleanupSyncer struct {
+	Cleanup
+	cleanupSvc *svc.CleanupSvc
+}
+
+func NewCleanupSyncer(s *dao.S) *CleanupSyncer {
+	return &CleanupSyncer{
+		cleanupSvc: svc.NewCleanupSvc(s),
+	}
+}
+
+func (c *CleanupSyncer) Sync(ctx context.Context) error {
+	done, err := c.cleanupSvc.Run(ctx)
+	if done > 0 {
+		log.Ctx(ctx).Infof("removed data of %d objects", done)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type DeleteJob struct {
+}
+
+func (c *DeleteJob) Interval() time.Duration {
+	return time.Second * 5
+}
+
+func (c *DeleteJob) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *DeleteJob) RunOnce() bool {
+	return false
+}
+
+func (c *DeleteJob) EnvIsolation() bool {
+	return false
+}
+
+// DeleteJobSyncer 定时执行删除前缀下所有对象的任务，服务重启后从保存的进度继续
+type DeleteJobSyncer struct {
+	DeleteJob
+	deleteJobSvc *svc.DeleteJobSvc
+}
+
+func NewDeleteJobSyncer(s *dao.S) *DeleteJobSyncer {
+	return &DeleteJobSyncer{
+		deleteJobSvc: svc.NewDeleteJobSvc(s),
+	}
+}
+
+func (d *DeleteJobSyncer) Sync(ctx context.Context) error {
+	finished, err := d.deleteJobSvc.Run(ctx)
+	if finished > 0 {
+		log.Ctx(ctx).Infof("finished %d delete jobs", finished)
+	}
+	return err
+}
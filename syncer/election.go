@@ -0,0 +1,173 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/etcd"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/redis"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"encoding/json"
+	"fmt"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"sync"
+	"time"
+)
+
+const (
+	// electionTTL etcd 会话的租约时间（秒），领导者失联超过该时间后其他实例接替
+	electionTTL = 10
+	// electionRetryInterval etcd 不可用时重试选主的间隔
+	electionRetryInterval = 5 * time.Second
+)
+
+var leadershipCallbacks = struct {
+	sync.RWMutex
+	fns []func(name string, leader bool)
+}{}
+
+// OnLeadershipChange 注册领导权变化回调，当前实例成为或不再是某个任务的领导者时调用
+func OnLeadershipChange(fn func(name string, leader bool)) {
+	leadershipCallbacks.Lock()
+	defer leadershipCallbacks.Unlock()
+	leadershipCallbacks.fns = append(leadershipCallbacks.fns, fn)
+}
+
+func notifyLeadership(name string, leader bool) {
+	if leader {
+		log.Infof("%s became leader on %s", instance, name)
+	} else {
+		log.Infof("%s lost leadership on %s", instance, name)
+	}
+	leadershipCallbacks.RLock()
+	defer leadershipCallbacks.RUnlock()
+	for _, fn := range leadershipCallbacks.fns {
+		fn(name, leader)
+	}
+}
+
+// fencingResource 元数据库中记录 fencing token 的名称，两种选主方式的 token 不可比较，分开记录
+func fencingResource(election, sName string) string {
+	return election + "/" + sName
+}
+
+func electionPrefix(name string) string {
+	return fmt.Sprintf("/syncer/election/%s", name)
+}
+
+// runWithEtcdElection 通过 etcd 选主，当选后一直持有领导权直到 ctx 取消或会话失效，
+// 不需要像 redis 锁那样每轮重新抢占。选主 key 的 create revision 作为 fencing token。
+func runWithEtcdElection(ctx context.Context, syncerSvc *svc.SyncerSvc, name, sName string, s Syncer, ticker *time.Ticker) {
+	cli, err := etcd.Client()
+	if err != nil {
+		log.Errorf("get etcd client failed: %v", err)
+		time.Sleep(electionRetryInterval)
+		return
+	}
+	session, err := concurrency.NewSession(cli, concurrency.WithTTL(electionTTL), concurrency.WithContext(ctx))
+	if err != nil {
+		log.Errorf("create etcd session for %s failed: %v", name, err)
+		time.Sleep(electionRetryInterval)
+		return
+	}
+	defer session.Close()
+
+	election := concurrency.NewElection(session, electionPrefix(sName))
+	leader := types.SyncerLeader{
+		Name:     name,
+		Election: config.ElectionEtcd,
+		Instance: instance,
+	}
+	value, _ := json.Marshal(leader)
+	if err = election.Campaign(ctx, string(value)); err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("campaign %s failed: %v", name, err)
+			time.Sleep(electionRetryInterval)
+		}
+		return
+	}
+	defer func() {
+		resignCtx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		if err := election.Resign(resignCtx); err != nil {
+			log.Warnf("resign %s failed: %v", name, err)
+		}
+	}()
+
+	// 记录当选时间，供管理接口展示
+	leader.Since = time.Now()
+	value, _ = json.Marshal(leader)
+	if err = election.Proclaim(ctx, string(value)); err != nil {
+		log.Warnf("proclaim %s leader failed: %v", name, err)
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-session.Done():
+			log.Warnf("etcd session of %s expired", name)
+			cancel()
+		case <-leaderCtx.Done():
+		}
+	}()
+
+	token, key := election.Rev(), election.Key()
+	fenced := fencing.NewContext(leaderCtx, &fencing.Token{
+		Resource: fencingResource(config.ElectionEtcd, sName),
+		Value:    token,
+		Validate: func(ctx context.Context) error {
+			resp, err := cli.Get(ctx, key)
+			if err != nil {
+				return err
+			}
+			if len(resp.Kvs) == 0 || resp.Kvs[0].CreateRevision != token {
+				return fencing.ErrStaleToken
+			}
+			return nil
+		},
+	})
+	lead(fenced, syncerSvc, name, s, ticker)
+}
+
+// Leader 返回后台任务当前的领导者，没有领导者时返回 nil
+func Leader(ctx context.Context, name string, s Syncer) (*types.SyncerLeader, error) {
+	sName := isolatedName(name, s, config.GetSyncerEnvGroup())
+	if config.GetSyncerJob(name).ElectionMode() == config.ElectionEtcd {
+		cli, err := etcd.Client()
+		if err != nil {
+			return nil, err
+		}
+		// 最早创建的 key 即为当前领导者
+		resp, err := cli.Get(ctx, electionPrefix(sName)+"/", clientv3.WithFirstCreate()...)
+		if err != nil {
+			return nil, fmt.Errorf("get %s leader: %w", name, err)
+		}
+		if len(resp.Kvs) == 0 {
+			return nil, nil
+		}
+		leader := &types.SyncerLeader{}
+		if err = json.Unmarshal(resp.Kvs[0].Value, leader); err != nil {
+			return nil, fmt.Errorf("unmarshal %s leader: %w", name, err)
+		}
+		leader.Token = resp.Kvs[0].CreateRevision
+		return leader, nil
+	}
+
+	leader, err := svc.NewSyncerSvc().GetLeader(ctx, sName)
+	if err != nil || leader == nil {
+		return nil, err
+	}
+	// 领导者异常退出时记录不会被清除，以锁是否存在为准
+	n, err := redis.Redis().Exists(ctx, lockKey(sName)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	return leader, nil
+}
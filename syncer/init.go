@@ -2,19 +2,22 @@ package syncer
 
 import (
 	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
 	"distributed-object-storage/pkg/log"
-	"reflect"
 	"sync"
 )
 
-func Init(ctx context.Context) {
-	var syncers = []Syncer{
-		NewNodeHealthCheckSyncer(),
-	}
+func Init(ctx context.Context, s *dao.S) {
+	registerDefaults(s)
 	wg := new(sync.WaitGroup)
-	for _, iter := range syncers {
+	for _, iter := range registered() {
+		if !config.GetSyncerJob(iter.name).IsEnabled() {
+			log.Infof("syncer:[%s] disabled", iter.name)
+			continue
+		}
 		wg.Add(1)
-		log.Infof("start syncer:[%s]", reflect.TypeOf(iter))
+		log.Infof("start syncer:[%s]", iter.name)
 		oneSyncer := iter
 		go func() {
 			defer wg.Done()
@@ -23,7 +26,7 @@ func Init(ctx context.Context) {
 				case <-ctx.Done():
 					return
 				default:
-					Run(ctx, oneSyncer, "test")
+					Run(ctx, oneSyncer.name, oneSyncer.syncer, config.GetSyncerEnvGroup())
 				}
 			}
 		}()
@@ -0,0 +1,55 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type Lifecycle struct {
+}
+
+func (c *Lifecycle) Interval() time.Duration {
+	return time.Duration(config.GetLifecycle().IntervalMinutes) * time.Minute
+}
+
+func (c *Lifecycle) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Lifecycle) RunOnce() bool {
+	return false
+}
+
+func (c *Lifecycle) EnvIsolation() bool {
+	return false
+}
+
+// LifecycleSyncer 定时执行各桶的生命周期规则，删除记录保存在数据库中，执行结果保存在 redis 中
+type LifecycleSyncer struct {
+	Lifecycle
+	lifecycleSvc *svc.LifecycleSvc
+}
+
+func NewLifecycleSyncer(s *dao.S) *LifecycleSyncer {
+	return &LifecycleSyncer{
+		lifecycleSvc: svc.NewLifecycleSvc(s),
+	}
+}
+
+func (r *LifecycleSyncer) Sync(ctx context.Context) error {
+	report, err := r.lifecycleSvc.Run(ctx, config.GetLifecycle().BatchSize)
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Infof("lifecycle finished, buckets %d, expired %d (%d bytes), noncurrent removed %d, uploads aborted %d, failed %d",
+		report.Buckets, report.Expired, report.ExpiredBytes, report.NoncurrentRemoved, report.UploadsAborted, report.Failed)
+	if err = fencing.Check(ctx); err != nil {
+		return err
+	}
+	return r.lifecycleSvc.SaveReport(ctx, report)
+}
@@ -0,0 +1,55 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type QuotaUsage struct {
+}
+
+func (c *QuotaUsage) Interval() time.Duration {
+	return time.Duration(config.GetQuota().ReconcileIntervalMinutes) * time.Minute
+}
+
+func (c *QuotaUsage) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *QuotaUsage) RunOnce() bool {
+	return false
+}
+
+func (c *QuotaUsage) EnvIsolation() bool {
+	return false
+}
+
+// QuotaUsageSyncer 定时按元数据重新统计桶和用户的用量，修正 redis 中增量计数的误差
+type QuotaUsageSyncer struct {
+	QuotaUsage
+	quotaSvc *svc.QuotaSvc
+}
+
+func NewQuotaUsageSyncer(s *dao.S) *QuotaUsageSyncer {
+	return &QuotaUsageSyncer{
+		quotaSvc: svc.NewQuotaSvc(s),
+	}
+}
+
+func (r *QuotaUsageSyncer) Sync(ctx context.Context) error {
+	if err := fencing.Check(ctx); err != nil {
+		return err
+	}
+	report, err := r.quotaSvc.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Infof("quota usage reconciled, buckets %d, users %d, bytes %d, objects %d",
+		report.Buckets, report.Users, report.Bytes, report.Objects)
+	return r.quotaSvc.SaveReconcile(ctx, report)
+}
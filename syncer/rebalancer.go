@@ -0,0 +1,312 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/pkg/placement"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"fmt"
+	"golang.org/x/time/rate"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Rebalance struct {
+}
+
+func (c *Rebalance) Interval() time.Duration {
+	return time.Minute
+}
+
+func (c *Rebalance) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Rebalance) RunOnce() bool {
+	return false
+}
+
+func (c *Rebalance) EnvIsolation() bool {
+	return false
+}
+
+// RebalancerSyncer 节点加入或离开后，将副本位置与放置策略不一致的对象迁移到新的节点上。
+// 只有节点拓扑（节点、zone/rack、权重、是否已满）发生变化时才会遍历元数据，
+// 进度保存在 redis 中，暂停后下一次调度从上次处理到的位置继续。
+type RebalancerSyncer struct {
+	Rebalance
+	metadataDao  *dao.MetadataNode
+	metadataSvc  *svc.MetadataSvc
+	rebalanceSvc *svc.RebalanceSvc
+}
+
+func NewRebalancerSyncer(s *dao.S) *RebalancerSyncer {
+	return &RebalancerSyncer{
+		metadataDao:  s.MetadataNode,
+		metadataSvc:  svc.NewMetadataSvc(s),
+		rebalanceSvc: svc.NewRebalanceSvc(),
+	}
+}
+
+// rebalanceRun 一次 Sync 中共享的状态
+type rebalanceRun struct {
+	policy   *placement.Policy
+	buckets  map[string]*placement.Policy
+	nodes    map[string]types.StorageNodeInfo
+	ranked   []types.StorageNodeInfo
+	limiter  *rate.Limiter
+	mutex    sync.Mutex
+	progress *types.RebalanceProgress
+}
+
+func (r *RebalancerSyncer) Sync(ctx context.Context) error {
+	paused, err := r.rebalanceSvc.IsPaused(ctx)
+	if err != nil || paused {
+		return err
+	}
+	nodes, err := minIo.GetStorageNodes()
+	if err != nil {
+		return err
+	}
+	policy := placement.Default()
+	topology := topologyOf(policy, nodes)
+
+	progress, err := r.rebalanceSvc.GetProgress(ctx)
+	if err != nil {
+		return err
+	}
+	if progress.Done == topology && progress.Cursor == 0 {
+		return nil
+	}
+	if progress.Topology != topology {
+		progress = types.RebalanceProgress{
+			Topology:  topology,
+			Done:      progress.Done,
+			StartedAt: time.Now(),
+		}
+		log.Ctx(ctx).Infof("rebalance start for topology %s", topology)
+	}
+	progress.State = types.RebalanceRunning
+	if err = r.rebalanceSvc.SaveProgress(ctx, progress); err != nil {
+		return err
+	}
+
+	run := &rebalanceRun{
+		policy:   policy,
+		buckets:  make(map[string]*placement.Policy),
+		nodes:    make(map[string]types.StorageNodeInfo, len(nodes)),
+		ranked:   nodes,
+		limiter:  bandwidthLimiter(config.GetRebalance().BandwidthBytes),
+		progress: &progress,
+	}
+	for _, node := range nodes {
+		run.nodes[node.ID] = node
+	}
+
+	c := config.GetRebalance()
+	for {
+		if paused, err = r.rebalanceSvc.IsPaused(ctx); err != nil || paused {
+			return err
+		}
+		batch, err := r.metadataDao.ListObjectMetadataAfter(ctx, progress.Cursor, c.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		r.migrateBatch(ctx, run, batch, c.Concurrency)
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		progress.Cursor = batch[len(batch)-1].Id
+		if err = fencing.Check(ctx); err != nil {
+			return err
+		}
+		if err = r.rebalanceSvc.SaveProgress(ctx, progress); err != nil {
+			return err
+		}
+	}
+
+	progress.State = types.RebalanceIdle
+	progress.Done = topology
+	progress.Cursor = 0
+	progress.FinishedAt = time.Now()
+	log.Ctx(ctx).Infof("rebalance finished, scanned %d, moved %d, failed %d", progress.Scanned, progress.Moved, progress.Failed)
+	if err = fencing.Check(ctx); err != nil {
+		return err
+	}
+	return r.rebalanceSvc.SaveProgress(ctx, progress)
+}
+
+// migrateBatch 以最多 concurrency 个并发迁移一批对象
+func (r *RebalancerSyncer) migrateBatch(ctx context.Context, run *rebalanceRun, batch []*dbm.ObjectMetadata, concurrency int) {
+	sem := make(chan struct{}, concurrency)
+	wg := new(sync.WaitGroup)
+	for _, meta := range batch {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(meta *dbm.ObjectMetadata) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			moved, err := r.migrateObject(ctx, run, meta)
+			run.mutex.Lock()
+			defer run.mutex.Unlock()
+			run.progress.Scanned++
+			run.progress.Moved += int64(moved)
+			run.progress.MovedBytes += int64(moved) * meta.Size
+			if err != nil {
+				run.progress.Failed++
+				run.progress.LastError = err.Error()
+				log.Ctx(ctx).Errorf("rebalance %s/%s failed: %v", meta.BucketName, meta.ObjectName, err)
+			}
+		}(meta)
+	}
+	wg.Wait()
+}
+
+// bucketPolicy 返回桶使用的放置策略，同一次 Sync 中每个桶只查询一次配置
+func (r *RebalancerSyncer) bucketPolicy(ctx context.Context, run *rebalanceRun, bucketName string) (*placement.Policy, error) {
+	run.mutex.Lock()
+	policy, ok := run.buckets[bucketName]
+	run.mutex.Unlock()
+	if ok {
+		return policy, nil
+	}
+	cfg, err := r.metadataSvc.BucketSvc.Lookup(ctx, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	policy = run.policy.ForBucket(cfg)
+	run.mutex.Lock()
+	run.buckets[bucketName] = policy
+	run.mutex.Unlock()
+	return policy, nil
+}
+
+// migrateObject 将对象复制到放置策略要求但尚未保存的节点，全部成功后再删除多余的副本。
+// 复制在对象锁外进行，提交时在锁内确认对象没有被修改，否则放弃本次迁移。
+// 返回成功复制的副本数。已转移到冷存储的对象不在节点上，不需要迁移。
+func (r *RebalancerSyncer) migrateObject(ctx context.Context, run *rebalanceRun, meta *dbm.ObjectMetadata) (int, error) {
+	if meta.IsCold() {
+		return 0, nil
+	}
+	policy, err := r.bucketPolicy(ctx, run, meta.BucketName)
+	if err != nil {
+		return 0, err
+	}
+	targets, err := policy.Place(placement.Key(meta.BucketName, meta.ObjectName), run.ranked)
+	if err != nil {
+		return 0, err
+	}
+	actual := make(map[string]bool)
+	sources := make([]*minIo.MinioHelper, 0)
+	for _, id := range meta.Nodes() {
+		actual[id] = true
+		if node, ok := run.nodes[id]; ok {
+			sources = append(sources, minIo.GetNodeClient(node))
+		}
+	}
+	desired := make(map[string]bool)
+	missing := make([]types.StorageNodeInfo, 0)
+	for _, node := range targets {
+		desired[node.ID] = true
+		if !actual[node.ID] {
+			missing = append(missing, node)
+		}
+	}
+	extra := make([]string, 0)
+	for _, id := range meta.Nodes() {
+		if !desired[id] {
+			extra = append(extra, id)
+		}
+	}
+	if len(missing) == 0 && len(extra) == 0 {
+		return 0, nil
+	}
+	if len(sources) == 0 {
+		return 0, fmt.Errorf("no live replica on %s", meta.StorageNodes)
+	}
+
+	copied := make([]string, 0, len(missing))
+	var copyErr error
+	for _, node := range missing {
+		if err = copyFromAny(ctx, run.limiter, sources, minIo.GetNodeClient(node), meta); err != nil {
+			copyErr = fmt.Errorf("copy to node %s: %w", node.ID, err)
+			break
+		}
+		copied = append(copied, node.ID)
+	}
+	// 失去领导权后不再修改元数据，已复制的副本由新的领导者处理
+	if err = fencing.Check(ctx); err != nil {
+		return len(copied), err
+	}
+	// 部分副本复制失败时只记录新增的副本，保留原有副本等待下一轮
+	if copyErr != nil {
+		extra = nil
+		if len(copied) == 0 {
+			return 0, copyErr
+		}
+	}
+	err = r.metadataSvc.CommitObjectMigration(ctx, meta, copied, extra, run.nodes)
+	if errors.Is(err, errors.ErrPreconditionFailed) {
+		// 对象在复制期间被重新写入，新的写入已经按当前的拓扑放置
+		log.Ctx(ctx).Infof("skip rebalance of %s/%s: %v", meta.BucketName, meta.ObjectName, err)
+		return 0, nil
+	}
+	if err != nil {
+		return len(copied), err
+	}
+	return len(copied), copyErr
+}
+
+// copyFromAny 依次尝试从各个现有副本复制到 dst
+func copyFromAny(ctx context.Context, limiter *rate.Limiter, sources []*minIo.MinioHelper, dst *minIo.MinioHelper, meta *dbm.ObjectMetadata) error {
+	var err error
+	for _, src := range sources {
+		_, err = dst.CopyObjectFromWithLimit(ctx, src, meta.BucketName, meta.ObjectName, limiter)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// bandwidthLimiter 按每秒字节数限速，bandwidth <= 0 时不限速
+func bandwidthLimiter(bandwidth int64) *rate.Limiter {
+	if bandwidth <= 0 {
+		return nil
+	}
+	burst := int(bandwidth)
+	if burst > minIo.ChunkPartSize {
+		burst = minIo.ChunkPartSize
+	}
+	return rate.NewLimiter(rate.Limit(bandwidth), burst)
+}
+
+// topologyOf 影响放置结果的节点拓扑，包括按剩余空间分档折算后的权重，剩余空间跨过分档时拓扑才变化
+func topologyOf(policy *placement.Policy, nodes []types.StorageNodeInfo) string {
+	weights := policy.Weights(nodes)
+	list := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, fmt.Sprintf("%s|%s|%s|%g|%t", node.ID, node.Zone, node.Rack, weights[node.ID], policy.IsFull(node)))
+	}
+	sort.Strings(list)
+	return fmt.Sprintf("%d:%s", policy.Replicas, strings.Join(list, ","))
+}
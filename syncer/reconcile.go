@@ -0,0 +1,55 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type Reconcile struct {
+}
+
+func (c *Reconcile) Interval() time.Duration {
+	return time.Duration(config.GetReconcile().IntervalMinutes) * time.Minute
+}
+
+func (c *Reconcile) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Reconcile) RunOnce() bool {
+	return false
+}
+
+func (c *Reconcile) EnvIsolation() bool {
+	return false
+}
+
+// ReconcileSyncer 定时对比元数据与各存储节点上的数据，报告保存在 redis 中
+type ReconcileSyncer struct {
+	Reconcile
+	reconcileSvc *svc.ReconcileSvc
+}
+
+func NewReconcileSyncer(s *dao.S) *ReconcileSyncer {
+	return &ReconcileSyncer{
+		reconcileSvc: svc.NewReconcileSvc(s),
+	}
+}
+
+func (r *ReconcileSyncer) Sync(ctx context.Context) error {
+	report, err := r.reconcileSvc.Reconcile(ctx, svc.ReconcileOptions{Fix: config.GetReconcile().Fix})
+	if err != nil {
+		return err
+	}
+	log.Ctx(ctx).Infof("reconcile finished, buckets %d, objects %d, orphans %d, dangling %d, mismatched %d, fixed %d",
+		report.Buckets, report.Objects, report.Orphans, report.Dangling, report.Mismatched, report.Fixed)
+	if err = fencing.Check(ctx); err != nil {
+		return err
+	}
+	return r.reconcileSvc.SaveReport(ctx, report)
+}
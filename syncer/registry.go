@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	name   string
+	syncer Syncer
+}
+
+var registry = struct {
+	sync.RWMutex
+	entries []entry
+}{}
+
+// Register 按名字注册后台任务，名字用于配置、锁和管理接口，重复注册会 panic
+func Register(name string, s Syncer) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, e := range registry.entries {
+		if e.name == name {
+			panic(fmt.Sprintf("syncer %s already registered", name))
+		}
+	}
+	registry.entries = append(registry.entries, entry{name: name, syncer: s})
+}
+
+// Lookup 根据名字查找已注册的后台任务
+func Lookup(name string) (Syncer, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, e := range registry.entries {
+		if e.name == name {
+			return e.syncer, true
+		}
+	}
+	return nil, false
+}
+
+func registered() []entry {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]entry{}, registry.entries...)
+}
+
+// registerDefaults 注册内置的后台任务
+func registerDefaults(s *dao.S) {
+	Register("node_health_check", NewNodeHealthCheckSyncer())
+	Register("rebalancer", NewRebalancerSyncer(s))
+	Register("scrubber", NewScrubberSyncer(s))
+	Register("reconcile", NewReconcileSyncer(s))
+	Register("quota_usage", NewQuotaUsageSyncer(s))
+	Register("lifecycle", NewLifecycleSyncer(s))
+	Register("replication", NewReplicationSyncer(s))
+	Register("delete_job", NewDeleteJobSyncer(s))
+	Register("object_cleanup", NewCleanupSyncer(s))
+}
+
+// List 返回所有已注册的后台任务及其最近一次执行记录
+func List(ctx context.Context) ([]types.SyncerInfo, error) {
+	syncerSvc := svc.NewSyncerSvc()
+	res := make([]types.SyncerInfo, 0)
+	for _, e := range registered() {
+		lastRun, err := syncerSvc.LastRun(ctx, e.name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, types.SyncerInfo{
+			Name:     e.name,
+			Enabled:  config.GetSyncerJob(e.name).IsEnabled(),
+			Interval: interval(e.name, e.syncer).String(),
+			RunOnce:  e.syncer.RunOnce(),
+			Election: config.GetSyncerJob(e.name).ElectionMode(),
+			LastRun:  lastRun,
+		})
+	}
+	return res, nil
+}
+
+// interval 配置中覆盖的执行间隔优先于任务默认值
+func interval(name string, s Syncer) time.Duration {
+	if seconds := config.GetSyncerJob(name).IntervalSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return s.Interval()
+}
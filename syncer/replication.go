@@ -0,0 +1,57 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/svc"
+	"time"
+)
+
+type Replication struct {
+}
+
+func (c *Replication) Interval() time.Duration {
+	return time.Duration(config.GetReplication().IntervalSeconds) * time.Second
+}
+
+func (c *Replication) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Replication) RunOnce() bool {
+	return false
+}
+
+func (c *Replication) EnvIsolation() bool {
+	return false
+}
+
+// ReplicationSyncer 定时处理复制队列，把对象的写入和删除发送到其他集群，并更新积压指标
+type ReplicationSyncer struct {
+	Replication
+	replicationSvc *svc.ReplicationSvc
+}
+
+func NewReplicationSyncer(s *dao.S) *ReplicationSyncer {
+	return &ReplicationSyncer{
+		replicationSvc: svc.NewReplicationSvc(s),
+	}
+}
+
+func (r *ReplicationSyncer) Sync(ctx context.Context) error {
+	processed, err := r.replicationSvc.Run(ctx, config.GetReplication())
+	if err != nil {
+		return err
+	}
+	stats, err := r.replicationSvc.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	if processed > 0 {
+		log.Ctx(ctx).Infof("replication processed %d tasks, pending %d, failed %d, lag %.0fs",
+			processed, stats.Pending, stats.Failed, stats.LagSeconds)
+	}
+	return nil
+}
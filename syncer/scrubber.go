@@ -0,0 +1,227 @@
+package syncer
+
+import (
+	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/errors"
+	"distributed-object-storage/pkg/db/dao"
+	"distributed-object-storage/pkg/db/dbm"
+	"distributed-object-storage/pkg/fencing"
+	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/minIo"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/time/rate"
+	"strings"
+	"time"
+)
+
+type Scrub struct {
+}
+
+func (c *Scrub) Interval() time.Duration {
+	return time.Duration(config.GetScrub().IntervalMinutes) * time.Minute
+}
+
+func (c *Scrub) BeforeStart(ctx context.Context) {
+	return
+}
+
+func (c *Scrub) RunOnce() bool {
+	return false
+}
+
+func (c *Scrub) EnvIsolation() bool {
+	return false
+}
+
+// ScrubberSyncer 逐个桶遍历对象元数据，检查每个节点上的副本是否存在、大小和 ETag 是否一致，
+// 记录缺失或损坏的副本，并在对象锁内从与元数据一致的副本修复。按 ObjectsPerSecond 限速，避免影响正常读写。
+type ScrubberSyncer struct {
+	Scrub
+	metadataDao *dao.MetadataNode
+	scrubSvc    *svc.ScrubSvc
+}
+
+func NewScrubberSyncer(s *dao.S) *ScrubberSyncer {
+	return &ScrubberSyncer{
+		metadataDao: s.MetadataNode,
+		scrubSvc:    svc.NewScrubSvc(s),
+	}
+}
+
+// replicaIssue 单个副本的校验结果
+type replicaIssue struct {
+	node   types.StorageNodeInfo
+	kind   string
+	detail string
+}
+
+func (r *ScrubberSyncer) Sync(ctx context.Context) error {
+	c := config.GetScrub()
+	limiter := rate.NewLimiter(rate.Limit(c.ObjectsPerSecond), 1)
+	bandwidth := bandwidthLimiter(c.BandwidthBytes)
+
+	cursor, err := r.scrubSvc.GetCursor(ctx)
+	if err != nil {
+		return err
+	}
+	buckets, err := r.metadataDao.ListBucketNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, bucketName := range buckets {
+		if cursor != nil && bucketName < cursor.BucketName {
+			continue
+		}
+		if cursor == nil || cursor.BucketName != bucketName {
+			cursor = &types.ScrubCursor{
+				BucketName: bucketName,
+				Report:     types.ScrubReport{BucketName: bucketName, StartedAt: time.Now()},
+			}
+		}
+		if err = r.scrubBucket(ctx, cursor, c.BatchSize, limiter, bandwidth, !c.DisableRepair); err != nil {
+			return err
+		}
+		cursor.Report.LastScrubTime = time.Now()
+		if err = fencing.Check(ctx); err != nil {
+			return err
+		}
+		if err = r.scrubSvc.SaveReport(ctx, cursor.Report); err != nil {
+			return err
+		}
+		log.Ctx(ctx).Infof("scrub bucket %s finished, objects %d, missing %d, corrupted %d, repaired %d", bucketName,
+			cursor.Report.Objects, cursor.Report.Missing, cursor.Report.Corrupted, cursor.Report.Repaired)
+	}
+	if err = fencing.Check(ctx); err != nil {
+		return err
+	}
+	return r.scrubSvc.ClearCursor(ctx)
+}
+
+func (r *ScrubberSyncer) scrubBucket(ctx context.Context, cursor *types.ScrubCursor, batchSize int, limiter, bandwidth *rate.Limiter, repair bool) error {
+	for {
+		batch, err := r.metadataDao.ListBucketObjectMetadataAfter(ctx, cursor.BucketName, cursor.LastID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		// 每批开始时重新获取节点列表，节点上下线后尽快生效
+		nodes, err := minIo.GetStorageNodes()
+		if err != nil {
+			return err
+		}
+		registered := make(map[string]types.StorageNodeInfo, len(nodes))
+		for _, node := range nodes {
+			registered[node.ID] = node
+		}
+		for _, meta := range batch {
+			if err = limiter.Wait(ctx); err != nil {
+				return err
+			}
+			r.scrubObject(ctx, meta, registered, bandwidth, repair, &cursor.Report)
+			cursor.LastID = meta.Id
+		}
+		if err = fencing.Check(ctx); err != nil {
+			return err
+		}
+		if err = r.scrubSvc.SaveCursor(ctx, *cursor); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *ScrubberSyncer) scrubObject(ctx context.Context, meta *dbm.ObjectMetadata, registered map[string]types.StorageNodeInfo,
+	bandwidth *rate.Limiter, repair bool, report *types.ScrubReport) {
+	report.Objects++
+	healthy := make([]types.StorageNodeInfo, 0)
+	issues := make([]replicaIssue, 0)
+	for _, id := range meta.Nodes() {
+		// 已离开集群的节点由 rebalancer 负责迁移，这里不检查
+		node, ok := registered[id]
+		if !ok {
+			continue
+		}
+		kind, detail := checkReplica(ctx, minIo.GetNodeClient(node), meta, bandwidth)
+		if kind == "" {
+			healthy = append(healthy, node)
+			continue
+		}
+		issues = append(issues, replicaIssue{node: node, kind: kind, detail: detail})
+	}
+
+	for _, issue := range issues {
+		record := &dbm.ScrubIssue{
+			BucketName: meta.BucketName,
+			ObjectName: meta.ObjectName,
+			NodeID:     issue.node.ID,
+			Kind:       issue.kind,
+			Detail:     issue.detail,
+			DetectedAt: time.Now(),
+		}
+		if repair && len(healthy) > 0 && fencing.Check(ctx) == nil {
+			err := r.scrubSvc.RepairReplica(ctx, meta, issue.node, healthy, bandwidth)
+			if errors.Is(err, errors.ErrPreconditionFailed) {
+				// 对象在校验之后被重新写入或删除，校验结果已经过期
+				log.Ctx(ctx).Infof("skip repair of %s/%s: %v", meta.BucketName, meta.ObjectName, err)
+				continue
+			}
+			if err != nil {
+				record.Detail = fmt.Sprintf("%s; repair failed: %v", record.Detail, err)
+			} else {
+				record.Repaired = true
+				report.Repaired++
+			}
+		}
+		if issue.kind == dbm.ScrubIssueMissing {
+			report.Missing++
+		} else {
+			report.Corrupted++
+		}
+		log.Ctx(ctx).Warnf("scrub %s/%s on node %s: %s", meta.BucketName, meta.ObjectName, issue.node.ID, record.Detail)
+		if err := r.scrubSvc.RecordIssue(ctx, record); err != nil {
+			log.Ctx(ctx).Errorf("record scrub issue failed: %v", err)
+		}
+	}
+}
+
+// checkReplica 检查单个副本，返回问题类型和描述，副本健康时返回空
+func checkReplica(ctx context.Context, client *minIo.MinioHelper, meta *dbm.ObjectMetadata, bandwidth *rate.Limiter) (string, string) {
+	info, err := client.MinioCore.Client.StatObject(ctx, meta.BucketName, meta.ObjectName, minio.StatObjectOptions{})
+	if err != nil {
+		code := minio.ToErrorResponse(err).Code
+		if code == "NoSuchKey" || code == "NoSuchBucket" {
+			return dbm.ScrubIssueMissing, "replica not found"
+		}
+		return dbm.ScrubIssueMissing, fmt.Sprintf("stat failed: %v", err)
+	}
+	etag := strings.Trim(info.ETag, "\"")
+	if info.Size != meta.Size {
+		return dbm.ScrubIssueCorrupted, fmt.Sprintf("size %d, expected %d", info.Size, meta.Size)
+	}
+	if meta.ETag != "" && etag != meta.ETag {
+		return dbm.ScrubIssueCorrupted, fmt.Sprintf("etag %s, expected %s", etag, meta.ETag)
+	}
+	if !config.GetScrub().VerifyData {
+		return "", ""
+	}
+
+	// HEAD 只能发现元信息不一致，读取全部数据重新计算 ETag 才能发现位翻转
+	object, _, _, err := client.MinioCore.GetObject(ctx, meta.BucketName, meta.ObjectName, minio.GetObjectOptions{})
+	if err != nil {
+		return dbm.ScrubIssueMissing, fmt.Sprintf("read failed: %v", err)
+	}
+	defer object.Close()
+	computed, size, err := minIo.ComputeETag(minIo.NewLimitedReader(ctx, object, bandwidth), minIo.IsMultipartETag(etag))
+	if err != nil {
+		return dbm.ScrubIssueCorrupted, fmt.Sprintf("read failed at %d: %v", size, err)
+	}
+	if computed != etag {
+		return dbm.ScrubIssueCorrupted, fmt.Sprintf("data checksum %s, expected %s", computed, etag)
+	}
+	return "", ""
+}
@@ -40,7 +40,8 @@ func NewNodeHealthCheckSyncer() *NodeHealthCheckSyncer {
 func (g NodeHealthCheckSyncer) Sync(ctx context.Context) error {
 	servicesList, _ := minIo.GetStorageNodeList()
 	for _, service := range servicesList {
-		resp, err := http.Get(service.Value + "/minio/health/live")
+		node := minIo.ParseStorageNode(service.Key, service.Value)
+		resp, err := http.Get(node.Endpoint + "/minio/health/live")
 		if err != nil {
 			log.Errorf("%s is unhealthy: %v\n", service, err)
 			continue
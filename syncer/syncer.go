@@ -2,11 +2,18 @@ package syncer
 
 import (
 	"context"
+	"distributed-object-storage/config"
+	"distributed-object-storage/pkg/fencing"
 	"distributed-object-storage/pkg/log"
+	"distributed-object-storage/pkg/metrics"
+	"distributed-object-storage/pkg/tracing"
 	"distributed-object-storage/redis"
+	"distributed-object-storage/svc"
+	"distributed-object-storage/types"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
 	"math/rand"
-	"reflect"
+	"os"
 	"runtime/debug"
 	"time"
 )
@@ -24,7 +31,13 @@ type Syncer interface {
 	EnvIsolation() bool
 }
 
-func Run(ctx context.Context, s Syncer, envGroup string) {
+// instance 当前网关实例的标识，写入执行记录
+var instance = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+func Run(ctx context.Context, name string, s Syncer, envGroup string) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Errorf("panic recover: %v", err)
@@ -32,56 +45,162 @@ func Run(ctx context.Context, s Syncer, envGroup string) {
 		}
 	}()
 	s.BeforeStart(ctx)
-	ticker := time.NewTicker(s.Interval())
+	ticker := time.NewTicker(interval(name, s))
+	defer ticker.Stop()
 
-	sName := getTypeName(s)
-	if s.EnvIsolation() && envGroup != "" {
-		sName = fmt.Sprintf("%s_%s", envGroup, sName)
+	sName := isolatedName(name, s, envGroup)
+
+	syncerSvc := svc.NewSyncerSvc()
+	run := func() {
+		runWithRedisLock(ctx, syncerSvc, name, sName, s, ticker)
+	}
+	if config.GetSyncerJob(name).ElectionMode() == config.ElectionEtcd {
+		run = func() {
+			runWithEtcdElection(ctx, syncerSvc, name, sName, s, ticker)
+		}
 	}
 
-	lock := getRedisLock(sName)
+	// run once的任务会阻塞, 长时运行
+	if s.RunOnce() {
+		run()
+		return
+	}
 
-	run := func() {
-		err := lock.Lock(ctx)
-		if err != nil {
-			//logs.Debugf("get lock: %s failed", lock.Key())
-			time.Sleep(delayFunc())
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		default:
 		}
+		run()
+		// 让其他等待的有机会获取锁
+		time.Sleep(delayFunc())
+	}
+}
 
-		defer func() {
-			err := lock.UnLock(ctx)
-			if err != nil {
-				log.Warnf("unlock :%s failed", lock.Key())
-			}
-		}()
+// runWithRedisLock 抢占 redis 锁，持有锁期间作为领导者执行任务
+func runWithRedisLock(ctx context.Context, syncerSvc *svc.SyncerSvc, name, sName string, s Syncer, ticker *time.Ticker) {
+	lock := getRedisLock(sName)
+	err := lock.TryLock(ctx, 0)
+	if err != nil {
+		//logs.Debugf("get lock: %s failed", lock.Key())
+		time.Sleep(delayFunc())
+		return
+	}
 
-		start := time.Now()
-		runCtx := ctx
-		log.Infof("%s syncing", sName)
-		err = s.Sync(runCtx)
+	defer func() {
+		err := lock.UnLock(context.Background())
 		if err != nil {
-			log.Errorf("sync failed: %v", err)
+			log.Warnf("unlock :%s failed", lock.Key())
 		}
+	}()
 
-		log.Infof("%s sync end with %d ms", sName, time.Since(start).Milliseconds())
+	token := lock.Token()
+	leader := types.SyncerLeader{
+		Name:     name,
+		Election: config.ElectionRedis,
+		Instance: instance,
+		Token:    token,
+		Since:    time.Now(),
+	}
+	if err = syncerSvc.SaveLeader(ctx, sName, leader); err != nil {
+		log.Warnf("save %s leader failed: %v", name, err)
+	}
+	defer func() {
+		if err := syncerSvc.ClearLeader(context.Background(), sName, token); err != nil {
+			log.Warnf("clear %s leader failed: %v", name, err)
+		}
+	}()
+
+	// 续期失败时立即停止正在执行的任务
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
 		select {
-		case <-ctx.Done():
+		case <-lock.Lost():
+			cancel()
+		case <-leaderCtx.Done():
+		}
+	}()
+
+	fenced := fencing.NewContext(leaderCtx, &fencing.Token{
+		Resource: fencingResource(config.ElectionRedis, sName),
+		Value:    token,
+		Validate: func(ctx context.Context) error {
+			err := lock.Check(ctx)
+			if err == redis.ErrLockNotHeld {
+				return fencing.ErrStaleToken
+			}
+			return err
+		},
+	})
+	lead(fenced, syncerSvc, name, s, ticker)
+}
+
+// lead 作为领导者循环执行任务，直到 ctx 取消
+func lead(ctx context.Context, syncerSvc *svc.SyncerSvc, name string, s Syncer, ticker *time.Ticker) {
+	notifyLeadership(name, true)
+	defer notifyLeadership(name, false)
+	for {
+		syncOnce(ctx, syncerSvc, name, s)
+		// 持有领导权期间等待下一次执行，被手动触发时立即再执行一次
+		if !waitNext(ctx, syncerSvc, name, ticker) {
 			return
-		case <-ticker.C:
 		}
 	}
+}
 
-	// run once的任务会阻塞, 长时运行
-	if s.RunOnce() {
-		run()
-		return
+// syncOnce 执行一次 Sync 并记录执行结果
+func syncOnce(ctx context.Context, syncerSvc *svc.SyncerSvc, name string, s Syncer) {
+	trigger := types.SyncerTriggerSchedule
+	if triggered, _ := syncerSvc.ConsumeTrigger(ctx, name); triggered {
+		trigger = types.SyncerTriggerManual
+	}
+
+	start := time.Now()
+	log.Infof("%s syncing", name)
+	// 每次执行作为一条独立的链路
+	spanCtx, span := tracing.Start(log.NewContext(ctx, log.Fields{log.FieldSyncer: name}), "syncer."+name,
+		attribute.String("trigger", trigger))
+	err := s.Sync(spanCtx)
+	tracing.End(span, err)
+	if err != nil {
+		log.Errorf("sync failed: %v", err)
 	}
 
+	log.Infof("%s sync end with %d ms", name, time.Since(start).Milliseconds())
+	metrics.ObserveSyncerRun(name, time.Since(start), err)
+	run := types.SyncerRun{
+		Name:       name,
+		Instance:   instance,
+		Trigger:    trigger,
+		StartedAt:  start,
+		FinishedAt: time.Now(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if err = syncerSvc.RecordRun(context.Background(), run); err != nil {
+		log.Warnf("record %s run failed: %v", name, err)
+	}
+}
+
+// waitNext 等待到下一次执行时间或被手动触发，ctx 取消时返回 false
+func waitNext(ctx context.Context, syncerSvc *svc.SyncerSvc, name string, ticker *time.Ticker) bool {
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
 	for {
-		run()
-		// 让其他等待的有机会获取锁
-		time.Sleep(delayFunc())
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			return true
+		case <-poll.C:
+			if triggered, _ := syncerSvc.IsTriggered(ctx, name); triggered {
+				return true
+			}
+		}
 	}
 }
 
@@ -90,14 +209,17 @@ func delayFunc() time.Duration {
 }
 
 func getRedisLock(name string) *redis.Lock {
-	key := fmt.Sprintf("test%s", name)
-	return redis.NewRedisLock(key)
+	return redis.NewRedisLock(lockKey(name))
+}
+
+func lockKey(name string) string {
+	return fmt.Sprintf("syncer:lock:%s", name)
 }
 
-func getTypeName(s Syncer) string {
-	r := reflect.TypeOf(s)
-	if r.Kind() == reflect.Ptr {
-		r = r.Elem()
+// isolatedName EnvIsolation 的任务按环境分组区分锁和选主的 key
+func isolatedName(name string, s Syncer, envGroup string) string {
+	if s.EnvIsolation() && envGroup != "" {
+		return fmt.Sprintf("%s_%s", envGroup, name)
 	}
-	return r.Name()
+	return name
 }
@@ -0,0 +1,123 @@
+package types
+
+import "time"
+
+// 审计日志中的操作类型
+const (
+	AuditActionObjectGet         = "object.get"
+	AuditActionObjectStat        = "object.stat"
+	AuditActionObjectList        = "object.list"
+	AuditActionObjectPut         = "object.put"
+	AuditActionObjectDelete      = "object.delete"
+	AuditActionObjectRestore     = "object.restore"
+	AuditActionObjectCopy        = "object.copy"
+	AuditActionObjectRename      = "object.rename"
+	AuditActionObjectDeleteBatch = "object.delete.batch"
+	AuditActionDeleteJobCreate   = "object.delete.prefix"
+	AuditActionDeleteJobStatus   = "object.delete.job"
+	AuditActionReplicaPut        = "object.replica.put"
+	AuditActionReplicaDelete     = "object.replica.delete"
+	AuditActionUploadPause       = "upload.pause"
+	AuditActionUploadResume      = "upload.resume"
+	AuditActionUploadCancel      = "upload.cancel"
+	AuditActionUploadStatus      = "upload.status"
+	AuditActionBucketList        = "bucket.list"
+	AuditActionBucketCreate      = "bucket.create"
+	AuditActionBucketDelete      = "bucket.delete"
+	AuditActionBucketConfigGet   = "bucket.config.get"
+	AuditActionBucketConfigPut   = "bucket.config.put"
+	AuditActionBucketUsage       = "bucket.usage"
+	AuditActionLifecycleGet      = "bucket.lifecycle.get"
+	AuditActionLifecyclePut      = "bucket.lifecycle.put"
+	AuditActionLifecycleDelete   = "bucket.lifecycle.delete"
+	AuditActionReplicationGet    = "bucket.replication.get"
+	AuditActionReplicationPut    = "bucket.replication.put"
+	AuditActionReplicationDelete = "bucket.replication.delete"
+	AuditActionReplicationResync = "bucket.replication.resync"
+	AuditActionUserLogin         = "user.login"
+	AuditActionUserRegister      = "user.register"
+	AuditActionUserList          = "user.list"
+	AuditActionUserInfo          = "user.info"
+	AuditActionUserUsage         = "user.usage"
+	AuditActionAdminRead         = "admin.read"
+	AuditActionRebalancePause    = "admin.rebalance.pause"
+	AuditActionRebalanceResume   = "admin.rebalance.resume"
+	AuditActionSyncerTrigger     = "admin.syncer.trigger"
+	AuditActionConfigReload      = "admin.config.reload"
+	AuditActionUserQuotaPut      = "admin.quota.user.put"
+)
+
+// AuditActions 路由（"METHOD 路径"）对应的操作类型，未列出的路由以 "METHOD 路径" 作为操作类型
+var AuditActions = map[string]string{
+	"GET /storage/object":                            AuditActionObjectGet,
+	"POST /storage/upload":                           AuditActionObjectPut,
+	"DELETE /storage/delete":                         AuditActionObjectDelete,
+	"POST /storage/restore":                          AuditActionObjectRestore,
+	"POST /storage/copy":                             AuditActionObjectCopy,
+	"POST /storage/rename":                           AuditActionObjectRename,
+	"POST /storage/delete/batch":                     AuditActionObjectDeleteBatch,
+	"POST /storage/delete/prefix":                    AuditActionDeleteJobCreate,
+	"GET /storage/delete/jobs":                       AuditActionDeleteJobStatus,
+	"GET /storage/delete/jobs/:id":                   AuditActionDeleteJobStatus,
+	"PUT /storage/replica":                           AuditActionReplicaPut,
+	"DELETE /storage/replica":                        AuditActionReplicaDelete,
+	"POST /storage/pause/:uploadId":                  AuditActionUploadPause,
+	"POST /storage/resume/:uploadId":                 AuditActionUploadResume,
+	"POST /storage/cancel/:uploadId":                 AuditActionUploadCancel,
+	"GET /storage/status/:uploadId":                  AuditActionUploadStatus,
+	"GET /metadata/object":                           AuditActionObjectStat,
+	"GET /metadata/object/list":                      AuditActionObjectList,
+	"GET /metadata/bucket/list":                      AuditActionBucketList,
+	"POST /metadata/bucket/:name":                    AuditActionBucketCreate,
+	"DELETE /metadata/bucket/:name":                  AuditActionBucketDelete,
+	"GET /metadata/bucket/:name/config":              AuditActionBucketConfigGet,
+	"PUT /metadata/bucket/:name/config":              AuditActionBucketConfigPut,
+	"GET /metadata/bucket/:name/usage":               AuditActionBucketUsage,
+	"GET /metadata/bucket/:name/lifecycle":           AuditActionLifecycleGet,
+	"PUT /metadata/bucket/:name/lifecycle":           AuditActionLifecyclePut,
+	"DELETE /metadata/bucket/:name/lifecycle":        AuditActionLifecycleDelete,
+	"GET /metadata/bucket/:name/replication":         AuditActionReplicationGet,
+	"PUT /metadata/bucket/:name/replication":         AuditActionReplicationPut,
+	"DELETE /metadata/bucket/:name/replication":      AuditActionReplicationDelete,
+	"POST /metadata/bucket/:name/replication/resync": AuditActionReplicationResync,
+	"POST /login":                                    AuditActionUserLogin,
+	"POST /register":                                 AuditActionUserRegister,
+	"GET /user/list":                                 AuditActionUserList,
+	"POST /user/info/:id":                            AuditActionUserInfo,
+	"GET /user/usage/:id":                            AuditActionUserUsage,
+	"GET /admin/rebalance":                           AuditActionAdminRead,
+	"POST /admin/rebalance/pause":                    AuditActionRebalancePause,
+	"POST /admin/rebalance/resume":                   AuditActionRebalanceResume,
+	"GET /admin/scrub":                               AuditActionAdminRead,
+	"GET /admin/scrub/issues":                        AuditActionAdminRead,
+	"GET /admin/reconcile":                           AuditActionAdminRead,
+	"GET /admin/syncers":                             AuditActionAdminRead,
+	"GET /admin/syncers/:name/runs":                  AuditActionAdminRead,
+	"POST /admin/syncers/:name/trigger":              AuditActionSyncerTrigger,
+	"GET /admin/syncers/:name/leader":                AuditActionAdminRead,
+	"GET /admin/metrics":                             AuditActionAdminRead,
+	"GET /admin/config":                              AuditActionAdminRead,
+	"POST /admin/config/reload":                      AuditActionConfigReload,
+	"GET /admin/quota":                               AuditActionAdminRead,
+	"GET /admin/quota/user/:id":                      AuditActionAdminRead,
+	"PUT /admin/quota/user/:id":                      AuditActionUserQuotaPut,
+	"GET /admin/lifecycle":                           AuditActionAdminRead,
+	"GET /admin/lifecycle/logs":                      AuditActionAdminRead,
+	"GET /admin/replication":                         AuditActionAdminRead,
+	"GET /admin/replication/tasks":                   AuditActionAdminRead,
+	"GET /admin/audit":                               AuditActionAdminRead,
+}
+
+// ListAuditLogReq 审计日志查询条件，from/to 为 RFC3339 格式的时间，查询 [from, to) 内的记录
+type ListAuditLogReq struct {
+	UserID     uint      `json:"user_id" form:"user_id" `
+	UserName   string    `json:"user_name" form:"user_name" `
+	Action     string    `json:"action" form:"action" `
+	BucketName string    `json:"bucket_name" form:"bucket_name" `
+	ObjectName string    `json:"object_name" form:"object_name" `
+	Result     string    `json:"result" form:"result" `
+	From       time.Time `json:"from" form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `json:"to" form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Current    int       `json:"current" form:"current" `
+	PageSize   int       `json:"pageSize" form:"pageSize" `
+}
@@ -0,0 +1,41 @@
+package types
+
+import "time"
+
+const (
+	DefaultBucketRegion = "us-east-1"
+
+	BucketVersioningOff       = "Off"       // 未开启版本控制
+	BucketVersioningEnabled   = "Enabled"   // 开启版本控制
+	BucketVersioningSuspended = "Suspended" // 暂停版本控制，已有版本保留
+
+	StorageClassStandard = "STANDARD" // 标准存储
+	StorageClassCold     = "COLD"     // 冷存储，对象保存在 OSS 中，只能由生命周期规则转移
+)
+
+// BucketSettings 桶创建后可以修改的配置
+type BucketSettings struct {
+	// Zone 桶的主可用区，主副本优先放在该可用区的节点上，为空时不限制
+	Zone string `json:"zone"`
+	// Versioning 版本控制状态 Off/Enabled/Suspended，默认 Off
+	Versioning string `json:"versioning"`
+	// Replicas 对象副本数，为 0 时使用 placement.replicas
+	Replicas int `json:"replicas"`
+	// StorageClass 新写入对象的默认存储类型，默认 STANDARD
+	StorageClass string `json:"storage_class"`
+	// Quota 桶的硬配额和软配额
+	Quota
+	// Tags 桶的标签
+	Tags map[string]string `json:"tags"`
+}
+
+// BucketConfig 桶注册表中记录的桶配置
+type BucketConfig struct {
+	Name         string    `json:"name"`
+	OwnerID      uint      `json:"owner_id"`
+	Owner        string    `json:"owner"`
+	Region       string    `json:"region"`
+	CreationDate time.Time `json:"creation_date"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	BucketSettings
+}
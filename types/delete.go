@@ -0,0 +1,49 @@
+package types
+
+const (
+	DeleteJobPending   = "PENDING"
+	DeleteJobRunning   = "RUNNING"
+	DeleteJobCompleted = "COMPLETED"
+	DeleteJobFailed    = "FAILED"
+)
+
+// DeleteObjectsReq 批量删除对象的请求，桶名和 quiet 在查询参数中，对象列表在请求体中
+type DeleteObjectsReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	// Quiet 为 true 时结果中只返回删除失败的对象
+	Quiet   bool               `json:"quiet" form:"quiet" `
+	Objects []DeleteObjectsKey `json:"objects" form:"-" `
+}
+
+// DeleteObjectsKey 批量删除中的一个对象，ETag 不为空时只在对象未被修改时删除
+type DeleteObjectsKey struct {
+	ObjectName string `json:"object_name"`
+	ETag       string `json:"etag,omitempty"`
+}
+
+// DeleteObjectsError 批量删除中删除失败的对象
+type DeleteObjectsError struct {
+	ObjectName string `json:"object_name"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+// DeleteObjectsResult 批量删除的结果，按请求中的顺序返回
+type DeleteObjectsResult struct {
+	Deleted []DeleteObjectsKey   `json:"deleted"`
+	Errors  []DeleteObjectsError `json:"errors"`
+}
+
+// DeletePrefixReq 异步删除桶内以 Prefix 开头的所有对象，Prefix 为空时清空整个桶
+type DeletePrefixReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	Prefix     string `json:"prefix" form:"prefix" `
+}
+
+// ListDeleteJobReq 删除任务的查询条件
+type ListDeleteJobReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	State      string `json:"state" form:"state" `
+	Current    int    `json:"current" form:"current" `
+	PageSize   int    `json:"pageSize" form:"pageSize" `
+}
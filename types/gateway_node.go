@@ -47,13 +47,13 @@ const (
 
 // PerformanceMetrics  包含性能监控的指标
 type PerformanceMetrics struct {
-	RequestRate       float64   //每秒请求数
-	ErrorRate         float64   //平均延迟（毫秒）
-	AverageLatency    float64   //错误率
-	ActiveConnections int       // 活跃连接数
-	CPUUsage          float64   //CPU 使⽤率
-	MemoryUsage       float64   //内存使⽤率
-	Timestamp         time.Time // 指标收集时间
+	RequestRate       float64   `json:"request_rate"`       //每秒请求数
+	ErrorRate         float64   `json:"error_rate"`         //错误率（5xx 占比，百分比）
+	AverageLatency    float64   `json:"average_latency"`    //平均延迟（毫秒）
+	ActiveConnections int       `json:"active_connections"` // 活跃连接数（处理中的请求数）
+	CPUUsage          float64   `json:"cpu_usage"`          //CPU 使⽤率（百分比）
+	MemoryUsage       float64   `json:"memory_usage"`       //内存使⽤率（占 GOMEMLIMIT 的百分比）
+	Timestamp         time.Time `json:"timestamp"`          // 指标收集时间
 }
 
 // GatewayConfig 网关的配置信息
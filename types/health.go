@@ -0,0 +1,21 @@
+package types
+
+const (
+	HealthStatusOK    = "ok"
+	HealthStatusError = "error"
+)
+
+// DependencyHealth 单个依赖的检查结果
+type DependencyHealth struct {
+	Name      string `json:"name"`              // 依赖名称，如 mysql、redis、etcd、storage
+	Status    string `json:"status"`            // ok 或 error
+	LatencyMs int64  `json:"latency_ms"`        // 检查耗时（毫秒）
+	Message   string `json:"message,omitempty"` // 附加信息，如健康节点数
+	Error     string `json:"error,omitempty"`   // 检查失败的原因
+}
+
+// Readiness 网关是否可以接收流量，所有依赖正常时 Status 为 ok
+type Readiness struct {
+	Status string             `json:"status"`
+	Checks []DependencyHealth `json:"checks"`
+}
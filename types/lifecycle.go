@@ -0,0 +1,85 @@
+package types
+
+import "time"
+
+const (
+	LifecycleRuleEnabled  = "Enabled"
+	LifecycleRuleDisabled = "Disabled"
+
+	LifecycleActionExpire      = "expire"       // 删除过期的对象
+	LifecycleActionNoncurrent  = "noncurrent"   // 删除过期的历史版本
+	LifecycleActionAbortUpload = "abort_upload" // 取消未完成的分片上传
+	LifecycleActionTransition  = "transition"   // 将对象转移到冷存储
+)
+
+// LifecycleFilter 规则适用的对象，为空时适用于桶内所有对象。
+// 标签只用于过期删除和转移，历史版本和未完成的分片上传只按前缀匹配。
+type LifecycleFilter struct {
+	Prefix string            `json:"prefix"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// LifecycleExpiration 对象的过期条件，Days 与 Date 只能设置一个
+type LifecycleExpiration struct {
+	// Days 对象最后修改后经过的天数
+	Days int `json:"days"`
+	// Date 到达该时间后所有匹配的对象都过期
+	Date *time.Time `json:"date,omitempty"`
+}
+
+// Cutoff 返回 now 时最后修改时间早于该时间的对象已过期，ok 为 false 时没有对象过期
+func (e LifecycleExpiration) Cutoff(now time.Time) (cutoff time.Time, ok bool) {
+	if e.Date != nil {
+		return now, !now.Before(*e.Date)
+	}
+	return now.AddDate(0, 0, -e.Days), e.Days > 0
+}
+
+// LifecycleTransition 对象最后修改后经过 Days 天转移到 StorageClass 对应的存储，目前只支持 COLD
+type LifecycleTransition struct {
+	Days         int    `json:"days"`
+	StorageClass string `json:"storage_class"`
+}
+
+// LifecycleRule 桶的生命周期规则
+type LifecycleRule struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"` // Enabled 或 Disabled，默认 Enabled
+	Filter LifecycleFilter `json:"filter"`
+	// Expiration 当前版本的过期条件
+	Expiration *LifecycleExpiration `json:"expiration,omitempty"`
+	// Transition 当前版本转移到冷存储的条件
+	Transition *LifecycleTransition `json:"transition,omitempty"`
+	// NoncurrentVersionExpirationDays 版本成为历史版本后经过的天数，只对开启过版本控制的桶生效
+	NoncurrentVersionExpirationDays int `json:"noncurrent_version_expiration_days"`
+	// AbortIncompleteMultipartUploadDays 分片上传开始后经过的天数
+	AbortIncompleteMultipartUploadDays int `json:"abort_incomplete_multipart_upload_days"`
+}
+
+// BucketLifecycle 桶的生命周期配置
+type BucketLifecycle struct {
+	Rules []LifecycleRule `json:"rules"`
+}
+
+// LifecycleReport 一次执行生命周期规则的结果
+type LifecycleReport struct {
+	Buckets           int       `json:"buckets"`
+	Expired           int64     `json:"expired"`
+	ExpiredBytes      int64     `json:"expired_bytes"`
+	Transitioned      int64     `json:"transitioned"`
+	TransitionedBytes int64     `json:"transitioned_bytes"`
+	NoncurrentRemoved int64     `json:"noncurrent_removed"`
+	UploadsAborted    int64     `json:"uploads_aborted"`
+	Failed            int64     `json:"failed"`
+	LastError         string    `json:"last_error,omitempty"`
+	StartedAt         time.Time `json:"started_at"`
+	FinishedAt        time.Time `json:"finished_at"`
+}
+
+// ListLifecycleLogReq 生命周期删除记录的查询条件
+type ListLifecycleLogReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	Action     string `json:"action" form:"action" `
+	Current    int    `json:"current" form:"current" `
+	PageSize   int    `json:"pageSize" form:"pageSize" `
+}
@@ -16,6 +16,10 @@ type ObjectMetadata struct {
 	StorageNodes []string  `json:"storage_nodes"` // 存储该对象的节点列表
 	VersionID    string    `json:"version_id"`    // 对象的版本 ID （如果启⽤了版本控制）
 	IsLatest     bool      `json:"is_latest"`     // 是否是最新版本
+	StorageClass string    `json:"storage_class"` // 对象的存储类型
+	TierKey      string    `json:"tier_key"`      // 对象在冷存储中的 key，为空表示对象在存储节点上
+	// ReplicationStatus 跨集群复制状态 PENDING/COMPLETED/FAILED/REPLICA，桶没有复制规则时为空
+	ReplicationStatus string `json:"replication_status"`
 }
 
 // BucketInfo 定义了桶的基本信息
@@ -61,8 +65,12 @@ type ListBucketReq struct {
 	MaxKeys int    `json:"max_keys" form:"max_keys" `
 }
 
+// CreateBucketReq 创建桶时的配置，未填写的使用默认值，桶名取自路径
 type CreateBucketReq struct {
 	BucketName string `json:"bucket_name" form:"bucket_name" `
+	// Region 桶所在的区域，创建后不能修改
+	Region string `json:"region" form:"region" `
+	BucketSettings
 }
 
 type GetObjectReq struct {
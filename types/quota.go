@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+const (
+	QuotaScopeBucket = "bucket"
+	QuotaScopeUser   = "user"
+)
+
+// Quota 对象总大小和对象数的配额，为 0 时不限制。
+// 超过软配额只记录告警并在用量报告中标出，超过硬配额的上传会被拒绝。
+type Quota struct {
+	QuotaBytes       int64 `json:"quota_bytes"`        // 硬配额，对象总大小上限（字节）
+	QuotaObjects     int64 `json:"quota_objects"`      // 硬配额，对象数上限
+	SoftQuotaBytes   int64 `json:"soft_quota_bytes"`   // 软配额，对象总大小告警线（字节）
+	SoftQuotaObjects int64 `json:"soft_quota_objects"` // 软配额，对象数告警线
+}
+
+// Usage 已使用的存储量
+type Usage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+// UsageReport 桶或用户的用量与配额
+type UsageReport struct {
+	Scope        string `json:"scope"` // bucket 或 user
+	Name         string `json:"name"`  // 桶名或用户 ID
+	Usage        Usage  `json:"usage"`
+	Quota        Quota  `json:"quota"`
+	SoftExceeded bool   `json:"soft_exceeded"`
+	HardExceeded bool   `json:"hard_exceeded"`
+}
+
+// UsageReconcileReport 最近一次按元数据重新统计用量的结果
+type UsageReconcileReport struct {
+	Buckets    int       `json:"buckets"`
+	Users      int       `json:"users"`
+	Bytes      int64     `json:"bytes"`
+	Objects    int64     `json:"objects"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// UserQuota 用户的配额，用户的用量为其拥有的所有桶的用量之和
+type UserQuota struct {
+	UserID    uint      `json:"user_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Quota
+}
+
+// Exceeded 返回用量超过的软配额和硬配额
+func (q Quota) Exceeded(u Usage) (soft, hard bool) {
+	over := func(used, limit int64) bool { return limit > 0 && used > limit }
+	soft = over(u.Bytes, q.SoftQuotaBytes) || over(u.Objects, q.SoftQuotaObjects)
+	hard = over(u.Bytes, q.QuotaBytes) || over(u.Objects, q.QuotaObjects)
+	return soft, hard
+}
+
+// UsageOverview 所有桶和用户的用量报告
+type UsageOverview struct {
+	Buckets       []UsageReport        `json:"buckets"`
+	Users         []UsageReport        `json:"users"`
+	LastReconcile UsageReconcileReport `json:"last_reconcile"`
+}
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+type RebalanceState string
+
+const (
+	RebalanceIdle    RebalanceState = "idle"
+	RebalanceRunning RebalanceState = "running"
+	RebalancePaused  RebalanceState = "paused"
+)
+
+// RebalanceProgress 对象迁移任务的进度，保存在 redis 中供所有网关查询
+type RebalanceProgress struct {
+	State       RebalanceState `json:"state"`
+	Paused      bool           `json:"paused"`       // 是否被管理员暂停
+	Topology    string         `json:"topology"`     // 本轮迁移针对的节点拓扑
+	Done        string         `json:"done"`         // 已完成迁移的节点拓扑
+	Cursor      uint           `json:"cursor"`       // 已处理到的元数据 id
+	Scanned     int64          `json:"scanned"`      // 已检查的对象数
+	Moved       int64          `json:"moved"`        // 已迁移的副本数
+	Failed      int64          `json:"failed"`       // 迁移失败的副本数
+	MovedBytes  int64          `json:"moved_bytes"`  // 已迁移的字节数
+	StartedAt   time.Time      `json:"started_at"`   // 本轮开始时间
+	FinishedAt  time.Time      `json:"finished_at"`  // 本轮结束时间
+	LastError   string         `json:"last_error"`   // 最近一次错误
+	UpdatedTime time.Time      `json:"updated_time"` // 进度更新时间
+}
@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+const (
+	ReconcileOrphan   = "orphan"   // 节点上有数据但元数据中没有记录
+	ReconcileDangling = "dangling" // 元数据记录的节点上没有数据
+	ReconcileMismatch = "mismatch" // 节点上的数据大小或 ETag 与元数据不一致
+)
+
+// ReconcileItem 一条元数据与存储节点不一致的记录
+type ReconcileItem struct {
+	Kind       string `json:"kind"`
+	BucketName string `json:"bucket_name"`
+	ObjectName string `json:"object_name"`
+	NodeID     string `json:"node_id"`
+	Detail     string `json:"detail"`
+	Fixed      bool   `json:"fixed"` // 是否已修复
+}
+
+// ReconcileReport 一次元数据与存储节点对账的结果
+type ReconcileReport struct {
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Fix        bool            `json:"fix"`        // 是否修复不一致
+	Buckets    int             `json:"buckets"`    // 检查的桶数
+	Objects    int64           `json:"objects"`    // 检查的对象数
+	Orphans    int64           `json:"orphans"`    // 没有元数据的副本数
+	Dangling   int64           `json:"dangling"`   // 元数据指向但不存在的副本数
+	Mismatched int64           `json:"mismatched"` // 大小或 ETag 不一致的副本数
+	Fixed      int64           `json:"fixed"`      // 已修复的记录数
+	Items      []ReconcileItem `json:"items"`      // 不一致的明细，最多保留 MaxReconcileItems 条
+}
+
+const MaxReconcileItems = 1000
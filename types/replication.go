@@ -0,0 +1,70 @@
+package types
+
+import "time"
+
+const (
+	ReplicationRuleEnabled  = "Enabled"
+	ReplicationRuleDisabled = "Disabled"
+
+	ReplicationTargetS3      = "s3"      // S3 兼容的存储
+	ReplicationTargetGateway = "gateway" // 另一个集群的网关
+
+	ReplicationOpPut    = "put"
+	ReplicationOpDelete = "delete"
+
+	ReplicationStatusPending   = "PENDING"   // 等待复制
+	ReplicationStatusCompleted = "COMPLETED" // 已复制到目标
+	ReplicationStatusFailed    = "FAILED"    // 重试次数用完仍未复制成功
+	ReplicationStatusReplica   = "REPLICA"   // 由其他集群复制来的对象，不再向外复制
+)
+
+// ReplicationTarget 复制的目标
+type ReplicationTarget struct {
+	// Type s3 或 gateway，默认 s3
+	Type string `json:"type"`
+	// Endpoint s3 为 host:port，gateway 为远端网关地址如 http://dr-site:3002；以 https:// 开头时使用 TLS
+	Endpoint string `json:"endpoint"`
+	// Bucket 目标桶，默认与源桶同名
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+// ReplicationRule 桶的复制规则，对象按顺序匹配第一条启用的规则
+type ReplicationRule struct {
+	ID     string            `json:"id"`
+	Status string            `json:"status"` // Enabled 或 Disabled，默认 Enabled
+	Prefix string            `json:"prefix"`
+	Target ReplicationTarget `json:"target"`
+	// DeleteReplication 为 true 时删除对象也同步删除目标上的对象
+	DeleteReplication bool `json:"delete_replication"`
+}
+
+// BucketReplication 桶的复制配置
+type BucketReplication struct {
+	Rules []ReplicationRule `json:"rules"`
+}
+
+// ReplicationStats 复制队列的积压情况
+type ReplicationStats struct {
+	Pending int64 `json:"pending"`
+	Failed  int64 `json:"failed"`
+	// OldestPending 最早进入队列且尚未完成的任务的入队时间
+	OldestPending *time.Time `json:"oldest_pending,omitempty"`
+	// LagSeconds 最早的未完成任务已等待的秒数
+	LagSeconds float64 `json:"lag_seconds"`
+}
+
+// ReplicationResyncReport 重新同步桶内已有对象的结果
+type ReplicationResyncReport struct {
+	Objects int64 `json:"objects"`
+	Queued  int64 `json:"queued"`
+}
+
+// ListReplicationTaskReq 复制任务的查询条件
+type ListReplicationTaskReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	State      string `json:"state" form:"state" `
+	Current    int    `json:"current" form:"current" `
+	PageSize   int    `json:"pageSize" form:"pageSize" `
+}
@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// ScrubReport 某个桶最近一轮副本校验的结果
+type ScrubReport struct {
+	BucketName    string    `json:"bucket_name"`
+	StartedAt     time.Time `json:"started_at"`      // 本轮开始校验该桶的时间
+	LastScrubTime time.Time `json:"last_scrub_time"` // 最近一次完成校验的时间
+	Objects       int64     `json:"objects"`         // 校验的对象数
+	Missing       int64     `json:"missing"`         // 缺失的副本数
+	Corrupted     int64     `json:"corrupted"`       // 损坏的副本数
+	Repaired      int64     `json:"repaired"`        // 已修复的副本数
+}
+
+// ScrubCursor 正在进行的校验进度，网关切换后从这里继续
+type ScrubCursor struct {
+	BucketName string      `json:"bucket_name"`
+	LastID     uint        `json:"last_id"`
+	Report     ScrubReport `json:"report"`
+}
+
+type ListScrubIssueReq struct {
+	BucketName string `json:"bucket_name" form:"bucket_name" `
+	Current    int    `json:"current" form:"current" `
+	PageSize   int    `json:"pageSize" form:"pageSize" `
+}
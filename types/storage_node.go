@@ -2,6 +2,7 @@ package types
 
 import (
 	"github.com/minio/minio-go/v7"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,10 +24,28 @@ type PartInfo struct {
 
 // DiskUsage 定义了存储节点的磁盘使⽤情况。
 type DiskUsage struct {
-	TotalSpace      int64   // 总存储空间（字节）
-	UsedSpace       int64   // 已使⽤的存储空间（字节）
-	AvailableSpace  int64   //可⽤存储空间（字节）
-	UsagePercentage float64 // 使⽤率（百分⽐）
+	TotalSpace      int64   `json:"total_space"`      // 总存储空间（字节）
+	UsedSpace       int64   `json:"used_space"`       // 已使⽤的存储空间（字节）
+	AvailableSpace  int64   `json:"available_space"`  //可⽤存储空间（字节）
+	UsagePercentage float64 `json:"usage_percentage"` // 使⽤率（百分⽐）
+}
+
+// StorageNodeInfo 存储节点在 etcd（minio/<id>）中的注册信息
+type StorageNodeInfo struct {
+	ID        string    `json:"id"`         // 节点唯⼀标识符，即 etcd key 的后缀
+	Endpoint  string    `json:"endpoint"`   // 节点地址，如 http://127.0.0.1:9000
+	Zone      string    `json:"zone"`       // 可用区标签
+	Rack      string    `json:"rack"`       // 机架标签
+	Weight    float64   `json:"weight"`     // 运维设置的权重，<=0 时按 1 处理
+	DiskUsage DiskUsage `json:"disk_usage"` // 节点上报的磁盘使用情况
+	UpdatedAt time.Time `json:"updated_at"` // 最后一次上报时间
+}
+
+// Host 返回去掉协议前缀的 host:port，供 minio 客户端使用
+func (n StorageNodeInfo) Host() string {
+	host := strings.TrimPrefix(n.Endpoint, "http://")
+	host = strings.TrimPrefix(host, "https://")
+	return strings.TrimSuffix(host, "/")
 }
 
 type UploadReq struct {
@@ -44,3 +63,45 @@ type UploadStatus struct {
 	CurrentPart    int
 	Error          error
 }
+
+// WriteCondition 写入对象的前置条件，对应 HTTP 的 If-Match / If-None-Match
+type WriteCondition struct {
+	// IfMatch 对象当前的 ETag 与之相同时才写入，"*" 表示对象存在即可
+	IfMatch string
+	// IfNoneMatch 为 "*" 时只在对象不存在时写入
+	IfNoneMatch string
+}
+
+const (
+	MetadataDirectiveCopy    = "COPY"    // 沿用源对象的元数据
+	MetadataDirectiveReplace = "REPLACE" // 使用请求中的元数据
+)
+
+// CopyObjectReq 服务端复制对象的请求，源和目标可以在不同的桶中
+type CopyObjectReq struct {
+	SourceBucket string `json:"source_bucket" form:"source_bucket"`
+	SourceObject string `json:"source_object" form:"source_object"`
+	BucketName   string `json:"bucket_name" form:"bucket_name"`
+	ObjectName   string `json:"object_name" form:"object_name"`
+	// MetadataDirective 默认 COPY；REPLACE 时目标对象使用请求中的 ContentType 和 StorageClass
+	MetadataDirective string `json:"metadata_directive" form:"metadata_directive"`
+	ContentType       string `json:"content_type" form:"content_type"`
+	StorageClass      string `json:"storage_class" form:"storage_class"`
+}
+
+// RenameObjectReq 重命名对象的请求，NewBucketName 为空时在原来的桶内重命名
+type RenameObjectReq struct {
+	BucketName    string `json:"bucket_name" form:"bucket_name"`
+	ObjectName    string `json:"object_name" form:"object_name"`
+	NewBucketName string `json:"new_bucket_name" form:"new_bucket_name"`
+	NewObjectName string `json:"new_object_name" form:"new_object_name"`
+}
+
+// CopyObjectResult 复制或重命名后目标对象的信息
+type CopyObjectResult struct {
+	BucketName   string    `json:"bucket_name"`
+	ObjectName   string    `json:"object_name"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+const (
+	SyncerTriggerSchedule = "schedule" // 按执行间隔调度
+	SyncerTriggerManual   = "manual"   // 管理员手动触发
+)
+
+// SyncerRun 后台任务的一次执行记录
+type SyncerRun struct {
+	Name       string    `json:"name"`
+	Instance   string    `json:"instance"` // 执行该任务的网关实例
+	Trigger    string    `json:"trigger"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SyncerInfo 已注册的后台任务
+type SyncerInfo struct {
+	Name     string     `json:"name"`
+	Enabled  bool       `json:"enabled"`
+	Interval string     `json:"interval"`
+	RunOnce  bool       `json:"run_once"`
+	Election string     `json:"election"`
+	LastRun  *SyncerRun `json:"last_run,omitempty"`
+}
+
+// SyncerLeader 后台任务当前的领导者
+type SyncerLeader struct {
+	Name     string    `json:"name"`
+	Election string    `json:"election"`
+	Instance string    `json:"instance"`
+	Token    int64     `json:"token"` // fencing token，每次选主单调递增
+	Since    time.Time `json:"since"`
+}